@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"linyapsmanager/internal/cmdwhitelist"
+	"linyapsmanager/internal/i18n"
+)
+
+// ctlCommand describes a linyapsctl subcommand. Runtime help, flag parsing and
+// the generated man page are all derived from these specs.
+type ctlCommand struct {
+	Name        string
+	Args        string // positional argument synopsis, e.g. "<opID>"
+	Summary     string
+	Description string
+	Flags       []ctlFlag
+	Run         func(flags map[string]string, args []string) int
+}
+
+// ctlFlag describes a long option. Options with an empty Arg are booleans.
+// Repeatable options may be given more than once; their values are joined
+// with newlines.
+type ctlFlag struct {
+	Name        string
+	Arg         string
+	Description string
+	Repeatable  bool
+}
+
+// ctlCommands is populated in init to allow commands to reference the table.
+var ctlCommands []*ctlCommand
+
+func init() {
+	// Prepended rather than assigned, so commands registered by the init of
+	// a file compiled earlier are kept; help still comes first.
+	ctlCommands = append([]*ctlCommand{
+		{
+			Name:    "help",
+			Summary: "Show help for linyapsctl",
+			Flags: []ctlFlag{
+				{Name: "all", Description: "Also list the options of every command"},
+			},
+			Run: func(flags map[string]string, args []string) int {
+				printHelp(flags["all"] != "")
+				return 0
+			},
+		},
+		{
+			Name:        "gen-man",
+			Summary:     "Generate the linyapsctl(1) man page",
+			Description: "Writes the troff source of the man page, generated from the same command specs as this help text.",
+			Flags: []ctlFlag{
+				{Name: "output", Arg: "FILE", Description: "Write to FILE instead of standard output"},
+			},
+			Run: runGenMan,
+		},
+	}, ctlCommands...)
+}
+
+// runCtl handles invocations of the linyapsctl binary under its own name.
+func runCtl(args []string) int {
+	if len(args) == 0 {
+		printUsage()
+		return 1
+	}
+	switch args[0] {
+	case "-h", "--help":
+		printHelp(false)
+		return 0
+	case "--help-all":
+		printHelp(true)
+		return 0
+	}
+
+	cmd := findCtlCommand(args[0])
+	if cmd == nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: unknown command %q\n", args[0]))
+		printUsage()
+		return 1
+	}
+	flags, positional, err := parseCtlFlags(cmd, args[1:])
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 2
+	}
+	if _, ok := flags["help"]; ok {
+		printCommandHelp(cmd)
+		return 0
+	}
+	return cmd.Run(flags, positional)
+}
+
+func findCtlCommand(name string) *ctlCommand {
+	for _, c := range ctlCommands {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// parseCtlFlags splits args into the options declared by cmd and positional
+// arguments. Both --name=value and --name value forms are accepted; "--" ends
+// option parsing. Boolean options are stored with the value "true", and a
+// later value of any other option replaces the earlier one unless it is
+// Repeatable.
+func parseCtlFlags(cmd *ctlCommand, args []string) (map[string]string, []string, error) {
+	flags := make(map[string]string)
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if name == "help" {
+			flags["help"] = "true"
+			continue
+		}
+		spec := findCtlFlag(cmd, name)
+		if spec == nil {
+			return nil, nil, fmt.Errorf(i18n.T("unknown option --%s for %s"), name, cmd.Name)
+		}
+		switch {
+		case spec.Arg == "":
+			if hasValue {
+				return nil, nil, fmt.Errorf(i18n.T("option --%s does not take a value"), name)
+			}
+			value = "true"
+		case !hasValue:
+			if i+1 >= len(args) {
+				return nil, nil, fmt.Errorf(i18n.T("option --%s requires %s"), name, spec.Arg)
+			}
+			i++
+			value = args[i]
+		}
+		if prev, ok := flags[name]; ok && spec.Repeatable {
+			value = prev + "\n" + value
+		}
+		flags[name] = value
+	}
+	return flags, positional, nil
+}
+
+func findCtlFlag(cmd *ctlCommand, name string) *ctlFlag {
+	for i := range cmd.Flags {
+		if cmd.Flags[i].Name == name {
+			return &cmd.Flags[i]
+		}
+	}
+	return nil
+}
+
+// synopsis returns the one-line usage of cmd.
+func (c *ctlCommand) synopsis() string {
+	parts := []string{"linyapsctl", c.Name}
+	if len(c.Flags) > 0 {
+		parts = append(parts, "[options]")
+	}
+	if c.Args != "" {
+		parts = append(parts, c.Args)
+	}
+	return strings.Join(parts, " ")
+}
+
+func (f ctlFlag) String() string {
+	if f.Arg == "" {
+		return "--" + f.Name
+	}
+	return "--" + f.Name + "=" + f.Arg
+}
+
+func printUsage() {
+	fmt.Println(i18n.T("LinyapsManager Client"))
+	fmt.Println()
+	fmt.Println(i18n.T("This program should be invoked via symlinks named after the command to execute."))
+	fmt.Println()
+	fmt.Println(i18n.T("Example:"))
+	fmt.Println("  ln -s linyapsctl ll-cli")
+	fmt.Println("  ./ll-cli install com.example.app")
+	fmt.Println()
+	fmt.Println(i18n.T("Allowed commands:"))
+	for _, cmd := range allowedCommands() {
+		fmt.Printf("  - %s\n", cmd)
+	}
+	fmt.Println()
+	fmt.Println(i18n.T("Commands:"))
+	for _, c := range ctlCommands {
+		fmt.Printf("  %-12s %s\n", c.Name, i18n.T(c.Summary))
+	}
+}
+
+// printHelp prints the usage; with all set it also documents every option.
+func printHelp(all bool) {
+	printUsage()
+	if !all {
+		fmt.Println()
+		fmt.Println(i18n.T("Run 'linyapsctl --help-all' to list all options."))
+		return
+	}
+	for _, c := range ctlCommands {
+		fmt.Println()
+		printCommandHelp(c)
+	}
+}
+
+func printCommandHelp(c *ctlCommand) {
+	fmt.Printf("%s\n", c.synopsis())
+	fmt.Printf("  %s\n", i18n.T(c.Summary))
+	if c.Description != "" {
+		fmt.Printf("  %s\n", i18n.T(c.Description))
+	}
+	for _, f := range c.Flags {
+		fmt.Printf("    %-20s %s\n", f, i18n.T(f.Description))
+	}
+}
+
+// allowedCommands returns the whitelisted command names in a stable order.
+func allowedCommands() []string {
+	cmds := cmdwhitelist.ListCommands()
+	sort.Strings(cmds)
+	return cmds
+}
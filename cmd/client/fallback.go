@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/cmdwhitelist"
+	"linyapsmanager/internal/i18n"
+)
+
+// envLocalFallback lets the client run a command itself when the service
+// cannot be reached, as in rescue shells or early boot: "readonly" (or 1)
+// for commands that only read state, "all" for every whitelisted command.
+// It is off by default.
+const envLocalFallback = "LINYAPS_LOCAL_FALLBACK"
+
+// readOnlySubcommands are the ll-cli subcommands that change nothing.
+// "repo" only counts with "show".
+var readOnlySubcommands = map[string]bool{
+	"list": true, "search": true, "info": true, "ps": true, "content": true,
+	"version": true, "--version": true, "help": true, "--help": true,
+}
+
+// serviceUnavailable reports whether err means nobody answers for the
+// service, as opposed to the service refusing or failing the command.
+func serviceUnavailable(err error) bool {
+	var dbusErr dbus.Error
+	if !errors.As(err, &dbusErr) {
+		return false
+	}
+	switch dbusErr.Name {
+	case "org.freedesktop.DBus.Error.ServiceUnknown",
+		"org.freedesktop.DBus.Error.NameHasNoOwner",
+		"org.freedesktop.DBus.Error.NoReply",
+		"org.freedesktop.DBus.Error.Spawn.Failed",
+		"org.freedesktop.DBus.Error.Spawn.ChildExited",
+		"org.freedesktop.DBus.Error.TimedOut":
+		return true
+	}
+	return false
+}
+
+// fallbackAllowed reports whether LINYAPS_LOCAL_FALLBACK permits running
+// command with args locally.
+func fallbackAllowed(command string, args []string) bool {
+	switch strings.ToLower(os.Getenv(envLocalFallback)) {
+	case "all":
+		return true
+	case "readonly", "1", "true":
+		return command == "ll-cli" && readOnlyLLCli(args)
+	}
+	return false
+}
+
+func readOnlyLLCli(args []string) bool {
+	var words []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") || readOnlySubcommands[arg] {
+			words = append(words, arg)
+		}
+	}
+	if len(words) == 0 {
+		return false
+	}
+	if words[0] == "repo" {
+		return len(words) == 1 || words[1] == "show"
+	}
+	return readOnlySubcommands[words[0]]
+}
+
+// runLocalFallback runs command directly when the service could not be
+// reached because of reason and LINYAPS_LOCAL_FALLBACK permits it. It
+// returns the exit code and whether the command was run. The command is
+// still checked against the whitelist, and a notice on stderr makes clear
+// that the service was bypassed.
+func runLocalFallback(command string, args []string, reason error) (int, bool) {
+	if !fallbackAllowed(command, args) {
+		return 0, false
+	}
+	program, validatedArgs, err := cmdwhitelist.ValidateCommand(command, args)
+	if err != nil {
+		return 0, false
+	}
+	path, err := realProgram(program)
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: local fallback: %v\n", err))
+		return 0, false
+	}
+	fmt.Fprint(os.Stderr, i18n.T("linyapsctl: service unavailable (%v), running %s directly (local fallback)\n", reason, command))
+
+	cmd := exec.Command(path, validatedArgs...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode(), true
+	case err != nil:
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 1, true
+	}
+	return 0, true
+}
+
+// realProgram finds program in PATH, skipping entries that are this client
+// under the program's name, as the command symlinks are.
+func realProgram(program string) (string, error) {
+	if filepath.IsAbs(program) {
+		return program, nil
+	}
+	self, err := os.Executable()
+	if err == nil {
+		self, err = filepath.EvalSymlinks(self)
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		candidate := filepath.Join(dir, program)
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() || info.Mode()&0o111 == 0 {
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(candidate); err == nil && resolved == self {
+			continue
+		}
+		return candidate, nil
+	}
+	return "", fmt.Errorf(i18n.T("%s not found in PATH"), program)
+}
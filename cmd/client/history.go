@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/dbusutil"
+	"linyapsmanager/internal/history"
+	"linyapsmanager/internal/i18n"
+)
+
+func init() {
+	ctlCommands = append(ctlCommands, &ctlCommand{
+		Name:    "history",
+		Args:    "export",
+		Summary: "Export the operation history",
+		Description: "export writes every recorded operation matching the filters to stdout, " +
+			"newest first. Dates are YYYY-MM-DD or RFC 3339 times.",
+		Flags: []ctlFlag{
+			{Name: "format", Arg: "csv|json", Description: "Output format (default csv)"},
+			{Name: "since", Arg: "DATE", Description: "Only operations that ended at or after DATE"},
+			{Name: "until", Arg: "DATE", Description: "Only operations that ended before DATE"},
+			{Name: "app", Arg: "APPID", Description: "Only operations on APPID"},
+		},
+		Run: runHistory,
+	})
+}
+
+func runHistory(flags map[string]string, args []string) int {
+	if len(args) != 1 || args[0] != "export" {
+		printCommandHelp(findCtlCommand("history"))
+		return 2
+	}
+	format := flags["format"]
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "json" {
+		fmt.Fprint(os.Stderr, i18n.T("Error: invalid --format %q\n", format))
+		return 2
+	}
+
+	filter := map[string]dbus.Variant{"limit": dbus.MakeVariant(int32(history.MaxLimit))}
+	for _, key := range []string{"since", "until"} {
+		if v := flags[key]; v != "" {
+			t, err := parseDate(v)
+			if err != nil {
+				fmt.Fprint(os.Stderr, i18n.T("Error: invalid --%s %q\n", key, v))
+				return 2
+			}
+			filter[key] = dbus.MakeVariant(t.Unix())
+		}
+	}
+	if v := flags["app"]; v != "" {
+		filter["app_id"] = dbus.MakeVariant(v)
+	}
+
+	entries, err := fetchHistory(filter)
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 1
+	}
+	if format == "json" {
+		err = writeHistoryJSON(os.Stdout, entries)
+	} else {
+		err = writeHistoryCSV(os.Stdout, entries)
+	}
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 1
+	}
+	return 0
+}
+
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.ParseInLocation("2006-01-02", s, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
+
+// fetchHistory pages through GetHistory until the cursor runs out.
+func fetchHistory(filter map[string]dbus.Variant) ([]history.Entry, error) {
+	conn, err := dbusutil.Connect("")
+	if err != nil {
+		return nil, fmt.Errorf(i18n.T("failed to connect to D-Bus: %w"), err)
+	}
+	defer conn.Close()
+	obj := conn.Object(dbusconsts.BusName, dbus.ObjectPath(dbusconsts.ObjectPath))
+
+	var entries []history.Entry
+	for {
+		var page []map[string]dbus.Variant
+		var next string
+		if err := obj.Call(dbusconsts.Interface+".GetHistory", 0, filter).Store(&page, &next); err != nil {
+			return nil, fmt.Errorf(i18n.T("D-Bus call failed: %w"), err)
+		}
+		for _, m := range page {
+			entries = append(entries, history.EntryFromVariants(m))
+		}
+		if next == "" {
+			return entries, nil
+		}
+		filter["cursor"] = dbus.MakeVariant(next)
+	}
+}
+
+func writeHistoryJSON(w io.Writer, entries []history.Entry) error {
+	if entries == nil {
+		entries = []history.Entry{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
+
+func writeHistoryCSV(w io.Writer, entries []history.Entry) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"id", "start_time", "end_time", "duration_ms", "command", "operation", "ref", "app_id", "state", "exit_code", "error", "caller", "version"})
+	for _, e := range entries {
+		cw.Write([]string{
+			e.ID,
+			e.StartTime.Format(time.RFC3339),
+			e.EndTime.Format(time.RFC3339),
+			strconv.FormatInt(e.DurationMs, 10),
+			e.Command,
+			e.Operation,
+			e.Ref,
+			e.AppID,
+			e.State,
+			strconv.Itoa(e.ExitCode),
+			e.Error,
+			e.Caller,
+			e.Version,
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusutil"
+	"linyapsmanager/internal/i18n"
+)
+
+func init() {
+	ctlCommands = append(ctlCommands, &ctlCommand{
+		Name:    "install",
+		Args:    "<appid>...",
+		Summary: "Install apps",
+		Description: "install installs the apps through the service and shows the install output. " +
+			"Several apps are installed one after the other as one operation, which fails if any install failed. " +
+			"With --launch the app is started once it is installed, or right away if it already was.",
+		Flags: []ctlFlag{
+			{Name: "version", Arg: "VERSION", Description: "Install VERSION instead of the newest version"},
+			{Name: "module", Arg: "MODULE", Description: "Install MODULE, such as develop, instead of binary"},
+			{Name: "channel", Arg: "CHANNEL", Description: "Install from CHANNEL, such as stable or testing"},
+			{Name: "repo", Arg: "NAME", Description: "Install from the repository NAME instead of the default one"},
+			{Name: "launch", Description: "Start the app once it is installed"},
+			{Name: "force", Description: "Reinstall apps that are already installed; only with several apps"},
+		},
+		Run: runInstall,
+	})
+}
+
+func runInstall(flags map[string]string, args []string) int {
+	if len(args) == 0 {
+		printCommandHelp(findCtlCommand("install"))
+		return 2
+	}
+	if len(args) > 1 {
+		for _, name := range []string{"version", "module", "channel", "repo", "launch"} {
+			if flags[name] != "" {
+				fmt.Fprint(os.Stderr, i18n.T("Error: --%s takes a single app\n", name))
+				return 2
+			}
+		}
+		return installBatch(args, flags["force"] != "")
+	}
+	if flags["force"] != "" {
+		fmt.Fprint(os.Stderr, i18n.T("Error: --%s takes several apps\n", "force"))
+		return 2
+	}
+	options := map[string]dbus.Variant{}
+	if flags["module"] != "" {
+		options["module"] = dbus.MakeVariant(flags["module"])
+	}
+	for _, key := range []string{"channel", "repo"} {
+		if flags[key] != "" {
+			options[key] = dbus.MakeVariant(flags[key])
+		}
+	}
+	if flags["launch"] != "" {
+		options["autoLaunch"] = dbus.MakeVariant(true)
+	}
+
+	conn, err := dbusutil.Connect("")
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: failed to connect to D-Bus: %v\n", err))
+		return 1
+	}
+	defer conn.Close()
+
+	exitCode, err := followRemote(conn, "InstallStream", func(data string, isStderr bool) {
+		if isStderr {
+			fmt.Fprint(os.Stderr, data)
+		} else {
+			fmt.Print(data)
+		}
+	}, args[0], flags["version"], options)
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 1
+	}
+	return exitCode
+}
+
+func installBatch(refs []string, force bool) int {
+	conn, err := dbusutil.Connect("")
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: failed to connect to D-Bus: %v\n", err))
+		return 1
+	}
+	defer conn.Close()
+
+	exitCode, err := followRemote(conn, "InstallBatchStream", func(data string, isStderr bool) {
+		if isStderr {
+			fmt.Fprint(os.Stderr, data)
+		} else {
+			fmt.Print(data)
+		}
+	}, refs, force)
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 1
+	}
+	return exitCode
+}
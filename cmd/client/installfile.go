@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"linyapsmanager/internal/dbusutil"
+	"linyapsmanager/internal/i18n"
+)
+
+func init() {
+	ctlCommands = append(ctlCommands, &ctlCommand{
+		Name:    "install-file",
+		Args:    "<path>",
+		Summary: "Install a package from a local .layer or .uab file",
+		Description: "install-file installs the package in a .layer or .uab file through the service, " +
+			"without a repository, and shows the install output. The file must be readable by the service.",
+		Flags: []ctlFlag{
+			{Name: "force", Description: "Replace the installed version of the package"},
+		},
+		Run: runInstallFile,
+	})
+}
+
+func runInstallFile(flags map[string]string, args []string) int {
+	if len(args) != 1 {
+		printCommandHelp(findCtlCommand("install-file"))
+		return 2
+	}
+	path, err := filepath.Abs(args[0])
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 2
+	}
+
+	conn, err := dbusutil.Connect("")
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: failed to connect to D-Bus: %v\n", err))
+		return 1
+	}
+	defer conn.Close()
+
+	exitCode, err := followRemote(conn, "InstallFileStream", func(data string, isStderr bool) {
+		if isStderr {
+			fmt.Fprint(os.Stderr, data)
+		} else {
+			fmt.Print(data)
+		}
+	}, path, flags["force"] != "")
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 1
+	}
+	return exitCode
+}
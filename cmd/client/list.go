@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/dbusutil"
+	"linyapsmanager/internal/i18n"
+)
+
+func init() {
+	ctlCommands = append(ctlCommands, &ctlCommand{
+		Name:    "list",
+		Summary: "List installed apps, runtimes or bases",
+		Description: "list shows the installed packages of one type. Runtimes and bases are listed " +
+			"separately from apps, so cleanup tools can tell which layers are installed.",
+		Flags: []ctlFlag{
+			{Name: "type", Arg: "app|runtime|base|all", Description: "Type of packages to list (default app)"},
+			{Name: "json", Description: "Print JSON instead of a table"},
+		},
+		Run: runList,
+	})
+}
+
+func runList(flags map[string]string, args []string) int {
+	if len(args) != 0 {
+		printCommandHelp(findCtlCommand("list"))
+		return 2
+	}
+	asJSON := flags["json"] != ""
+	var method string
+	switch kind := flags["type"]; kind {
+	case "runtime":
+		method = "ListRuntimes"
+	case "base":
+		method = "ListBases"
+	case "", "app", "all":
+		llArgs := []string{"list"}
+		if kind != "" {
+			llArgs = append(llArgs, "--type="+kind)
+		}
+		if asJSON {
+			llArgs = append(llArgs, "--json")
+		}
+		return runCtlLLCli(llArgs)
+	default:
+		fmt.Fprint(os.Stderr, i18n.T("Error: invalid --%s %q\n", "type", kind))
+		return 2
+	}
+
+	conn, err := dbusutil.Connect("")
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: failed to connect to D-Bus: %v\n", err))
+		return 1
+	}
+	defer conn.Close()
+	obj := conn.Object(dbusconsts.BusName, dbus.ObjectPath(dbusconsts.ObjectPath))
+	var out string
+	if err := obj.Call(dbusconsts.Interface+"."+method, 0, asJSON).Store(&out); err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 1
+	}
+	fmt.Print(out)
+	return 0
+}
+
+// runCtlLLCli runs ll-cli with args through the service like the ll-cli
+// link does.
+func runCtlLLCli(args []string) int {
+	conn, err := dbusutil.Connect("")
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: failed to connect to D-Bus: %v\n", err))
+		return 1
+	}
+	exitCode, err := executeCommand(conn, "ll-cli", args)
+	conn.Close()
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 1
+	}
+	return exitCode
+}
@@ -12,6 +12,7 @@ import (
 	_ "linyapsmanager/internal/cmdwhitelist/rules" // Register command rules
 	"linyapsmanager/internal/dbusconsts"
 	"linyapsmanager/internal/dbusutil"
+	"linyapsmanager/internal/i18n"
 	"linyapsmanager/internal/streaming"
 )
 
@@ -24,13 +25,12 @@ func main() {
 
 	// Handle special case: if invoked as the base client binary name
 	if cmdName == "linyapsctl" {
-		printUsage()
-		os.Exit(1)
+		os.Exit(runCtl(os.Args[1:]))
 	}
 
 	// Check if command is allowed
 	if !cmdwhitelist.IsAllowed(cmdName) {
-		fmt.Fprintf(os.Stderr, "Error: command %q is not allowed\n", cmdName)
+		fmt.Fprint(os.Stderr, i18n.T("Error: command %q is not allowed\n", cmdName))
 		os.Exit(1)
 	}
 
@@ -40,64 +40,68 @@ func main() {
 	// Connect to D-Bus
 	conn, err := dbusutil.Connect("")
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: failed to connect to D-Bus: %v\n", err)
+		if code, ok := runLocalFallback(cmdName, args, err); ok {
+			os.Exit(code)
+		}
+		fmt.Fprint(os.Stderr, i18n.T("Error: failed to connect to D-Bus: %v\n", err))
 		os.Exit(1)
 	}
-	defer conn.Close()
 
 	// Execute command via D-Bus
 	exitCode, err := executeCommand(conn, cmdName, args)
+	conn.Close()
+	if err != nil && serviceUnavailable(err) {
+		if code, ok := runLocalFallback(cmdName, args, err); ok {
+			os.Exit(code)
+		}
+	}
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
 		os.Exit(1)
 	}
 
 	os.Exit(exitCode)
 }
 
-func printUsage() {
-	fmt.Println("LinyapsManager Client")
-	fmt.Println()
-	fmt.Println("This program should be invoked via symlinks named after the command to execute.")
-	fmt.Println()
-	fmt.Println("Example:")
-	fmt.Println("  ln -s linyapsctl ll-cli")
-	fmt.Println("  ./ll-cli install com.example.app")
-	fmt.Println()
-	fmt.Println("Allowed commands:")
-	for _, cmd := range cmdwhitelist.ListCommands() {
-		fmt.Printf("  - %s\n", cmd)
-	}
+func executeCommand(conn *dbus.Conn, command string, args []string) (int, error) {
+	return runRemote(conn, command, args, func(data string, isStderr bool) {
+		if isStderr {
+			fmt.Fprint(os.Stderr, data)
+		} else {
+			fmt.Print(data)
+		}
+	})
 }
 
-func executeCommand(conn *dbus.Conn, command string, args []string) (int, error) {
+// runRemote executes a whitelisted command through the service, passing each
+// output chunk to outputFn, and returns the remote exit code.
+func runRemote(conn *dbus.Conn, command string, args []string, outputFn func(data string, isStderr bool)) (int, error) {
+	return followRemote(conn, "ExecuteCommand", outputFn, command, args)
+}
+
+// followRemote calls a service method that returns an operation ID, passing
+// each output chunk of the operation to outputFn, and returns its exit code.
+func followRemote(conn *dbus.Conn, method string, outputFn func(data string, isStderr bool), args ...interface{}) (int, error) {
 	obj := conn.Object(dbusconsts.BusName, dbus.ObjectPath(dbusconsts.ObjectPath))
 
 	// Set up signal receiver before making the call
 	receiver, err := streaming.NewReceiver(conn)
 	if err != nil {
-		return -1, fmt.Errorf("failed to create signal receiver: %w", err)
+		return -1, fmt.Errorf(i18n.T("failed to create signal receiver: %w"), err)
 	}
 	defer receiver.Stop()
 
-	// Call ExecuteCommand
 	var operationID string
-	err = obj.Call(dbusconsts.Interface+".ExecuteCommand", 0, command, args).Store(&operationID)
+	err = obj.Call(dbusconsts.Interface+"."+method, 0, args...).Store(&operationID)
 	if err != nil {
-		return -1, fmt.Errorf("D-Bus call failed: %w", err)
+		return -1, fmt.Errorf(i18n.T("D-Bus call failed: %w"), err)
 	}
 
 	// Wait for output and completion
-	exitCode, errorMsg := receiver.WaitForOperation(operationID, func(data string, isStderr bool) {
-		if isStderr {
-			fmt.Fprint(os.Stderr, data)
-		} else {
-			fmt.Print(data)
-		}
-	})
+	exitCode, errorMsg := receiver.WaitForOperation(operationID, outputFn)
 
 	if errorMsg != "" {
-		return exitCode, fmt.Errorf("command failed: %s", errorMsg)
+		return exitCode, fmt.Errorf(i18n.T("command failed: %s"), errorMsg)
 	}
 
 	return exitCode, nil
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"linyapsmanager/internal/i18n"
+)
+
+// runGenMan implements "linyapsctl gen-man".
+func runGenMan(flags map[string]string, args []string) int {
+	w := io.Writer(os.Stdout)
+	if path := flags["output"]; path != "" {
+		f, err := os.Create(path)
+		if err != nil {
+			fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := writeManPage(w); err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 1
+	}
+	return 0
+}
+
+// writeManPage renders linyapsctl(1) in troff from the command specs.
+// The man page is always English so packaged docs are reproducible.
+func writeManPage(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString(".TH LINYAPSCTL 1 \"\" \"LinyapsManager\" \"User Commands\"\n")
+	b.WriteString(".SH NAME\n")
+	b.WriteString("linyapsctl \\- client for the LinyapsManager D-Bus service\n")
+
+	b.WriteString(".SH SYNOPSIS\n")
+	b.WriteString(".B <command>\n.RI [ args ...]\n.br\n")
+	for _, c := range ctlCommands {
+		fmt.Fprintf(&b, ".B %s\n.br\n", manEscape(c.synopsis()))
+	}
+
+	b.WriteString(".SH DESCRIPTION\n")
+	b.WriteString("When invoked through a symlink named after a whitelisted command, ")
+	b.WriteString("linyapsctl forwards the arguments to the LinyapsManager service, ")
+	b.WriteString("which runs the command on the host and streams its output back. ")
+	b.WriteString("The exit status is that of the remote command.\n")
+	b.WriteString(".PP\nThe following commands are allowed:\n")
+	for _, cmd := range allowedCommands() {
+		fmt.Fprintf(&b, ".IP \\(bu 2\n%s\n", manEscape(cmd))
+	}
+
+	b.WriteString(".SH COMMANDS\n")
+	for _, c := range ctlCommands {
+		fmt.Fprintf(&b, ".TP\n.B %s\n%s\n", manEscape(c.synopsis()), manEscape(c.Summary))
+		if c.Description != "" {
+			fmt.Fprintf(&b, "%s\n", manEscape(c.Description))
+		}
+		for _, f := range c.Flags {
+			fmt.Fprintf(&b, ".RS\n.TP\n.B %s\n%s\n.RE\n", manEscape(f.String()), manEscape(f.Description))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// manEscape escapes text for use in a troff line.
+func manEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	if strings.HasPrefix(s, ".") || strings.HasPrefix(s, "'") {
+		s = `\&` + s
+	}
+	return s
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"linyapsmanager/internal/dbusutil"
+	"linyapsmanager/internal/i18n"
+	"linyapsmanager/internal/llcli"
+)
+
+func init() {
+	ctlCommands = append(ctlCommands, &ctlCommand{
+		Name:    "open",
+		Args:    "<uri>",
+		Summary: "Handle a store link",
+		Description: "open performs the request of a linglong://install/<appid> link, as opened from a " +
+			"store web page, after the user confirms it, and shows the install output.",
+		Run: runOpen,
+	})
+}
+
+func runOpen(flags map[string]string, args []string) int {
+	if len(args) != 1 {
+		printCommandHelp(findCtlCommand("open"))
+		return 2
+	}
+	if _, err := llcli.ParseURI(args[0]); err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 2
+	}
+
+	conn, err := dbusutil.Connect("")
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: failed to connect to D-Bus: %v\n", err))
+		return 1
+	}
+	defer conn.Close()
+
+	exitCode, err := followRemote(conn, "HandleURI", func(data string, isStderr bool) {
+		if isStderr {
+			fmt.Fprint(os.Stderr, data)
+		} else {
+			fmt.Print(data)
+		}
+	}, args[0])
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 1
+	}
+	return exitCode
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"linyapsmanager/internal/dbusutil"
+	"linyapsmanager/internal/i18n"
+	"linyapsmanager/internal/llcli"
+)
+
+const (
+	defaultProbeTimeout = 5 * time.Second
+	defaultBenchCount   = 3
+)
+
+func init() {
+	ctlCommands = append(ctlCommands, &ctlCommand{
+		Name:    "repo",
+		Args:    "probe | bench <ref> | add <name> <url> | remove <name> | set-default <name> | update <name> <url>",
+		Summary: "Measure or change the configured repositories",
+		Description: "probe times a request to every repository from 'll-cli repo show'; " +
+			"bench fetches the ostree ref pointer <ref> from each repository several times. " +
+			"add, remove, set-default and update change the repository configuration through the service, " +
+			"which asks for administrator authentication.",
+		Flags: []ctlFlag{
+			{Name: "timeout", Arg: "SECONDS", Description: "Per-request timeout (default 5)"},
+			{Name: "count", Arg: "N", Description: "Number of bench fetches per repository (default 3)"},
+		},
+		Run: runRepo,
+	})
+}
+
+// repoMethods maps the repo subcommands that change the configuration to
+// their service method and number of arguments.
+var repoMethods = map[string]struct {
+	method string
+	nargs  int
+}{
+	"add":         {"RepoAdd", 2},
+	"remove":      {"RepoRemove", 1},
+	"set-default": {"RepoSetDefault", 1},
+	"update":      {"RepoUpdate", 2},
+}
+
+func runRepo(flags map[string]string, args []string) int {
+	if len(args) == 0 || (args[0] == "bench" && len(args) < 2) {
+		printCommandHelp(findCtlCommand("repo"))
+		return 2
+	}
+	if m, ok := repoMethods[args[0]]; ok {
+		if len(args) != m.nargs+1 {
+			printCommandHelp(findCtlCommand("repo"))
+			return 2
+		}
+		return changeRepos(m.method, args[1:])
+	}
+
+	timeout := defaultProbeTimeout
+	if v := flags["timeout"]; v != "" {
+		secs, err := strconv.Atoi(v)
+		if err != nil || secs <= 0 {
+			fmt.Fprint(os.Stderr, i18n.T("Error: invalid --timeout %q\n", v))
+			return 2
+		}
+		timeout = time.Duration(secs) * time.Second
+	}
+	count := defaultBenchCount
+	if v := flags["count"]; v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			fmt.Fprint(os.Stderr, i18n.T("Error: invalid --count %q\n", v))
+			return 2
+		}
+		count = n
+	}
+
+	cfg, err := fetchRepoConfig()
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 1
+	}
+	client := &http.Client{Timeout: timeout}
+
+	switch args[0] {
+	case "probe":
+		probeRepos(client, cfg)
+	case "bench":
+		benchRepos(client, cfg, args[1], count)
+	default:
+		fmt.Fprint(os.Stderr, i18n.T("Error: unknown command %q\n", "repo "+args[0]))
+		return 2
+	}
+	return 0
+}
+
+// changeRepos calls a repository method of the service and shows the
+// output of ll-cli.
+func changeRepos(method string, args []string) int {
+	conn, err := dbusutil.Connect("")
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: failed to connect to D-Bus: %v\n", err))
+		return 1
+	}
+	defer conn.Close()
+
+	callArgs := make([]interface{}, len(args))
+	for i, a := range args {
+		callArgs[i] = a
+	}
+	exitCode, err := followRemote(conn, method, func(data string, isStderr bool) {
+		if isStderr {
+			fmt.Fprint(os.Stderr, data)
+		} else {
+			fmt.Print(data)
+		}
+	}, callArgs...)
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 1
+	}
+	return exitCode
+}
+
+// fetchRepoConfig runs "ll-cli repo show" through the service and parses it.
+func fetchRepoConfig() (llcli.RepoConfig, error) {
+	conn, err := dbusutil.Connect("")
+	if err != nil {
+		return llcli.RepoConfig{}, fmt.Errorf(i18n.T("failed to connect to D-Bus: %w"), err)
+	}
+	defer conn.Close()
+
+	var out strings.Builder
+	code, err := runRemote(conn, "ll-cli", []string{"repo", "show"}, func(data string, isStderr bool) {
+		if !isStderr {
+			out.WriteString(data)
+		}
+	})
+	if err != nil {
+		return llcli.RepoConfig{}, err
+	}
+	if code != 0 {
+		return llcli.RepoConfig{}, fmt.Errorf(i18n.T("ll-cli repo show exited with code %d"), code)
+	}
+	return llcli.ParseRepoShow(out.String())
+}
+
+// timeFetch issues a GET for url and returns the time to the response headers
+// along with the HTTP status.
+func timeFetch(client *http.Client, url string) (time.Duration, string, error) {
+	start := time.Now()
+	resp, err := client.Get(url)
+	if err != nil {
+		return 0, "", err
+	}
+	elapsed := time.Since(start)
+	resp.Body.Close()
+	return elapsed, resp.Status, nil
+}
+
+func probeRepos(client *http.Client, cfg llcli.RepoConfig) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, i18n.T("NAME\tURL\tLATENCY\tSTATUS"))
+	for _, repo := range cfg.Repos {
+		name := repo.Name
+		if name == cfg.Default {
+			name += "*"
+		}
+		latency, status, err := timeFetch(client, repo.URL)
+		if err != nil {
+			fmt.Fprintf(tw, "%s\t%s\t-\t%v\n", name, repo.URL, err)
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", name, repo.URL, latency.Round(time.Millisecond), status)
+	}
+	tw.Flush()
+}
+
+func benchRepos(client *http.Client, cfg llcli.RepoConfig, ref string, count int) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, i18n.T("NAME\tMIN\tAVG\tMAX\tFAILED"))
+	for _, repo := range cfg.Repos {
+		url := refURL(repo, ref)
+		var total, min, max time.Duration
+		var ok, failed int
+		for i := 0; i < count; i++ {
+			d, status, err := timeFetch(client, url)
+			if err != nil || !strings.HasPrefix(status, "2") {
+				failed++
+				continue
+			}
+			ok++
+			total += d
+			if min == 0 || d < min {
+				min = d
+			}
+			if d > max {
+				max = d
+			}
+		}
+		if ok == 0 {
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t%d/%d\n", repo.Name, failed, count)
+			continue
+		}
+		avg := total / time.Duration(ok)
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d/%d\n", repo.Name,
+			min.Round(time.Millisecond), avg.Round(time.Millisecond), max.Round(time.Millisecond), failed, count)
+	}
+	tw.Flush()
+}
+
+// refURL returns the URL of the ostree ref pointer for ref in repo. Repository
+// URLs either point at the server root (repos live under /repos/<name>) or,
+// on older configurations, already include the /repos/ path.
+func refURL(repo llcli.Repo, ref string) string {
+	base := strings.TrimRight(repo.URL, "/")
+	if !strings.HasSuffix(base, "/repos/"+repo.Name) {
+		if strings.HasSuffix(base, "/repos") {
+			base += "/" + repo.Name
+		} else {
+			base += "/repos/" + repo.Name
+		}
+	}
+	return base + "/refs/heads/" + strings.TrimPrefix(ref, "/")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/dbusutil"
+	"linyapsmanager/internal/i18n"
+)
+
+func init() {
+	ctlCommands = append(ctlCommands, &ctlCommand{
+		Name:    "run",
+		Args:    "<appid> [-- <args>...]",
+		Summary: "Run an app with arguments and environment variables",
+		Description: "run starts the app through the service and prints the operation ID. As with ll-cli run, " +
+			"arguments after -- replace the command the app starts with and are checked against the exec policy. " +
+			"With --attach it shows the app's console output until it exits and exits with its exit code; " +
+			"without arguments or --env the app then runs on a terminal.",
+		Flags: []ctlFlag{
+			{Name: "version", Arg: "VERSION", Description: "Run VERSION instead of the newest installed version"},
+			{Name: "env", Arg: "KEY=VALUE", Description: "Set an environment variable in the app; may be repeated", Repeatable: true},
+			{Name: "attach", Description: "Show the app's output and wait for it to exit"},
+		},
+		Run: runRun,
+	})
+}
+
+func runRun(flags map[string]string, args []string) int {
+	if len(args) < 1 {
+		printCommandHelp(findCtlCommand("run"))
+		return 2
+	}
+	env := map[string]string{}
+	if v := flags["env"]; v != "" {
+		for _, kv := range strings.Split(v, "\n") {
+			key, value, ok := strings.Cut(kv, "=")
+			if !ok || key == "" {
+				fmt.Fprint(os.Stderr, i18n.T("Error: invalid --env %q, want KEY=VALUE\n", kv))
+				return 2
+			}
+			env[key] = value
+		}
+	}
+	appArgs := args[1:]
+	if appArgs == nil {
+		appArgs = []string{}
+	}
+
+	conn, err := dbusutil.Connect("")
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: failed to connect to D-Bus: %v\n", err))
+		return 1
+	}
+	defer conn.Close()
+
+	if flags["attach"] == "" {
+		obj := conn.Object(dbusconsts.BusName, dbus.ObjectPath(dbusconsts.ObjectPath))
+		var opID string
+		if err := obj.Call(dbusconsts.Interface+".RunWithArgs", 0, args[0], flags["version"], appArgs, env).Store(&opID); err != nil {
+			fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+			return 1
+		}
+		fmt.Println(opID)
+		return 0
+	}
+
+	printOutput := func(data string, isStderr bool) {
+		if isStderr {
+			fmt.Fprint(os.Stderr, data)
+		} else {
+			fmt.Print(data)
+		}
+	}
+	var exitCode int
+	if len(appArgs) == 0 && len(env) == 0 {
+		exitCode, err = followRemote(conn, "RunStream", printOutput, args[0], flags["version"])
+	} else {
+		exitCode, err = followRemote(conn, "RunWithArgs", printOutput, args[0], flags["version"], appArgs, env)
+	}
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 1
+	}
+	return exitCode
+}
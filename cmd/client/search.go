@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/dbusutil"
+	"linyapsmanager/internal/i18n"
+)
+
+func init() {
+	ctlCommands = append(ctlCommands, &ctlCommand{
+		Name:    "search",
+		Args:    "<keyword>",
+		Summary: "Search the repositories",
+		Description: "search looks up packages matching the keyword in the configured repositories " +
+			"through the service, optionally only of one type, in one repository or from one channel.",
+		Flags: []ctlFlag{
+			{Name: "type", Arg: "app|runtime|base|all", Description: "Type of packages to search (default app)"},
+			{Name: "repo", Arg: "NAME", Description: "Search only the repository NAME"},
+			{Name: "channel", Arg: "CHANNEL", Description: "Show only packages from CHANNEL, such as main"},
+			{Name: "json", Description: "Print JSON instead of a table"},
+		},
+		Run: runSearch,
+	})
+}
+
+func runSearch(flags map[string]string, args []string) int {
+	if len(args) != 1 {
+		printCommandHelp(findCtlCommand("search"))
+		return 2
+	}
+	opts := map[string]string{}
+	for _, key := range []string{"type", "repo", "channel"} {
+		if flags[key] != "" {
+			opts[key] = flags[key]
+		}
+	}
+
+	conn, err := dbusutil.Connect("")
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: failed to connect to D-Bus: %v\n", err))
+		return 1
+	}
+	defer conn.Close()
+	obj := conn.Object(dbusconsts.BusName, dbus.ObjectPath(dbusconsts.ObjectPath))
+	var results []map[string]dbus.Variant
+	if err := obj.Call(dbusconsts.Interface+".SearchFiltered", 0, args[0], opts).Store(&results); err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 1
+	}
+
+	if flags["json"] != "" {
+		list := make([]map[string]interface{}, 0, len(results))
+		for _, r := range results {
+			entry := make(map[string]interface{}, len(r))
+			for k, v := range r {
+				entry[k] = v.Value()
+			}
+			list = append(list, entry)
+		}
+		data, err := json.MarshalIndent(list, "", "  ")
+		if err != nil {
+			fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+			return 1
+		}
+		fmt.Println(string(data))
+		return 0
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tVERSION\tCHANNEL\tMODULE\tREPO\tDESCRIPTION")
+	for _, r := range results {
+		fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\n", r["id"].Value(), r["version"].Value(), r["channel"].Value(),
+			r["module"].Value(), r["repo"].Value(), r["description"].Value())
+	}
+	w.Flush()
+	return 0
+}
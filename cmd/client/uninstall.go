@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusutil"
+	"linyapsmanager/internal/i18n"
+)
+
+func init() {
+	ctlCommands = append(ctlCommands, &ctlCommand{
+		Name:    "uninstall",
+		Args:    "<appid>...",
+		Summary: "Uninstall apps",
+		Description: "uninstall removes the apps through the service and shows the uninstall output. " +
+			"Several apps are removed one after the other as one operation, which fails if any removal failed.",
+		Run: runUninstall,
+	})
+}
+
+func runUninstall(flags map[string]string, args []string) int {
+	if len(args) == 0 {
+		printCommandHelp(findCtlCommand("uninstall"))
+		return 2
+	}
+	conn, err := dbusutil.Connect("")
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: failed to connect to D-Bus: %v\n", err))
+		return 1
+	}
+	defer conn.Close()
+
+	output := func(data string, isStderr bool) {
+		if isStderr {
+			fmt.Fprint(os.Stderr, data)
+		} else {
+			fmt.Print(data)
+		}
+	}
+	var exitCode int
+	if len(args) > 1 {
+		exitCode, err = followRemote(conn, "UninstallBatchStream", output, args)
+	} else {
+		exitCode, err = followRemote(conn, "UninstallStream", output, args[0], "", map[string]dbus.Variant{})
+	}
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 1
+	}
+	return exitCode
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"linyapsmanager/internal/dbusutil"
+	"linyapsmanager/internal/i18n"
+)
+
+func init() {
+	ctlCommands = append(ctlCommands, &ctlCommand{
+		Name:    "upgrade",
+		Args:    "<appid>...",
+		Summary: "Upgrade the given apps",
+		Description: "upgrade upgrades only the given apps through the service, one after the other as one operation, " +
+			"and shows the upgrade output. The operation fails if any upgrade failed.",
+		Run: runUpgrade,
+	})
+}
+
+func runUpgrade(flags map[string]string, args []string) int {
+	if len(args) == 0 {
+		printCommandHelp(findCtlCommand("upgrade"))
+		return 2
+	}
+	conn, err := dbusutil.Connect("")
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: failed to connect to D-Bus: %v\n", err))
+		return 1
+	}
+	defer conn.Close()
+
+	exitCode, err := followRemote(conn, "UpgradeSelectedStream", func(data string, isStderr bool) {
+		if isStderr {
+			fmt.Fprint(os.Stderr, data)
+		} else {
+			fmt.Print(data)
+		}
+	}, args)
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+		return 1
+	}
+	return exitCode
+}
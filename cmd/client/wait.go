@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/dbusutil"
+	"linyapsmanager/internal/i18n"
+	"linyapsmanager/internal/streaming"
+)
+
+// Exit codes of linyapsctl wait besides the operation's own, following
+// timeout(1).
+const (
+	waitExitFailed    = 1   // the operation failed without an exit code of its own
+	waitExitTimedOut  = 124 // --timeout expired first
+	waitExitError     = 125 // the operation could not be waited for
+	waitExitCancelled = 130 // the operation was cancelled
+)
+
+func init() {
+	ctlCommands = append(ctlCommands, &ctlCommand{
+		Name:    "wait",
+		Args:    "<opID>",
+		Summary: "Wait for an operation to finish",
+		Description: "Blocks until the operation finishes and exits with its exit code, printing nothing. " +
+			"An operation that failed without an exit code gives 1, a cancelled one 130; " +
+			"124 means --timeout expired first and 125 that the operation could not be waited for.",
+		Flags: []ctlFlag{
+			{Name: "timeout", Arg: "DURATION", Description: "Give up after DURATION (e.g. 90s, 5m; plain numbers are seconds)"},
+		},
+		Run: runWait,
+	})
+}
+
+func runWait(flags map[string]string, args []string) int {
+	if len(args) != 1 {
+		printCommandHelp(findCtlCommand("wait"))
+		return 2
+	}
+	var timeout <-chan time.Time
+	if v := flags["timeout"]; v != "" {
+		d, err := parseWaitTimeout(v)
+		if err != nil {
+			fmt.Fprint(os.Stderr, i18n.T("Error: invalid --timeout %q\n", v))
+			return 2
+		}
+		timeout = time.After(d)
+	}
+	code, err := waitOperation(args[0], timeout)
+	if err != nil {
+		fmt.Fprint(os.Stderr, i18n.T("Error: %v\n", err))
+	}
+	return code
+}
+
+// parseWaitTimeout accepts a Go duration or a number of seconds.
+func parseWaitTimeout(s string) (time.Duration, error) {
+	if secs, err := strconv.ParseFloat(s, 64); err == nil && secs >= 0 {
+		return time.Duration(secs * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(s)
+	if err == nil && d < 0 {
+		err = fmt.Errorf("negative duration")
+	}
+	return d, err
+}
+
+// waitOperation waits for operationID to finish and returns the exit code
+// of linyapsctl wait.
+func waitOperation(operationID string, timeout <-chan time.Time) (int, error) {
+	conn, err := dbusutil.Connect("")
+	if err != nil {
+		return waitExitError, fmt.Errorf(i18n.T("failed to connect to D-Bus: %w"), err)
+	}
+	defer conn.Close()
+
+	// Subscribe before asking for the status, so a Complete signal sent in
+	// between is not missed
+	receiver, err := streaming.NewReceiver(conn)
+	if err != nil {
+		return waitExitError, fmt.Errorf(i18n.T("failed to create signal receiver: %w"), err)
+	}
+	defer receiver.Stop()
+
+	status, err := operationStatus(conn, operationID)
+	if err != nil {
+		return waitExitError, err
+	}
+	if state, _ := status["state"].Value().(string); state != string(streaming.StateRunning) {
+		return waitExitCode(status), nil
+	}
+
+	done := make(chan int, 1)
+	go func() {
+		code, _ := receiver.WaitForOperation(operationID, func(string, bool) {})
+		done <- code
+	}()
+	select {
+	case code := <-done:
+		// The final state tells a cancellation from a failure
+		if status, err := operationStatus(conn, operationID); err == nil {
+			return waitExitCode(status), nil
+		}
+		if code < 0 {
+			return waitExitFailed, nil
+		}
+		return code, nil
+	case <-timeout:
+		return waitExitTimedOut, nil
+	}
+}
+
+func operationStatus(conn *dbus.Conn, operationID string) (map[string]dbus.Variant, error) {
+	obj := conn.Object(dbusconsts.BusName, dbus.ObjectPath(dbusconsts.ObjectPath))
+	var status map[string]dbus.Variant
+	if err := obj.Call(dbusconsts.Interface+".GetOperationStatus", 0, operationID).Store(&status); err != nil {
+		return nil, fmt.Errorf(i18n.T("D-Bus call failed: %w"), err)
+	}
+	return status, nil
+}
+
+// waitExitCode maps the status of a finished operation to an exit code.
+func waitExitCode(status map[string]dbus.Variant) int {
+	state, _ := status["state"].Value().(string)
+	code, _ := status["exit_code"].Value().(int32)
+	switch {
+	case state == string(streaming.StateCancelled):
+		return waitExitCancelled
+	case state == string(streaming.StateCompleted):
+		return 0
+	case code > 0:
+		return int(code)
+	}
+	return waitExitFailed
+}
@@ -0,0 +1,97 @@
+// Command linyaps-guest makes requests to the host from inside an app
+// container through the manager's guest interface:
+//
+//	linyaps-guest install-runtime <ref>   install the app's missing runtime or base
+//	linyaps-guest open <url>              open url on the host
+//	linyaps-guest version                 print the guest protocol version
+//
+// install-runtime shows the install output and exits with its exit code.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/dbusutil"
+	"linyapsmanager/internal/streaming"
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %[1]s install-runtime <ref>\n       %[1]s open <url>\n       %[1]s version\n", os.Args[0])
+}
+
+func main() {
+	args := os.Args[1:]
+	if len(args) == 0 {
+		usage()
+		os.Exit(2)
+	}
+	want := map[string]int{"install-runtime": 1, "open": 1, "version": 0}
+	n, ok := want[args[0]]
+	if !ok || len(args) != n+1 {
+		usage()
+		os.Exit(2)
+	}
+
+	conn, err := dbusutil.Connect("")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to connect to D-Bus: %v\n", err)
+		os.Exit(1)
+	}
+	defer conn.Close()
+	obj := conn.Object(dbusconsts.BusName, dbus.ObjectPath(dbusconsts.GuestPath))
+
+	switch args[0] {
+	case "version":
+		var v uint32
+		if err := obj.Call(dbusconsts.GuestInterface+".Version", 0).Store(&v); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(v)
+	case "open":
+		if err := obj.Call(dbusconsts.GuestInterface+".OpenHostURL", 0, args[1]).Err; err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	case "install-runtime":
+		code, err := installRuntime(conn, obj, args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			if code == 0 {
+				code = 1
+			}
+		}
+		os.Exit(code)
+	}
+}
+
+// installRuntime requests the install of ref, shows its output and returns
+// its exit code.
+func installRuntime(conn *dbus.Conn, obj dbus.BusObject, ref string) (int, error) {
+	// Subscribe before the call so no output is missed
+	receiver, err := streaming.NewReceiver(conn)
+	if err != nil {
+		return 1, err
+	}
+	defer receiver.Stop()
+
+	var opID string
+	if err := obj.Call(dbusconsts.GuestInterface+".RequestInstallMissingRuntime", 0, ref).Store(&opID); err != nil {
+		return 1, err
+	}
+	code, msg := receiver.WaitForOperation(opID, func(data string, isStderr bool) {
+		if isStderr {
+			fmt.Fprint(os.Stderr, data)
+		} else {
+			fmt.Print(data)
+		}
+	})
+	if msg != "" {
+		return code, fmt.Errorf("install failed: %s", msg)
+	}
+	return code, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusconsts"
+)
+
+// envBusAliases lists, separated by commas or spaces, the additional
+// well-known names to own for frontends that still use another name. When
+// it is not set, defaultBusAliases are owned; set it empty for none.
+const envBusAliases = "LINYAPS_BUS_ALIASES"
+
+// defaultBusAliases is the versioned name some frontends look for.
+var defaultBusAliases = []string{dbusconsts.BusName + "1"}
+
+// busAliases records which alias names were acquired at startup.
+type busAliases struct {
+	owned       []string
+	unavailable []string
+}
+
+// busAliasesFromEnv returns the configured alias names, without duplicates
+// and without the main name.
+func busAliasesFromEnv() []string {
+	v, ok := os.LookupEnv(envBusAliases)
+	if !ok {
+		return defaultBusAliases
+	}
+	return parseBusAliases(v)
+}
+
+func parseBusAliases(s string) []string {
+	seen := map[string]bool{dbusconsts.BusName: true}
+	names := []string{}
+	for _, name := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// requestBusAliases tries to own each of names with the same flags as the
+// main name. The objects are exported on the connection, so they answer
+// under every name it owns. A name that cannot be had, because another
+// process owns it or the bus policy forbids it, is logged and skipped:
+// the main name is what matters.
+func requestBusAliases(conn *dbus.Conn, names []string, takeover bool) busAliases {
+	flags := dbus.NameFlagAllowReplacement | dbus.NameFlagDoNotQueue
+	if takeover {
+		flags |= dbus.NameFlagReplaceExisting
+	}
+	a := busAliases{owned: []string{}, unavailable: []string{}}
+	for _, name := range names {
+		reply, err := conn.RequestName(name, flags)
+		if err == nil && reply != dbus.RequestNameReplyPrimaryOwner && reply != dbus.RequestNameReplyAlreadyOwner {
+			err = fmt.Errorf("owned by another process")
+		}
+		if err != nil {
+			log.Printf("[WARN] cannot own alias name %s: %v", name, err)
+			a.unavailable = append(a.unavailable, name)
+			continue
+		}
+		a.owned = append(a.owned, name)
+	}
+	if len(a.owned) > 0 {
+		log.Printf("[INFO] also serving as %s", strings.Join(a.owned, ", "))
+	}
+	return a
+}
+
+// GetBackendInfo describes the running service:
+//   - version (s): service version
+//   - bus_name (s): the main well-known name
+//   - unique_name (s): the connection's unique name
+//   - aliases (as): the additional names it answers to
+//   - unavailable_aliases (as): configured names it could not own
+func (m *LinyapsManager) GetBackendInfo() (map[string]dbus.Variant, *dbus.Error) {
+	return map[string]dbus.Variant{
+		"version":             dbus.MakeVariant(version),
+		"bus_name":            dbus.MakeVariant(dbusconsts.BusName),
+		"unique_name":         dbus.MakeVariant(m.conn.Names()[0]),
+		"aliases":             dbus.MakeVariant(m.aliases.owned),
+		"unavailable_aliases": dbus.MakeVariant(m.aliases.unavailable),
+	}, nil
+}
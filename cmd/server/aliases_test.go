@@ -0,0 +1,23 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseBusAliases(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{"", []string{}},
+		{"org.example.A1", []string{"org.example.A1"}},
+		{"org.example.A1, org.example.A2,,org.example.A1", []string{"org.example.A1", "org.example.A2"}},
+		{"org.linglong_store.LinyapsManager org.example.B", []string{"org.example.B"}},
+	}
+	for _, tt := range tests {
+		if got := parseBusAliases(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseBusAliases(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
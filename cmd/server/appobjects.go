@@ -0,0 +1,242 @@
+package main
+
+import (
+	"context"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/streaming"
+)
+
+// layersDir holds the unpacked layers of installed packages, as
+// <channel>/<id>/<version>/<arch> or, before ll-cli 1.5, <id>/<version>/<arch>.
+const layersDir = "/var/lib/linglong/layers"
+
+// appRefreshTimeout bounds one refresh of the app objects.
+const appRefreshTimeout = 2 * time.Minute
+
+// appObjects exposes each installed app as a child object under
+// dbusconsts.AppsPath, announced through the same ObjectManager as the
+// operation objects. The set is refreshed from ll-cli list whenever an
+// operation that changes the installed set finishes, emitting the app
+// change signals for the differences, and Running follows the app's ll-cli
+// run operations.
+type appObjects struct {
+	conn      *dbus.Conn
+	installed *installedIndex
+
+	refreshMu sync.Mutex // serializes refresh
+	loaded    bool       // the first refresh, which announces no changes, is done
+
+	mu      sync.Mutex
+	apps    map[string]*appObject // by app ID
+	running map[string]int        // ll-cli run operations by app ID
+}
+
+// appObject is the exported object of one app.
+type appObject struct {
+	path  dbus.ObjectPath
+	props *prop.Properties
+	pkg   llcli.Package
+}
+
+func newAppObjects(conn *dbus.Conn, installed *installedIndex) *appObjects {
+	return &appObjects{conn: conn, installed: installed, apps: make(map[string]*appObject), running: make(map[string]int)}
+}
+
+// appPath maps an app ID to its object path. Dots and hyphens are not
+// valid in path elements, so they become underscores.
+func appPath(appID string) dbus.ObjectPath {
+	return dbus.ObjectPath(dbusconsts.AppsPath + "/" + strings.NewReplacer(".", "_", "-", "_").Replace(appID))
+}
+
+// managed adds the app objects to objects.
+func (a *appObjects) managed(objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, app := range a.apps {
+		all, _ := app.props.GetAll(dbusconsts.AppInterface)
+		objects[app.path] = map[string]map[string]dbus.Variant{dbusconsts.AppInterface: all}
+	}
+}
+
+// update is a streaming.Registry watcher tracking running apps and
+// refreshing the objects after operations that change the installed set.
+func (a *appObjects) update(op streaming.Operation) {
+	if op.Labels["command"] != "ll-cli" {
+		return
+	}
+	switch op.Labels["operation"] {
+	case "run":
+		appID := llcli.AppIDFromRef(op.Labels["ref"])
+		if appID == "" {
+			return
+		}
+		a.mu.Lock()
+		if op.State == streaming.StateRunning {
+			a.running[appID]++
+		} else if a.running[appID]--; a.running[appID] <= 0 {
+			delete(a.running, appID)
+		}
+		running := a.running[appID] > 0
+		app := a.apps[appID]
+		a.mu.Unlock()
+		if app != nil {
+			app.props.SetMust(dbusconsts.AppInterface, "Running", running)
+		}
+	case "install", "uninstall", "upgrade", "prune", "downgrade", "switch-channel":
+		if op.State != streaming.StateRunning {
+			go a.refresh()
+		}
+	}
+}
+
+// refresh brings the app objects in line with the installed apps.
+func (a *appObjects) refresh() {
+	a.refreshMu.Lock()
+	defer a.refreshMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), appRefreshTimeout)
+	defer cancel()
+	pkgs, err := a.installed.packages(ctx)
+	if err != nil {
+		log.Printf("[WARN] failed to refresh app objects: %v", err)
+		return
+	}
+	current := installedApps(pkgs)
+
+	a.mu.Lock()
+	var removed []*appObject
+	for id, app := range a.apps {
+		if _, ok := current[id]; !ok {
+			removed = append(removed, app)
+			delete(a.apps, id)
+		}
+	}
+	a.mu.Unlock()
+	for _, app := range removed {
+		a.conn.Export(nil, app.path, dbusconsts.AppInterface)
+		a.conn.Export(nil, app.path, propertiesInterface)
+		a.conn.Export(nil, app.path, introspectableInterface)
+		emitObjectManager(a.conn, "InterfacesRemoved", app.path, []string{dbusconsts.AppInterface})
+		a.emitChange(dbusconsts.SignalAppRemoved, app.pkg.ID, app.pkg.Version)
+	}
+
+	for id, pkg := range current {
+		a.mu.Lock()
+		app := a.apps[id]
+		a.mu.Unlock()
+		switch {
+		case app == nil:
+			a.add(pkg)
+			a.emitChange(dbusconsts.SignalAppInstalled, pkg.ID, pkg.Version)
+		case app.pkg.Version != pkg.Version || app.pkg.Channel != pkg.Channel:
+			old := app.pkg
+			app.pkg = pkg
+			app.props.SetMust(dbusconsts.AppInterface, "Version", pkg.Version)
+			app.props.SetMust(dbusconsts.AppInterface, "Channel", pkg.Channel)
+			app.props.SetMust(dbusconsts.AppInterface, "Size", layerSize(pkg))
+			if old.Version != pkg.Version {
+				a.emitChange(dbusconsts.SignalAppUpgraded, pkg.ID, old.Version, pkg.Version)
+			}
+		}
+	}
+	a.loaded = true
+}
+
+// emitChange emits an app change signal, unless this is the first refresh
+// listing the apps installed before the service started.
+func (a *appObjects) emitChange(member string, values ...interface{}) {
+	if !a.loaded {
+		return
+	}
+	log.Printf("[INFO] %s %v", member, values)
+	if err := a.conn.Emit(dbusconsts.ObjectPath, dbusconsts.Interface+"."+member, values...); err != nil {
+		log.Printf("[WARN] failed to emit %s: %v", member, err)
+	}
+}
+
+func (a *appObjects) add(pkg llcli.Package) {
+	path := appPath(pkg.ID)
+	changing := func(v interface{}) *prop.Prop {
+		return &prop.Prop{Value: v, Emit: prop.EmitTrue}
+	}
+	a.mu.Lock()
+	running := a.running[pkg.ID] > 0
+	a.mu.Unlock()
+	p, err := prop.Export(a.conn, path, prop.Map{
+		dbusconsts.AppInterface: {
+			"Id":      {Value: pkg.ID, Emit: prop.EmitConst},
+			"Version": changing(pkg.Version),
+			"Channel": changing(pkg.Channel),
+			"Size":    changing(layerSize(pkg)),
+			"Running": changing(running),
+		},
+	})
+	if err != nil {
+		log.Printf("[WARN] failed to export app object %s: %v", path, err)
+		return
+	}
+	a.conn.Export(propertiesIntrospectable(p, dbusconsts.AppInterface), path, introspectableInterface)
+
+	a.mu.Lock()
+	a.apps[pkg.ID] = &appObject{path: path, props: p, pkg: pkg}
+	a.mu.Unlock()
+
+	all, _ := p.GetAll(dbusconsts.AppInterface)
+	emitObjectManager(a.conn, "InterfacesAdded", path, map[string]map[string]dbus.Variant{dbusconsts.AppInterface: all})
+}
+
+// installedApps returns the newest installed binary module of each app,
+// leaving out runtimes and bases.
+func installedApps(pkgs []llcli.Package) map[string]llcli.Package {
+	apps := make(map[string]llcli.Package)
+	for _, p := range pkgs {
+		if p.Module != "" && p.Module != "binary" {
+			continue
+		}
+		if p.Kind != "" && p.Kind != "app" {
+			continue
+		}
+		if cur, ok := apps[p.ID]; ok && llcli.CompareVersions(cur.Version, p.Version) >= 0 {
+			continue
+		}
+		apps[p.ID] = p
+	}
+	return apps
+}
+
+// layerSize returns the bytes the layer of pkg takes up on disk, or 0 if
+// it cannot be found.
+func layerSize(pkg llcli.Package) uint64 {
+	patterns := []string{
+		filepath.Join(layersDir, "*", pkg.ID, pkg.Version),
+		filepath.Join(layersDir, pkg.ID, pkg.Version),
+	}
+	var size uint64
+	for _, pattern := range patterns {
+		dirs, _ := filepath.Glob(pattern)
+		for _, dir := range dirs {
+			filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+				if err != nil || !d.Type().IsRegular() {
+					return nil
+				}
+				if info, err := d.Info(); err == nil {
+					size += uint64(info.Size())
+				}
+				return nil
+			})
+		}
+	}
+	return size
+}
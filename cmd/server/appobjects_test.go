@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"linyapsmanager/internal/llcli"
+)
+
+func TestInstalledApps(t *testing.T) {
+	pkgs := []llcli.Package{
+		{ID: "org.example.app", Version: "1.2", Module: "binary", Kind: "app"},
+		{ID: "org.example.app", Version: "1.10", Module: "binary", Kind: "app"},
+		{ID: "org.example.app", Version: "1.10", Module: "develop", Kind: "app"},
+		{ID: "org.deepin.base", Version: "23.1.0", Module: "binary", Kind: "base"},
+		{ID: "org.example.old", Version: "3"},
+	}
+	apps := installedApps(pkgs)
+	if len(apps) != 2 {
+		t.Fatalf("installedApps = %+v, want 2 apps", apps)
+	}
+	if got := apps["org.example.app"]; got.Version != "1.10" || got.Module != "binary" {
+		t.Errorf("org.example.app = %+v, want binary 1.10", got)
+	}
+	if _, ok := apps["org.example.old"]; !ok {
+		t.Error("app without kind and module missing")
+	}
+}
+
+func TestAppPath(t *testing.T) {
+	if got, want := appPath("org.example.my-app"), "/org/linglong_store/LinyapsManager/apps/org_example_my_app"; string(got) != want {
+		t.Errorf("appPath = %s, want %s", got, want)
+	}
+	if !appPath("org.example.my-app").IsValid() {
+		t.Error("app path not valid")
+	}
+}
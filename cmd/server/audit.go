@@ -0,0 +1,377 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/audit"
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/history"
+	"linyapsmanager/internal/polkit"
+)
+
+const (
+	// auditQueue bounds the calls waiting to be logged; beyond it calls go
+	// unlogged rather than stalling the bus connection.
+	auditQueue = 1024
+	// auditMaxArgs bounds the logged arguments of one call, in bytes.
+	auditMaxArgs = 1024
+	// auditMaxPending bounds the calls waiting for their reply.
+	auditMaxPending = 4096
+	// defaultAuditLimit is the number of entries GetAuditLog returns for limit 0.
+	defaultAuditLimit = 100
+)
+
+// envAuditKey names a file holding the base64 ed25519 key, the device key,
+// that signs audit log checkpoints. Without it the log is only chained.
+const envAuditKey = "LINYAPS_AUDIT_KEY"
+
+// auditRedacted lists the methods whose arguments are secrets.
+var auditRedacted = map[string]bool{
+	"RunWithToken": true,
+}
+
+// auditor logs every call to the manager's interfaces with the caller's
+// credentials, the result and the time taken. Calls and replies are seen
+// by connection interceptors, which run on the connection's reader, so
+// they only queue events; a worker writes the log.
+//
+// The caller's credentials are requested from the bus as soon as a call
+// arrives, before it is dispatched, so the bus answers while the caller is
+// still connected waiting for the reply.
+type auditor struct {
+	log     *audit.Log
+	pub     ed25519.PublicKey // verifies checkpoints; nil if they are not signed
+	conn    atomic.Pointer[dbus.Conn]
+	events  chan auditEvent
+	dropped atomic.Uint64
+
+	pending map[callKey]*pendingCall // owned by the worker
+}
+
+// callKey identifies a call by its sender and serial.
+type callKey struct {
+	peer   string
+	serial uint32
+}
+
+type pendingCall struct {
+	entry audit.Entry
+	start time.Time
+}
+
+type auditEvent struct {
+	key  callKey
+	at   time.Time
+	call bool // an incoming call; otherwise its reply
+
+	// Calls
+	method  string
+	path    string
+	args    string
+	noReply bool
+	creds   *dbus.Call // GetConnectionCredentials of the sender, nil if not sent
+
+	// Replies
+	errName string
+	errMsg  string
+}
+
+// openAuditor opens the audit log in the state directory, or returns nil
+// if it cannot.
+func openAuditor() *auditor {
+	dir := history.StateDir()
+	if dir == "" {
+		log.Printf("[WARN] audit log unavailable: no state directory")
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		log.Printf("[WARN] audit log unavailable: %v", err)
+		return nil
+	}
+	l, err := audit.Open(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		log.Printf("[WARN] audit log unavailable: %v", err)
+		return nil
+	}
+	var pub ed25519.PublicKey
+	if key, err := auditKeyFromEnv(); err != nil {
+		log.Printf("[WARN] audit checkpoints are not signed: %v", err)
+	} else if key != nil {
+		l.SignWith(key)
+		pub = key.Public().(ed25519.PublicKey)
+	}
+	return &auditor{
+		log:     l,
+		pub:     pub,
+		events:  make(chan auditEvent, auditQueue),
+		pending: make(map[callKey]*pendingCall),
+	}
+}
+
+// auditKeyFromEnv reads the checkpoint signing key, returning nil if none
+// is configured.
+func auditKeyFromEnv() (ed25519.PrivateKey, error) {
+	path := os.Getenv(envAuditKey)
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return audit.ParseKey(strings.TrimSpace(string(data)))
+}
+
+// options returns the connection options installing the interceptors.
+func (a *auditor) options() []dbus.ConnOption {
+	if a == nil {
+		return nil
+	}
+	return []dbus.ConnOption{
+		dbus.WithIncomingInterceptor(a.incoming),
+		dbus.WithOutgoingInterceptor(a.outgoing),
+	}
+}
+
+// start begins logging the calls received on conn.
+func (a *auditor) start(conn *dbus.Conn) {
+	if a == nil {
+		return
+	}
+	a.conn.Store(conn)
+	go a.run()
+}
+
+// close closes the log; calls served afterwards are not written.
+func (a *auditor) close() {
+	if a == nil {
+		return
+	}
+	if n := a.dropped.Load(); n > 0 {
+		log.Printf("[WARN] %d calls were not audited because the audit queue was full", n)
+	}
+	a.log.Close()
+}
+
+func (a *auditor) incoming(msg *dbus.Message) {
+	if msg.Type != dbus.TypeMethodCall {
+		return
+	}
+	iface, _ := msg.Headers[dbus.FieldInterface].Value().(string)
+	if !strings.HasPrefix(iface, dbusconsts.Interface) {
+		return
+	}
+	sender, _ := msg.Headers[dbus.FieldSender].Value().(string)
+	path, _ := msg.Headers[dbus.FieldPath].Value().(dbus.ObjectPath)
+	member, _ := msg.Headers[dbus.FieldMember].Value().(string)
+	var creds *dbus.Call
+	if conn := a.conn.Load(); conn != nil {
+		creds = conn.BusObject().Go("org.freedesktop.DBus.GetConnectionCredentials", 0, nil, sender)
+	}
+	a.queue(auditEvent{
+		key:     callKey{sender, msg.Serial()},
+		at:      time.Now(),
+		call:    true,
+		method:  iface + "." + member,
+		path:    string(path),
+		args:    auditArgs(member, msg.Body),
+		noReply: msg.Flags&dbus.FlagNoReplyExpected != 0,
+		creds:   creds,
+	})
+}
+
+func (a *auditor) outgoing(msg *dbus.Message) {
+	if msg.Type != dbus.TypeMethodReply && msg.Type != dbus.TypeError {
+		return
+	}
+	dest, _ := msg.Headers[dbus.FieldDestination].Value().(string)
+	serial, _ := msg.Headers[dbus.FieldReplySerial].Value().(uint32)
+	ev := auditEvent{key: callKey{dest, serial}, at: time.Now()}
+	if msg.Type == dbus.TypeError {
+		ev.errName, _ = msg.Headers[dbus.FieldErrorName].Value().(string)
+		if len(msg.Body) > 0 {
+			ev.errMsg, _ = msg.Body[0].(string)
+		}
+	}
+	a.queue(ev)
+}
+
+func (a *auditor) queue(ev auditEvent) {
+	select {
+	case a.events <- ev:
+	default:
+		if a.dropped.Add(1) == 1 {
+			log.Printf("[WARN] audit queue full, calls go unaudited")
+		}
+	}
+}
+
+func (a *auditor) run() {
+	for ev := range a.events {
+		if ev.call {
+			uid, pid := credentials(ev.creds)
+			p := &pendingCall{start: ev.at, entry: audit.Entry{
+				Time:   ev.at.UTC(),
+				Method: ev.method,
+				Path:   ev.path,
+				Args:   ev.args,
+				Caller: ev.key.peer,
+				UID:    uid,
+				PID:    pid,
+			}}
+			if ev.noReply {
+				p.entry.Result = "ok"
+				a.write(p.entry)
+			} else if len(a.pending) < auditMaxPending {
+				a.pending[ev.key] = p
+			}
+			continue
+		}
+		p, ok := a.pending[ev.key]
+		if !ok {
+			continue // a reply to a call we do not audit
+		}
+		delete(a.pending, ev.key)
+		p.entry.Result = "ok"
+		if ev.errName != "" {
+			p.entry.Result, p.entry.Error = ev.errName, ev.errMsg
+		}
+		p.entry.DurationMs = ev.at.Sub(p.start).Milliseconds()
+		a.write(p.entry)
+	}
+}
+
+func (a *auditor) write(e audit.Entry) {
+	if err := a.log.Append(e); err != nil {
+		log.Printf("[WARN] failed to write audit entry: %v", err)
+	}
+}
+
+// credentials returns the uid and pid from a GetConnectionCredentials
+// call, or -1 for what could not be resolved.
+func credentials(call *dbus.Call) (int64, int64) {
+	uid, pid := int64(-1), int64(-1)
+	if call == nil {
+		return uid, pid
+	}
+	<-call.Done
+	var creds map[string]dbus.Variant
+	if call.Store(&creds) != nil {
+		return uid, pid
+	}
+	if v, ok := creds["UnixUserID"].Value().(uint32); ok {
+		uid = int64(v)
+	}
+	if v, ok := creds["ProcessID"].Value().(uint32); ok {
+		pid = int64(v)
+	}
+	return uid, pid
+}
+
+// auditArgs formats call arguments in GVariant text format.
+func auditArgs(member string, body []interface{}) string {
+	if len(body) == 0 {
+		return ""
+	}
+	if auditRedacted[member] {
+		return "<redacted>"
+	}
+	parts := make([]string, len(body))
+	for i, arg := range body {
+		parts[i] = dbus.MakeVariant(arg).String()
+	}
+	s := strings.Join(parts, ", ")
+	if len(s) > auditMaxArgs {
+		s = s[:auditMaxArgs] + "..."
+	}
+	return s
+}
+
+// GetAuditLog returns up to limit of the latest audited calls, newest
+// first (limit 0 means 100). Entries hold time (x, unix seconds),
+// method, path, args, caller, result, error (s), uid, pid and duration_ms
+// (x); uid and pid are -1 when unknown. Only the user the manager runs as
+// and root may read it.
+func (m *LinyapsManager) GetAuditLog(sender dbus.Sender, limit uint32) ([]map[string]dbus.Variant, *dbus.Error) {
+	if err := m.checkAuditReader(sender); err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	n := int(limit)
+	if n == 0 {
+		n = defaultAuditLimit
+	}
+	entries := m.audit.log.Recent(n)
+	out := make([]map[string]dbus.Variant, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, map[string]dbus.Variant{
+			"time":        dbus.MakeVariant(e.Time.Unix()),
+			"method":      dbus.MakeVariant(e.Method),
+			"path":        dbus.MakeVariant(e.Path),
+			"args":        dbus.MakeVariant(e.Args),
+			"caller":      dbus.MakeVariant(e.Caller),
+			"uid":         dbus.MakeVariant(e.UID),
+			"pid":         dbus.MakeVariant(e.PID),
+			"result":      dbus.MakeVariant(e.Result),
+			"error":       dbus.MakeVariant(e.Error),
+			"duration_ms": dbus.MakeVariant(e.DurationMs),
+		})
+	}
+	return out, nil
+}
+
+// VerifyAuditLog checks that the audit log was not altered: every entry
+// must match its hash and link to the one before it, and with a signing
+// key configured every checkpoint signature must verify. The result holds
+// ok (b), signed (b, whether checkpoints were checked), entries,
+// unchained (entries from before the log was chained), checkpoints,
+// signed_through (entries covered by the last checkpoint), broken_entry
+// (1-based, 0 if none) (u) and problem (s). Readers are limited as for
+// GetAuditLog.
+func (m *LinyapsManager) VerifyAuditLog(sender dbus.Sender) (map[string]dbus.Variant, *dbus.Error) {
+	if err := m.checkAuditReader(sender); err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	r, err := m.audit.log.Verify(m.audit.pub)
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	if r.Broken != 0 {
+		log.Printf("[WARN] audit log verification failed at entry %d: %s", r.Broken, r.Problem)
+	}
+	return map[string]dbus.Variant{
+		"ok":             dbus.MakeVariant(r.Broken == 0),
+		"signed":         dbus.MakeVariant(m.audit.pub != nil),
+		"entries":        dbus.MakeVariant(uint32(r.Entries)),
+		"unchained":      dbus.MakeVariant(uint32(r.Unchained)),
+		"checkpoints":    dbus.MakeVariant(uint32(r.Checkpoints)),
+		"signed_through": dbus.MakeVariant(uint32(r.SignedThrough)),
+		"broken_entry":   dbus.MakeVariant(uint32(r.Broken)),
+		"problem":        dbus.MakeVariant(r.Problem),
+	}, nil
+}
+
+// checkAuditReader allows the user the manager runs as and root to read
+// the audit log.
+func (m *LinyapsManager) checkAuditReader(sender dbus.Sender) error {
+	if m.audit == nil {
+		return errors.New("audit log is not available")
+	}
+	uid, err := polkit.SenderUID(m.conn, sender)
+	if err != nil {
+		return err
+	}
+	if uid != 0 && int(uid) != os.Getuid() {
+		return fmt.Errorf("uid %d may not read the audit log", uid)
+	}
+	return nil
+}
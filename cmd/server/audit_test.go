@@ -0,0 +1,32 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestAuditArgs(t *testing.T) {
+	tests := []struct {
+		member string
+		body   []interface{}
+		want   string
+	}{
+		{"Ping", nil, ""},
+		{"ExecuteCommand", []interface{}{"ll-cli", []string{"install", "org.a"}}, `"ll-cli", ["install", "org.a"]`},
+		{"ListVersions", []interface{}{"org.a", true}, `"org.a", true`},
+		{"ExecuteCommandWithOptions", []interface{}{map[string]dbus.Variant{"timeout": dbus.MakeVariant(uint32(5))}}, `{"timeout": <@u 5>}`},
+		{"RunWithToken", []interface{}{"secret"}, "<redacted>"},
+	}
+	for _, tt := range tests {
+		if got := auditArgs(tt.member, tt.body); got != tt.want {
+			t.Errorf("auditArgs(%s) = %s, want %s", tt.member, got, tt.want)
+		}
+	}
+
+	long := auditArgs("ExecuteCommand", []interface{}{strings.Repeat("x", 2*auditMaxArgs)})
+	if len(long) != auditMaxArgs+3 || !strings.HasSuffix(long, "...") {
+		t.Errorf("long args not truncated: %d bytes", len(long))
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/streaming"
+)
+
+// launchContainerWait bounds how long an install with autoLaunch waits for
+// the container of the app it started.
+const launchContainerWait = 10 * time.Second
+
+// Keys added to the Complete details of installs with autoLaunch.
+const (
+	detailLaunchOperationID = "launch_operation_id" // s: the operation running the app
+	detailContainerID       = "container_id"        // s: the app's container, if it showed up in time
+)
+
+// installAndLaunch runs the ll-cli install command line program args and,
+// once it succeeded, starts the app with ll-cli run as its own operation,
+// like RunWithArgs would, so it keeps running after the install completes.
+// A plain install of a ref that is already installed goes straight to the
+// launch. Complete reports the launch operation and the container it got.
+// installArgs are the ll-cli arguments before confinement.
+func (m *LinyapsManager) installAndLaunch(ctx context.Context, sender dbus.Sender, env []string, program string, args, installArgs []string) string {
+	return streaming.RunCommandTask(ctx, m.sink, program, args, func(ctx context.Context, out func(string, bool)) (map[string]interface{}, error) {
+		details := map[string]interface{}{}
+		ref, plain := plainInstall(installArgs)
+		if !plain {
+			ref, _ = llcli.ParseRef(firstPositional(installArgs[1:]))
+		}
+		if _, ok, err := m.installed.installed(ctx, ref); plain && err == nil && ok {
+			out(fmt.Sprintf("%s is already installed\n", ref), false)
+			details[detailAlreadyInstalled] = true
+		} else {
+			if err := streaming.RunChild(ctx, out, env, program, args...); err != nil {
+				return details, childExit(err)
+			}
+			m.installed.drop()
+		}
+
+		before := containersOf(ctx, ref.ID)
+		out(fmt.Sprintf("==> starting %s\n", ref.ID), false)
+		runRef := llcli.Ref{ID: ref.ID, Version: ref.Version}
+		opID, dbusErr := m.execute(sender, "ll-cli", []string{"run", runRef.String()}, execOptions{})
+		if dbusErr != nil {
+			return details, fmt.Errorf("installed, but cannot start %s: %v", ref.ID, dbusErr)
+		}
+		details[detailLaunchOperationID] = opID
+		if id := m.launchedContainer(ctx, ref.ID, opID, before); id != "" {
+			details[detailContainerID] = id
+			out(fmt.Sprintf("%s is running in container %s\n", ref.ID, id), false)
+		} else {
+			log.Printf("[WARN] no container of %s showed up within %s of launching it (opID=%s)", ref.ID, launchContainerWait, opID)
+		}
+		return details, nil
+	})
+}
+
+// containersOf returns the IDs of the running containers of appID.
+func containersOf(ctx context.Context, appID string) map[string]bool {
+	ids := make(map[string]bool)
+	containers, err := runningContainers(ctx)
+	if err != nil {
+		return ids
+	}
+	for _, c := range containers {
+		if c.Matches(appID) {
+			ids[c.ID] = true
+		}
+	}
+	return ids
+}
+
+// launchedContainer waits for a container of appID not in before to show
+// up while the launch operation opID runs, and returns its ID, or "" if
+// none did within launchContainerWait.
+func (m *LinyapsManager) launchedContainer(ctx context.Context, appID, opID string, before map[string]bool) string {
+	ctx, cancel := context.WithTimeout(ctx, launchContainerWait)
+	defer cancel()
+	ticker := time.NewTicker(exitPollInterval)
+	defer ticker.Stop()
+	for {
+		for id := range containersOf(ctx, appID) {
+			if !before[id] {
+				return id
+			}
+		}
+		if op, ok := streaming.DefaultRegistry.Lookup(opID); !ok || op.State != streaming.StateRunning {
+			return ""
+		}
+		select {
+		case <-ctx.Done():
+			return ""
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/cmdwhitelist"
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/lockout"
+	"linyapsmanager/internal/streaming"
+	"linyapsmanager/internal/telemetry"
+)
+
+// maxBatchRefs bounds the refs of one batch operation.
+const maxBatchRefs = 50
+
+// Keys added to the Complete details of batch operations.
+const (
+	detailSucceeded = "succeeded" // as: refs whose command succeeded
+	detailFailed    = "failed"    // as: refs whose command failed
+	detailNotRun    = "not_run"   // as: refs left out because the operation was cancelled
+
+	detailDependencies        = "dependencies"         // as: bases and runtimes installed ahead of the refs
+	detailRemovedDependencies = "removed_dependencies" // as: those uninstalled again, no ref needing them installed
+)
+
+// batchRunning is the UninstallBatch status of the refs of a batch still
+// running when the reply was due.
+const batchRunning = "running"
+
+// InstallBatchStream installs refs one after the other as a single
+// operation, with ll-cli install --force for each when force is set, and
+// returns its ID like ExecuteCommand. The bases and runtimes the refs need
+// that are not installed yet are installed first, once each, so apps
+// sharing them do not download them again. A failed install does not stop
+// the others, but an app whose dependency failed is not attempted, and
+// dependencies that no app of the batch ended up installed with are
+// uninstalled again at the end. Each install starts with an output line
+// "==> [i/n] ll-cli install <ref>" and runs under the limits and with the
+// history and telemetry entries of a single install.
+//
+// Complete details hold succeeded, failed and not_run (as) for refs, and
+// dependencies and removed_dependencies (as) for the bases and runtimes the
+// batch installed and removed again, besides the usual install details; the
+// exit code is 0 only if every install succeeded.
+func (m *LinyapsManager) InstallBatchStream(sender dbus.Sender, refs []string, force bool) (string, *dbus.Error) {
+	items, err := batchRefs(refs)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	if dbusErr := m.batchReady(); dbusErr != nil {
+		return "", dbusErr
+	}
+	var flags []string
+	if force {
+		flags = []string{"--force"}
+	}
+
+	opID, _ := m.startBatch(sender, "install", items, flags)
+	return opID, nil
+}
+
+// UninstallBatchStream removes refs one after the other as a single
+// operation and returns its ID, like InstallBatchStream.
+func (m *LinyapsManager) UninstallBatchStream(sender dbus.Sender, refs []string) (string, *dbus.Error) {
+	items, err := batchRefs(refs)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	if dbusErr := m.batchReady(); dbusErr != nil {
+		return "", dbusErr
+	}
+	opID, _ := m.startBatch(sender, "uninstall", items, nil)
+	return opID, nil
+}
+
+// UninstallBatch is UninstallBatchStream waiting for the batch, within the
+// reply budget of blocking methods, and returning one entry per ref holding
+// ref, status (s: succeeded, failed, not_run, or running if the batch was
+// not done in time) and error (s). With running entries the outcome comes
+// with the Complete signal of the returned operation, as for
+// UninstallBatchStream.
+func (m *LinyapsManager) UninstallBatch(sender dbus.Sender, refs []string) (string, []map[string]dbus.Variant, *dbus.Error) {
+	items, err := batchRefs(refs)
+	if err != nil {
+		return "", nil, dbus.MakeFailedError(err)
+	}
+	if dbusErr := m.batchReady(); dbusErr != nil {
+		return "", nil, dbusErr
+	}
+	ctx, cancel := replyContext()
+	defer cancel()
+	opID, done := m.startBatch(sender, "uninstall", items, nil)
+	var r batchResult
+	finished := true
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+		finished = false
+	}
+	entries := make([]map[string]dbus.Variant, 0, len(items))
+	for _, ref := range items {
+		status := detailNotRun
+		switch {
+		case !finished:
+			status = batchRunning
+		case slices.Contains(r.succeeded, ref):
+			status = detailSucceeded
+		case slices.Contains(r.failed, ref):
+			status = detailFailed
+		}
+		entries = append(entries, map[string]dbus.Variant{
+			"ref":    dbus.MakeVariant(ref),
+			"status": dbus.MakeVariant(status),
+			"error":  dbus.MakeVariant(r.errors[ref]),
+		})
+	}
+	return opID, entries, nil
+}
+
+// UpgradeSelectedStream upgrades appIDs one after the other as a single
+// operation and returns its ID, like InstallBatchStream, for updates the
+// user picked. Progress signals report the whole batch, each app taking an
+// equal part.
+func (m *LinyapsManager) UpgradeSelectedStream(sender dbus.Sender, appIDs []string) (string, *dbus.Error) {
+	items, err := batchRefs(appIDs)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	for _, appID := range items {
+		if ref, _ := llcli.ParseRef(appID); ref.String() != ref.ID {
+			return "", dbus.MakeFailedError(fmt.Errorf("invalid app id %q", appID))
+		}
+	}
+	if dbusErr := m.batchReady(); dbusErr != nil {
+		return "", dbusErr
+	}
+	opID, _ := m.startBatch(sender, "upgrade", items, nil)
+	return opID, nil
+}
+
+// batchReady refuses batches while the service is draining or the backend
+// is not ready.
+func (m *LinyapsManager) batchReady() *dbus.Error {
+	if m.draining.Load() {
+		return dbus.MakeFailedError(errors.New("service is being replaced by a new instance, retry"))
+	}
+	return m.ready.check()
+}
+
+// startBatch starts the operation running ll-cli subcmd for refs. It waits
+// in the job queue like a single install or uninstall and may run for
+// that timeout per ref. Progress is reported for the whole batch, each ref
+// taking an equal part. The result is sent on the returned channel once the
+// batch ran, or when it was cancelled while queued.
+func (m *LinyapsManager) startBatch(sender dbus.Sender, subcmd string, refs, flags []string) (string, <-chan batchResult) {
+	labels := map[string]string{"command": "ll-cli", "caller": string(sender), "operation": subcmd}
+	steps := len(refs)
+	if subcmd == "install" {
+		steps *= 3 // a base and a runtime may come before each app
+	}
+	ctx := m.operationContext(labels, timeoutFor(subcmd)*time.Duration(steps))
+	done := make(chan batchResult, 1)
+	if m.queue != nil {
+		ctx = streaming.WithGate(ctx, func(ctx context.Context, operationID string) (func(), error) {
+			release, err := m.queue.Acquire(ctx, operationID)
+			if err != nil {
+				done <- batchResult{notRun: refs}
+			}
+			return release, err
+		})
+	}
+	var opID string
+	started := make(chan struct{})
+	opID = streaming.RunCommandTask(ctx, m.sink, "ll-cli", append([]string{subcmd}, refs...), func(ctx context.Context, out func(string, bool)) (map[string]interface{}, error) {
+		<-started
+		b := &batchRun{opID: opID, caller: string(sender), out: out, total: len(refs)}
+		var r batchResult
+		var err error
+		if subcmd == "install" {
+			r, err = m.installBatch(ctx, b, refs, flags)
+		} else {
+			r, err = m.runBatch(ctx, b, subcmd, refs, flags)
+		}
+		if subcmd != "uninstall" {
+			// The lockout watcher only knows the ref of single-ref operations
+			for _, ref := range r.succeeded {
+				if appID := llcli.AppIDFromRef(ref); m.appDisabled(appID) {
+					if _, err := lockout.HideEntries(appID); err != nil {
+						log.Printf("[WARN] failed to hide entries of disabled %s: %v", appID, err)
+					}
+				}
+			}
+		}
+		done <- r
+		return r.details(), err
+	})
+	close(started)
+	log.Printf("[INFO] batch %s of %d refs started: opID=%s", subcmd, len(refs), opID)
+	return opID, done
+}
+
+// batchRefs validates the refs of a batch, which must be distinct.
+func batchRefs(refs []string) ([]string, error) {
+	if len(refs) == 0 || len(refs) > maxBatchRefs {
+		return nil, fmt.Errorf("want 1 to %d refs, got %d", maxBatchRefs, len(refs))
+	}
+	seen := make(map[string]bool, len(refs))
+	for _, s := range refs {
+		if _, err := llcli.ParseRef(s); err != nil {
+			return nil, err
+		}
+		if seen[s] {
+			return nil, fmt.Errorf("%s is listed twice", s)
+		}
+		seen[s] = true
+	}
+	return refs, nil
+}
+
+// batchResult tells what became of the refs of a batch.
+type batchResult struct {
+	succeeded, failed, notRun []string
+	errors                    map[string]string // why each failed ref failed
+
+	// dependencies and removedDeps are set by installBatch only.
+	dependencies, removedDeps []string
+}
+
+func (r batchResult) details() map[string]interface{} {
+	d := map[string]interface{}{
+		detailSucceeded: append([]string{}, r.succeeded...),
+		detailFailed:    append([]string{}, r.failed...),
+		detailNotRun:    append([]string{}, r.notRun...),
+	}
+	if r.dependencies != nil {
+		d[detailDependencies] = append([]string{}, r.dependencies...)
+		d[detailRemovedDependencies] = append([]string{}, r.removedDeps...)
+	}
+	return d
+}
+
+// batchRun is the state a batch operation shares across its runBatch calls.
+type batchRun struct {
+	opID, caller string
+	out          func(string, bool)
+	total        int // commands the batch is expected to run, for progress
+	started      int // commands started or skipped so far
+}
+
+// installBatch installs the bases and runtimes refs need that are missing,
+// then refs, and finally uninstalls the dependencies it installed that no
+// installed ref needs, as described for InstallBatchStream.
+func (m *LinyapsManager) installBatch(ctx context.Context, b *batchRun, refs, flags []string) (batchResult, error) {
+	deps, needs := m.batchDependencies(ctx, b.out, refs)
+	b.total = len(deps) + len(refs)
+	r := batchResult{errors: make(map[string]string), dependencies: []string{}, removedDeps: []string{}}
+
+	var failedDeps []string
+	if len(deps) > 0 {
+		b.out(fmt.Sprintf("==> installing %d shared dependencies\n", len(deps)), false)
+		dr, err := m.runBatch(ctx, b, "install", deps, nil)
+		r.dependencies, failedDeps = append(r.dependencies, dr.succeeded...), dr.failed
+		if ctx.Err() != nil {
+			r.notRun = refs
+			r.removedDeps = m.removeBatchDependencies(ctx, b, r.dependencies)
+			return r, err
+		}
+	}
+
+	apps := make([]string, 0, len(refs))
+	for _, ref := range refs {
+		if i := slices.IndexFunc(needs[ref], func(dep string) bool { return slices.Contains(failedDeps, dep) }); i >= 0 {
+			msg := fmt.Sprintf("dependency %s failed to install", needs[ref][i])
+			b.out(fmt.Sprintf("install %s skipped: %s\n", ref, msg), true)
+			r.failed = append(r.failed, ref)
+			r.errors[ref] = msg
+			b.started++
+			continue
+		}
+		apps = append(apps, ref)
+	}
+	ar, err := m.runBatch(ctx, b, "install", apps, flags)
+	r.succeeded, r.notRun = ar.succeeded, ar.notRun
+	r.failed = append(r.failed, ar.failed...)
+	for ref, msg := range ar.errors {
+		r.errors[ref] = msg
+	}
+
+	var unused []string
+	for _, dep := range r.dependencies {
+		if !slices.ContainsFunc(r.succeeded, func(ref string) bool { return slices.Contains(needs[ref], dep) }) {
+			unused = append(unused, dep)
+		}
+	}
+	r.removedDeps = m.removeBatchDependencies(ctx, b, unused)
+	if len(r.failed) > 0 && err == nil {
+		err = fmt.Errorf("%d of %d installs failed", len(r.failed), len(refs))
+	}
+	return r, err
+}
+
+// batchDependencies resolves the base and runtime of each ref and returns
+// the distinct ones not installed yet, bases first, and the ones each ref
+// needs. A ref that cannot be resolved is installed without its
+// dependencies being prepared.
+func (m *LinyapsManager) batchDependencies(ctx context.Context, out func(string, bool), refs []string) ([]string, map[string][]string) {
+	installed, err := m.installed.packages(ctx)
+	if err != nil {
+		out(fmt.Sprintf("cannot list installed packages, skipping shared dependencies: %v\n", err), true)
+		return nil, nil
+	}
+	var bases, runtimes []string
+	needs := make(map[string][]string, len(refs))
+	for _, s := range refs {
+		ref, _ := llcli.ParseRef(s)
+		app, err := m.resolveApp(ctx, ref)
+		if err != nil {
+			out(fmt.Sprintf("cannot resolve the dependencies of %s: %v\n", s, err), true)
+			continue
+		}
+		for _, dep := range []struct {
+			ref  string
+			list *[]string
+		}{{app.Base, &bases}, {app.Runtime, &runtimes}} {
+			r, err := llcli.ParseRef(dep.ref)
+			if dep.ref == "" || err != nil {
+				continue
+			}
+			if slices.ContainsFunc(installed, func(p llcli.Package) bool { return providesLayer(p, r) }) {
+				continue
+			}
+			needs[s] = append(needs[s], dep.ref)
+			if !slices.Contains(*dep.list, dep.ref) {
+				*dep.list = append(*dep.list, dep.ref)
+			}
+		}
+	}
+	return append(bases, runtimes...), needs
+}
+
+// removeBatchDependencies uninstalls deps, which installBatch installed
+// for refs that did not get installed. It runs even when the batch was
+// cancelled, and returns the deps it removed.
+func (m *LinyapsManager) removeBatchDependencies(ctx context.Context, b *batchRun, deps []string) []string {
+	if len(deps) == 0 {
+		return []string{}
+	}
+	b.out(fmt.Sprintf("==> removing %d dependencies no installed app needs\n", len(deps)), false)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeoutFor("uninstall")*time.Duration(len(deps)))
+	defer cancel()
+	r, _ := m.runBatch(ctx, b, "uninstall", deps, nil)
+	return append([]string{}, r.succeeded...)
+}
+
+// runBatch runs ll-cli subcmd for each ref in turn, each command line
+// checked against the whitelist and confined like ExecuteCommand, and
+// reports which succeeded. Each command is recorded in the history as
+// <opID>#<n>, n counting the commands of the whole batch, and, for
+// installs, reported to telemetry like a single operation. Progress counts
+// each command as an equal part of b.total.
+func (m *LinyapsManager) runBatch(ctx context.Context, b *batchRun, subcmd string, refs, flags []string) (batchResult, error) {
+	env := buildCommandEnv("ll-cli")
+	out := b.out
+	r := batchResult{errors: make(map[string]string)}
+	for i, ref := range refs {
+		if ctx.Err() != nil {
+			r.notRun = refs[i:]
+			return r, ctx.Err()
+		}
+		if m.emitter != nil {
+			m.emitter.ScaleProgress(b.opID, b.started, b.total)
+		}
+		b.started++
+		args := append(append([]string{subcmd}, flags...), ref)
+		out(fmt.Sprintf("==> [%d/%d] ll-cli %s %s\n", i+1, len(refs), subcmd, ref), false)
+		start := time.Now()
+		labels := map[string]string{"command": "ll-cli", "caller": b.caller, "operation": subcmd, "ref": ref}
+		program, validated, err := cmdwhitelist.ValidateCommand("ll-cli", args)
+		if err == nil {
+			var policy map[string]string
+			program, validated, policy = confineLLCli(program, validated)
+			for k, v := range policy {
+				labels[k] = v
+			}
+			err = streaming.RunChild(ctx, out, env, program, validated...)
+		}
+		m.recordBatchItem(ctx, fmt.Sprintf("%s#%d", b.opID, b.started), labels, start, err)
+		if err != nil {
+			out(fmt.Sprintf("%s %s failed: %v\n", subcmd, ref, err), true)
+			r.failed = append(r.failed, ref)
+			r.errors[ref] = err.Error()
+			continue
+		}
+		r.succeeded = append(r.succeeded, ref)
+	}
+	if len(r.failed) > 0 {
+		return r, fmt.Errorf("%d of %d %ss failed", len(r.failed), len(refs), subcmd)
+	}
+	return r, nil
+}
+
+// recordBatchItem adds the history entry of one command of a batch and
+// reports installs to telemetry, as the sinks do for single operations.
+func (m *LinyapsManager) recordBatchItem(ctx context.Context, id string, labels map[string]string, start time.Time, err error) {
+	state, exitCode, msg := streaming.StateCompleted, 0, ""
+	if err != nil {
+		state, exitCode, msg = streaming.StateFailed, 1, err.Error()
+		var exitErr *streaming.ExitError
+		if errors.As(err, &exitErr) {
+			exitCode = exitErr.Code
+		}
+		if ctx.Err() != nil {
+			state = streaming.StateCancelled
+		}
+	}
+	if m.history != nil {
+		end := time.Now()
+		e := historyEntry(streaming.Operation{
+			ID:        id,
+			Labels:    labels,
+			State:     state,
+			ExitCode:  exitCode,
+			ErrorMsg:  msg,
+			StartTime: start,
+			EndTime:   end,
+		})
+		e.DurationMs = end.Sub(start).Milliseconds()
+		if err := m.history.Append(e); err != nil {
+			log.Printf("[WARN] failed to record %s in history: %v", id, err)
+		}
+	}
+	if m.telemetry != nil && labels["operation"] == "install" && state != streaming.StateCancelled && m.telemetry.Consent() {
+		ev := telemetry.Event{Kind: "install", Ref: labels["ref"], Success: err == nil, ExitCode: exitCode}
+		if err := m.telemetry.Submit(ev); err != nil {
+			log.Printf("[WARN] telemetry: %v", err)
+		}
+	}
+}
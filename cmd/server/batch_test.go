@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestBatchRefs(t *testing.T) {
+	if _, err := batchRefs([]string{"org.example.a", "main:org.example.b/1.0"}); err != nil {
+		t.Errorf("batchRefs: %v", err)
+	}
+	for _, bad := range [][]string{
+		nil,
+		{"org.example.a", "--force"},
+		{"org.example.a", "org.example.a"},
+		make([]string, maxBatchRefs+1),
+	} {
+		if _, err := batchRefs(bad); err == nil {
+			t.Errorf("batchRefs(%q) succeeded", bad)
+		}
+	}
+}
+
+func TestRunBatchCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	refs := []string{"org.example.a", "org.example.b"}
+	b := &batchRun{opID: "op", out: func(string, bool) {}, total: len(refs)}
+	r, err := (&LinyapsManager{}).runBatch(ctx, b, "install", refs, nil)
+	if err == nil {
+		t.Error("cancelled batch succeeded")
+	}
+	if got := r.details()[detailNotRun]; !reflect.DeepEqual(got, refs) {
+		t.Errorf("not_run = %v, want %v", got, refs)
+	}
+}
+
+func TestBatchResultDetails(t *testing.T) {
+	if _, ok := (batchResult{}).details()[detailDependencies]; ok {
+		t.Error("details of a batch without dependency resolution list dependencies")
+	}
+	r := batchResult{dependencies: []string{"main:org.deepin.base/23.1.0/x86_64"}, removedDeps: []string{}}
+	d := r.details()
+	if got := d[detailDependencies]; !reflect.DeepEqual(got, r.dependencies) {
+		t.Errorf("dependencies = %v, want %v", got, r.dependencies)
+	}
+	if got := d[detailRemovedDependencies]; !reflect.DeepEqual(got, []string{}) {
+		t.Errorf("removed_dependencies = %v, want []", got)
+	}
+}
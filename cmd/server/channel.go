@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/streaming"
+)
+
+// SwitchChannel moves the installed appID to the newest version published in
+// channel. ll-cli cannot change the channel of an installed app, so the app
+// is uninstalled and installed again from the new channel as one streamed
+// operation ("switch-channel"); if that install fails the previous version
+// is reinstalled from its old channel.
+//
+// Complete details hold from_channel, to_channel, from_version, to_version
+// (s) and rolled_back (b).
+func (m *LinyapsManager) SwitchChannel(sender dbus.Sender, appID, channel string) (string, *dbus.Error) {
+	if m.draining.Load() {
+		return "", dbus.MakeFailedError(errors.New("service is being replaced by a new instance, retry"))
+	}
+	target, err := llcli.ParseRef(channel + ":" + appID)
+	if err != nil || target.Version != "" {
+		return "", dbus.MakeFailedError(fmt.Errorf("invalid app id %q or channel %q", appID, channel))
+	}
+	if dbusErr := m.ready.check(); dbusErr != nil {
+		return "", dbusErr
+	}
+
+	ctx, cancel := replyContext()
+	defer cancel()
+	installed, err := m.installed.binaries(ctx, appID)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	if len(installed) == 0 {
+		return "", dbus.MakeFailedError(fmt.Errorf("%s is not installed", appID))
+	}
+	current := installed[0]
+	if current.Channel == channel {
+		return "", dbus.MakeFailedError(fmt.Errorf("%s is already installed from channel %s", appID, channel))
+	}
+
+	remote, err := remoteVersions(ctx, appID)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	for _, p := range remote {
+		if p.Channel == channel && (p.Module == "" || p.Module == "binary") &&
+			(target.Version == "" || llcli.CompareVersions(p.Version, target.Version) > 0) {
+			target.Version = p.Version
+		}
+	}
+	if target.Version == "" {
+		return "", dbus.MakeFailedError(fmt.Errorf("%s is not available in channel %s", appID, channel))
+	}
+	from := llcli.Ref{Channel: current.Channel, ID: appID, Version: current.Version}
+
+	labels := map[string]string{
+		"command":   "ll-cli",
+		"caller":    string(sender),
+		"operation": "switch-channel",
+		"ref":       target.String(),
+	}
+	ctx = m.operationContext(labels, timeoutFor("switch-channel"))
+	opID := streaming.RunDetailedTask(ctx, m.sink, "ll-cli", func(ctx context.Context, out func(string, bool)) (map[string]interface{}, error) {
+		rolledBack, err := replaceRef(ctx, out, from, target)
+		return map[string]interface{}{
+			"from_channel": from.Channel,
+			"to_channel":   target.Channel,
+			"from_version": from.Version,
+			"to_version":   target.Version,
+			"rolled_back":  rolledBack,
+		}, err
+	})
+	log.Printf("[INFO] switch channel %s -> %s started: opID=%s", from, target, opID)
+	return opID, nil
+}
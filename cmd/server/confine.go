@@ -0,0 +1,74 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"linyapsmanager/internal/limits"
+	"linyapsmanager/internal/llcli"
+)
+
+// confineLLCli wraps an ll-cli command line so the child runs under the
+// resource limits of its operation type. App launches (ll-cli run) are placed
+// in their own transient scope instead, named after the app so session
+// managers can track them and container exits map to scope stop events.
+// The returned labels describe the policy that was applied.
+func confineLLCli(program string, args []string) (string, []string, map[string]string) {
+	subcmd, rest := llcliSubcommand(args)
+	labels := map[string]string{"operation": subcmd}
+	switch subcmd {
+	case "install", "uninstall", "upgrade", "run":
+		// ll-cli install also takes a layer or uab file, which is no ref
+		if ref := firstPositional(rest); ref != "" {
+			if _, err := llcli.ParseRef(ref); err == nil {
+				labels["ref"] = ref
+			}
+		}
+	}
+	if subcmd == "run" {
+		if appID := firstPositional(rest); appID != "" {
+			unit := limits.AppScopeName(appID)
+			wrappedProgram, wrappedArgs := limits.WrapScope(unit, program, args)
+			if wrappedProgram != program {
+				log.Printf("[INFO] launching %s in scope %s", appID, unit)
+				labels["scope"] = unit
+			}
+			return wrappedProgram, wrappedArgs, labels
+		}
+	}
+
+	l := limits.ForOperation(subcmd)
+	if l.IsZero() {
+		return program, args, labels
+	}
+	wrappedProgram, wrappedArgs := limits.Wrap(l, program, args)
+	if wrappedProgram != program {
+		log.Printf("[INFO] applying limits %s via %s", l, wrappedProgram)
+		labels["limits"] = l.String()
+	}
+	return wrappedProgram, wrappedArgs, labels
+}
+
+// llcliSubcommand returns the first non-flag argument of an ll-cli command line
+// and the arguments following it, or limits.DefaultOperation if there is none.
+func llcliSubcommand(args []string) (string, []string) {
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			return arg, args[i+1:]
+		}
+	}
+	return limits.DefaultOperation, nil
+}
+
+// firstPositional returns the first argument that is not a flag, stopping at "--".
+func firstPositional(args []string) string {
+	for _, arg := range args {
+		if arg == "--" {
+			return ""
+		}
+		if !strings.HasPrefix(arg, "-") {
+			return arg
+		}
+	}
+	return ""
+}
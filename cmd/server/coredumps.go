@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/coredump"
+	"linyapsmanager/internal/history"
+	"linyapsmanager/internal/limits"
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/streaming"
+)
+
+// maxListedCrashes bounds the result of ListCrashes.
+const maxListedCrashes = 20
+
+// tagLaunch adds the version label to a running ll-cli run operation. The
+// operation's scope label already names the transient unit systemd-coredump
+// records for the app's processes, so together with history they tie a
+// coredump to the app and version that produced it.
+func (m *LinyapsManager) tagLaunch(opID, ref string) {
+	r, err := llcli.ParseRef(ref)
+	if err != nil {
+		return
+	}
+	version := r.Version
+	if version == "" {
+		pkg, ok, err := m.installed.installed(context.Background(), r)
+		if err != nil || !ok {
+			return
+		}
+		version = pkg.Version
+	}
+	streaming.DefaultRegistry.SetLabel(opID, "version", version)
+}
+
+// ListCrashes returns recent coredumps of apps launched through the manager,
+// newest first, for appID or for all apps if appID is empty. It needs
+// systemd-coredump and read access to its journal entries.
+//
+// Entries hold time (x, unix seconds), pid (i), signal, exe, unit, corefile
+// (path of the stored core, empty if not kept), app_id, version and
+// operation_id (s, the launch operation; empty if history does not know it).
+func (m *LinyapsManager) ListCrashes(appID string) ([]map[string]dbus.Variant, *dbus.Error) {
+	if appID != "" {
+		if r, err := llcli.ParseRef(appID); err != nil || r.String() != r.ID {
+			return nil, dbus.MakeFailedError(fmt.Errorf("invalid app id %q", appID))
+		}
+	}
+	ctx, cancel := replyContext()
+	defer cancel()
+	prefix := limits.AppScopePrefix(appID)
+	dumps, err := coredump.List(ctx, prefix, maxListedCrashes)
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+
+	launches := map[string]history.Entry{}
+	if m.history != nil && len(dumps) > 0 {
+		entries, _, err := m.history.Query(history.Query{AppID: appID, Limit: history.MaxLimit})
+		if err != nil {
+			log.Printf("[WARN] crashes listed without history: %v", err)
+		}
+		for _, e := range entries {
+			if e.Scope != "" {
+				launches[e.Scope] = e
+			}
+		}
+	}
+
+	out := make([]map[string]dbus.Variant, 0, len(dumps))
+	for _, d := range dumps {
+		launch, ok := launches[d.Unit]
+		if !ok {
+			launch.AppID = appIDFromScope(d.Unit)
+		}
+		out = append(out, map[string]dbus.Variant{
+			"time":         dbus.MakeVariant(d.Time.Unix()),
+			"pid":          dbus.MakeVariant(int32(d.PID)),
+			"signal":       dbus.MakeVariant(d.Signal),
+			"exe":          dbus.MakeVariant(d.Exe),
+			"unit":         dbus.MakeVariant(d.Unit),
+			"corefile":     dbus.MakeVariant(d.Filename),
+			"app_id":       dbus.MakeVariant(launch.AppID),
+			"version":      dbus.MakeVariant(launch.Version),
+			"operation_id": dbus.MakeVariant(launch.ID),
+		})
+	}
+	return out, nil
+}
+
+// appIDFromScope recovers the (unit-escaped) app ID from a scope name
+// returned by limits.AppScopeName.
+func appIDFromScope(unit string) string {
+	s := strings.TrimPrefix(strings.TrimSuffix(unit, ".scope"), limits.AppScopePrefix(""))
+	if i := strings.LastIndexByte(s, '-'); i > 0 {
+		return s[:i]
+	}
+	return s
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/history"
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/streaming"
+)
+
+const (
+	// crashWindow is how soon after launch a failing exit counts as a crash
+	// rather than the app being closed with an error later on.
+	crashWindow = 10 * time.Second
+	// crashOutputLimit bounds the output kept per launch; the tail is kept.
+	crashOutputLimit = 256 * 1024
+	// maxCrashReports is how many report directories are kept.
+	maxCrashReports = 20
+)
+
+// crashEnvKeys are the environment variables recorded in crash reports,
+// the ones that usually decide whether a GUI app can start at all.
+var crashEnvKeys = []string{
+	"DISPLAY", "WAYLAND_DISPLAY", "XDG_SESSION_TYPE", "XDG_CURRENT_DESKTOP",
+	"XDG_RUNTIME_DIR", "DBUS_SESSION_BUS_ADDRESS", "LANG", "LANGUAGE", "LC_ALL",
+	"QT_QPA_PLATFORM", "GDK_BACKEND", "XAUTHORITY",
+}
+
+// crashReport is written as report.json next to output.log.
+type crashReport struct {
+	OperationID string                 `json:"operation_id"`
+	Ref         string                 `json:"ref"`
+	AppID       string                 `json:"app_id"`
+	ExitCode    int                    `json:"exit_code"`
+	Error       string                 `json:"error,omitempty"`
+	Details     map[string]interface{} `json:"details,omitempty"`
+	StartTime   time.Time              `json:"start_time"`
+	DurationMs  int64                  `json:"duration_ms"`
+	Environment map[string]string      `json:"environment"`
+}
+
+// crashSink keeps the output of ll-cli run operations during their first
+// crashWindow. When one exits nonzero within that window it writes a crash
+// report directory and emits AppCrashed(appID, reportPath).
+type crashSink struct {
+	streaming.OutputSink
+	dir  string
+	emit func(appID, reportPath string) error
+
+	mu   sync.Mutex
+	logs map[string]*crashLog // nil entry: not tracked
+}
+
+// crashLog is the output of one launch so far.
+type crashLog struct {
+	start time.Time
+	out   strings.Builder
+}
+
+func newCrashSink(next streaming.OutputSink, conn *dbus.Conn) *crashSink {
+	s := &crashSink{OutputSink: next, logs: make(map[string]*crashLog)}
+	if dir := history.StateDir(); dir != "" {
+		s.dir = filepath.Join(dir, "crashes")
+	}
+	s.emit = func(appID, reportPath string) error {
+		return conn.Emit(dbusconsts.ObjectPath, dbusconsts.Interface+"."+dbusconsts.SignalAppCrashed, appID, reportPath)
+	}
+	return s
+}
+
+func (s *crashSink) EmitOutput(operationID, data string, isStderr bool) error {
+	s.mu.Lock()
+	if l := s.logLocked(operationID); l != nil {
+		if time.Since(l.start) > crashWindow {
+			// Running long enough to not be a launch failure
+			s.logs[operationID] = nil
+		} else {
+			if isStderr {
+				l.out.WriteString("[stderr] ")
+			}
+			l.out.WriteString(data)
+			if l.out.Len() > crashOutputLimit {
+				tail := l.out.String()[l.out.Len()-crashOutputLimit/2:]
+				l.out.Reset()
+				l.out.WriteString(tail)
+			}
+		}
+	}
+	s.mu.Unlock()
+	return s.OutputSink.EmitOutput(operationID, data, isStderr)
+}
+
+func (s *crashSink) EmitComplete(operationID string, exitCode int, errorMsg string, details map[string]interface{}) error {
+	s.mu.Lock()
+	l := s.logLocked(operationID)
+	delete(s.logs, operationID)
+	s.mu.Unlock()
+
+	err := s.OutputSink.EmitComplete(operationID, exitCode, errorMsg, details)
+	if l != nil && exitCode != 0 {
+		if op, ok := streaming.DefaultRegistry.Lookup(operationID); ok && op.Duration() <= crashWindow {
+			s.report(op, exitCode, errorMsg, details, l.out.String())
+		}
+	}
+	return err
+}
+
+// logLocked returns the output log of an operation, creating it on first
+// use, or nil if the operation is not an ll-cli run.
+func (s *crashSink) logLocked(operationID string) *crashLog {
+	l, ok := s.logs[operationID]
+	if !ok {
+		if op, found := streaming.DefaultRegistry.Lookup(operationID); found && s.dir != "" &&
+			op.Labels["command"] == "ll-cli" && op.Labels["operation"] == "run" {
+			l = &crashLog{start: op.StartTime}
+		}
+		s.logs[operationID] = l
+	}
+	return l
+}
+
+func (s *crashSink) report(op streaming.Operation, exitCode int, errorMsg string, details map[string]interface{}, output string) {
+	ref := op.Labels["ref"]
+	appID := llcli.AppIDFromRef(ref)
+	if appID == "" {
+		appID = "unknown"
+	}
+	path, err := s.writeReport(op, appID, exitCode, errorMsg, details, output)
+	if err != nil {
+		log.Printf("[WARN] failed to write crash report for %s: %v", op.ID, err)
+		return
+	}
+	log.Printf("[INFO] %s exited with %d %s after launch, report at %s", appID, exitCode, op.Duration().Round(time.Millisecond), path)
+	if err := s.emit(appID, path); err != nil {
+		log.Printf("[WARN] failed to emit %s: %v", dbusconsts.SignalAppCrashed, err)
+	}
+}
+
+func (s *crashSink) writeReport(op streaming.Operation, appID string, exitCode int, errorMsg string, details map[string]interface{}, output string) (string, error) {
+	suffix := strings.TrimPrefix(op.ID, "op-")
+	if len(suffix) > 8 {
+		suffix = suffix[:8]
+	}
+	name := fmt.Sprintf("%s-%s-%s", appID, op.StartTime.Format("20060102-150405"), suffix)
+	path := filepath.Join(s.dir, name)
+	if err := os.MkdirAll(path, 0o700); err != nil {
+		return "", err
+	}
+
+	env := map[string]string{}
+	for _, kv := range buildCommandEnv("ll-cli") {
+		k, v, _ := strings.Cut(kv, "=")
+		for _, key := range crashEnvKeys {
+			if k == key {
+				env[k] = v
+			}
+		}
+	}
+	report := crashReport{
+		OperationID: op.ID,
+		Ref:         op.Labels["ref"],
+		AppID:       appID,
+		ExitCode:    exitCode,
+		Error:       errorMsg,
+		Details:     details,
+		StartTime:   op.StartTime,
+		DurationMs:  op.Duration().Milliseconds(),
+		Environment: env,
+	}
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(path, "report.json"), append(data, '\n'), 0o600); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(path, "output.log"), []byte(output), 0o600); err != nil {
+		return "", err
+	}
+	pruneCrashReports(s.dir, maxCrashReports)
+	return path, nil
+}
+
+// pruneCrashReports removes the oldest report directories beyond keep.
+func pruneCrashReports(dir string, keep int) {
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) <= keep {
+		return
+	}
+	type report struct {
+		name string
+		mod  time.Time
+	}
+	reports := make([]report, 0, len(entries))
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && e.IsDir() {
+			reports = append(reports, report{e.Name(), info.ModTime()})
+		}
+	}
+	sort.Slice(reports, func(i, j int) bool { return reports[i].mod.After(reports[j].mod) })
+	for _, r := range reports[min(keep, len(reports)):] {
+		if err := os.RemoveAll(filepath.Join(dir, r.name)); err != nil {
+			log.Printf("[WARN] failed to prune crash report %s: %v", r.name, err)
+		}
+	}
+}
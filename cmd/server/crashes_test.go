@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"linyapsmanager/internal/streaming"
+)
+
+func TestCrashSinkReportsEarlyFailure(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	rec := &completeRecorder{done: make(chan map[string]interface{}, 1)}
+	sink := newCrashSink(rec, nil)
+	crashed := make(chan [2]string, 1)
+	sink.emit = func(appID, reportPath string) error {
+		crashed <- [2]string{appID, reportPath}
+		return nil
+	}
+
+	labels := map[string]string{"command": "ll-cli", "operation": "run", "ref": "org.example.app"}
+	ctx := streaming.WithLabels(context.Background(), labels)
+	script := `echo "starting"; echo "cannot open display" >&2; exit 3`
+	if _, err := streaming.RunCommandStreaming(ctx, sink, nil, "/bin/sh", "-c", script); err != nil {
+		t.Fatal(err)
+	}
+
+	var got [2]string
+	select {
+	case got = <-crashed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("AppCrashed was not emitted")
+	}
+	if got[0] != "org.example.app" {
+		t.Errorf("appID = %q", got[0])
+	}
+	output, err := os.ReadFile(filepath.Join(got[1], "output.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// stdout and stderr are read concurrently, so only each line is ordered
+	for _, line := range []string{"starting\n", "[stderr] cannot open display\n"} {
+		if !strings.Contains(string(output), line) {
+			t.Errorf("output.log = %q, missing %q", output, line)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(got[1], "report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report crashReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatal(err)
+	}
+	if report.ExitCode != 3 || report.AppID != "org.example.app" {
+		t.Errorf("report = %+v", report)
+	}
+	if len(sink.logs) != 0 {
+		t.Errorf("logs leaked: %v", sink.logs)
+	}
+}
+
+func TestCrashSinkIgnoresOtherOperations(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	rec := &completeRecorder{done: make(chan map[string]interface{}, 1)}
+	sink := newCrashSink(rec, nil)
+	sink.emit = func(appID, reportPath string) error {
+		t.Errorf("unexpected AppCrashed(%s, %s)", appID, reportPath)
+		return nil
+	}
+
+	labels := map[string]string{"command": "ll-cli", "operation": "install", "ref": "org.example.app"}
+	ctx := streaming.WithLabels(context.Background(), labels)
+	if _, err := streaming.RunCommandStreaming(ctx, sink, nil, "/bin/sh", "-c", "exit 1"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-rec.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("operation did not complete")
+	}
+}
+
+func TestPruneCrashReports(t *testing.T) {
+	dir := t.TempDir()
+	base := time.Now()
+	for i, name := range []string{"a", "b", "c"} {
+		path := filepath.Join(dir, name)
+		if err := os.Mkdir(path, 0o700); err != nil {
+			t.Fatal(err)
+		}
+		mod := base.Add(time.Duration(i) * time.Minute)
+		if err := os.Chtimes(path, mod, mod); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pruneCrashReports(dir, 2)
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if strings.Join(names, ",") != "b,c" {
+		t.Errorf("kept %v, want [b c]", names)
+	}
+}
+
+func TestAppIDFromScope(t *testing.T) {
+	for unit, want := range map[string]string{
+		"app-linglong-org.example.app-3.scope":      "org.example.app",
+		"app-linglong-org.example_x-12.scope":       "org.example_x",
+		"app-linglong-org.example.app-4242.7.scope": "org.example.app",
+	} {
+		if got := appIDFromScope(unit); got != want {
+			t.Errorf("appIDFromScope(%q) = %q, want %q", unit, got, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/llcli"
+)
+
+// GetDependencies returns the base and runtime appID needs, for the given
+// version or else the newest one, installed or not. They come from ll-cli
+// info for an installed app and from the repository otherwise. Each entry
+// holds:
+//   - kind (s): "base" or "runtime"
+//   - ref (s): the layer as the app names it, e.g.
+//     main:org.deepin.base/23.1.0/x86_64
+//   - installed (b): whether a version satisfying ref is installed
+//   - installed_version (s): the newest such version, or empty
+//   - size (t): download size the repository reports, 0 when installed or
+//     unknown
+func (m *LinyapsManager) GetDependencies(sender dbus.Sender, appID, version string) ([]map[string]dbus.Variant, *dbus.Error) {
+	s, err := packageRef(appID, version)
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	ref, _ := llcli.ParseRef(s)
+	if m.appHidden(sender, appID) {
+		return nil, dbus.MakeFailedError(fmt.Errorf("%s not found", s))
+	}
+	if dbusErr := m.ready.check(); dbusErr != nil {
+		return nil, dbusErr
+	}
+	ctx, cancel := replyContext()
+	defer cancel()
+
+	app, err := m.resolveApp(ctx, ref)
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	installed, err := m.installed.packages(ctx)
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	entries := []map[string]dbus.Variant{}
+	for _, dep := range []struct{ kind, ref string }{{"base", app.Base}, {"runtime", app.Runtime}} {
+		if dep.ref == "" {
+			continue
+		}
+		r, err := llcli.ParseRef(dep.ref)
+		if err != nil {
+			return nil, dbus.MakeFailedError(fmt.Errorf("%s of %s: %w", dep.kind, s, err))
+		}
+		have := ""
+		for _, p := range installed {
+			if providesLayer(p, r) && (have == "" || llcli.CompareVersions(p.Version, have) > 0) {
+				have = p.Version
+			}
+		}
+		var size uint64
+		if have == "" {
+			size = remoteSize(ctx, r)
+		}
+		entries = append(entries, map[string]dbus.Variant{
+			"kind":              dbus.MakeVariant(dep.kind),
+			"ref":               dbus.MakeVariant(dep.ref),
+			"installed":         dbus.MakeVariant(have != ""),
+			"installed_version": dbus.MakeVariant(have),
+			"size":              dbus.MakeVariant(size),
+		})
+	}
+	return entries, nil
+}
+
+// resolveApp returns the newest installed binary module of the app ref
+// refers to, with its base and runtime filled in by ll-cli info when the
+// list lacks them, or else the newest one in the repository.
+func (m *LinyapsManager) resolveApp(ctx context.Context, ref llcli.Ref) (llcli.Package, error) {
+	pkgs, err := m.installed.binaries(ctx, ref.ID)
+	if err != nil {
+		return llcli.Package{}, err
+	}
+	for _, p := range pkgs {
+		if !p.Matches(ref) {
+			continue
+		}
+		if p.Base != "" || p.Runtime != "" {
+			return p, nil
+		}
+		out, err := llcliOutput(ctx, "info", p.ID+"/"+p.Version, "--json")
+		if err != nil {
+			return llcli.Package{}, err
+		}
+		return llcli.ParseInfo(out)
+	}
+
+	remote, err := remoteVersions(ctx, ref.ID)
+	if err != nil {
+		return llcli.Package{}, err
+	}
+	if p, ok := newestBinary(remote, ref); ok {
+		return p, nil
+	}
+	return llcli.Package{}, fmt.Errorf("%s not found", ref)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/history"
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/lockout"
+	"linyapsmanager/internal/polkit"
+	"linyapsmanager/internal/streaming"
+)
+
+// manageAppsAction is the polkit action guarding DisableApp and EnableApp.
+const manageAppsAction = "org.linglong_store.LinyapsManager.manage-apps"
+
+var errLockoutDisabled = errors.New("disabling apps is not available")
+
+// openLockout loads the set of disabled apps and re-hides the desktop
+// entries of disabled apps whenever an install or upgrade exports them again.
+func openLockout() *lockout.Store {
+	dir := history.StateDir()
+	if dir == "" {
+		log.Printf("[WARN] disabling apps unavailable: no state directory")
+		return nil
+	}
+	store, err := lockout.Open(filepath.Join(dir, "disabled.json"))
+	if err != nil {
+		log.Printf("[WARN] disabling apps unavailable: %v", err)
+		return nil
+	}
+	streaming.DefaultRegistry.Watch(func(op streaming.Operation) {
+		if op.State == streaming.StateRunning || op.Labels["command"] != "ll-cli" {
+			return
+		}
+		switch op.Labels["operation"] {
+		case "install", "upgrade", "downgrade", "switch-channel":
+		default:
+			return
+		}
+		if appID := llcli.AppIDFromRef(op.Labels["ref"]); store.Disabled(appID) {
+			if _, err := lockout.HideEntries(appID); err != nil {
+				log.Printf("[WARN] failed to hide entries of disabled %s: %v", appID, err)
+			}
+		}
+	})
+	return store
+}
+
+// appDisabled reports whether appID may not be started.
+func (m *LinyapsManager) appDisabled(appID string) bool {
+	return m.lockout != nil && m.lockout.Disabled(appID)
+}
+
+// appDisabledError is the structured error for starting a disabled app.
+func appDisabledError(appID string) *dbus.Error {
+	return dbus.NewError(dbusconsts.ErrorAppDisabled, []interface{}{fmt.Sprintf("%s is disabled", appID)})
+}
+
+// DisableApp hides appID from application menus and refuses to run it,
+// without removing its data or layers. Running instances are left alone.
+// The caller needs the manage-apps polkit authorization.
+func (m *LinyapsManager) DisableApp(sender dbus.Sender, appID string) *dbus.Error {
+	if dbusErr := m.checkManageApps(sender, appID, true); dbusErr != nil {
+		return dbusErr
+	}
+	hidden, err := m.lockout.Disable(appID)
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	log.Printf("[INFO] %s disabled by %s, hid %d desktop entries", appID, sender, len(hidden))
+	return nil
+}
+
+// EnableApp undoes DisableApp. Unlike DisableApp it is refused when polkit
+// cannot be reached, so a lockout cannot be lifted without authorization.
+func (m *LinyapsManager) EnableApp(sender dbus.Sender, appID string) *dbus.Error {
+	if dbusErr := m.checkManageApps(sender, appID, false); dbusErr != nil {
+		return dbusErr
+	}
+	if err := m.lockout.Enable(appID); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	log.Printf("[INFO] %s enabled by %s", appID, sender)
+	return nil
+}
+
+// ListDisabledApps returns the IDs of the disabled apps, sorted.
+func (m *LinyapsManager) ListDisabledApps() ([]string, *dbus.Error) {
+	if m.lockout == nil {
+		return nil, dbus.MakeFailedError(errLockoutDisabled)
+	}
+	return m.lockout.List(), nil
+}
+
+// checkManageApps validates appID and authorizes the caller, letting polkit
+// ask for authentication. failOpen allows the call when polkit is
+// unavailable.
+func (m *LinyapsManager) checkManageApps(sender dbus.Sender, appID string, failOpen bool) *dbus.Error {
+	if m.lockout == nil {
+		return dbus.MakeFailedError(errLockoutDisabled)
+	}
+	if ref, err := llcli.ParseRef(appID); err != nil || ref.String() != ref.ID {
+		return dbus.MakeFailedError(fmt.Errorf("invalid app id %q", appID))
+	}
+	ok, err := polkit.CheckSenderInteractive(m.conn, sender, manageAppsAction)
+	switch {
+	case errors.Is(err, polkit.ErrUnavailable) && failOpen:
+		log.Printf("[WARN] %v, allowing %s", err, manageAppsAction)
+		return nil
+	case err != nil:
+		return dbus.MakeFailedError(err)
+	case !ok:
+		return dbus.MakeFailedError(fmt.Errorf("not authorized for %s", manageAppsAction))
+	}
+	return nil
+}
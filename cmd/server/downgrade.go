@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/streaming"
+)
+
+// Warning is a structured caveat returned before a risky operation
+// (D-Bus signature (ss)).
+type Warning struct {
+	Code    string
+	Message string
+}
+
+// Warning codes.
+const (
+	warnDataCompatibility = "data_compatibility"
+	warnMultipleInstalled = "multiple_installed"
+)
+
+// Downgrade replaces the installed version of appID with the older
+// targetVersion, which must be available remotely.
+//
+// Without confirm it only validates the request and returns the warnings the
+// user should see; the operation ID is empty. With confirm it uninstalls the
+// current version and installs the target as one streamed operation,
+// recorded in history as operation "downgrade". If installing the target
+// fails, the previous version is reinstalled.
+func (m *LinyapsManager) Downgrade(sender dbus.Sender, appID, targetVersion string, confirm bool) (string, []Warning, *dbus.Error) {
+	if m.draining.Load() {
+		return "", nil, dbus.MakeFailedError(errors.New("service is being replaced by a new instance, retry"))
+	}
+	ref, err := llcli.ParseRef(appID + "/" + targetVersion)
+	if err != nil || ref.Channel != "" || ref.Arch != "" {
+		return "", nil, dbus.MakeFailedError(fmt.Errorf("invalid app id %q or version %q", appID, targetVersion))
+	}
+	if dbusErr := m.ready.check(); dbusErr != nil {
+		return "", nil, dbusErr
+	}
+
+	ctx, cancel := replyContext()
+	current, warnings, err := m.checkDowngrade(ctx, appID, targetVersion)
+	cancel()
+	if err != nil {
+		return "", nil, dbus.MakeFailedError(err)
+	}
+	if !confirm {
+		return "", warnings, nil
+	}
+
+	labels := map[string]string{
+		"command":   "ll-cli",
+		"caller":    string(sender),
+		"operation": "downgrade",
+		"ref":       ref.String(),
+	}
+	ctx = m.operationContext(labels, timeoutFor("downgrade"))
+	opID := streaming.RunDetailedTask(ctx, m.sink, "ll-cli", func(ctx context.Context, out func(string, bool)) (map[string]interface{}, error) {
+		from := llcli.Ref{ID: appID, Version: current}
+		rolledBack, err := replaceRef(ctx, out, from, ref)
+		return map[string]interface{}{
+			"from_version": current,
+			"to_version":   targetVersion,
+			"rolled_back":  rolledBack,
+		}, err
+	})
+	log.Printf("[INFO] downgrade %s %s -> %s started: opID=%s", appID, current, targetVersion, opID)
+	return opID, warnings, nil
+}
+
+// checkDowngrade validates a downgrade and returns the installed version.
+func (m *LinyapsManager) checkDowngrade(ctx context.Context, appID, targetVersion string) (string, []Warning, error) {
+	installed, err := m.installed.binaries(ctx, appID)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(installed) == 0 {
+		return "", nil, fmt.Errorf("%s is not installed", appID)
+	}
+	current := installed[0].Version
+	if llcli.CompareVersions(targetVersion, current) >= 0 {
+		return "", nil, fmt.Errorf("%s is not older than the installed version %s", targetVersion, current)
+	}
+
+	remote, err := remoteVersions(ctx, appID)
+	if err != nil {
+		return "", nil, err
+	}
+	found := false
+	for _, p := range remote {
+		if p.Version == targetVersion {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", nil, fmt.Errorf("version %s of %s is not available in any repository", targetVersion, appID)
+	}
+
+	var warnings []Warning
+	if home, err := os.UserHomeDir(); err == nil {
+		if _, err := os.Stat(filepath.Join(home, ".linglong", appID)); err == nil {
+			warnings = append(warnings, Warning{
+				Code:    warnDataCompatibility,
+				Message: fmt.Sprintf("data written by %s %s may not be readable by %s", appID, current, targetVersion),
+			})
+		}
+	}
+	if len(installed) > 1 {
+		warnings = append(warnings, Warning{
+			Code:    warnMultipleInstalled,
+			Message: fmt.Sprintf("%d versions of %s are installed; only %s is replaced", len(installed), appID, current),
+		})
+	}
+	return current, warnings, nil
+}
+
+// replaceRef uninstalls from and installs to, reinstalling from if the
+// install fails. It reports whether that rollback happened.
+func replaceRef(ctx context.Context, out func(string, bool), from, to llcli.Ref) (bool, error) {
+	env := buildCommandEnv("ll-cli")
+	step := func(args ...string) error {
+		out("==> ll-cli "+strings.Join(args, " ")+"\n", false)
+		return streaming.RunChild(ctx, out, env, "ll-cli", args...)
+	}
+
+	if err := step("uninstall", from.String()); err != nil {
+		return false, err
+	}
+	err := step("install", to.String())
+	if err == nil {
+		return false, nil
+	}
+	out(fmt.Sprintf("install of %s failed, restoring %s\n", to, from), true)
+	if rbErr := step("install", from.String()); rbErr != nil {
+		return false, fmt.Errorf("%v; restoring %s also failed: %v", err, from, rbErr)
+	}
+	return true, fmt.Errorf("%v (restored %s)", err, from)
+}
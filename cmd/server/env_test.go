@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	data := "# session\nDISPLAY=:0\n\n  WAYLAND_DISPLAY=wayland-0  \n=orphan\nnoequals\n"
+	want := []string{"DISPLAY=:0", "WAYLAND_DISPLAY=wayland-0"}
+	if got := parseEnvFile(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseEnvFile = %q, want %q", got, want)
+	}
+}
+
+// FuzzParseEnvFile checks that every entry passed to exec has a key and
+// cannot smuggle a second variable through an embedded newline.
+func FuzzParseEnvFile(f *testing.F) {
+	f.Add("DISPLAY=:0\nXAUTHORITY=/run/user/1000/xauth\n")
+	f.Add("# comment\n=x\n\r\n")
+
+	f.Fuzz(func(t *testing.T, data string) {
+		for _, kv := range parseEnvFile(data) {
+			key, _, ok := strings.Cut(kv, "=")
+			if !ok || key == "" {
+				t.Fatalf("entry %q has no key", kv)
+			}
+			if strings.Contains(kv, "\n") || strings.HasPrefix(kv, "#") {
+				t.Fatalf("entry %q escaped line parsing", kv)
+			}
+		}
+	})
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/history"
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/streaming"
+)
+
+// maxEstimateRefs bounds the refs of one EstimateOperation call, each of
+// which may need an ll-cli search.
+const maxEstimateRefs = 50
+
+// downloadSpeeds averages the download speeds reported in the Progress of
+// running operations until history takes them.
+type downloadSpeeds struct {
+	mu  sync.Mutex
+	ops map[string]*speedSum
+}
+
+type speedSum struct {
+	total uint64
+	n     uint64
+}
+
+func newDownloadSpeeds() *downloadSpeeds {
+	return &downloadSpeeds{ops: make(map[string]*speedSum)}
+}
+
+// observe is an Emitter progress watcher.
+func (d *downloadSpeeds) observe(operationID string, p streaming.Progress) {
+	if p.BytesPerSec == 0 {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.ops[operationID]
+	if s == nil {
+		s = &speedSum{}
+		d.ops[operationID] = s
+	}
+	s.total += p.BytesPerSec
+	s.n++
+}
+
+// take returns and forgets the average speed of an operation, or 0.
+func (d *downloadSpeeds) take(operationID string) uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.ops[operationID]
+	delete(d.ops, operationID)
+	if s == nil {
+		return 0
+	}
+	return s.total / s.n
+}
+
+// estimate is the result of EstimateOperation.
+type estimate struct {
+	bytes    uint64
+	min, max time.Duration
+	samples  int
+	unknown  []string // refs whose size is not known
+	present  []string // refs already installed
+}
+
+// EstimateOperation estimates installing refs. The download size comes
+// from the repository and the time from the download speeds and install
+// durations in the operation history, so it is a rough guide that improves
+// as more is installed. The result holds:
+//   - download_bytes (t): total size of the refs that report one
+//   - min_seconds, max_seconds (u): expected time range, 0 if unknown
+//   - samples (u): past downloads the speed is based on
+//   - unknown (as): refs whose size the repository does not report
+//   - installed (as): refs already installed, which need no download
+func (m *LinyapsManager) EstimateOperation(refs []string) (map[string]dbus.Variant, *dbus.Error) {
+	if len(refs) == 0 || len(refs) > maxEstimateRefs {
+		return nil, dbus.MakeFailedError(fmt.Errorf("want 1 to %d refs, got %d", maxEstimateRefs, len(refs)))
+	}
+	parsed := make([]llcli.Ref, 0, len(refs))
+	for _, s := range refs {
+		r, err := llcli.ParseRef(s)
+		if err != nil {
+			return nil, dbus.MakeFailedError(err)
+		}
+		parsed = append(parsed, r)
+	}
+	if m.history == nil {
+		return nil, dbus.MakeFailedError(errHistoryDisabled)
+	}
+	entries, _, err := m.history.Query(history.Query{Limit: history.MaxLimit})
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+
+	ctx, cancel := replyContext()
+	defer cancel()
+	e := estimate{unknown: []string{}, present: []string{}}
+	var sizes []uint64
+	var durations [][]time.Duration
+	for _, r := range parsed {
+		if _, ok, err := m.installed.installed(ctx, r); err == nil && ok {
+			e.present = append(e.present, r.String())
+			continue
+		}
+		if size := remoteSize(ctx, r); size > 0 {
+			sizes = append(sizes, size)
+			continue
+		}
+		e.unknown = append(e.unknown, r.String())
+		durations = append(durations, installDurations(entries, r.ID))
+	}
+	estimateTime(&e, sizes, installSpeeds(entries), durations)
+
+	return map[string]dbus.Variant{
+		"download_bytes": dbus.MakeVariant(e.bytes),
+		"min_seconds":    dbus.MakeVariant(uint32(e.min.Round(time.Second) / time.Second)),
+		"max_seconds":    dbus.MakeVariant(uint32(e.max.Round(time.Second) / time.Second)),
+		"samples":        dbus.MakeVariant(uint32(e.samples)),
+		"unknown":        dbus.MakeVariant(e.unknown),
+		"installed":      dbus.MakeVariant(e.present),
+	}, nil
+}
+
+// remoteSize returns the size the repository reports for the newest
+// binary module matching r, or 0.
+func remoteSize(ctx context.Context, r llcli.Ref) uint64 {
+	pkgs, err := remoteVersions(ctx, r.ID)
+	if err != nil {
+		log.Printf("[WARN] cannot look up the size of %s: %v", r, err)
+		return 0
+	}
+	best, ok := newestBinary(pkgs, r)
+	if !ok || best.Size <= 0 {
+		return 0
+	}
+	return uint64(best.Size)
+}
+
+// newestBinary returns the newest binary module in pkgs matching r.
+func newestBinary(pkgs []llcli.Package, r llcli.Ref) (llcli.Package, bool) {
+	var best *llcli.Package
+	for i, p := range pkgs {
+		if !p.Matches(r) || (p.Module != "" && p.Module != "binary") {
+			continue
+		}
+		if r.Channel != "" && p.Channel != "" && p.Channel != r.Channel {
+			continue
+		}
+		if best == nil || llcli.CompareVersions(p.Version, best.Version) > 0 {
+			best = &pkgs[i]
+		}
+	}
+	if best == nil {
+		return llcli.Package{}, false
+	}
+	return *best, true
+}
+
+// installSpeeds returns the download speeds of the completed installs and
+// upgrades in entries, slowest first.
+func installSpeeds(entries []history.Entry) []uint64 {
+	var speeds []uint64
+	for _, e := range entries {
+		if isDownload(e) && e.BytesPerSec > 0 {
+			speeds = append(speeds, e.BytesPerSec)
+		}
+	}
+	sort.Slice(speeds, func(i, j int) bool { return speeds[i] < speeds[j] })
+	return speeds
+}
+
+// installDurations returns how long the completed installs and upgrades of
+// appID in entries took.
+func installDurations(entries []history.Entry, appID string) []time.Duration {
+	var ds []time.Duration
+	for _, e := range entries {
+		if isDownload(e) && e.AppID == appID {
+			ds = append(ds, time.Duration(e.DurationMs)*time.Millisecond)
+		}
+	}
+	return ds
+}
+
+func isDownload(e history.Entry) bool {
+	return e.Command == "ll-cli" && (e.Operation == "install" || e.Operation == "upgrade") &&
+		e.State == string(streaming.StateCompleted)
+}
+
+// estimateTime fills in the size and time range of e. Known sizes are
+// divided by the upper and lower quartile of the past speeds; refs of
+// unknown size add the shortest and longest of their past installs.
+func estimateTime(e *estimate, sizes, speeds []uint64, durations [][]time.Duration) {
+	for _, size := range sizes {
+		e.bytes += size
+	}
+	if len(speeds) > 0 && e.bytes > 0 {
+		slow, fast := speeds[len(speeds)/4], speeds[len(speeds)*3/4]
+		e.min += time.Duration(float64(e.bytes) / float64(fast) * float64(time.Second))
+		e.max += time.Duration(float64(e.bytes) / float64(slow) * float64(time.Second))
+		e.samples = len(speeds)
+	}
+	for _, ds := range durations {
+		if len(ds) == 0 {
+			continue
+		}
+		lo, hi := ds[0], ds[0]
+		for _, d := range ds[1:] {
+			lo, hi = min(lo, d), max(hi, d)
+		}
+		e.min += lo
+		e.max += hi
+	}
+}
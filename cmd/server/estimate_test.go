@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"linyapsmanager/internal/history"
+	"linyapsmanager/internal/streaming"
+)
+
+func TestDownloadSpeeds(t *testing.T) {
+	d := newDownloadSpeeds()
+	d.observe("op", streaming.Progress{Percent: 10, BytesPerSec: 100})
+	d.observe("op", streaming.Progress{Percent: 20})
+	d.observe("op", streaming.Progress{Percent: 30, BytesPerSec: 300})
+	if got := d.take("op"); got != 200 {
+		t.Errorf("take = %d, want 200", got)
+	}
+	if got := d.take("op"); got != 0 {
+		t.Errorf("second take = %d, want 0", got)
+	}
+}
+
+func TestEstimateTime(t *testing.T) {
+	install := func(app string, speed uint64, d time.Duration) history.Entry {
+		return history.Entry{Command: "ll-cli", Operation: "install", AppID: app, State: "completed",
+			BytesPerSec: speed, DurationMs: d.Milliseconds()}
+	}
+	entries := []history.Entry{
+		install("org.a", 1000, time.Minute),
+		install("org.a", 4000, 3*time.Minute),
+		install("org.b", 2000, 2*time.Minute),
+		{Command: "ll-cli", Operation: "install", AppID: "org.c", State: "failed", BytesPerSec: 1, DurationMs: 5},
+		{Command: "ll-cli", Operation: "run", AppID: "org.c", State: "completed", BytesPerSec: 1},
+	}
+	speeds := installSpeeds(entries)
+	if len(speeds) != 3 || speeds[0] != 1000 || speeds[2] != 4000 {
+		t.Fatalf("installSpeeds = %v", speeds)
+	}
+
+	var e estimate
+	estimateTime(&e, []uint64{6000, 2000}, speeds, [][]time.Duration{installDurations(entries, "org.a"), installDurations(entries, "org.c")})
+	if e.bytes != 8000 || e.samples != 3 {
+		t.Errorf("bytes, samples = %d, %d", e.bytes, e.samples)
+	}
+	// 8000 bytes at 4000 to 1000 B/s, plus one to three minutes for org.a
+	if want := 2*time.Second + time.Minute; e.min != want {
+		t.Errorf("min = %s, want %s", e.min, want)
+	}
+	if want := 8*time.Second + 3*time.Minute; e.max != want {
+		t.Errorf("max = %s, want %s", e.max, want)
+	}
+
+	e = estimate{}
+	estimateTime(&e, []uint64{1000}, nil, nil)
+	if e.min != 0 || e.max != 0 {
+		t.Errorf("without history = %s..%s, want no time", e.min, e.max)
+	}
+}
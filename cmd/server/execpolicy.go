@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/execpolicy"
+	"linyapsmanager/internal/llcli"
+)
+
+// execPolicyEnv overrides the path of the exec policy file.
+const execPolicyEnv = "LINYAPS_EXEC_POLICY"
+
+// execDefaultShell is what ll-cli exec runs when given no command.
+const execDefaultShell = "bash"
+
+// llcliValueFlags are the ll-cli run and exec options taking a separate
+// value, so the value is not mistaken for the app.
+var llcliValueFlags = map[string]bool{
+	"--file":              true,
+	"--url":               true,
+	"--env":               true,
+	"--base":              true,
+	"--runtime":           true,
+	"--extensions":        true,
+	"--working-directory": true,
+}
+
+// containerCommand returns the app and the command line an ll-cli exec or
+// run command line runs inside the app's container. ok is false for other
+// subcommands and for runs of the app's own entry point.
+func containerCommand(args []string) (appID string, command []string, ok bool) {
+	subcmd, app, _, command := containerArgs(args)
+	if app == "" {
+		return "", nil, false
+	}
+	if len(command) == 0 {
+		if subcmd == "run" {
+			return "", nil, false
+		}
+		command = []string{execDefaultShell}
+	}
+	return llcli.AppIDFromRef(app), command, true
+}
+
+// containerArgs splits an ll-cli exec or run command line into its
+// subcommand, the app, the values of its --env options in either the
+// "--env NAME=VALUE" or the "--env=NAME=VALUE" form, and the command given
+// after the app. app is empty for other subcommands.
+func containerArgs(args []string) (subcmd, app string, env, command []string) {
+	subcmd, rest := llcliSubcommand(args)
+	if subcmd != "exec" && subcmd != "run" {
+		return subcmd, "", nil, nil
+	}
+	for i := 0; i < len(rest); i++ {
+		arg := rest[i]
+		if arg == "--" {
+			command = rest[i+1:]
+			break
+		}
+		if value, ok := strings.CutPrefix(arg, "--env="); ok {
+			env = append(env, value)
+			continue
+		}
+		if strings.HasPrefix(arg, "-") {
+			if llcliValueFlags[arg] {
+				i++
+				if arg == "--env" && i < len(rest) {
+					env = append(env, rest[i])
+				}
+			}
+			continue
+		}
+		if app == "" {
+			app = arg
+			continue
+		}
+		command = rest[i:]
+		break
+	}
+	return subcmd, app, env, command
+}
+
+// checkExecPolicy refuses ll-cli command lines running a command inside an
+// app container that the exec policy does not allow, and ll-cli exec and
+// run command lines setting an environment variable outside the app's env
+// list, as RunWithEnv does. The policy file is read on every check so
+// edits apply without a restart; one that cannot be read refuses all such
+// commands. Refusals carry ErrorExecDenied, so the audit log records them
+// with the caller.
+func checkExecPolicy(sender dbus.Sender, args []string) *dbus.Error {
+	_, app, env, _ := containerArgs(args)
+	appID, command, ok := containerCommand(args)
+	if !ok && len(env) == 0 {
+		return nil
+	}
+	if appID == "" {
+		appID = llcli.AppIDFromRef(app)
+	}
+	policy, err := loadExecPolicy()
+	if err != nil {
+		log.Printf("[ERROR] exec policy unavailable, refusing %q in %s: %v", args, appID, err)
+		return dbus.NewError(dbusconsts.ErrorExecDenied, []interface{}{fmt.Sprintf("exec policy unavailable: %v", err)})
+	}
+	for _, kv := range env {
+		name, _, _ := strings.Cut(kv, "=")
+		if err := policy.CheckEnv(appID, name); err != nil {
+			log.Printf("[WARN] exec policy refused %s: %v", sender, err)
+			return dbus.NewError(dbusconsts.ErrorExecDenied, []interface{}{err.Error()})
+		}
+	}
+	if !ok {
+		return nil
+	}
+	if err := policy.Check(appID, command); err != nil {
+		log.Printf("[WARN] exec policy refused %s: %v", sender, err)
+		return dbus.NewError(dbusconsts.ErrorExecDenied, []interface{}{err.Error()})
+	}
+	return nil
+}
+
+// loadExecPolicy reads the exec policy file.
+func loadExecPolicy() (*execpolicy.Policy, error) {
+	path := os.Getenv(execPolicyEnv)
+	if path == "" {
+		path = execpolicy.DefaultPath
+	}
+	return execpolicy.Load(path)
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestContainerCommand(t *testing.T) {
+	tests := []struct {
+		args    string
+		app     string
+		command []string
+	}{
+		{"exec org.example.app", "org.example.app", []string{"bash"}},
+		{"exec org.example.app -- sh -c id", "org.example.app", []string{"sh", "-c", "id"}},
+		{"exec --working-directory /tmp org.example.app ls -l", "org.example.app", []string{"ls", "-l"}},
+		{"run org.example.app/1.0.0 -- bash", "org.example.app", []string{"bash"}},
+		{"run --file /tmp/a.txt org.example.app", "", nil},
+		{"install org.example.app", "", nil},
+	}
+	for _, tt := range tests {
+		app, command, ok := containerCommand(strings.Fields(tt.args))
+		if app != tt.app || !reflect.DeepEqual(command, tt.command) || ok != (tt.app != "") {
+			t.Errorf("containerCommand(%q) = %q, %q, %v", tt.args, app, command, ok)
+		}
+	}
+}
+
+func TestCheckExecPolicy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "exec-policy.json")
+	t.Setenv(execPolicyEnv, path)
+	if err := checkExecPolicy(":1.1", []string{"exec", "org.example.app", "--", "sh", "-c", "id"}); err != nil {
+		t.Errorf("no policy: %v", err)
+	}
+
+	os.WriteFile(path, []byte(`{"default": {"deny": ["sh -c"]}}`), 0o644)
+	if err := checkExecPolicy(":1.1", []string{"exec", "org.example.app", "--", "sh", "-c", "id"}); err == nil || err.Name != "org.linglong_store.LinyapsManager.Error.ExecDenied" {
+		t.Errorf("denied exec: %v", err)
+	}
+	if err := checkExecPolicy(":1.1", []string{"install", "sh"}); err != nil {
+		t.Errorf("install: %v", err)
+	}
+
+	os.WriteFile(path, []byte(`{`), 0o644)
+	if err := checkExecPolicy(":1.1", []string{"exec", "org.example.app", "--", "ls"}); err == nil {
+		t.Error("broken policy allowed exec")
+	}
+}
+
+func TestExecuteChecksEnv(t *testing.T) {
+	t.Setenv(execPolicyEnv, filepath.Join(t.TempDir(), "exec-policy.json"))
+	m := &LinyapsManager{ready: &readiness{ready: true}}
+	for _, args := range [][]string{
+		{"run", "org.example.app", "--env", "LD_PRELOAD=/tmp/x.so"},
+		{"run", "org.example.app", "--env=LD_PRELOAD=/tmp/x.so"},
+		{"run", "--env", "LANG=C", "--env", "PATH=/tmp", "org.example.app"},
+		{"exec", "org.example.app", "--env=QT_PLUGIN_PATH=/tmp", "--", "ls"},
+	} {
+		_, err := m.ExecuteCommand(":1.1", "ll-cli", args)
+		if err == nil || err.Name != "org.linglong_store.LinyapsManager.Error.ExecDenied" {
+			t.Errorf("ExecuteCommand(ll-cli %q) = %v, want ExecDenied", args, err)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/streaming"
+)
+
+// maxInputSize bounds the data of one SendInput call.
+const maxInputSize = 64 << 10
+
+// ExecStream runs args, or a shell if args is empty, inside container, a
+// running instance or app ID as ll-cli exec takes it, on a pseudo-terminal.
+// It returns the operation ID like ExecuteCommand; the terminal output
+// arrives as Output signals, SendInput types into it and Complete reports
+// the exit code. The command is subject to the exec policy and has no
+// timeout.
+func (m *LinyapsManager) ExecStream(sender dbus.Sender, container string, args []string) (string, *dbus.Error) {
+	if ref, err := llcli.ParseRef(container); err != nil || ref.String() != ref.ID {
+		return "", dbus.MakeFailedError(fmt.Errorf("invalid container %q", container))
+	}
+	cmdArgs := []string{"exec", container}
+	if len(args) > 0 {
+		cmdArgs = append(append(cmdArgs, "--"), args...)
+	}
+	return m.execute(sender, "ll-cli", cmdArgs, execOptions{hasTimeout: true, pty: true})
+}
+
+// SendInput writes data to the terminal of an ExecStream operation, as if
+// typed; end lines with "\n". Only the user that started the operation,
+// root and the daemon's user may send input.
+func (m *LinyapsManager) SendInput(sender dbus.Sender, operationID, data string) *dbus.Error {
+	if !streaming.ValidOperationID(operationID) {
+		return dbus.MakeFailedError(fmt.Errorf("invalid operation id %q", operationID))
+	}
+	if len(data) > maxInputSize {
+		return dbus.MakeFailedError(fmt.Errorf("input too large: %d bytes, max %d", len(data), maxInputSize))
+	}
+	op, ok := streaming.DefaultRegistry.Lookup(operationID)
+	if !ok {
+		return dbus.MakeFailedError(fmt.Errorf("unknown operation %q", operationID))
+	}
+	if dbusErr := m.checkOwner(sender, operationID, op.Labels[ownerLabel]); dbusErr != nil {
+		return dbusErr
+	}
+	if err := streaming.DefaultRegistry.SendInput(operationID, data); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/container"
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/guestpolicy"
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/polkit"
+)
+
+// guestPolicyEnv overrides the path of the guest policy file.
+const guestPolicyEnv = "LINYAPS_GUEST_POLICY"
+
+// maxGuestURL bounds the URLs apps may ask the host to open, in bytes.
+const maxGuestURL = 4096
+
+// guest serves dbusconsts.GuestInterface: the requests apps make from
+// inside their container, each checked against the guest policy of the
+// calling app.
+type guest struct {
+	m *LinyapsManager
+}
+
+// Version returns dbusconsts.GuestProtocolVersion.
+func (g *guest) Version() (uint32, *dbus.Error) {
+	return dbusconsts.GuestProtocolVersion, nil
+}
+
+// RequestInstallMissingRuntime installs ref, which must be the runtime or
+// base declared by the calling app, and returns the operation ID like
+// ExecuteCommand. A ref without a version installs the declared one.
+func (g *guest) RequestInstallMissingRuntime(sender dbus.Sender, ref string) (string, *dbus.Error) {
+	if g.m.draining.Load() {
+		return "", dbus.MakeFailedError(errors.New("service is being replaced by a new instance, retry"))
+	}
+	want, err := llcli.ParseRef(ref)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	app, policy, dbusErr := g.caller(sender)
+	if dbusErr != nil {
+		return "", dbusErr
+	}
+	if !policy.MayInstallRuntime(app.ID) {
+		return "", guestDenied(app.ID, "installing runtimes is not allowed")
+	}
+
+	ctx, cancel := replyContext()
+	pkgs, err := g.m.installed.binaries(ctx, app.ID)
+	cancel()
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	for _, pkg := range pkgs {
+		if app.Version != "" && pkg.Version != app.Version {
+			continue
+		}
+		if target, ok := declaredDependency(pkg, want); ok {
+			log.Printf("[INFO] %s requests install of its dependency %s", app.ID, target)
+			return g.m.execute(sender, "ll-cli", []string{"install", target.String()}, execOptions{})
+		}
+	}
+	return "", guestDenied(app.ID, fmt.Sprintf("%s is not its runtime or base", ref))
+}
+
+// OpenHostURL opens url with the host's default handler. Its scheme must
+// be allowed for the calling app.
+func (g *guest) OpenHostURL(sender dbus.Sender, rawURL string) *dbus.Error {
+	u, err := parseGuestURL(rawURL)
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	app, policy, dbusErr := g.caller(sender)
+	if dbusErr != nil {
+		return dbusErr
+	}
+	if !policy.MayOpenScheme(app.ID, u.Scheme) {
+		return guestDenied(app.ID, fmt.Sprintf("opening %s URLs is not allowed", u.Scheme))
+	}
+
+	cmd := exec.Command("xdg-open", u.String())
+	cmd.Env = append(append(os.Environ(), sessionEnv()...), loadUserEnv()...)
+	if err := cmd.Start(); err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("open %s: %w", u.Redacted(), err))
+	}
+	go cmd.Wait()
+	log.Printf("[INFO] %s opened %s on the host", app.ID, u.Redacted())
+	return nil
+}
+
+// caller returns the app whose container sender runs in and the guest
+// policy in effect.
+func (g *guest) caller(sender dbus.Sender) (llcli.Ref, *guestpolicy.Policy, *dbus.Error) {
+	pid, err := polkit.SenderPID(g.m.conn, sender)
+	if err != nil {
+		return llcli.Ref{}, nil, dbus.MakeFailedError(err)
+	}
+	app, err := containerApp(pid)
+	if err != nil {
+		log.Printf("[WARN] guest request from %s refused: %v", sender, err)
+		return llcli.Ref{}, nil, dbus.NewError(dbusconsts.ErrorGuestDenied, []interface{}{err.Error()})
+	}
+	path := os.Getenv(guestPolicyEnv)
+	if path == "" {
+		path = guestpolicy.DefaultPath
+	}
+	policy, err := guestpolicy.Load(path)
+	if err != nil {
+		log.Printf("[ERROR] guest policy unavailable, refusing %s: %v", app.ID, err)
+		return llcli.Ref{}, nil, dbus.NewError(dbusconsts.ErrorGuestDenied, []interface{}{fmt.Sprintf("guest policy unavailable: %v", err)})
+	}
+	return app, policy, nil
+}
+
+// containerApp returns the ref of the app whose container runs pid. A
+// container's processes share the pid namespace of its init process, which
+// they cannot leave, so unlike the environment or the process name it
+// cannot be forged from inside.
+func containerApp(pid uint32) (llcli.Ref, error) {
+	ns, err := container.PIDNamespace(int(pid))
+	if err != nil {
+		return llcli.Ref{}, err
+	}
+	if own, err := container.PIDNamespace(os.Getpid()); err != nil || own == ns {
+		return llcli.Ref{}, fmt.Errorf("process %d does not run in an app container", pid)
+	}
+	ctx, cancel := replyContext()
+	defer cancel()
+	containers, err := runningContainers(ctx)
+	if err != nil {
+		return llcli.Ref{}, err
+	}
+	for _, c := range containers {
+		if c.PID <= 0 {
+			continue
+		}
+		if cns, err := container.PIDNamespace(c.PID); err == nil && cns == ns {
+			return llcli.ParseRef(c.App)
+		}
+	}
+	return llcli.Ref{}, fmt.Errorf("process %d does not run in an app container", pid)
+}
+
+// declaredDependency returns the ref to install if want names the runtime
+// or base of pkg: the same ID, and a version within the declared one, so
+// "23.1" admits "23.1.0.2" but not "23.2". Without a version in want the
+// declared ref is returned.
+func declaredDependency(pkg llcli.Package, want llcli.Ref) (llcli.Ref, bool) {
+	for _, dep := range []string{pkg.Runtime, pkg.Base} {
+		declared, err := llcli.ParseRef(dep)
+		if err != nil || declared.ID != want.ID {
+			continue
+		}
+		if want.Version == "" {
+			return declared, true
+		}
+		if declared.Version == "" || want.Version == declared.Version || strings.HasPrefix(want.Version, declared.Version+".") {
+			return want, true
+		}
+	}
+	return llcli.Ref{}, false
+}
+
+// parseGuestURL validates a URL an app asks the host to open.
+func parseGuestURL(s string) (*url.URL, error) {
+	if len(s) > maxGuestURL {
+		return nil, fmt.Errorf("URL longer than %d bytes", maxGuestURL)
+	}
+	if strings.ContainsFunc(s, func(r rune) bool { return r < 0x20 || r == 0x7f || r == ' ' }) {
+		return nil, errors.New("URL contains spaces or control characters")
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || strings.HasPrefix(s, "-") {
+		return nil, fmt.Errorf("not an absolute URL: %q", s)
+	}
+	if (u.Scheme == "http" || u.Scheme == "https") && u.Host == "" {
+		return nil, fmt.Errorf("URL without host: %q", s)
+	}
+	return u, nil
+}
+
+// guestDenied is the error for a request the guest policy refuses.
+func guestDenied(appID, reason string) *dbus.Error {
+	log.Printf("[WARN] guest request of %s refused: %s", appID, reason)
+	return dbus.NewError(dbusconsts.ErrorGuestDenied, []interface{}{fmt.Sprintf("%s: %s", appID, reason)})
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+
+	"linyapsmanager/internal/llcli"
+)
+
+func TestDeclaredDependency(t *testing.T) {
+	pkg := llcli.Package{
+		ID:      "org.example.app",
+		Runtime: "main:org.deepin.runtime.dtk/23.1.0/x86_64",
+		Base:    "main:org.deepin.base/23.1",
+	}
+	tests := []struct {
+		want    string
+		install string // empty if refused
+	}{
+		{"org.deepin.runtime.dtk", "main:org.deepin.runtime.dtk/23.1.0/x86_64"},
+		{"org.deepin.runtime.dtk/23.1.0", "org.deepin.runtime.dtk/23.1.0"},
+		{"org.deepin.runtime.dtk/23.1.0.3", "org.deepin.runtime.dtk/23.1.0.3"},
+		{"org.deepin.base/23.1.5", "org.deepin.base/23.1.5"},
+		{"org.deepin.base/23.10", ""},
+		{"org.deepin.runtime.dtk/23.2.0", ""},
+		{"org.example.other", ""},
+	}
+	for _, tt := range tests {
+		want, err := llcli.ParseRef(tt.want)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, ok := declaredDependency(pkg, want)
+		if ok != (tt.install != "") || (ok && got.String() != tt.install) {
+			t.Errorf("declaredDependency(%s) = %s, %v; want %q", tt.want, got, ok, tt.install)
+		}
+	}
+}
+
+func TestParseGuestURL(t *testing.T) {
+	for _, s := range []string{"https://example.com/a?b=c", "mailto:someone@example.com"} {
+		if _, err := parseGuestURL(s); err != nil {
+			t.Errorf("parseGuestURL(%q): %v", s, err)
+		}
+	}
+	for _, s := range []string{"", "example.com", "-https://x", "https:///path", "https://example.com/a b", "https://example.com/\n"} {
+		if _, err := parseGuestURL(s); err == nil {
+			t.Errorf("parseGuestURL(%q) accepted", s)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"log"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/history"
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/streaming"
+)
+
+// openHistory opens the history store and records every finished operation
+// in it, with the download speed seen by speeds. History is best effort:
+// without a store GetHistory fails.
+func openHistory(speeds *downloadSpeeds) *history.Store {
+	retention, err := history.RetentionFromEnv()
+	if err != nil {
+		log.Printf("[WARN] %v, using defaults", err)
+		retention = history.Retention{MaxEntries: 1000}
+	}
+	path := history.DefaultPath()
+	store, err := history.Open(path, retention)
+	if err != nil {
+		log.Printf("[WARN] history disabled: %v", err)
+		return nil
+	}
+	streaming.DefaultRegistry.Watch(func(op streaming.Operation) {
+		if op.State == streaming.StateRunning {
+			return
+		}
+		e := historyEntry(op)
+		e.BytesPerSec = speeds.take(op.ID)
+		if err := store.Append(e); err != nil {
+			log.Printf("[WARN] failed to record %s in history: %v", op.ID, err)
+		}
+	})
+	log.Printf("[INFO] history at %s (max %d entries, %s)", path, retention.MaxEntries, retention.MaxAge)
+	return store
+}
+
+var errHistoryDisabled = errors.New("operation history is not available")
+
+func historyEntry(op streaming.Operation) history.Entry {
+	e := history.Entry{
+		ID:         op.ID,
+		Command:    op.Labels["command"],
+		Operation:  op.Labels["operation"],
+		Ref:        op.Labels["ref"],
+		Caller:     op.Labels["caller"],
+		Version:    op.Labels["version"],
+		Scope:      op.Labels["scope"],
+		State:      string(op.State),
+		ExitCode:   op.ExitCode,
+		Error:      op.ErrorMsg,
+		StartTime:  op.StartTime,
+		EndTime:    op.EndTime,
+		DurationMs: op.Duration().Milliseconds(),
+		Phases:     launchPhases(op.Labels),
+	}
+	if e.Command == "" {
+		e.Command = op.Program
+	}
+	if e.Ref != "" {
+		e.AppID = llcli.AppIDFromRef(e.Ref)
+	}
+	return e
+}
+
+// GetHistory returns finished operations, newest first, and a cursor for the
+// next page ("" on the last page). Recognised filter keys:
+//   - since, until (x): unix seconds bounding the end time, until exclusive
+//   - app_id (s): only operations on this app
+//   - cursor (s): continue after a previous page
+//   - limit (i or u): page size, default 50, at most 1000
+//
+// Entries carry id, command, operation, ref, app_id, caller, version, scope,
+// state, error (s), exit_code (i), start_time, end_time (x, unix seconds),
+// duration_ms (x), phases (a{sx}, launch phase timings in ms when
+// LINYAPS_TRACE_LAUNCH is set) and bytes_per_sec (t, the average download
+// speed ll-cli reported, 0 if none).
+func (m *LinyapsManager) GetHistory(filter map[string]dbus.Variant) ([]map[string]dbus.Variant, string, *dbus.Error) {
+	if m.history == nil {
+		return nil, "", dbus.MakeFailedError(errHistoryDisabled)
+	}
+	q, err := history.QueryFromVariants(filter)
+	if err != nil {
+		return nil, "", dbus.MakeFailedError(err)
+	}
+	entries, next, err := m.history.Query(q)
+	if err != nil {
+		return nil, "", dbus.MakeFailedError(err)
+	}
+	out := make([]map[string]dbus.Variant, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, e.Variants())
+	}
+	return out, next, nil
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"log"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/icons"
+)
+
+// GetAppIcon returns a local file path with the icon of an installed
+// application at size x size pixels (0 for the largest available). The path
+// may point to a scalable SVG; PNG icons of other sizes are resized and cached.
+// Apps hidden from the caller by the visibility policy have no icon.
+func (m *LinyapsManager) GetAppIcon(sender dbus.Sender, appID string, size int32) (string, *dbus.Error) {
+	if m.appHidden(sender, appID) {
+		return "", appHiddenError(appID)
+	}
+	path, err := icons.Resolve(appID, int(size))
+	if err != nil {
+		log.Printf("[WARN] GetAppIcon %s size=%d: %v", appID, size, err)
+		return "", dbus.MakeFailedError(err)
+	}
+	return path, nil
+}
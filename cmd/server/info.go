@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/llcli"
+)
+
+// maxInfoOutput bounds the ll-cli output Info returns, well below the bus
+// message size limit.
+const maxInfoOutput = 256 << 10
+
+// Info returns the output of ll-cli info for ref, an installed app id or
+// ref such as org.example.app/1.0.0, with --json when asJSON is true like
+// the other read methods. Text output over 256 KiB keeps its beginning and
+// end around a line telling how much was left out; JSON output that large
+// is an error, as cutting it would not leave JSON.
+func (m *LinyapsManager) Info(sender dbus.Sender, ref string, asJSON bool) (string, *dbus.Error) {
+	ctx, cancel := replyContext()
+	defer cancel()
+	out, dbusErr := m.info(ctx, sender, ref, asJSON)
+	if dbusErr != nil {
+		return "", dbusErr
+	}
+	if len(out) <= maxInfoOutput {
+		return out, nil
+	}
+	if asJSON {
+		return "", dbus.MakeFailedError(fmt.Errorf("info of %s is %d bytes, over the %d byte limit", ref, len(out), maxInfoOutput))
+	}
+	return capOutput(out, maxInfoOutput), nil
+}
+
+// capOutput shortens s to about max bytes, keeping its head and tail at
+// rune boundaries with a marker telling how many bytes were left out.
+func capOutput(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	head, tail := max/2, len(s)-max/2
+	for head > 0 && !utf8.RuneStart(s[head]) {
+		head--
+	}
+	for tail < len(s) && !utf8.RuneStart(s[tail]) {
+		tail++
+	}
+	return fmt.Sprintf("%s\n... [%d bytes omitted] ...\n%s", s[:head], tail-head, s[tail:])
+}
+
+// Info2 is Info returning the fields ll-cli info reports: id, name,
+// version, channel, arch, module, kind, runtime, base, description (s) and
+// size (t, 0 if not reported).
+func (m *LinyapsManager) Info2(sender dbus.Sender, ref string) (map[string]dbus.Variant, *dbus.Error) {
+	ctx, cancel := replyContext()
+	defer cancel()
+	out, dbusErr := m.info(ctx, sender, ref, true)
+	if dbusErr != nil {
+		return nil, dbusErr
+	}
+	p, err := llcli.ParseInfo(out)
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	return map[string]dbus.Variant{
+		"id":          dbus.MakeVariant(p.ID),
+		"name":        dbus.MakeVariant(p.Name),
+		"version":     dbus.MakeVariant(p.Version),
+		"channel":     dbus.MakeVariant(p.Channel),
+		"arch":        dbus.MakeVariant(p.Arch),
+		"module":      dbus.MakeVariant(p.Module),
+		"kind":        dbus.MakeVariant(p.Kind),
+		"runtime":     dbus.MakeVariant(p.Runtime),
+		"base":        dbus.MakeVariant(p.Base),
+		"description": dbus.MakeVariant(p.Description),
+		"size":        dbus.MakeVariant(uint64(max(p.Size, 0))),
+	}, nil
+}
+
+func (m *LinyapsManager) info(ctx context.Context, sender dbus.Sender, ref string, asJSON bool) (string, *dbus.Error) {
+	r, err := llcli.ParseRef(ref)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	if m.appHidden(sender, r.ID) {
+		return "", appHiddenError(r.ID)
+	}
+	if dbusErr := m.ready.check(); dbusErr != nil {
+		return "", dbusErr
+	}
+	args := []string{"info", r.String()}
+	if asJSON {
+		args = append(args, "--json")
+	}
+	out, err := llcliOutput(ctx, args...)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return out, nil
+}
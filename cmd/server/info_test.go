@@ -0,0 +1,24 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestCapOutput(t *testing.T) {
+	if got := capOutput("short", 16); got != "short" {
+		t.Errorf("capOutput of short output = %q", got)
+	}
+	s := strings.Repeat("é", 100) // 200 bytes
+	got := capOutput(s, 51)
+	if !utf8.ValidString(got) {
+		t.Errorf("capOutput cut a rune: %q", got)
+	}
+	if !strings.HasPrefix(got, strings.Repeat("é", 12)+"\n... [") || !strings.HasSuffix(got, "] ...\n"+strings.Repeat("é", 12)) {
+		t.Errorf("capOutput = %q, want 12 runes on each side of the marker", got)
+	}
+	if !strings.Contains(got, "[152 bytes omitted]") {
+		t.Errorf("capOutput = %q, want 152 bytes omitted", got)
+	}
+}
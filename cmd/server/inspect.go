@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/container"
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/polkit"
+)
+
+// findContainer returns the single running container matching target, a
+// container ID, an ID prefix or an app ID.
+func findContainer(target string) (llcli.Container, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), installedTimeout)
+	defer cancel()
+	all, err := runningContainers(ctx)
+	if err != nil {
+		return llcli.Container{}, err
+	}
+	var matched []llcli.Container
+	for _, c := range all {
+		if c.ID == target {
+			return c, nil
+		}
+		if c.Matches(target) {
+			matched = append(matched, c)
+		}
+	}
+	switch len(matched) {
+	case 0:
+		return llcli.Container{}, fmt.Errorf("no running container matches %q", target)
+	case 1:
+		return matched[0], nil
+	default:
+		return llcli.Container{}, fmt.Errorf("%q matches %d containers, use the full container id", target, len(matched))
+	}
+}
+
+// InspectContainer describes what a running container can access, read from
+// /proc of its init process. The dictionary holds:
+//   - id, app (s): container ID and app ref as listed by ll-cli ps
+//   - pid (i): host PID of the container's init process
+//   - start_time (x): when it started, in unix seconds, 0 if unknown
+//   - uptime_sec (t): how long it has been running, 0 if unknown
+//   - bundle (s): host directory of the app's files, which the container
+//     mounts at /opt/apps/<app id>/files; empty if not found
+//   - cgroup (s): its cgroup path
+//   - user_namespace (s): e.g. "user:[4026532840]"
+//   - uid_map, gid_map (a(uuu)): inside, outside, count of each mapping
+//   - env (as): its initial environment, which may hold secrets; empty
+//     unless the caller is root or the user the manager runs as
+//   - mounts (aa{ss}): target, root, source, fstype and options of each mount;
+//     for bind mounts root is the host path
+//
+// Containers of apps hidden from the caller are reported as not found.
+func (m *LinyapsManager) InspectContainer(sender dbus.Sender, containerID string) (map[string]dbus.Variant, *dbus.Error) {
+	if containerID == "" {
+		return nil, dbus.MakeFailedError(fmt.Errorf("empty container id"))
+	}
+	c, err := findContainer(containerID)
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	if appID := llcli.AppIDFromRef(c.App); m.appHidden(sender, appID) {
+		return nil, appHiddenError(appID)
+	}
+	if c.PID <= 0 {
+		return nil, dbus.MakeFailedError(fmt.Errorf("ll-cli did not report a pid for container %s", c.ID))
+	}
+	info, err := container.Inspect(c.PID)
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+
+	mounts := make([]map[string]string, 0, len(info.Mounts))
+	for _, mt := range info.Mounts {
+		mounts = append(mounts, map[string]string{
+			"target":  mt.Target,
+			"root":    mt.Root,
+			"source":  mt.Source,
+			"fstype":  mt.FSType,
+			"options": mt.Options,
+		})
+	}
+	env := info.Env
+	if env == nil || !m.mayReadEnv(sender) {
+		env = []string{}
+	}
+	var startTime int64
+	var uptime uint64
+	if started, err := container.StartTime(c.PID); err == nil {
+		startTime = started.Unix()
+		uptime = uint64(max(time.Since(started), 0) / time.Second)
+	} else {
+		log.Printf("[WARN] cannot read the start time of container %s: %v", c.ID, err)
+	}
+	return map[string]dbus.Variant{
+		"id":             dbus.MakeVariant(c.ID),
+		"app":            dbus.MakeVariant(c.App),
+		"pid":            dbus.MakeVariant(int32(c.PID)),
+		"start_time":     dbus.MakeVariant(startTime),
+		"uptime_sec":     dbus.MakeVariant(uptime),
+		"bundle":         dbus.MakeVariant(appBundle(info.Mounts, llcli.AppIDFromRef(c.App))),
+		"cgroup":         dbus.MakeVariant(info.Cgroup),
+		"user_namespace": dbus.MakeVariant(info.UserNamespace),
+		"uid_map":        dbus.MakeVariant(idMaps(info.UIDMap)),
+		"gid_map":        dbus.MakeVariant(idMaps(info.GIDMap)),
+		"env":            dbus.MakeVariant(env),
+		"mounts":         dbus.MakeVariant(mounts),
+	}, nil
+}
+
+// mayReadEnv reports whether the caller is root or the user the manager
+// runs as, the only callers shown a container's environment.
+func (m *LinyapsManager) mayReadEnv(sender dbus.Sender) bool {
+	uid, err := polkit.SenderUID(m.conn, sender)
+	if err != nil {
+		log.Printf("[WARN] cannot identify %s, withholding container environment: %v", sender, err)
+		return false
+	}
+	return uid == 0 || int(uid) == os.Getuid()
+}
+
+// appBundle returns the host directory bind mounted as the files of appID.
+func appBundle(mounts []container.Mount, appID string) string {
+	target := "/opt/apps/" + appID + "/files"
+	for _, mt := range mounts {
+		if mt.Target == target {
+			return mt.Root
+		}
+	}
+	return ""
+}
+
+func idMaps(maps []container.IDMap) []container.IDMap {
+	if maps == nil {
+		return []container.IDMap{}
+	}
+	return maps
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/streaming"
+)
+
+const (
+	// installedTTL bounds how stale the installed list may be; changes made
+	// through this service invalidate it immediately.
+	installedTTL     = time.Minute
+	installedTimeout = 15 * time.Second
+)
+
+// detailAlreadyInstalled marks installs answered from the installed list
+// without running ll-cli.
+const detailAlreadyInstalled = "already_installed"
+
+// installedIndex caches the output of "ll-cli list --json".
+type installedIndex struct {
+	mu      sync.Mutex
+	pkgs    []llcli.Package
+	fetched time.Time
+}
+
+// invalidate is a streaming.Registry watcher dropping the cache whenever an
+// operation that changes the installed set finishes.
+func (x *installedIndex) invalidate(op streaming.Operation) {
+	if op.State == streaming.StateRunning || op.Labels["command"] != "ll-cli" {
+		return
+	}
+	switch op.Labels["operation"] {
+	case "install", "uninstall", "upgrade", "prune", "downgrade", "switch-channel":
+		x.drop()
+	}
+}
+
+// drop empties the cache, so the next lookup runs ll-cli list.
+func (x *installedIndex) drop() {
+	x.mu.Lock()
+	x.fetched = time.Time{}
+	x.mu.Unlock()
+}
+
+// packages returns the installed packages, refreshing the cache when stale.
+func (x *installedIndex) packages(ctx context.Context) ([]llcli.Package, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if !x.fetched.IsZero() && time.Since(x.fetched) < installedTTL {
+		return x.pkgs, nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, installedTimeout)
+	defer cancel()
+
+	out, err := llcliOutput(ctx, "list", "--json")
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err := llcli.ParseList(out)
+	if err != nil {
+		return nil, err
+	}
+	x.pkgs, x.fetched = pkgs, time.Now()
+	return pkgs, nil
+}
+
+// installed returns the installed package matching ref, if any.
+func (x *installedIndex) installed(ctx context.Context, ref llcli.Ref) (llcli.Package, bool, error) {
+	pkgs, err := x.packages(ctx)
+	if err != nil {
+		return llcli.Package{}, false, err
+	}
+	for _, p := range pkgs {
+		if p.Matches(ref) && (p.Module == "" || p.Module == "binary") {
+			return p, true, nil
+		}
+	}
+	return llcli.Package{}, false, nil
+}
+
+// binaries returns the installed binary modules of appID, newest first.
+func (x *installedIndex) binaries(ctx context.Context, appID string) ([]llcli.Package, error) {
+	pkgs, err := x.packages(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []llcli.Package
+	for _, p := range pkgs {
+		if p.ID == appID && (p.Module == "" || p.Module == "binary") {
+			out = append(out, p)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return llcli.CompareVersions(out[i].Version, out[j].Version) > 0
+	})
+	return out, nil
+}
+
+// plainInstall returns the ref of an "ll-cli install <ref>" command line
+// without options.
+func plainInstall(args []string) (llcli.Ref, bool) {
+	if len(args) != 2 || args[0] != "install" || strings.HasPrefix(args[1], "-") {
+		return llcli.Ref{}, false
+	}
+	ref, err := llcli.ParseRef(args[1])
+	return ref, err == nil
+}
+
+// installFastPath answers a plain "ll-cli install <ref>" for an installed
+// ref without running ll-cli: the returned operation completes at once with
+// exit code 0 and details already_installed=true. Installs with any option,
+// such as --force or --module, always run ll-cli.
+func (m *LinyapsManager) installFastPath(sender dbus.Sender, args []string) (string, bool) {
+	ref, ok := plainInstall(args)
+	if !ok {
+		return "", false
+	}
+	ctx, cancel := replyContext()
+	pkg, ok, err := m.installed.installed(ctx, ref)
+	cancel()
+	if err != nil {
+		log.Printf("[WARN] installed list unavailable, running install: %v", err)
+		return "", false
+	}
+	if !ok {
+		return "", false
+	}
+
+	labels := map[string]string{"command": "ll-cli", "caller": string(sender), "operation": "install", "ref": args[1]}
+	ctx = streaming.WithLabels(context.Background(), m.labelOwner(labels))
+	opID := streaming.RunDetailedTask(ctx, m.sink, "ll-cli", func(ctx context.Context, out func(string, bool)) (map[string]interface{}, error) {
+		out(fmt.Sprintf("%s/%s is already installed\n", pkg.ID, pkg.Version), false)
+		return map[string]interface{}{detailAlreadyInstalled: true}, nil
+	})
+	log.Printf("[INFO] %s already installed (version %s), skipped ll-cli: opID=%s", pkg.ID, pkg.Version, opID)
+	return opID, true
+}
+
+// IsInstalled reports whether appID is installed, in the given version when
+// version is not empty, and returns the newest such installed version. It
+// is answered from the cached installed list, so it is much cheaper than
+// fetching the whole list. An app hidden from the caller by the visibility
+// policy is not installed.
+func (m *LinyapsManager) IsInstalled(sender dbus.Sender, appID, version string) (bool, string, *dbus.Error) {
+	s, err := packageRef(appID, version)
+	if err != nil {
+		return false, "", dbus.MakeFailedError(err)
+	}
+	ref, _ := llcli.ParseRef(s)
+	if m.appHidden(sender, appID) {
+		return false, "", nil
+	}
+	if dbusErr := m.ready.check(); dbusErr != nil {
+		return false, "", dbusErr
+	}
+	ctx, cancel := replyContext()
+	defer cancel()
+	pkgs, err := m.installed.binaries(ctx, appID)
+	if err != nil {
+		return false, "", dbus.MakeFailedError(err)
+	}
+	for _, p := range pkgs {
+		if p.Matches(ref) {
+			return true, p.Version, nil
+		}
+	}
+	return false, "", nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// packageFileExts are the file types ll-cli install accepts besides refs.
+var packageFileExts = map[string]bool{".layer": true, ".uab": true}
+
+// InstallFileStream installs the package in a local .layer or .uab file
+// with ll-cli install, replacing an installed version when force is set,
+// for offline deployments. path must be absolute and readable by the
+// service. It returns an operation ID like ExecuteCommand; progress arrives
+// as Output signals and the result as Complete.
+func (m *LinyapsManager) InstallFileStream(sender dbus.Sender, path string, force bool) (string, *dbus.Error) {
+	if err := checkPackageFile(path); err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	args := []string{"install"}
+	if force {
+		args = append(args, "--force")
+	}
+	return m.execute(sender, "ll-cli", append(args, path), execOptions{})
+}
+
+// checkPackageFile checks that path names a readable package file.
+func checkPackageFile(path string) error {
+	if !filepath.IsAbs(path) || filepath.Clean(path) != path {
+		return fmt.Errorf("package file path %q must be absolute and clean", path)
+	}
+	if ext := strings.ToLower(filepath.Ext(path)); !packageFileExts[ext] {
+		return fmt.Errorf("package file %s is not a .layer or .uab file", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("cannot read package file: %w", err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("cannot read package file: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("package file %s is not a regular file", path)
+	}
+	return nil
+}
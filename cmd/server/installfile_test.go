@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckPackageFile(t *testing.T) {
+	dir := t.TempDir()
+	layer := filepath.Join(dir, "org.example.app_1.0_x86_64_binary.layer")
+	if err := os.WriteFile(layer, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "dir.uab"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	other := filepath.Join(dir, "app.deb")
+	if err := os.WriteFile(other, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := checkPackageFile(layer); err != nil {
+		t.Errorf("checkPackageFile(%s) = %v", layer, err)
+	}
+	for _, bad := range []string{
+		"app.layer",
+		dir + "/../app.layer",
+		other,
+		filepath.Join(dir, "missing.uab"),
+		filepath.Join(dir, "dir.uab"),
+	} {
+		if err := checkPackageFile(bad); err == nil {
+			t.Errorf("checkPackageFile(%s) succeeded, want error", bad)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+	"github.com/godbus/dbus/v5/prop"
+
+	"linyapsmanager/internal/dbusconsts"
+)
+
+const introspectableInterface = "org.freedesktop.DBus.Introspectable"
+
+// methodArgNames names the arguments of the exported methods, inputs then
+// outputs. introspect.Methods derives the signatures from the Go methods
+// but cannot see parameter names; TestMethodArgNames keeps this in step.
+var methodArgNames = map[string][]string{
+	// Manager
+	"CancelOperation":           {"operationID"},
+	"CheckUpdate":               {"latestVersion", "newer"},
+	"CreateLaunchToken":         {"appID", "ttlSec", "token"},
+	"DisableApp":                {"appID"},
+	"Downgrade":                 {"appID", "targetVersion", "confirm", "operationID", "warnings"},
+	"EnableApp":                 {"appID"},
+	"EstimateOperation":         {"refs", "estimate"},
+	"ExecStream":                {"container", "args", "operationID"},
+	"ExecuteCommand":            {"command", "args", "operationID"},
+	"ExecuteCommandWithOptions": {"command", "args", "options", "operationID"},
+	"GetAppIcon":                {"appID", "size", "path"},
+	"GetAuditLog":               {"limit", "entries"},
+	"GetAvailableVersions":      {"appID", "versions"},
+	"GetBackendInfo":            {"info"},
+	"GetDependencies":           {"appID", "version", "dependencies"},
+	"GetHistory":                {"filter", "entries", "nextCursor"},
+	"GetOperationStatus":        {"operationID", "status"},
+	"GetRepoIssues":             {"issues"},
+	"GetTelemetryConsent":       {"consent"},
+	"GetTelemetryPayloads":      {"payloads"},
+	"GetVisibilityPolicy":       {"rules"},
+	"HandleURI":                 {"uri", "operationID"},
+	"Info":                      {"ref", "json", "output"},
+	"Info2":                     {"ref", "info"},
+	"InspectContainer":          {"containerID", "info"},
+	"InstallBatchStream":        {"refs", "force", "operationID"},
+	"InstallFileStream":         {"path", "force", "operationID"},
+	"InstallStream":             {"appID", "version", "options", "operationID"},
+	"IsInstalled":               {"appID", "version", "installed", "installedVersion"},
+	"Kill":                      {"appID", "signal", "operationID"},
+	"ListBases":                 {"json", "output"},
+	"ListCrashes":               {"appID", "crashes"},
+	"ListDisabledApps":          {"appIDs"},
+	"ListOperations":            {"operations"},
+	"ListPaged":                 {"offset", "limit", "type", "packages", "total"},
+	"ListRuntimes":              {"json", "output"},
+	"ListVersions":              {"appID", "includeRemote", "versions"},
+	"Ping":                      {"reply"},
+	"Prune":                     {"operationID"},
+	"PrunePreview":              {"packages", "reclaimable"},
+	"Quit":                      {},
+	"ReplayOutput":              {"operationID", "fromOffset", "chunks", "complete"},
+	"RepoAdd":                   {"name", "url", "operationID"},
+	"RepoRemove":                {"name", "operationID"},
+	"RepoSetDefault":            {"name", "operationID"},
+	"RepoUpdate":                {"name", "url", "operationID"},
+	"RunWithArgs":               {"appID", "version", "args", "env", "operationID"},
+	"RunStream":                 {"appID", "version", "operationID"},
+	"RunWithToken":              {"token", "operationID"},
+	"SelfUpdate":                {"operationID"},
+	"SearchFiltered":            {"keyword", "opts", "results"},
+	"SendInput":                 {"operationID", "data"},
+	"SetTelemetryConsent":       {"consent"},
+	"SetVisibilityPolicy":       {"uid", "allow", "deny"},
+	"SubmitRating":              {"ref", "rating"},
+	"SwitchChannel":             {"appID", "channel", "operationID"},
+	"UninstallBatch":            {"refs", "operationID", "results"},
+	"UninstallBatchStream":      {"refs", "operationID"},
+	"UninstallStream":           {"appID", "version", "options", "operationID"},
+	"UpgradeSelectedStream":     {"appIDs", "operationID"},
+	"VerifyAuditLog":            {"report"},
+	"WaitForExit":               {"target", "timeoutSec", "operationID"},
+	"WaitReady":                 {"timeoutMs", "ready"},
+	// Guest
+	"OpenHostURL":                  {"url"},
+	"RequestInstallMissingRuntime": {"ref", "operationID"},
+	"Version":                      {"version"},
+	// ObjectManager
+	"GetManagedObjects": {"objects"},
+}
+
+// managerSignals describes the signals of dbusconsts.Interface; see
+// dbusconsts for their meaning.
+var managerSignals = []introspect.Signal{
+	{Name: dbusconsts.SignalOutput, Args: []introspect.Arg{
+		{Name: "operationID", Type: "s"}, {Name: "data", Type: "s"}, {Name: "isStderr", Type: "b"}, {Name: "seq", Type: "t"},
+	}},
+	{Name: dbusconsts.SignalComplete, Args: []introspect.Arg{
+		{Name: "operationID", Type: "s"}, {Name: "exitCode", Type: "i"}, {Name: "errorMsg", Type: "s"}, {Name: "finalSeq", Type: "t"}, {Name: "details", Type: "a{sv}"},
+	}},
+	{Name: dbusconsts.SignalProgress, Args: []introspect.Arg{
+		{Name: "operationID", Type: "s"}, {Name: "percent", Type: "d"}, {Name: "bytesPerSec", Type: "t"}, {Name: "phase", Type: "s"},
+	}},
+	{Name: dbusconsts.SignalQueued, Args: []introspect.Arg{
+		{Name: "operationID", Type: "s"}, {Name: "position", Type: "u"},
+	}},
+	{Name: dbusconsts.SignalAppCrashed, Args: []introspect.Arg{
+		{Name: "appID", Type: "s"}, {Name: "reportPath", Type: "s"},
+	}},
+	{Name: dbusconsts.SignalAppInstalled, Args: []introspect.Arg{
+		{Name: "appID", Type: "s"}, {Name: "version", Type: "s"},
+	}},
+	{Name: dbusconsts.SignalAppRemoved, Args: []introspect.Arg{
+		{Name: "appID", Type: "s"}, {Name: "version", Type: "s"},
+	}},
+	{Name: dbusconsts.SignalAppUpgraded, Args: []introspect.Arg{
+		{Name: "appID", Type: "s"}, {Name: "oldVersion", Type: "s"}, {Name: "newVersion", Type: "s"},
+	}},
+	{Name: dbusconsts.SignalRepoConfigIssue, Args: []introspect.Arg{
+		{Name: "repo", Type: "s"}, {Name: "problem", Type: "s"}, {Name: "hint", Type: "s"},
+	}},
+}
+
+// objectManagerSignals describes the signals of org.freedesktop.DBus.ObjectManager.
+var objectManagerSignals = []introspect.Signal{
+	{Name: "InterfacesAdded", Args: []introspect.Arg{
+		{Name: "object", Type: "o"}, {Name: "interfaces", Type: "a{sa{sv}}"},
+	}},
+	{Name: "InterfacesRemoved", Args: []introspect.Arg{
+		{Name: "object", Type: "o"}, {Name: "interfaces", Type: "as"},
+	}},
+}
+
+// describeMethods returns the introspection data of the methods v exports,
+// with the argument names from methodArgNames.
+func describeMethods(v interface{}) []introspect.Method {
+	methods := introspect.Methods(v)
+	for i := range methods {
+		names := methodArgNames[methods[i].Name]
+		for j := range methods[i].Args {
+			if j < len(names) {
+				methods[i].Args[j].Name = names[j]
+			}
+		}
+	}
+	return methods
+}
+
+// exportIntrospection exports org.freedesktop.DBus.Introspectable on the
+// manager and guest objects, so busctl, d-feet and code generators can
+// discover their methods and signals.
+func exportIntrospection(conn *dbus.Conn, m *LinyapsManager, g *guest, objects *objectManager) error {
+	manager := &introspect.Node{
+		Interfaces: []introspect.Interface{
+			{Name: dbusconsts.Interface, Methods: describeMethods(m), Signals: managerSignals},
+			{Name: objectManagerInterface, Methods: describeMethods(objects), Signals: objectManagerSignals},
+		},
+		Children: []introspect.Node{{Name: "Guest"}, {Name: "apps"}, {Name: "operations"}},
+	}
+	if err := conn.Export(introspect.NewIntrospectable(manager), dbus.ObjectPath(dbusconsts.ObjectPath), introspectableInterface); err != nil {
+		return err
+	}
+	guestNode := &introspect.Node{
+		Interfaces: []introspect.Interface{
+			{Name: dbusconsts.GuestInterface, Methods: describeMethods(g)},
+		},
+	}
+	return conn.Export(introspect.NewIntrospectable(guestNode), dbus.ObjectPath(dbusconsts.GuestPath), introspectableInterface)
+}
+
+// propertiesIntrospectable describes an object exported with p, whose
+// properties are all on iface.
+func propertiesIntrospectable(p *prop.Properties, iface string) introspect.Introspectable {
+	return introspect.NewIntrospectable(&introspect.Node{
+		Interfaces: []introspect.Interface{
+			prop.IntrospectData,
+			{Name: iface, Properties: p.Introspection(iface)},
+		},
+	})
+}
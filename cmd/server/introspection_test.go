@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+)
+
+// TestMethodArgNames fails when an exported method is added or changed
+// without naming its arguments in methodArgNames.
+func TestMethodArgNames(t *testing.T) {
+	for _, v := range []interface{}{&LinyapsManager{}, &guest{}, &objectManager{}} {
+		for _, m := range introspect.Methods(v) {
+			names, ok := methodArgNames[m.Name]
+			if !ok {
+				t.Errorf("%s: no argument names", m.Name)
+				continue
+			}
+			if len(names) != len(m.Args) {
+				t.Errorf("%s: %d argument names for %d arguments", m.Name, len(names), len(m.Args))
+			}
+		}
+	}
+}
+
+func TestSignalSignatures(t *testing.T) {
+	for _, signals := range [][]introspect.Signal{managerSignals, objectManagerSignals} {
+		for _, s := range signals {
+			for _, arg := range s.Args {
+				if _, err := dbus.ParseSignature(arg.Type); err != nil || arg.Name == "" {
+					t.Errorf("%s: argument %q of type %q: %v", s.Name, arg.Name, arg.Type, err)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/godbus/dbus/v5"
+	"golang.org/x/sys/unix"
+
+	"linyapsmanager/internal/llcli"
+)
+
+// maxSignal is the highest signal number on Linux, SIGRTMAX.
+const maxSignal = 64
+
+// Kill sends signal to the running instances of appID with ll-cli kill -s
+// and returns the operation ID like ExecuteCommand. signal is a name such
+// as "TERM" or "SIGKILL", or a number; empty means SIGTERM.
+func (m *LinyapsManager) Kill(sender dbus.Sender, appID, signal string) (string, *dbus.Error) {
+	if ref, err := llcli.ParseRef(appID); err != nil || ref.String() != ref.ID {
+		return "", dbus.MakeFailedError(fmt.Errorf("invalid app id %q", appID))
+	}
+	sig, err := parseSignal(signal)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return m.execute(sender, "ll-cli", []string{"kill", "-s", strconv.Itoa(int(sig)), appID}, execOptions{})
+}
+
+// parseSignal accepts a signal name with or without the SIG prefix, in any
+// case, or a signal number.
+func parseSignal(s string) (syscall.Signal, error) {
+	if s == "" {
+		return syscall.SIGTERM, nil
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 1 || n > maxSignal {
+			return 0, fmt.Errorf("invalid signal number %d", n)
+		}
+		return syscall.Signal(n), nil
+	}
+	name := strings.ToUpper(s)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if sig := unix.SignalNum(name); sig != 0 {
+		return sig, nil
+	}
+	return 0, fmt.Errorf("unknown signal %q", s)
+}
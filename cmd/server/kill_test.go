@@ -0,0 +1,30 @@
+package main
+
+import (
+	"syscall"
+	"testing"
+)
+
+func TestParseSignal(t *testing.T) {
+	tests := []struct {
+		in   string
+		want syscall.Signal
+	}{
+		{"", syscall.SIGTERM},
+		{"9", syscall.SIGKILL},
+		{"KILL", syscall.SIGKILL},
+		{"sigint", syscall.SIGINT},
+		{"SIGHUP", syscall.SIGHUP},
+	}
+	for _, tt := range tests {
+		got, err := parseSignal(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("parseSignal(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"0", "65", "-9", "NOPE", "SIG", "9 ; x"} {
+		if _, err := parseSignal(bad); err == nil {
+			t.Errorf("parseSignal(%q) succeeded, want error", bad)
+		}
+	}
+}
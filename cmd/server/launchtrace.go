@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"linyapsmanager/internal/container"
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/streaming"
+)
+
+// envTraceLaunch enables launch tracing when set to 1 or true.
+const envTraceLaunch = "LINYAPS_TRACE_LAUNCH"
+
+// Launch phases, recorded as "phase:<name>" labels holding milliseconds
+// since ll-cli run started, and kept in history.
+const (
+	phaseLabelPrefix = "phase:"
+	phaseFirstOutput = "first_output" // ll-cli run printed its first line
+	phaseContainer   = "container"    // the app's container shows up in ll-cli ps
+	phaseDisplayEnv  = "display_env"  // the container has DISPLAY or WAYLAND_DISPLAY
+)
+
+const (
+	tracePollInterval = 250 * time.Millisecond
+	traceTimeout      = 2 * time.Minute
+)
+
+func traceLaunchesFromEnv() bool {
+	v := os.Getenv(envTraceLaunch)
+	return v == "1" || strings.EqualFold(v, "true")
+}
+
+// setPhase records that phase of operation opID was reached now.
+func setPhase(opID, phase string) {
+	op, ok := streaming.DefaultRegistry.Lookup(opID)
+	if !ok {
+		return
+	}
+	ms := time.Since(op.StartTime).Milliseconds()
+	streaming.DefaultRegistry.SetLabel(opID, phaseLabelPrefix+phase, strconv.FormatInt(ms, 10))
+}
+
+// launchTrace watches for the container of one app launch. Containers that
+// already ran before the launch are ignored.
+type launchTrace struct {
+	appID  string
+	before map[string]bool
+}
+
+// newLaunchTrace must be called before the launch starts.
+func newLaunchTrace(ref string) *launchTrace {
+	t := &launchTrace{appID: llcli.AppIDFromRef(ref), before: map[string]bool{}}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if cs, err := runningContainers(ctx); err == nil {
+		for _, c := range cs {
+			t.before[c.ID] = true
+		}
+	}
+	return t
+}
+
+// run polls until the container phases are recorded, the launch ends or
+// traceTimeout passes.
+func (t *launchTrace) run(opID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), traceTimeout)
+	defer cancel()
+	ticker := time.NewTicker(tracePollInterval)
+	defer ticker.Stop()
+
+	var found llcli.Container
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if op, ok := streaming.DefaultRegistry.Lookup(opID); !ok || op.State != streaming.StateRunning {
+			return
+		}
+		if found.ID == "" {
+			cs, err := runningContainers(ctx)
+			if err != nil {
+				continue
+			}
+			for _, c := range cs {
+				if !t.before[c.ID] && c.Matches(t.appID) {
+					found = c
+					setPhase(opID, phaseContainer)
+					break
+				}
+			}
+		}
+		if found.PID > 0 {
+			env, err := container.Environ(found.PID)
+			if err != nil {
+				return
+			}
+			for _, kv := range env {
+				if strings.HasPrefix(kv, "DISPLAY=") || strings.HasPrefix(kv, "WAYLAND_DISPLAY=") {
+					setPhase(opID, phaseDisplayEnv)
+					return
+				}
+			}
+		}
+	}
+}
+
+// traceSink records the first_output phase of traced launches.
+type traceSink struct {
+	streaming.OutputSink
+
+	mu      sync.Mutex
+	pending map[string]bool // false: not traced or already recorded
+}
+
+func newTraceSink(next streaming.OutputSink) *traceSink {
+	return &traceSink{OutputSink: next, pending: make(map[string]bool)}
+}
+
+func (s *traceSink) EmitOutput(operationID, data string, isStderr bool) error {
+	s.mu.Lock()
+	pending, ok := s.pending[operationID]
+	if !ok {
+		op, found := streaming.DefaultRegistry.Lookup(operationID)
+		pending = found && op.Labels["trace"] == "launch"
+	}
+	s.pending[operationID] = false
+	s.mu.Unlock()
+
+	if pending {
+		setPhase(operationID, phaseFirstOutput)
+	}
+	return s.OutputSink.EmitOutput(operationID, data, isStderr)
+}
+
+func (s *traceSink) EmitComplete(operationID string, exitCode int, errorMsg string, details map[string]interface{}) error {
+	s.mu.Lock()
+	delete(s.pending, operationID)
+	s.mu.Unlock()
+	return s.OutputSink.EmitComplete(operationID, exitCode, errorMsg, details)
+}
+
+// launchPhases extracts the phase labels of an operation.
+func launchPhases(labels map[string]string) map[string]int64 {
+	var phases map[string]int64
+	for k, v := range labels {
+		name, ok := strings.CutPrefix(k, phaseLabelPrefix)
+		if !ok {
+			continue
+		}
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			continue
+		}
+		if phases == nil {
+			phases = map[string]int64{}
+		}
+		phases[name] = ms
+	}
+	return phases
+}
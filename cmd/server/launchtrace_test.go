@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"linyapsmanager/internal/streaming"
+)
+
+func TestTraceSinkRecordsFirstOutput(t *testing.T) {
+	rec := &completeRecorder{done: make(chan map[string]interface{}, 1)}
+	sink := newTraceSink(rec)
+
+	labels := map[string]string{"command": "ll-cli", "operation": "run", "ref": "org.example.app", "trace": "launch"}
+	ctx := streaming.WithLabels(context.Background(), labels)
+	opID, err := streaming.RunCommandStreaming(ctx, sink, nil, "/bin/sh", "-c", "sleep 0.05; echo started; echo more")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-rec.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("operation did not complete")
+	}
+
+	op, _ := streaming.DefaultRegistry.Lookup(opID)
+	phases := launchPhases(op.Labels)
+	if ms, ok := phases[phaseFirstOutput]; !ok || ms < 50 {
+		t.Errorf("phases = %v, want first_output >= 50ms", phases)
+	}
+	if len(sink.pending) != 0 {
+		t.Errorf("pending leaked: %v", sink.pending)
+	}
+}
+
+func TestLaunchPhases(t *testing.T) {
+	got := launchPhases(map[string]string{"operation": "run", "phase:container": "120", "phase:bad": "x"})
+	if len(got) != 1 || got["container"] != 120 {
+		t.Errorf("launchPhases = %v", got)
+	}
+	if got := launchPhases(map[string]string{"operation": "run"}); got != nil {
+		t.Errorf("launchPhases without phases = %v, want nil", got)
+	}
+}
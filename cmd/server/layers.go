@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/llcli"
+)
+
+// ListRuntimes returns the installed runtimes, as a JSON list like ll-cli
+// list --json when asJSON is true, or else as a table.
+func (m *LinyapsManager) ListRuntimes(asJSON bool) (string, *dbus.Error) {
+	return m.listLayers("runtime", asJSON)
+}
+
+// ListBases returns the installed bases like ListRuntimes.
+func (m *LinyapsManager) ListBases(asJSON bool) (string, *dbus.Error) {
+	return m.listLayers("base", asJSON)
+}
+
+func (m *LinyapsManager) listLayers(kind string, asJSON bool) (string, *dbus.Error) {
+	if dbusErr := m.ready.check(); dbusErr != nil {
+		return "", dbusErr
+	}
+	ctx, cancel := replyContext()
+	defer cancel()
+	pkgs, err := m.installed.packages(ctx)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	layers := packagesOfKind(pkgs, kind)
+	if asJSON {
+		data, err := json.MarshalIndent(layers, "", "  ")
+		if err != nil {
+			return "", dbus.MakeFailedError(err)
+		}
+		return string(data) + "\n", nil
+	}
+	return formatLayers(layers), nil
+}
+
+// Page sizes of ListPaged.
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+)
+
+// ListPaged returns one page of the installed packages of typ (app,
+// runtime, base, or all when empty) ordered by id and then newest version
+// first, and the number of such packages. limit 0 means 50, and at most
+// 500 are returned. Each entry holds id, name, version, channel, arch,
+// module, kind, runtime, base and description (s). Pages come from the
+// cached installed list, so paging through it does not run ll-cli again.
+// Apps hidden from the caller by the visibility policy are left out.
+func (m *LinyapsManager) ListPaged(sender dbus.Sender, offset, limit uint32, typ string) ([]map[string]dbus.Variant, uint32, *dbus.Error) {
+	switch typ {
+	case "", "all", "app", "runtime", "base":
+	default:
+		return nil, 0, dbus.MakeFailedError(fmt.Errorf("invalid type %q", typ))
+	}
+	if dbusErr := m.ready.check(); dbusErr != nil {
+		return nil, 0, dbusErr
+	}
+	ctx, cancel := replyContext()
+	defer cancel()
+	pkgs, err := m.installed.packages(ctx)
+	if err != nil {
+		return nil, 0, dbus.MakeFailedError(err)
+	}
+	kindOf := packageKinds(pkgs)
+	var visible []llcli.Package
+	for _, p := range pkgs {
+		p.Kind = kindOf(p)
+		if p.Kind == "app" && m.appHidden(sender, p.ID) {
+			continue
+		}
+		visible = append(visible, p)
+	}
+
+	page, total := pagePackages(visible, typ, int(offset), int(limit))
+	entries := make([]map[string]dbus.Variant, 0, len(page))
+	for _, p := range page {
+		entries = append(entries, map[string]dbus.Variant{
+			"id":          dbus.MakeVariant(p.ID),
+			"name":        dbus.MakeVariant(p.Name),
+			"version":     dbus.MakeVariant(p.Version),
+			"channel":     dbus.MakeVariant(p.Channel),
+			"arch":        dbus.MakeVariant(p.Arch),
+			"module":      dbus.MakeVariant(p.Module),
+			"kind":        dbus.MakeVariant(p.Kind),
+			"runtime":     dbus.MakeVariant(p.Runtime),
+			"base":        dbus.MakeVariant(p.Base),
+			"description": dbus.MakeVariant(p.Description),
+		})
+	}
+	return entries, uint32(total), nil
+}
+
+// pagePackages sorts the packages of kind, or all of pkgs when kind is
+// empty or "all", and returns limit of them from offset with their number.
+// Kind must already be set on pkgs.
+func pagePackages(pkgs []llcli.Package, kind string, offset, limit int) ([]llcli.Package, int) {
+	var matching []llcli.Package
+	for _, p := range pkgs {
+		if kind == "" || kind == "all" || p.Kind == kind {
+			matching = append(matching, p)
+		}
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		if matching[i].ID != matching[j].ID {
+			return matching[i].ID < matching[j].ID
+		}
+		return llcli.CompareVersions(matching[i].Version, matching[j].Version) > 0
+	})
+	if limit <= 0 {
+		limit = defaultPageLimit
+	}
+	limit = min(limit, maxPageLimit)
+	offset = min(offset, len(matching))
+	return matching[offset:min(offset+limit, len(matching))], len(matching)
+}
+
+// packagesOfKind returns the packages of kind app, runtime or base.
+func packagesOfKind(pkgs []llcli.Package, kind string) []llcli.Package {
+	kindOf := packageKinds(pkgs)
+	out := []llcli.Package{}
+	for _, p := range pkgs {
+		if kindOf(p) == kind {
+			out = append(out, p)
+		}
+	}
+	return out
+}
+
+// packageKinds returns a function telling the kind of each of pkgs. Before
+// ll-cli reported bases as a kind of their own they were listed as
+// runtimes, so a runtime that a package names as its base counts as one.
+func packageKinds(pkgs []llcli.Package) func(llcli.Package) string {
+	bases := make(map[string]bool)
+	for _, p := range pkgs {
+		if p.Base != "" {
+			bases[llcli.AppIDFromRef(p.Base)] = true
+		}
+	}
+	return func(p llcli.Package) string {
+		switch {
+		case p.Kind == "":
+			return "app"
+		case p.Kind == "runtime" && bases[p.ID]:
+			return "base"
+		}
+		return p.Kind
+	}
+}
+
+// PrunePreview lists what ll-cli prune would remove: the installed
+// runtimes and bases that no installed app, nor a runtime one of them
+// uses, depends on. Each entry holds id, version, channel, arch, module,
+// kind (s) and size (t, bytes on disk, 0 if unknown); reclaimable is their
+// total. A dependency given as a partial version keeps every installed
+// version it may resolve to, so the preview errs on the side of keeping.
+func (m *LinyapsManager) PrunePreview() ([]map[string]dbus.Variant, uint64, *dbus.Error) {
+	if dbusErr := m.ready.check(); dbusErr != nil {
+		return nil, 0, dbusErr
+	}
+	ctx, cancel := replyContext()
+	defer cancel()
+	pkgs, err := m.installed.packages(ctx)
+	if err != nil {
+		return nil, 0, dbus.MakeFailedError(err)
+	}
+	kindOf := packageKinds(pkgs)
+	var total uint64
+	entries := []map[string]dbus.Variant{}
+	for _, p := range unusedLayers(pkgs) {
+		size := layerSize(p)
+		total += size
+		entries = append(entries, map[string]dbus.Variant{
+			"id":      dbus.MakeVariant(p.ID),
+			"version": dbus.MakeVariant(p.Version),
+			"channel": dbus.MakeVariant(p.Channel),
+			"arch":    dbus.MakeVariant(p.Arch),
+			"module":  dbus.MakeVariant(p.Module),
+			"kind":    dbus.MakeVariant(kindOf(p)),
+			"size":    dbus.MakeVariant(size),
+		})
+	}
+	return entries, total, nil
+}
+
+// Prune removes the runtimes and bases PrunePreview lists with ll-cli
+// prune and returns the operation ID like ExecuteCommand.
+func (m *LinyapsManager) Prune(sender dbus.Sender) (string, *dbus.Error) {
+	return m.execute(sender, "ll-cli", []string{"prune"}, execOptions{})
+}
+
+// unusedLayers returns the runtimes and bases among pkgs that nothing
+// installed depends on.
+func unusedLayers(pkgs []llcli.Package) []llcli.Package {
+	kindOf := packageKinds(pkgs)
+	var deps []llcli.Ref
+	addDeps := func(p llcli.Package) {
+		for _, dep := range []string{p.Runtime, p.Base} {
+			if r, err := llcli.ParseRef(dep); err == nil {
+				deps = append(deps, r)
+			}
+		}
+	}
+	used := func(p llcli.Package) bool {
+		for _, r := range deps {
+			if providesLayer(p, r) {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, p := range pkgs {
+		if kindOf(p) == "app" {
+			addDeps(p)
+		}
+	}
+	// Runtimes in use keep their base
+	for _, p := range pkgs {
+		if kindOf(p) == "runtime" && used(p) {
+			addDeps(p)
+		}
+	}
+	unused := []llcli.Package{}
+	for _, p := range pkgs {
+		if kindOf(p) != "app" && !used(p) {
+			unused = append(unused, p)
+		}
+	}
+	return unused
+}
+
+// providesLayer reports whether p is the runtime or base r refers to. Apps
+// name them by a partial version such as 23.1.0, which any 23.1.0.x
+// satisfies.
+func providesLayer(p llcli.Package, r llcli.Ref) bool {
+	return r.ID == p.ID && (r.Version == "" || p.Version == r.Version || strings.HasPrefix(p.Version, r.Version+"."))
+}
+
+func formatLayers(pkgs []llcli.Package) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tVERSION\tCHANNEL\tMODULE\tARCH")
+	for _, p := range pkgs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", p.ID, p.Version, p.Channel, p.Module, p.Arch)
+	}
+	w.Flush()
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"linyapsmanager/internal/llcli"
+)
+
+func TestPackagesOfKind(t *testing.T) {
+	pkgs := []llcli.Package{
+		{ID: "org.example.app", Kind: "app", Runtime: "main:org.deepin.runtime.dtk/23.1", Base: "main:org.deepin.base/23.1"},
+		{ID: "org.example.old"},
+		{ID: "org.deepin.runtime.dtk", Kind: "runtime"},
+		{ID: "org.deepin.base", Kind: "runtime"},
+		{ID: "org.deepin.foundation", Kind: "base"},
+	}
+	tests := map[string][]string{
+		"app":     {"org.example.app", "org.example.old"},
+		"runtime": {"org.deepin.runtime.dtk"},
+		"base":    {"org.deepin.base", "org.deepin.foundation"},
+	}
+	for kind, want := range tests {
+		got := packagesOfKind(pkgs, kind)
+		if len(got) != len(want) {
+			t.Errorf("packagesOfKind(%s) = %+v, want %v", kind, got, want)
+			continue
+		}
+		for i, p := range got {
+			if p.ID != want[i] {
+				t.Errorf("packagesOfKind(%s)[%d] = %s, want %s", kind, i, p.ID, want[i])
+			}
+		}
+	}
+}
+
+func TestUnusedLayers(t *testing.T) {
+	pkgs := []llcli.Package{
+		{ID: "org.example.app", Kind: "app", Runtime: "main:org.deepin.runtime.dtk/23.1/x86_64", Base: "main:org.deepin.base/23.1.0"},
+		{ID: "org.deepin.runtime.dtk", Version: "23.1.0.2", Kind: "runtime", Base: "main:org.deepin.foundation/23.0.0"},
+		{ID: "org.deepin.runtime.dtk", Version: "23.0.1", Kind: "runtime"},
+		{ID: "org.deepin.base", Version: "23.1.0", Kind: "runtime"},
+		{ID: "org.deepin.base", Version: "20.0.0", Kind: "runtime"},
+		{ID: "org.deepin.foundation", Version: "23.0.0", Kind: "base"},
+		{ID: "org.deepin.foundation", Version: "20.0.0", Kind: "base"},
+	}
+	var got []string
+	for _, p := range unusedLayers(pkgs) {
+		got = append(got, p.ID+"/"+p.Version)
+	}
+	want := []string{"org.deepin.runtime.dtk/23.0.1", "org.deepin.base/20.0.0", "org.deepin.foundation/20.0.0"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("unusedLayers = %v, want %v", got, want)
+	}
+}
+
+func TestProvidesLayer(t *testing.T) {
+	p := llcli.Package{ID: "org.deepin.base", Version: "23.1.0.2"}
+	tests := []struct {
+		ref  string
+		want bool
+	}{
+		{"main:org.deepin.base/23.1.0/x86_64", true},
+		{"org.deepin.base/23.1.0.2", true},
+		{"org.deepin.base", true},
+		{"org.deepin.base/23.1", true},
+		{"org.deepin.base/23.1.1", false},
+		{"org.deepin.base/23.1.0.20", false},
+		{"org.deepin.runtime.dtk/23.1.0", false},
+	}
+	for _, tt := range tests {
+		r, err := llcli.ParseRef(tt.ref)
+		if err != nil {
+			t.Fatalf("ParseRef(%q): %v", tt.ref, err)
+		}
+		if got := providesLayer(p, r); got != tt.want {
+			t.Errorf("providesLayer(%s) = %v, want %v", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestPagePackages(t *testing.T) {
+	var pkgs []llcli.Package
+	for _, s := range []string{"org.c/1", "org.a/1.2", "org.b/1", "org.a/1.10", "org.base/1"} {
+		r, _ := llcli.ParseRef(s)
+		kind := "app"
+		if r.ID == "org.base" {
+			kind = "base"
+		}
+		pkgs = append(pkgs, llcli.Package{ID: r.ID, Version: r.Version, Kind: kind})
+	}
+	ids := func(page []llcli.Package) string {
+		var s []string
+		for _, p := range page {
+			s = append(s, p.ID+"/"+p.Version)
+		}
+		return strings.Join(s, " ")
+	}
+	tests := []struct {
+		kind          string
+		offset, limit int
+		want          string
+		total         int
+	}{
+		{"", 0, 0, "org.a/1.10 org.a/1.2 org.b/1 org.base/1 org.c/1", 5},
+		{"app", 1, 2, "org.a/1.2 org.b/1", 4},
+		{"app", 3, 2, "org.c/1", 4},
+		{"app", 9, 2, "", 4},
+		{"base", 0, 10, "org.base/1", 1},
+		{"runtime", 0, 10, "", 0},
+	}
+	for _, tt := range tests {
+		page, total := pagePackages(pkgs, tt.kind, tt.offset, tt.limit)
+		if got := ids(page); got != tt.want || total != tt.total {
+			t.Errorf("pagePackages(%q, %d, %d) = %q, %d, want %q, %d", tt.kind, tt.offset, tt.limit, got, total, tt.want, tt.total)
+		}
+	}
+}
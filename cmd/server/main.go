@@ -1,12 +1,14 @@
 package main
 
 import (
-	"context"
+	"errors"
+	"flag"
 	"log"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -17,8 +19,16 @@ import (
 	"linyapsmanager/internal/dbusconsts"
 	"linyapsmanager/internal/dbusutil"
 	"linyapsmanager/internal/envgrab"
+	"linyapsmanager/internal/history"
+	"linyapsmanager/internal/jobqueue"
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/lockout"
+	"linyapsmanager/internal/otlp"
 	"linyapsmanager/internal/proxy"
+	"linyapsmanager/internal/sdnotify"
 	"linyapsmanager/internal/streaming"
+	"linyapsmanager/internal/telemetry"
+	"linyapsmanager/internal/visibility"
 )
 
 const (
@@ -47,7 +57,35 @@ var (
 
 // LinyapsManager exposes a single D-Bus method for executing whitelisted commands.
 type LinyapsManager struct {
-	emitter *streaming.Emitter
+	conn      *dbus.Conn
+	emitter   *streaming.Emitter
+	sink      streaming.OutputSink
+	ready     *readiness
+	telemetry *telemetry.Reporter
+	history   *history.Store
+	installed *installedIndex
+	tokens    *tokenStore
+	queue     *jobqueue.Queue
+	lockout   *lockout.Store // nil if the disabled set could not be loaded
+	tracer    *otlp.Exporter // nil unless OTLP export is configured
+	audit     *auditor       // nil if the audit log could not be opened
+	// visibility limits the apps each user sees; nil if it could not be loaded.
+	visibility *visibility.Store
+	// traceLaunches records the phase timings of app launches in history.
+	traceLaunches bool
+	// repos checks the repository configuration for mistakes.
+	repos *repoChecker
+	// autoInstallRuntime installs the missing runtime or base of an app
+	// that fails to start without it; see startLaunch.
+	autoInstallRuntime bool
+
+	// aliases are the additional well-known names requested at startup.
+	aliases busAliases
+
+	// predecessor is the unique name of the instance we took over from, if any.
+	predecessor string
+	// draining is set once another instance has taken over the bus name.
+	draining atomic.Bool
 }
 
 // ExecuteCommand validates and executes a whitelisted command.
@@ -59,9 +97,40 @@ type LinyapsManager struct {
 //
 // Returns:
 //   - operationID: Unique ID to track this operation's output signals
-func (m *LinyapsManager) ExecuteCommand(command string, args []string) (string, *dbus.Error) {
+//
+// The operation times out after the default of its class; see timeoutFor.
+func (m *LinyapsManager) ExecuteCommand(sender dbus.Sender, command string, args []string) (string, *dbus.Error) {
+	return m.execute(sender, command, args, execOptions{})
+}
+
+// ExecuteCommandWithOptions is ExecuteCommand with options; see
+// parseExecOptions for the recognised keys.
+func (m *LinyapsManager) ExecuteCommandWithOptions(sender dbus.Sender, command string, args []string, options map[string]dbus.Variant) (string, *dbus.Error) {
+	opts, err := parseExecOptions(options)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return m.execute(sender, command, args, opts)
+}
+
+func (m *LinyapsManager) execute(sender dbus.Sender, command string, args []string, opts execOptions) (opID string, dbusErr *dbus.Error) {
 	log.Printf("[INFO] ExecuteCommand command=%s args=%v", command, args)
 
+	span := m.tracer.Start("ExecuteCommand", otlp.KindServer)
+	span.SetAttr("linyaps.command", command)
+	span.SetAttr("linyaps.caller", string(sender))
+	defer func() {
+		span.SetAttr("linyaps.operation_id", opID)
+		if dbusErr != nil {
+			span.SetError(dbusErr)
+		}
+		span.Finish()
+	}()
+
+	if m.draining.Load() {
+		return "", dbus.MakeFailedError(errors.New("service is being replaced by a new instance, retry"))
+	}
+
 	// Validate command against whitelist
 	program, validatedArgs, err := cmdwhitelist.ValidateCommand(command, args)
 	if err != nil {
@@ -69,23 +138,87 @@ func (m *LinyapsManager) ExecuteCommand(command string, args []string) (string,
 		return "", dbus.MakeFailedError(err)
 	}
 
+	// ll-cli needs the linglong package manager; fail fast until it answers
+	if cmdwhitelist.NeedsSpecialEnv(command) {
+		if dbusErr := m.ready.check(); dbusErr != nil {
+			log.Printf("[ERROR] %s rejected: backend not ready", command)
+			return "", dbusErr
+		}
+	}
+
+	if command == "ll-cli" {
+		if dbusErr := checkExecPolicy(sender, validatedArgs); dbusErr != nil {
+			return "", dbusErr
+		}
+		// Skip ll-cli entirely for installs of refs that are already installed
+		if !opts.autoLaunch {
+			if opID, ok := m.installFastPath(sender, validatedArgs); ok {
+				return opID, nil
+			}
+		}
+	}
+
 	// Build environment
 	env := buildCommandEnv(command)
 
+	// Confine the child with the limits or scope configured for this operation type
+	labels := map[string]string{"command": command, "caller": string(sender)}
+	if command == "ll-cli" {
+		var policy map[string]string
+		program, validatedArgs, policy = confineLLCli(program, validatedArgs)
+		for k, v := range policy {
+			labels[k] = v
+		}
+	}
+
+	if ref := labels["ref"]; ref != "" {
+		span.SetAttr("linyaps.app_id", llcli.AppIDFromRef(ref))
+	}
+	if labels["operation"] == "run" && m.appDisabled(llcli.AppIDFromRef(labels["ref"])) {
+		log.Printf("[INFO] refused to run disabled %s", labels["ref"])
+		return "", appDisabledError(llcli.AppIDFromRef(labels["ref"]))
+	}
+	if labels["operation"] == "run" && m.appHidden(sender, llcli.AppIDFromRef(labels["ref"])) {
+		log.Printf("[INFO] refused to run %s hidden from %s", labels["ref"], sender)
+		return "", appHiddenError(llcli.AppIDFromRef(labels["ref"]))
+	}
+	linkSpan(labels, span)
+
+	var trace *launchTrace
+	if m.traceLaunches && labels["operation"] == "run" && labels["ref"] != "" {
+		trace = newLaunchTrace(labels["ref"])
+		labels["trace"] = "launch"
+	}
+
 	// Execute command with streaming output
-	ctx, cancel := context.WithTimeout(context.Background(), cmdTimeout)
-	opID, err := streaming.RunCommand(ctx, m.emitter, env, program, validatedArgs...)
-	if err != nil {
-		cancel()
-		log.Printf("[ERROR] failed to start command: %v", err)
-		return "", dbus.MakeFailedError(err)
+	class := labels["operation"]
+	if class == "" {
+		class = command
+	}
+	ctx := m.operationContext(labels, opts.timeoutFor(class))
+	if labels["operation"] == "run" && labels["ref"] != "" {
+		opID = m.startLaunch(ctx, env, program, validatedArgs, labels["ref"], opts.pty)
+	} else if opts.autoLaunch {
+		opID = m.installAndLaunch(ctx, sender, env, program, validatedArgs, args)
+	} else {
+		run := streaming.RunCommandStreaming
+		if opts.pty {
+			run = streaming.RunCommandPTY
+		}
+		opID, err = run(ctx, m.sink, env, program, validatedArgs...)
+		if err != nil {
+			log.Printf("[ERROR] failed to start command: %v", err)
+			return "", dbus.MakeFailedError(err)
+		}
 	}
 
-	// Cancel context when command completes (handled by streaming)
-	go func() {
-		<-ctx.Done()
-		cancel()
-	}()
+	// Record which version a launch runs so crashes can be attributed to it
+	if labels["operation"] == "run" && labels["ref"] != "" {
+		go m.tagLaunch(opID, labels["ref"])
+	}
+	if trace != nil {
+		go trace.run(opID)
+	}
 
 	log.Printf("[INFO] command started: opID=%s", opID)
 	return opID, nil
@@ -108,6 +241,12 @@ func (m *LinyapsManager) Quit() *dbus.Error {
 	return nil
 }
 
+// lastLine returns the last non-empty line of s, for compact error messages.
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
 // buildCommandEnv builds the environment for running commands.
 func buildCommandEnv(command string) []string {
 	env := os.Environ()
@@ -138,11 +277,16 @@ func loadUserEnv() []string {
 	if err != nil {
 		return nil
 	}
-	lines := strings.Split(string(data), "\n")
+	return parseEnvFile(string(data))
+}
+
+// parseEnvFile returns the KEY=VALUE lines of an env file, skipping blank
+// lines, comments and lines without a key.
+func parseEnvFile(data string) []string {
 	var env []string
-	for _, l := range lines {
+	for _, l := range strings.Split(data, "\n") {
 		l = strings.TrimSpace(l)
-		if l == "" || strings.HasPrefix(l, "#") || !strings.Contains(l, "=") {
+		if l == "" || strings.HasPrefix(l, "#") || strings.Index(l, "=") < 1 {
 			continue
 		}
 		env = append(env, l)
@@ -171,34 +315,117 @@ func enforceEnglishLocale(env []string) []string {
 }
 
 func main() {
+	takeover := flag.Bool("takeover", false, "replace a running instance; it exits once its in-flight operations finish")
+	flag.Parse()
+
 	log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+	started := time.Now()
 
-	conn, err := dbusutil.Connect("")
+	auditor := openAuditor()
+	defer auditor.close()
+	conn, err := dbusutil.Connect("", auditor.options()...)
 	if err != nil {
 		log.Fatalf("connect bus failed: %v", err)
 	}
 	defer conn.Close()
+	auditor.start(conn)
 
-	reply, err := conn.RequestName(dbusconsts.BusName, dbus.NameFlagDoNotQueue)
+	nameLost, err := watchNameLost(conn)
 	if err != nil {
-		log.Fatalf("request name failed: %v", err)
+		log.Fatalf("%v", err)
 	}
-	if reply != dbus.RequestNameReplyPrimaryOwner {
-		log.Fatalf("name %s already taken", dbusconsts.BusName)
+	predecessor, err := requestBusName(conn, *takeover)
+	if err != nil {
+		log.Fatalf("%v", err)
 	}
+	aliases := requestBusAliases(conn, busAliasesFromEnv(), *takeover)
 
 	emitter := streaming.NewEmitter(conn)
-	mgr := &LinyapsManager{emitter: emitter}
+	defer func() {
+		emitter.Close()
+		st := emitter.Stats()
+		log.Printf("[INFO] signal stats: emitted=%d failed=%d dropped=%d avg=%s max=%s",
+			st.Emitted, st.Failed, st.Dropped, st.AvgLatency, st.MaxLatency)
+	}()
+	var sink streaming.OutputSink = emitter
+	if faults, err := streaming.FaultsFromEnv(); err != nil {
+		log.Fatalf("%s: %v", streaming.EnvFaults, err)
+	} else if !faults.IsZero() {
+		log.Printf("[WARN] fault injection enabled (%s), for development only", faults)
+		sink = streaming.NewFaultSink(emitter, faults)
+	}
+	reporter := telemetry.NewReporter(telemetry.DefaultConfigPath(), os.Getenv(telemetry.EnvURL), version)
+	sink = telemetrySink{OutputSink: sink, reporter: reporter}
+	sink = newResultSink(sink)
+	sink = newCrashSink(sink, conn)
+	tracer := openTracer()
+	defer tracer.Close()
+	traceLaunches := traceLaunchesFromEnv()
+	if traceLaunches {
+		log.Printf("[INFO] launch tracing enabled")
+		sink = newTraceSink(sink)
+	}
+	speeds := newDownloadSpeeds()
+	mgr := &LinyapsManager{
+		conn:               conn,
+		emitter:            emitter,
+		sink:               sink,
+		ready:              newReadiness(),
+		telemetry:          reporter,
+		history:            openHistory(speeds),
+		installed:          &installedIndex{},
+		tokens:             newTokenStore(),
+		queue:              newJobQueue(emitter),
+		lockout:            openLockout(),
+		visibility:         openVisibility(),
+		tracer:             tracer,
+		audit:              auditor,
+		traceLaunches:      traceLaunches,
+		aliases:            aliases,
+		predecessor:        predecessor,
+		autoInstallRuntime: autoInstallRuntimeFromEnv(),
+		repos:              newRepoChecker(conn),
+	}
+	if err := holdQueue(conn, mgr.queue, predecessor); err != nil {
+		log.Printf("[WARN] cannot wait for %s before starting queued operations: %v", predecessor, err)
+	}
+	streaming.DefaultRegistry.Watch(mgr.installed.invalidate)
+	mgr.ready.start()
 	conn.Export(mgr, dbus.ObjectPath(dbusconsts.ObjectPath), dbusconsts.Interface)
+	g := &guest{m: mgr}
+	conn.Export(g, dbus.ObjectPath(dbusconsts.GuestPath), dbusconsts.GuestInterface)
+
+	objects := &objectManager{operations: newOperationObjects(conn), apps: newAppObjects(conn, mgr.installed)}
+	conn.Export(objects, dbus.ObjectPath(dbusconsts.ObjectPath), objectManagerInterface)
+	if err := exportIntrospection(conn, mgr, g, objects); err != nil {
+		log.Printf("[WARN] failed to export introspection data: %v", err)
+	}
+	streaming.DefaultRegistry.Watch(objects.operations.update)
+	streaming.DefaultRegistry.Watch(objects.apps.update)
+	streaming.DefaultRegistry.Watch(mgr.repos.update)
+	emitter.WatchProgress(objects.operations.progress)
+	if mgr.history != nil {
+		emitter.WatchProgress(speeds.observe)
+	}
+	go func() {
+		if mgr.ready.wait(probeDeadline) {
+			objects.apps.refresh()
+			mgr.repos.check()
+		}
+	}()
 
-	log.Printf("[INFO] D-Bus service started: name=%s path=%s iface=%s",
-		dbusconsts.BusName, dbusconsts.ObjectPath, dbusconsts.Interface)
+	snapshots := startSnapshots(mgr)
+	defer snapshots.stop()
+
+	state := newServiceState(conn.Names()[0], started)
+	state.Aliases = aliases.owned
 
 	// Ensure dconf dir exists for apps expecting /tmp/linglong-runtime-<uid>/dconf.
 	if p, err := proxy.EnsureDconfDir(); err != nil {
 		log.Printf("[WARN] failed to ensure dconf dir %s: %v", p, err)
 	} else {
 		log.Printf("[INFO] dconf dir ready at %s", p)
+		state.DconfDir = p
 	}
 
 	// Optionally spawn a system-bus proxy socket for containers to consume.
@@ -206,6 +433,7 @@ func main() {
 		log.Printf("[WARN] failed to spawn proxy: %v", err)
 	} else if p != "" {
 		log.Printf("[INFO] proxy socket ready at %s (set LINYAPS_DBUS_ADDRESS to use)", p)
+		state.ProxySocket = p
 		defer func() {
 			if cleanup != nil {
 				cleanup()
@@ -218,6 +446,7 @@ func main() {
 		log.Printf("[WARN] failed to spawn session proxy: %v", err)
 	} else if p != "" {
 		log.Printf("[INFO] session proxy socket ready at %s (auto-injected into env)", p)
+		state.SessionProxySocket = p
 		defer func() {
 			if cleanup != nil {
 				cleanup()
@@ -225,9 +454,27 @@ func main() {
 		}()
 	}
 
+	statePath := stateFilePath()
+	if err := writeStateFile(statePath, state); err != nil {
+		log.Printf("[WARN] failed to write state file: %v", err)
+	} else {
+		defer removeStateFile(statePath)
+	}
+	if _, err := sdnotify.Notify("READY=1\nSTATUS=serving " + dbusconsts.BusName); err != nil {
+		log.Printf("[WARN] failed to notify readiness: %v", err)
+	}
+	log.Printf("[INFO] ready: name=%s unique=%s path=%s version=%s pid=%d state=%s",
+		state.BusName, state.UniqueName, state.ObjectPath, state.Version, state.PID, statePath)
+
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	<-sigCh
+	select {
+	case <-sigCh:
+	case <-nameLost:
+		log.Printf("[INFO] replaced by a new instance, draining in-flight operations")
+		mgr.draining.Store(true)
+		drain(sigCh)
+	}
 
 	log.Printf("[INFO] shutting down")
 }
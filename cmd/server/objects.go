@@ -0,0 +1,140 @@
+package main
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/prop"
+
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/streaming"
+)
+
+const (
+	objectManagerInterface = "org.freedesktop.DBus.ObjectManager"
+	propertiesInterface    = "org.freedesktop.DBus.Properties"
+)
+
+// operationObjects exposes each running operation as a child object under
+// dbusconsts.OperationsPath, announced through org.freedesktop.DBus.ObjectManager
+// on the service object. Objects are removed once their operation finishes;
+// the final State change is emitted just before removal.
+type operationObjects struct {
+	conn *dbus.Conn
+
+	mu    sync.Mutex
+	props map[dbus.ObjectPath]*prop.Properties
+}
+
+func newOperationObjects(conn *dbus.Conn) *operationObjects {
+	return &operationObjects{conn: conn, props: make(map[dbus.ObjectPath]*prop.Properties)}
+}
+
+// operationPath maps an operation ID to its object path. Hyphens are not
+// valid in path elements, so they become underscores.
+func operationPath(id string) dbus.ObjectPath {
+	return dbus.ObjectPath(dbusconsts.OperationsPath + "/" + strings.ReplaceAll(id, "-", "_"))
+}
+
+// objectManager implements org.freedesktop.DBus.ObjectManager on the
+// service object, listing the operation and app objects.
+type objectManager struct {
+	operations *operationObjects
+	apps       *appObjects
+}
+
+// GetManagedObjects implements org.freedesktop.DBus.ObjectManager.
+func (om *objectManager) GetManagedObjects() (map[dbus.ObjectPath]map[string]map[string]dbus.Variant, *dbus.Error) {
+	objects := make(map[dbus.ObjectPath]map[string]map[string]dbus.Variant)
+	om.operations.managed(objects)
+	om.apps.managed(objects)
+	return objects, nil
+}
+
+// managed adds the operation objects to objects.
+func (o *operationObjects) managed(objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for path, p := range o.props {
+		all, _ := p.GetAll(dbusconsts.OperationInterface)
+		objects[path] = map[string]map[string]dbus.Variant{dbusconsts.OperationInterface: all}
+	}
+}
+
+// update is a streaming.Registry watcher that adds, updates and removes the
+// object of an operation.
+func (o *operationObjects) update(op streaming.Operation) {
+	path := operationPath(op.ID)
+	if op.State == streaming.StateRunning {
+		o.add(path, op)
+		return
+	}
+
+	o.mu.Lock()
+	p, ok := o.props[path]
+	delete(o.props, path)
+	o.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	p.SetMust(dbusconsts.OperationInterface, "ExitCode", int32(op.ExitCode))
+	p.SetMust(dbusconsts.OperationInterface, "State", string(op.State))
+	o.conn.Export(nil, path, dbusconsts.OperationInterface)
+	o.conn.Export(nil, path, propertiesInterface)
+	o.conn.Export(nil, path, introspectableInterface)
+	emitObjectManager(o.conn, "InterfacesRemoved", path, []string{dbusconsts.OperationInterface})
+}
+
+// progress is a streaming.Emitter progress watcher updating the Progress
+// property of the operation's object.
+func (o *operationObjects) progress(id string, p streaming.Progress) {
+	o.mu.Lock()
+	props, ok := o.props[operationPath(id)]
+	o.mu.Unlock()
+	if ok {
+		props.SetMust(dbusconsts.OperationInterface, "Progress", int32(p.Percent))
+	}
+}
+
+func (o *operationObjects) add(path dbus.ObjectPath, op streaming.Operation) {
+	constant := func(v interface{}) *prop.Prop {
+		return &prop.Prop{Value: v, Emit: prop.EmitConst}
+	}
+	changing := func(v interface{}) *prop.Prop {
+		return &prop.Prop{Value: v, Emit: prop.EmitTrue}
+	}
+	p, err := prop.Export(o.conn, path, prop.Map{
+		dbusconsts.OperationInterface: {
+			"Id":       constant(op.ID),
+			"Command":  constant(op.Labels["command"]),
+			"Ref":      constant(op.Labels["ref"]),
+			"Caller":   constant(op.Labels["caller"]),
+			"State":    changing(string(op.State)),
+			"Progress": changing(int32(-1)),
+			"ExitCode": changing(int32(0)),
+		},
+	})
+	if err != nil {
+		log.Printf("[WARN] failed to export operation object %s: %v", path, err)
+		return
+	}
+	o.conn.Export(propertiesIntrospectable(p, dbusconsts.OperationInterface), path, introspectableInterface)
+
+	o.mu.Lock()
+	o.props[path] = p
+	o.mu.Unlock()
+
+	all, _ := p.GetAll(dbusconsts.OperationInterface)
+	emitObjectManager(o.conn, "InterfacesAdded", path, map[string]map[string]dbus.Variant{dbusconsts.OperationInterface: all})
+}
+
+// emitObjectManager emits an org.freedesktop.DBus.ObjectManager signal.
+func emitObjectManager(conn *dbus.Conn, member string, values ...interface{}) {
+	if err := conn.Emit(dbusconsts.ObjectPath, objectManagerInterface+"."+member, values...); err != nil {
+		log.Printf("[WARN] failed to emit %s: %v", member, err)
+	}
+}
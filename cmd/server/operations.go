@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/polkit"
+	"linyapsmanager/internal/streaming"
+)
+
+// ownerLabel is the operation label holding the uid of the caller that
+// started it; see checkOwner.
+const ownerLabel = "caller_uid"
+
+// GetOperationStatus returns the state of a running or recently finished
+// operation together with the policy that applies to it.
+//
+// The returned dictionary contains:
+//   - id, program (s), args (as), state (s), exit_code (i), error (s)
+//   - start_time, end_time (x): unix seconds, end_time is 0 while running
+//   - duration_ms (x): run time so far on the monotonic clock
+//   - wall_duration_ms (x): the same on the wall clock; differs if the clock jumped
+//   - timeout_sec (x): effective timeout, 0 if the operation has no deadline
+//   - queued (b): waiting in the job queue; start_time is then when it was queued
+//   - queue_ms (x): time spent in the job queue before it started
+//   - one string entry per policy label, e.g. command, operation, ref, limits,
+//     scope, caller and caller_uid, the uid of the user that started it
+//
+// Only the user that started the operation, root and the daemon's user may
+// query it.
+func (m *LinyapsManager) GetOperationStatus(sender dbus.Sender, operationID string) (map[string]dbus.Variant, *dbus.Error) {
+	if !streaming.ValidOperationID(operationID) {
+		return nil, dbus.MakeFailedError(fmt.Errorf("invalid operation id %q", operationID))
+	}
+	op, ok := streaming.DefaultRegistry.Lookup(operationID)
+	if !ok {
+		if status, ok := m.predecessorStatus(operationID); ok {
+			if dbusErr := m.checkOwner(sender, operationID, statusOwner(status)); dbusErr != nil {
+				return nil, dbusErr
+			}
+			return status, nil
+		}
+		return nil, dbus.MakeFailedError(fmt.Errorf("unknown operation %q", operationID))
+	}
+	if dbusErr := m.checkOwner(sender, operationID, op.Labels[ownerLabel]); dbusErr != nil {
+		return nil, dbusErr
+	}
+	return operationStatus(op), nil
+}
+
+// ListOperations returns the operations that are still running, oldest
+// first, so a restarted frontend can pick up in-flight installs. Each entry
+// has the same keys as GetOperationStatus; command, operation and ref tell
+// what it is doing. Operations of other users are left out, except for
+// root and the daemon's user.
+func (m *LinyapsManager) ListOperations(sender dbus.Sender) ([]map[string]dbus.Variant, *dbus.Error) {
+	uid, err := polkit.SenderUID(m.conn, sender)
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	ops := streaming.DefaultRegistry.Running()
+	out := make([]map[string]dbus.Variant, 0, len(ops))
+	for _, op := range ops {
+		if mayAccess(uid, op.Labels[ownerLabel]) {
+			out = append(out, operationStatus(op))
+		}
+	}
+	return out, nil
+}
+
+// CancelOperation stops a running operation. Its child process group gets
+// SIGTERM, and SIGKILL if it is still running 5 seconds later; Complete then
+// reports exit code -1, error "operation cancelled" and details
+// cancelled=true, and the operation state becomes "cancelled". Only the
+// user that started the operation, root and the daemon's user may cancel it.
+func (m *LinyapsManager) CancelOperation(sender dbus.Sender, operationID string) *dbus.Error {
+	if !streaming.ValidOperationID(operationID) {
+		return dbus.MakeFailedError(fmt.Errorf("invalid operation id %q", operationID))
+	}
+	op, ok := streaming.DefaultRegistry.Lookup(operationID)
+	if !ok && m.predecessor != "" {
+		status, ok := m.predecessorStatus(operationID)
+		if !ok {
+			return dbus.MakeFailedError(fmt.Errorf("unknown operation %q", operationID))
+		}
+		if dbusErr := m.checkOwner(sender, operationID, statusOwner(status)); dbusErr != nil {
+			return dbusErr
+		}
+		obj := m.conn.Object(m.predecessor, dbus.ObjectPath(dbusconsts.ObjectPath))
+		if err := obj.Call(dbusconsts.Interface+".CancelOperation", 0, operationID).Err; err != nil {
+			return dbus.MakeFailedError(err)
+		}
+		return nil
+	}
+	if ok {
+		if dbusErr := m.checkOwner(sender, operationID, op.Labels[ownerLabel]); dbusErr != nil {
+			return dbusErr
+		}
+	}
+	if err := streaming.DefaultRegistry.Cancel(operationID); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	log.Printf("[INFO] operation %s cancelled by %s", operationID, sender)
+	return nil
+}
+
+// ReplayOutput returns the output of a running or recently finished
+// operation with sequence numbers after fromOffset (0 for all), as
+// (data, isStderr, seq) entries, and whether the operation has completed.
+// A late subscriber first subscribes to Output and Complete, then replays
+// and skips live signals with seq numbers already replayed. At most the
+// last 128 KiB of output is kept per operation; a gap after fromOffset
+// means older output was dropped. Only the user that started the
+// operation, root and the daemon's user may replay it.
+func (m *LinyapsManager) ReplayOutput(sender dbus.Sender, operationID string, fromOffset uint64) ([]streaming.Chunk, bool, *dbus.Error) {
+	if !streaming.ValidOperationID(operationID) {
+		return nil, false, dbus.MakeFailedError(fmt.Errorf("invalid operation id %q", operationID))
+	}
+	if dbusErr := m.checkOwner(sender, operationID, m.operationOwner(operationID)); dbusErr != nil {
+		return nil, false, dbusErr
+	}
+	chunks, complete, ok := m.emitter.Replay(operationID, fromOffset)
+	if !ok {
+		// Known but silent so far
+		if op, known := streaming.DefaultRegistry.Lookup(operationID); known {
+			return []streaming.Chunk{}, op.State != streaming.StateRunning, nil
+		}
+		if m.predecessor == "" {
+			return nil, false, dbus.MakeFailedError(fmt.Errorf("unknown operation %q", operationID))
+		}
+		obj := m.conn.Object(m.predecessor, dbus.ObjectPath(dbusconsts.ObjectPath))
+		if err := obj.Call(dbusconsts.Interface+".ReplayOutput", 0, operationID, fromOffset).Store(&chunks, &complete); err != nil {
+			return nil, false, dbus.MakeFailedError(err)
+		}
+	}
+	if chunks == nil {
+		chunks = []streaming.Chunk{}
+	}
+	return chunks, complete, nil
+}
+
+// predecessorStatus asks the instance we took over from about an operation it
+// started. It fails once that instance has drained and exited.
+func (m *LinyapsManager) predecessorStatus(operationID string) (map[string]dbus.Variant, bool) {
+	if m.predecessor == "" {
+		return nil, false
+	}
+	var status map[string]dbus.Variant
+	obj := m.conn.Object(m.predecessor, dbus.ObjectPath(dbusconsts.ObjectPath))
+	if err := obj.Call(dbusconsts.Interface+".GetOperationStatus", 0, operationID).Store(&status); err != nil {
+		return nil, false
+	}
+	return status, true
+}
+
+// labelOwner adds to labels the uid of the caller named by their "caller"
+// label, making that user the owner of the operation started with them.
+// Operations without an owner are left to root and the daemon's user.
+func (m *LinyapsManager) labelOwner(labels map[string]string) map[string]string {
+	caller := labels["caller"]
+	if caller == "" {
+		return labels
+	}
+	uid, err := polkit.SenderUID(m.conn, dbus.Sender(caller))
+	if err != nil {
+		log.Printf("[WARN] cannot identify %s, its operation has no owner: %v", caller, err)
+		return labels
+	}
+	labels[ownerLabel] = strconv.FormatUint(uint64(uid), 10)
+	return labels
+}
+
+// checkOwner refuses sender access to an operation whose owner label is
+// owner, unless sender runs as that user, as root or as the daemon's user.
+func (m *LinyapsManager) checkOwner(sender dbus.Sender, operationID, owner string) *dbus.Error {
+	uid, err := polkit.SenderUID(m.conn, sender)
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	if !mayAccess(uid, owner) {
+		log.Printf("[WARN] uid %d refused access to operation %s of uid %q", uid, operationID, owner)
+		return dbus.MakeFailedError(fmt.Errorf("operation %s was started by another user", operationID))
+	}
+	return nil
+}
+
+// operationOwner returns the owner label of operationID, asking the
+// instance we took over from about operations it started, or "" if the
+// operation has no owner or is unknown.
+func (m *LinyapsManager) operationOwner(operationID string) string {
+	if op, ok := streaming.DefaultRegistry.Lookup(operationID); ok {
+		return op.Labels[ownerLabel]
+	}
+	if status, ok := m.predecessorStatus(operationID); ok {
+		return statusOwner(status)
+	}
+	return ""
+}
+
+// statusOwner returns the owner label of an operation status from the
+// instance we took over from, or "" if it has none.
+func statusOwner(status map[string]dbus.Variant) string {
+	owner, _ := status[ownerLabel].Value().(string)
+	return owner
+}
+
+// mayAccess reports whether uid may act on an operation owned by owner.
+func mayAccess(uid uint32, owner string) bool {
+	return uid == 0 || int(uid) == os.Getuid() || (owner != "" && strconv.FormatUint(uint64(uid), 10) == owner)
+}
+
+func operationStatus(op streaming.Operation) map[string]dbus.Variant {
+	status := make(map[string]dbus.Variant, len(op.Labels)+13)
+	for k, v := range op.Labels {
+		status[k] = dbus.MakeVariant(v)
+	}
+	var endTime int64
+	if !op.EndTime.IsZero() {
+		endTime = op.EndTime.Unix()
+	}
+	status["id"] = dbus.MakeVariant(op.ID)
+	status["program"] = dbus.MakeVariant(op.Program)
+	status["args"] = dbus.MakeVariant(op.Args)
+	status["state"] = dbus.MakeVariant(string(op.State))
+	status["exit_code"] = dbus.MakeVariant(int32(op.ExitCode))
+	status["error"] = dbus.MakeVariant(op.ErrorMsg)
+	status["start_time"] = dbus.MakeVariant(op.StartTime.Unix())
+	status["duration_ms"] = dbus.MakeVariant(op.Duration().Milliseconds())
+	status["wall_duration_ms"] = dbus.MakeVariant(op.WallDuration().Milliseconds())
+	status["end_time"] = dbus.MakeVariant(endTime)
+	status["timeout_sec"] = dbus.MakeVariant(int64(op.Timeout.Seconds()))
+	status["queued"] = dbus.MakeVariant(op.Queued)
+	status["queue_ms"] = dbus.MakeVariant(op.QueueWait.Milliseconds())
+	return status
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestMayAccess(t *testing.T) {
+	self := uint32(os.Getuid())
+	other := self + 1000
+	owner := strconv.FormatUint(uint64(other), 10)
+	if !mayAccess(other, owner) {
+		t.Error("owner refused")
+	}
+	if !mayAccess(0, owner) || !mayAccess(self, owner) {
+		t.Error("root or the daemon's user refused")
+	}
+	if mayAccess(other+1, owner) {
+		t.Error("another user allowed")
+	}
+	if mayAccess(other, "") {
+		t.Error("another user allowed on an operation without owner")
+	}
+}
+
+func TestStatusOwner(t *testing.T) {
+	if got := statusOwner(map[string]dbus.Variant{ownerLabel: dbus.MakeVariant("1000")}); got != "1000" {
+		t.Errorf("statusOwner = %q, want 1000", got)
+	}
+	if got := statusOwner(map[string]dbus.Variant{"caller": dbus.MakeVariant(":1.1")}); got != "" {
+		t.Errorf("statusOwner without owner = %q", got)
+	}
+}
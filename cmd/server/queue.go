@@ -0,0 +1,31 @@
+package main
+
+import (
+	"log"
+
+	"linyapsmanager/internal/jobqueue"
+	"linyapsmanager/internal/streaming"
+)
+
+// queuedOperations are the ll-cli operations that change installed state.
+// Concurrent runs race on the linglong backend, so they go through the job
+// queue one at a time; everything else (run, list, search, info, ...) starts
+// right away.
+var queuedOperations = map[string]bool{
+	"install":        true,
+	"uninstall":      true,
+	"upgrade":        true,
+	"prune":          true,
+	"downgrade":      true,
+	"switch-channel": true,
+}
+
+// newJobQueue creates the queue for mutating operations, announcing the
+// position of each waiting operation with a Queued signal.
+func newJobQueue(emitter *streaming.Emitter) *jobqueue.Queue {
+	return jobqueue.New(func(operationID string, position int) {
+		if err := emitter.EmitQueued(operationID, position); err != nil {
+			log.Printf("[WARN] failed to emit Queued for %s: %v", operationID, err)
+		}
+	})
+}
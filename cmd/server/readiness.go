@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusconsts"
+)
+
+const (
+	probeTimeout    = 15 * time.Second
+	probeMaxBackoff = 30 * time.Second
+	probeDeadline   = 3 * time.Minute
+)
+
+// readiness tracks whether the linglong backend answers. At boot the
+// package manager service and repository may not be up yet; ll-cli calls made
+// before then fail with a NotReady error instead of confusing ll-cli output.
+type readiness struct {
+	mu      sync.Mutex
+	ready   bool
+	probing bool
+	lastErr error
+	readyCh chan struct{} // closed once ready
+}
+
+func newReadiness() *readiness {
+	return &readiness{readyCh: make(chan struct{})}
+}
+
+// start probes in the background with bounded exponential backoff. If the
+// backend is still unavailable after probeDeadline, probing stops until
+// check is called again.
+func (r *readiness) start() {
+	r.mu.Lock()
+	if r.ready || r.probing {
+		r.mu.Unlock()
+		return
+	}
+	r.probing = true
+	r.mu.Unlock()
+
+	go func() {
+		deadline := time.Now().Add(probeDeadline)
+		backoff := time.Second
+		for {
+			err := probeBackend()
+			r.mu.Lock()
+			r.lastErr = err
+			if err == nil {
+				r.ready, r.probing = true, false
+				close(r.readyCh)
+				r.mu.Unlock()
+				log.Printf("[INFO] linglong backend ready")
+				return
+			}
+			if time.Now().After(deadline) {
+				r.probing = false
+				r.mu.Unlock()
+				log.Printf("[WARN] linglong backend not ready, giving up for now: %v", err)
+				return
+			}
+			r.mu.Unlock()
+			log.Printf("[INFO] linglong backend not ready yet, retrying in %s: %v", backoff, err)
+			time.Sleep(backoff)
+			backoff = min(backoff*2, probeMaxBackoff)
+		}
+	}()
+}
+
+// check returns nil when ready, otherwise a NotReady D-Bus error. A failed
+// backend is probed again so the service recovers once linglong comes up.
+func (r *readiness) check() *dbus.Error {
+	r.mu.Lock()
+	ready, lastErr := r.ready, r.lastErr
+	r.mu.Unlock()
+	if ready {
+		return nil
+	}
+	r.start()
+
+	msg := "linglong backend is not ready yet"
+	if lastErr != nil {
+		msg += ": " + lastErr.Error()
+	}
+	return dbus.NewError(dbusconsts.ErrorNotReady, []interface{}{msg})
+}
+
+// status reports whether the backend is ready and, if not, the last probe error.
+func (r *readiness) status() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.ready, r.lastErr
+}
+
+// wait blocks until ready or timeout and reports whether the backend is ready.
+func (r *readiness) wait(timeout time.Duration) bool {
+	r.start()
+	select {
+	case <-r.readyCh:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// probeBackend checks that ll-cli is installed and can reach the package
+// manager by reading the repository configuration.
+func probeBackend() error {
+	if _, err := exec.LookPath("ll-cli"); err != nil {
+		return errors.New("ll-cli not found in PATH")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "ll-cli", "repo", "show")
+	cmd.Env = buildCommandEnv("ll-cli")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("ll-cli repo show: %v: %s", err, lastLine(string(out)))
+	}
+	return nil
+}
+
+// WaitReady blocks until the linglong backend is ready or timeoutMs elapses,
+// and returns whether it is ready. Session startup scripts can call it before
+// issuing commands; keep timeoutMs below the caller's D-Bus reply timeout.
+func (m *LinyapsManager) WaitReady(timeoutMs uint32) (bool, *dbus.Error) {
+	return m.ready.wait(time.Duration(timeoutMs) * time.Millisecond), nil
+}
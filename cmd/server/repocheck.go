@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/streaming"
+)
+
+const (
+	repoCheckTimeout = time.Minute
+	repoProbeTimeout = 10 * time.Second
+)
+
+// repoChecker looks for misconfigured repositories once the backend is
+// ready and again after every ll-cli repo operation, emitting a
+// RepoConfigIssue signal for each problem not reported before. Many
+// install failures come down to a broken repository configuration whose
+// ll-cli errors users cannot make sense of.
+type repoChecker struct {
+	conn   *dbus.Conn
+	client *http.Client
+
+	checkMu sync.Mutex // serializes check
+
+	mu     sync.Mutex
+	issues []llcli.RepoIssue
+}
+
+func newRepoChecker(conn *dbus.Conn) *repoChecker {
+	return &repoChecker{conn: conn, client: &http.Client{Timeout: repoProbeTimeout}}
+}
+
+// update is a streaming.Registry watcher checking again after a repository
+// change.
+func (c *repoChecker) update(op streaming.Operation) {
+	if op.Labels["command"] == "ll-cli" && op.Labels["operation"] == "repo" && op.State != streaming.StateRunning {
+		go c.check()
+	}
+}
+
+// check reads the repository configuration, records its problems and
+// announces the new ones.
+func (c *repoChecker) check() {
+	c.checkMu.Lock()
+	defer c.checkMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), repoCheckTimeout)
+	defer cancel()
+	out, err := llcliOutput(ctx, "repo", "show")
+	if err != nil {
+		log.Printf("[WARN] repository check: %v", err)
+		return
+	}
+	cfg, err := llcli.ParseRepoShow(out)
+	if err != nil {
+		log.Printf("[WARN] repository check: %v", err)
+		return
+	}
+	issues := llcli.CheckRepoConfig(cfg)
+	issues = append(issues, c.probe(ctx, cfg, issues)...)
+
+	c.mu.Lock()
+	seen := make(map[llcli.RepoIssue]bool, len(c.issues))
+	for _, issue := range c.issues {
+		seen[issue] = true
+	}
+	c.issues = issues
+	c.mu.Unlock()
+
+	for _, issue := range issues {
+		if seen[issue] {
+			continue
+		}
+		log.Printf("[WARN] repository %q: %s: %s", issue.Repo, issue.Problem, issue.Hint)
+		if err := c.conn.Emit(dbusconsts.ObjectPath, dbusconsts.Interface+"."+dbusconsts.SignalRepoConfigIssue,
+			issue.Repo, issue.Problem, issue.Hint); err != nil {
+			log.Printf("[WARN] failed to emit %s: %v", dbusconsts.SignalRepoConfigIssue, err)
+		}
+	}
+}
+
+// probe reports the repositories whose server does not answer, skipping
+// those already found to have an invalid URL. Any HTTP response below 500
+// counts: the repository root need not be a page of its own.
+func (c *repoChecker) probe(ctx context.Context, cfg llcli.RepoConfig, known []llcli.RepoIssue) []llcli.RepoIssue {
+	invalid := make(map[string]bool)
+	for _, issue := range known {
+		if issue.Problem == llcli.RepoInvalidURL {
+			invalid[issue.Repo] = true
+		}
+	}
+	var issues []llcli.RepoIssue
+	for _, repo := range cfg.Repos {
+		if invalid[repo.Name] {
+			continue
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, repo.URL, nil)
+		if err != nil {
+			continue
+		}
+		resp, err := c.client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode < 500 {
+				continue
+			}
+		}
+		issues = append(issues, llcli.RepoIssue{Repo: repo.Name, Problem: llcli.RepoUnreachable,
+			Hint: fmt.Sprintf("check the network and the URL %s; correct it with ll-cli repo update %s <url>", repo.URL, repo.Name)})
+	}
+	return issues
+}
+
+// findings returns the problems found by the last check.
+func (c *repoChecker) findings() []llcli.RepoIssue {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]llcli.RepoIssue(nil), c.issues...)
+}
+
+// GetRepoIssues returns the repository problems found by the last check,
+// each with the keys "repo", "problem" and "hint" like the RepoConfigIssue
+// signal. The check runs once the backend is ready and after every
+// repository change.
+func (m *LinyapsManager) GetRepoIssues() ([]map[string]dbus.Variant, *dbus.Error) {
+	issues := m.repos.findings()
+	entries := make([]map[string]dbus.Variant, 0, len(issues))
+	for _, issue := range issues {
+		entries = append(entries, map[string]dbus.Variant{
+			"repo":    dbus.MakeVariant(issue.Repo),
+			"problem": dbus.MakeVariant(issue.Problem),
+			"hint":    dbus.MakeVariant(issue.Hint),
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/polkit"
+)
+
+// manageReposAction is the polkit action guarding changes to the
+// repository configuration.
+const manageReposAction = "org.linglong_store.LinyapsManager.manage-repos"
+
+var repoNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// RepoAdd adds the repository name at repoURL with ll-cli repo add and
+// returns the operation ID like ExecuteCommand. The caller needs the
+// manage-repos polkit authorization.
+func (m *LinyapsManager) RepoAdd(sender dbus.Sender, name, repoURL string) (string, *dbus.Error) {
+	if err := validRepoURL(repoURL); err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return m.changeRepos(sender, name, "add", name, repoURL)
+}
+
+// RepoRemove removes the repository name with ll-cli repo remove.
+func (m *LinyapsManager) RepoRemove(sender dbus.Sender, name string) (string, *dbus.Error) {
+	return m.changeRepos(sender, name, "remove", name)
+}
+
+// RepoSetDefault makes name the default repository with ll-cli repo
+// set-default.
+func (m *LinyapsManager) RepoSetDefault(sender dbus.Sender, name string) (string, *dbus.Error) {
+	return m.changeRepos(sender, name, "set-default", name)
+}
+
+// RepoUpdate points the repository name at repoURL with ll-cli repo update.
+func (m *LinyapsManager) RepoUpdate(sender dbus.Sender, name, repoURL string) (string, *dbus.Error) {
+	if err := validRepoURL(repoURL); err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return m.changeRepos(sender, name, "update", name, repoURL)
+}
+
+// changeRepos authorizes the caller for manage-repos, letting polkit ask
+// for authentication, and runs ll-cli repo with args. A repository decides
+// what code gets installed, so the call is refused when polkit cannot be
+// reached.
+func (m *LinyapsManager) changeRepos(sender dbus.Sender, name string, args ...string) (string, *dbus.Error) {
+	if m.draining.Load() {
+		return "", dbus.MakeFailedError(errors.New("service is being replaced by a new instance, retry"))
+	}
+	if !repoNamePattern.MatchString(name) {
+		return "", dbus.MakeFailedError(fmt.Errorf("invalid repository name %q", name))
+	}
+	ok, err := polkit.CheckSenderInteractive(m.conn, sender, manageReposAction)
+	if err != nil {
+		return "", dbus.MakeFailedError(fmt.Errorf("cannot authorize %s: %w", manageReposAction, err))
+	}
+	if !ok {
+		return "", dbus.MakeFailedError(fmt.Errorf("not authorized for %s", manageReposAction))
+	}
+	log.Printf("[INFO] ll-cli repo %v requested by %s", args, sender)
+	return m.execute(sender, "ll-cli", append([]string{"repo"}, args...), execOptions{})
+}
+
+// validRepoURL accepts absolute http and https URLs.
+func validRepoURL(s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("invalid repository URL: %w", err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return fmt.Errorf("invalid repository URL %q: want http(s)://host/...", s)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestValidRepoURL(t *testing.T) {
+	for _, ok := range []string{"https://mirror-repo-linglong.deepin.com", "http://10.0.0.1:8080/repos/stable"} {
+		if err := validRepoURL(ok); err != nil {
+			t.Errorf("validRepoURL(%q): %v", ok, err)
+		}
+	}
+	for _, bad := range []string{"", "mirror.example.com", "file:///srv/repo", "https://", "https://user:pw@example.com", "--help"} {
+		if err := validRepoURL(bad); err == nil {
+			t.Errorf("validRepoURL(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestRepoNamePattern(t *testing.T) {
+	for _, ok := range []string{"stable", "my-repo", "repo_2.mirror"} {
+		if !repoNamePattern.MatchString(ok) {
+			t.Errorf("%q rejected", ok)
+		}
+	}
+	for _, bad := range []string{"", "-stable", "a b", "a/b", "stable\n"} {
+		if repoNamePattern.MatchString(bad) {
+			t.Errorf("%q accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"strings"
+	"sync"
+
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/streaming"
+)
+
+// Keys added to the Complete details of ll-cli install operations.
+const (
+	detailInstalled = "installed" // as: refs installed
+	detailSkipped   = "skipped"   // as: refs that were already installed
+	detailRuntimes  = "runtimes"  // as: runtimes and bases pulled in
+)
+
+// resultSink parses the output of ll-cli install operations and reports what
+// was actually installed in the Complete details, so frontends need not
+// interpret the text themselves.
+type resultSink struct {
+	streaming.OutputSink
+
+	mu      sync.Mutex
+	parsers map[string]*llcli.InstallParser // nil entry: not an install
+}
+
+func newResultSink(next streaming.OutputSink) *resultSink {
+	return &resultSink{OutputSink: next, parsers: make(map[string]*llcli.InstallParser)}
+}
+
+func (s *resultSink) EmitOutput(operationID, data string, isStderr bool) error {
+	if p := s.parser(operationID); p != nil {
+		p.Line(strings.TrimSuffix(data, "\n"))
+	}
+	return s.OutputSink.EmitOutput(operationID, data, isStderr)
+}
+
+func (s *resultSink) EmitComplete(operationID string, exitCode int, errorMsg string, details map[string]interface{}) error {
+	p := s.parser(operationID)
+	s.mu.Lock()
+	delete(s.parsers, operationID)
+	s.mu.Unlock()
+
+	if p != nil {
+		if details == nil {
+			details = map[string]interface{}{}
+		}
+		r := p.Result()
+		details[detailInstalled] = nonNil(r.Installed)
+		details[detailSkipped] = nonNil(r.Skipped)
+		details[detailRuntimes] = nonNil(r.Runtimes)
+	}
+	return s.OutputSink.EmitComplete(operationID, exitCode, errorMsg, details)
+}
+
+// parser returns the install parser of an operation, creating it on first
+// use, or nil if the operation is not an ll-cli install. Output and Complete
+// of one operation are never concurrent with its removal, so the cached
+// entry is safe to reuse.
+func (s *resultSink) parser(operationID string) *llcli.InstallParser {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if p, ok := s.parsers[operationID]; ok {
+		return p
+	}
+	var p *llcli.InstallParser
+	if op, ok := streaming.DefaultRegistry.Lookup(operationID); ok &&
+		op.Labels["command"] == "ll-cli" && op.Labels["operation"] == "install" {
+		p = llcli.NewInstallParser(op.Labels["ref"])
+	}
+	s.parsers[operationID] = p
+	return p
+}
+
+// nonNil keeps empty lists encodable as D-Bus arrays.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"linyapsmanager/internal/streaming"
+)
+
+// completeRecorder captures the Complete details of operations.
+type completeRecorder struct {
+	done chan map[string]interface{}
+}
+
+func (r *completeRecorder) EmitOutput(string, string, bool) error { return nil }
+
+func (r *completeRecorder) EmitComplete(_ string, _ int, _ string, details map[string]interface{}) error {
+	r.done <- details
+	return nil
+}
+
+func TestResultSinkInstall(t *testing.T) {
+	rec := &completeRecorder{done: make(chan map[string]interface{}, 1)}
+	sink := newResultSink(rec)
+
+	labels := map[string]string{"command": "ll-cli", "operation": "install", "ref": "org.example.app"}
+	ctx := streaming.WithLabels(context.Background(), labels)
+	script := `echo "Installing runtime main:org.deepin.runtime.dtk/23.1.0/x86_64"
+echo "Install main:org.example.app/1.0.0/x86_64 success"`
+	if _, err := streaming.RunCommandStreaming(ctx, sink, nil, "/bin/sh", "-c", script); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case details := <-rec.done:
+		if got := details[detailInstalled]; !reflect.DeepEqual(got, []string{"main:org.example.app/1.0.0/x86_64"}) {
+			t.Errorf("installed = %v", got)
+		}
+		if got := details[detailRuntimes]; !reflect.DeepEqual(got, []string{"main:org.deepin.runtime.dtk/23.1.0/x86_64"}) {
+			t.Errorf("runtimes = %v", got)
+		}
+		if got := details[detailSkipped]; !reflect.DeepEqual(got, []string{}) {
+			t.Errorf("skipped = %v", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("operation did not complete")
+	}
+	if len(sink.parsers) != 0 {
+		t.Errorf("parsers leaked: %v", sink.parsers)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/execpolicy"
+	"linyapsmanager/internal/llcli"
+)
+
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// RunWithArgs starts appID, or the given version of it when version is not
+// empty, with ll-cli run and returns the operation ID like ExecuteCommand.
+// args follow "--" and, as with ll-cli, replace the command line the app
+// starts with, so they are subject to the exec policy. env sets variables
+// in the app's container for this launch only; only those the exec
+// policy's env list allows, by default locale, time zone and scaling
+// settings, are accepted.
+func (m *LinyapsManager) RunWithArgs(sender dbus.Sender, appID, version string, args []string, env map[string]string) (string, *dbus.Error) {
+	ref, err := packageRef(appID, version)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	var policy *execpolicy.Policy
+	if len(env) > 0 {
+		if policy, err = loadExecPolicy(); err != nil {
+			log.Printf("[ERROR] exec policy unavailable, refusing environment for %s: %v", appID, err)
+			return "", dbus.MakeFailedError(fmt.Errorf("exec policy unavailable: %v", err))
+		}
+	}
+	cmdArgs, err := runArgs(ref, args, env, policy)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return m.execute(sender, "ll-cli", cmdArgs, execOptions{})
+}
+
+// RunStream starts appID, or the given version of it when version is not
+// empty, on a pseudo-terminal and returns the operation ID like
+// ExecuteCommand. The app's console output, which many apps only write
+// when on a terminal, arrives as Output signals and its exit code with
+// Complete. It has no timeout.
+func (m *LinyapsManager) RunStream(sender dbus.Sender, appID, version string) (string, *dbus.Error) {
+	ref, err := packageRef(appID, version)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return m.execute(sender, "ll-cli", []string{"run", ref}, execOptions{hasTimeout: true, pty: true})
+}
+
+// runArgs builds the ll-cli run command line, passing env as --env
+// options sorted by name. Only variables in the env list of policy are
+// accepted: others, such as LD_PRELOAD or plugin paths, could make the app
+// load any code in the container while bypassing the exec policy.
+func runArgs(ref string, args []string, env map[string]string, policy *execpolicy.Policy) ([]string, error) {
+	appID := llcli.AppIDFromRef(ref)
+	keys := make([]string, 0, len(env))
+	for k, v := range env {
+		if !envKeyPattern.MatchString(k) {
+			return nil, fmt.Errorf("invalid environment variable name %q", k)
+		}
+		if err := policy.CheckEnv(appID, k); err != nil {
+			return nil, fmt.Errorf("environment variable %s is not allowed for %s", k, appID)
+		}
+		if strings.ContainsAny(v, "\x00\n") {
+			return nil, fmt.Errorf("invalid value for environment variable %s", k)
+		}
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	cmdArgs := []string{"run", ref}
+	for _, k := range keys {
+		cmdArgs = append(cmdArgs, "--env", k+"="+env[k])
+	}
+	if len(args) > 0 {
+		cmdArgs = append(append(cmdArgs, "--"), args...)
+	}
+	return cmdArgs, nil
+}
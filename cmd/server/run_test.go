@@ -0,0 +1,34 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"linyapsmanager/internal/execpolicy"
+)
+
+func TestRunArgs(t *testing.T) {
+	policy := &execpolicy.Policy{Default: execpolicy.Rule{Env: []string{"A", "B"}}}
+	got, err := runArgs("org.example.app/1.0", []string{"app", "--verbose"}, map[string]string{"B": "2", "A": "x y"}, policy)
+	want := []string{"run", "org.example.app/1.0", "--env", "A=x y", "--env", "B=2", "--", "app", "--verbose"}
+	if err != nil || !reflect.DeepEqual(got, want) {
+		t.Errorf("runArgs = %q, %v, want %q", got, err, want)
+	}
+	if got, _ := runArgs("org.example.app", nil, nil, nil); !reflect.DeepEqual(got, []string{"run", "org.example.app"}) {
+		t.Errorf("runArgs without args = %q", got)
+	}
+	for _, env := range []map[string]string{
+		{"1A": "x"},
+		{"A=B": "x"},
+		{"LD_PRELOAD": "/tmp/x.so"},
+		{"A": "x\ny"},
+		{"PATH": "/tmp"},
+	} {
+		if _, err := runArgs("org.example.app", nil, env, nil); err == nil {
+			t.Errorf("runArgs with env %q succeeded, want error", env)
+		}
+	}
+	if _, err := runArgs("org.example.app", nil, map[string]string{"LC_ALL": "C"}, nil); err != nil {
+		t.Errorf("runArgs with a default allowed variable: %v", err)
+	}
+}
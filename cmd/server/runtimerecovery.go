@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/streaming"
+)
+
+// envAutoInstallRuntime turns off installing the missing runtime or base of
+// an app that failed to start for want of it when set to 0 or false.
+const envAutoInstallRuntime = "LINYAPS_AUTO_INSTALL_RUNTIME"
+
+// Keys added to the Complete details of ll-cli run operations that failed
+// because the app's runtime or base was not installed.
+const (
+	detailMissingRuntime   = "missing_runtime"   // s: the runtime or base ll-cli reported missing
+	detailRecoveredRuntime = "recovered_runtime" // s: the ref installed before the launch was retried
+)
+
+func autoInstallRuntimeFromEnv() bool {
+	v := os.Getenv(envAutoInstallRuntime)
+	return v != "0" && !strings.EqualFold(v, "false")
+}
+
+// startLaunch runs an ll-cli run command line for ref. If it fails because
+// the app's runtime or base is missing, as after a prune, the declared
+// dependency is installed and the launch retried once within the same
+// operation; Complete then reports it as recovered_runtime. Without
+// m.autoInstallRuntime only missing_runtime is reported. With usePTY the
+// launches run on a pseudo-terminal.
+func (m *LinyapsManager) startLaunch(ctx context.Context, env []string, program string, args []string, ref string, usePTY bool) string {
+	appID := llcli.AppIDFromRef(ref)
+	launch := streaming.RunChild
+	if usePTY {
+		launch = streaming.RunChildPTY
+	}
+	return streaming.RunCommandTask(ctx, m.sink, program, args, func(ctx context.Context, out func(string, bool)) (map[string]interface{}, error) {
+		var mu sync.Mutex
+		missing, found := "", false
+		watch := func(data string, isStderr bool) {
+			mu.Lock()
+			if !found {
+				missing, found = llcli.MissingDependency(data, appID)
+			}
+			mu.Unlock()
+			out(data, isStderr)
+		}
+		err := launch(ctx, watch, env, program, args...)
+		if err == nil || !found || ctx.Err() != nil {
+			return nil, childExit(err)
+		}
+
+		target, ok := m.missingDependency(ref, missing)
+		if !ok {
+			return nil, childExit(err)
+		}
+		details := map[string]interface{}{detailMissingRuntime: target.String()}
+		if !m.autoInstallRuntime {
+			log.Printf("[INFO] %s failed to start without %s, not installing it (%s=0)", appID, target, envAutoInstallRuntime)
+			return details, childExit(err)
+		}
+
+		log.Printf("[INFO] %s failed to start without %s, installing it", appID, target)
+		out(fmt.Sprintf("==> %s is not installed, ll-cli install %s\n", target, target), false)
+		if err := streaming.RunChild(ctx, out, env, "ll-cli", "install", target.String()); err != nil {
+			return details, fmt.Errorf("install %s: %w", target, err)
+		}
+		m.installed.drop()
+		details[detailRecoveredRuntime] = target.String()
+		out("==> retrying launch\n", false)
+		return details, childExit(launch(ctx, out, env, program, args...))
+	})
+}
+
+// missingDependency returns the runtime or base of the app launched as ref
+// to install, given what ll-cli reported missing. A missing ref that the app
+// does not declare is never installed; if ll-cli named none, the first
+// declared dependency that is not installed is returned.
+func (m *LinyapsManager) missingDependency(ref, missing string) (llcli.Ref, bool) {
+	app, err := llcli.ParseRef(ref)
+	if err != nil {
+		return llcli.Ref{}, false
+	}
+	ctx, cancel := replyContext()
+	defer cancel()
+	pkgs, err := m.installed.binaries(ctx, app.ID)
+	if err != nil {
+		log.Printf("[WARN] cannot look up the dependencies of %s: %v", app.ID, err)
+		return llcli.Ref{}, false
+	}
+	for _, pkg := range pkgs {
+		if app.Version != "" && pkg.Version != app.Version {
+			continue
+		}
+		if missing != "" {
+			want, err := llcli.ParseRef(missing)
+			if err != nil {
+				return llcli.Ref{}, false
+			}
+			return declaredDependency(pkg, want)
+		}
+		for _, dep := range []string{pkg.Runtime, pkg.Base} {
+			declared, err := llcli.ParseRef(dep)
+			if err != nil {
+				continue
+			}
+			if _, ok, err := m.installed.installed(ctx, declared); err == nil && !ok {
+				return declared, true
+			}
+		}
+		return llcli.Ref{}, false
+	}
+	return llcli.Ref{}, false
+}
+
+// childExit returns the *streaming.ExitError within err, if any, so a task
+// reports the child's exit like RunCommandStreaming would.
+func childExit(err error) error {
+	var exitErr *streaming.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr
+	}
+	return err
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/llcli"
+)
+
+// maxKeywordLen bounds the keyword of SearchFiltered.
+const maxKeywordLen = 256
+
+var channelPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// SearchFiltered searches the configured repositories for keyword with
+// ll-cli search. opts takes, each unset when empty:
+//   - type: app, runtime, base or all, passed as --type (ll-cli searches
+//     apps by default)
+//   - repo: search only this repository, passed as --repo
+//   - channel: keep the results from this channel, such as main; ll-cli
+//     search has no such option, so the service filters them
+//
+// Each result holds id, name, version, channel, arch, module, kind, repo,
+// description (s) and size (t: download size, 0 if the repository does not
+// report it). Apps hidden from the caller by the visibility policy are left
+// out.
+func (m *LinyapsManager) SearchFiltered(sender dbus.Sender, keyword string, opts map[string]string) ([]map[string]dbus.Variant, *dbus.Error) {
+	args, channel, err := searchArgs(keyword, opts)
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	if dbusErr := m.ready.check(); dbusErr != nil {
+		return nil, dbusErr
+	}
+	ctx, cancel := replyContext()
+	defer cancel()
+	out, err := llcliOutput(ctx, args...)
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	pkgs, err := llcli.ParseSearch(out)
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+
+	results := []map[string]dbus.Variant{}
+	for _, p := range pkgs {
+		if channel != "" && p.Channel != channel {
+			continue
+		}
+		if opts["repo"] != "" && p.Repo != "" && p.Repo != opts["repo"] {
+			continue
+		}
+		if m.appHidden(sender, p.ID) {
+			continue
+		}
+		results = append(results, map[string]dbus.Variant{
+			"id":          dbus.MakeVariant(p.ID),
+			"name":        dbus.MakeVariant(p.Name),
+			"version":     dbus.MakeVariant(p.Version),
+			"channel":     dbus.MakeVariant(p.Channel),
+			"arch":        dbus.MakeVariant(p.Arch),
+			"module":      dbus.MakeVariant(p.Module),
+			"kind":        dbus.MakeVariant(p.Kind),
+			"repo":        dbus.MakeVariant(p.Repo),
+			"description": dbus.MakeVariant(p.Description),
+			"size":        dbus.MakeVariant(uint64(max(p.Size, 0))),
+		})
+	}
+	return results, nil
+}
+
+// searchArgs builds the ll-cli search command line for keyword and opts
+// and returns the channel to filter the results by.
+func searchArgs(keyword string, opts map[string]string) ([]string, string, error) {
+	if keyword == "" || len(keyword) > maxKeywordLen || strings.HasPrefix(keyword, "-") ||
+		strings.ContainsAny(keyword, "\x00\n") {
+		return nil, "", fmt.Errorf("invalid keyword %q", keyword)
+	}
+	args := []string{"search", keyword, "--json"}
+	var channel string
+	keys := make([]string, 0, len(opts))
+	for key := range opts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := opts[key]
+		if value == "" && (key == "type" || key == "repo" || key == "channel") {
+			continue // unset
+		}
+		switch key {
+		case "type":
+			switch value {
+			case "app", "runtime", "base", "all":
+			default:
+				return nil, "", fmt.Errorf("invalid type %q", value)
+			}
+			args = append(args, "--type="+value)
+		case "repo":
+			if !repoNamePattern.MatchString(value) {
+				return nil, "", fmt.Errorf("invalid repository name %q", value)
+			}
+			args = append(args, "--repo="+value)
+		case "channel":
+			if !channelPattern.MatchString(value) {
+				return nil, "", fmt.Errorf("invalid channel %q", value)
+			}
+			channel = value
+		default:
+			return nil, "", fmt.Errorf("unknown option %q", key)
+		}
+	}
+	return args, channel, nil
+}
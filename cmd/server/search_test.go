@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSearchArgs(t *testing.T) {
+	args, channel, err := searchArgs("calculator", map[string]string{"type": "app", "repo": "stable", "channel": "main"})
+	if err != nil {
+		t.Fatalf("searchArgs: %v", err)
+	}
+	want := []string{"search", "calculator", "--json", "--repo=stable", "--type=app"}
+	if !reflect.DeepEqual(args, want) || channel != "main" {
+		t.Errorf("searchArgs = %q, %q, want %q, main", args, channel, want)
+	}
+
+	bad := []struct {
+		keyword string
+		opts    map[string]string
+	}{
+		{"", nil},
+		{"--repo=evil", nil},
+		{"a\nb", nil},
+		{"calculator", map[string]string{"type": "plugin"}},
+		{"calculator", map[string]string{"repo": "-x"}},
+		{"calculator", map[string]string{"channel": "main:x"}},
+		{"calculator", map[string]string{"arch": "x86_64"}},
+	}
+	for _, tt := range bad {
+		if _, _, err := searchArgs(tt.keyword, tt.opts); err == nil {
+			t.Errorf("searchArgs(%q, %v) succeeded", tt.keyword, tt.opts)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"linyapsmanager/internal/metrics"
+	"linyapsmanager/internal/streaming"
+)
+
+// Snapshot files for deployments that cannot expose a metrics port: a
+// Prometheus textfile for node_exporter's textfile collector and a health
+// JSON document, rewritten every LINYAPS_SNAPSHOT_INTERVAL (default 15s).
+const (
+	envMetricsFile      = "LINYAPS_METRICS_FILE" // e.g. /var/lib/node_exporter/textfile_collector/linyaps.prom
+	envHealthFile       = "LINYAPS_HEALTH_FILE"
+	envSnapshotInterval = "LINYAPS_SNAPSHOT_INTERVAL"
+
+	defaultSnapshotInterval = 15 * time.Second
+)
+
+// Health states in the health snapshot.
+const (
+	healthOK       = "ok"       // backend ready, accepting calls
+	healthDegraded = "degraded" // backend not ready
+	healthDraining = "draining" // replaced by a new instance, finishing operations
+	healthStopped  = "stopped"  // written on shutdown
+)
+
+// healthSnapshot is the document written to LINYAPS_HEALTH_FILE.
+type healthSnapshot struct {
+	Status       string    `json:"status"`
+	Version      string    `json:"version"`
+	PID          int       `json:"pid"`
+	Started      time.Time `json:"started"`
+	Updated      time.Time `json:"updated"`
+	BackendReady bool      `json:"backend_ready"`
+	BackendError string    `json:"backend_error,omitempty"`
+	Running      int       `json:"running_operations"`
+	Queued       int       `json:"queued_operations"`
+	Signals      struct {
+		Emitted uint64 `json:"emitted"`
+		Failed  uint64 `json:"failed"`
+		Dropped uint64 `json:"dropped"`
+		Queued  int    `json:"queued"`
+	} `json:"signals"`
+}
+
+// opKey identifies a counter of finished operations.
+type opKey struct{ operation, state string }
+
+// snapshotWriter periodically writes the snapshot files.
+type snapshotWriter struct {
+	m           *LinyapsManager
+	metricsPath string
+	healthPath  string
+	interval    time.Duration
+	started     time.Time
+	stopCh      chan struct{}
+	wg          sync.WaitGroup
+
+	mu        sync.Mutex
+	finished  map[opKey]uint64
+	durations map[string]time.Duration // total run time per operation
+}
+
+// startSnapshots starts writing the snapshot files configured in the
+// environment, or returns nil if none is.
+func startSnapshots(m *LinyapsManager) *snapshotWriter {
+	w := &snapshotWriter{
+		m:           m,
+		metricsPath: os.Getenv(envMetricsFile),
+		healthPath:  os.Getenv(envHealthFile),
+		interval:    defaultSnapshotInterval,
+		started:     time.Now(),
+		stopCh:      make(chan struct{}),
+		finished:    make(map[opKey]uint64),
+		durations:   make(map[string]time.Duration),
+	}
+	if w.metricsPath == "" && w.healthPath == "" {
+		return nil
+	}
+	if v := os.Getenv(envSnapshotInterval); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Second {
+			log.Printf("[WARN] ignoring %s=%q: want a duration of at least 1s", envSnapshotInterval, v)
+		} else {
+			w.interval = d
+		}
+	}
+	streaming.DefaultRegistry.Watch(w.record)
+
+	w.write(false)
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				w.write(false)
+			case <-w.stopCh:
+				return
+			}
+		}
+	}()
+	log.Printf("[INFO] writing snapshots every %s (metrics=%q health=%q)", w.interval, w.metricsPath, w.healthPath)
+	return w
+}
+
+// stop writes the final snapshot, marking the service stopped.
+func (w *snapshotWriter) stop() {
+	if w == nil {
+		return
+	}
+	close(w.stopCh)
+	w.wg.Wait()
+	w.write(true)
+}
+
+func (w *snapshotWriter) record(op streaming.Operation) {
+	if op.State == streaming.StateRunning {
+		return
+	}
+	name := op.Labels["operation"]
+	if name == "" {
+		name = op.Labels["command"]
+	}
+	if name == "" {
+		name = op.Program
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.finished[opKey{name, string(op.State)}]++
+	w.durations[name] += op.Duration()
+}
+
+func (w *snapshotWriter) write(stopped bool) {
+	h := w.health(stopped)
+	if w.metricsPath != "" {
+		var buf bytes.Buffer
+		err := metrics.WriteText(&buf, w.families(h))
+		if err == nil {
+			err = metrics.WriteFile(w.metricsPath, buf.Bytes())
+		}
+		if err != nil {
+			log.Printf("[WARN] failed to write metrics snapshot: %v", err)
+		}
+	}
+	if w.healthPath != "" {
+		data, err := json.MarshalIndent(h, "", "  ")
+		if err == nil {
+			err = metrics.WriteFile(w.healthPath, append(data, '\n'))
+		}
+		if err != nil {
+			log.Printf("[WARN] failed to write health snapshot: %v", err)
+		}
+	}
+}
+
+func (w *snapshotWriter) health(stopped bool) healthSnapshot {
+	h := healthSnapshot{
+		Version: version,
+		PID:     os.Getpid(),
+		Started: w.started.UTC(),
+		Updated: time.Now().UTC(),
+		Running: streaming.DefaultRegistry.RunningCount(),
+	}
+	var backendErr error
+	h.BackendReady, backendErr = w.m.ready.status()
+	if backendErr != nil && !h.BackendReady {
+		h.BackendError = backendErr.Error()
+	}
+	if w.m.queue != nil {
+		h.Queued = len(w.m.queue.Waiting())
+	}
+	if w.m.emitter != nil {
+		st := w.m.emitter.Stats()
+		h.Signals.Emitted, h.Signals.Failed, h.Signals.Dropped, h.Signals.Queued = st.Emitted, st.Failed, st.Dropped, st.Queued
+	}
+	switch {
+	case stopped:
+		h.Status = healthStopped
+	case w.m.draining.Load():
+		h.Status = healthDraining
+	case !h.BackendReady:
+		h.Status = healthDegraded
+	default:
+		h.Status = healthOK
+	}
+	return h
+}
+
+func (w *snapshotWriter) families(h healthSnapshot) []metrics.Family {
+	bool01 := func(b bool) float64 {
+		if b {
+			return 1
+		}
+		return 0
+	}
+	fs := []metrics.Family{
+		{
+			Name: "linyaps_manager_info", Help: "Version of the running manager.", Type: metrics.Gauge,
+			Samples: []metrics.Sample{{Labels: map[string]string{"version": version}, Value: 1}},
+		},
+		metrics.Single("linyaps_manager_up", "Whether the manager is running; 0 in the snapshot written on shutdown.", metrics.Gauge, bool01(h.Status != healthStopped)),
+		metrics.Single("linyaps_manager_start_time_seconds", "Start time of the manager, unix seconds.", metrics.Gauge, float64(w.started.Unix())),
+		metrics.Single("linyaps_manager_backend_ready", "Whether the linglong backend answers.", metrics.Gauge, bool01(h.BackendReady)),
+		metrics.Single("linyaps_manager_draining", "Whether the manager was replaced and is finishing its operations.", metrics.Gauge, bool01(h.Status == healthDraining)),
+		metrics.Single("linyaps_manager_operations_running", "Operations that have not finished, including queued ones.", metrics.Gauge, float64(h.Running)),
+		metrics.Single("linyaps_manager_operations_queued", "Operations waiting in the job queue.", metrics.Gauge, float64(h.Queued)),
+		metrics.Single("linyaps_manager_signals_emitted_total", "D-Bus signals written to the bus.", metrics.Counter, float64(h.Signals.Emitted)),
+		metrics.Single("linyaps_manager_signals_failed_total", "D-Bus signals the bus refused.", metrics.Counter, float64(h.Signals.Failed)),
+		metrics.Single("linyaps_manager_signals_dropped_total", "Output signals dropped because the signal queue was full.", metrics.Counter, float64(h.Signals.Dropped)),
+		metrics.Single("linyaps_manager_signal_queue_length", "D-Bus signals waiting to be written.", metrics.Gauge, float64(h.Signals.Queued)),
+	}
+
+	w.mu.Lock()
+	finished := metrics.Family{Name: "linyaps_manager_operations_total", Help: "Finished operations by operation and final state.", Type: metrics.Counter}
+	for k, n := range w.finished {
+		finished.Samples = append(finished.Samples, metrics.Sample{
+			Labels: map[string]string{"operation": k.operation, "state": k.state},
+			Value:  float64(n),
+		})
+	}
+	durations := metrics.Family{Name: "linyaps_manager_operation_seconds_total", Help: "Total run time of finished operations, excluding time in the job queue.", Type: metrics.Counter}
+	for name, d := range w.durations {
+		durations.Samples = append(durations.Samples, metrics.Sample{
+			Labels: map[string]string{"operation": name},
+			Value:  d.Seconds(),
+		})
+	}
+	w.mu.Unlock()
+	sortSamples(finished.Samples)
+	sortSamples(durations.Samples)
+	return append(fs, finished, durations)
+}
+
+// sortSamples orders samples by their labels so snapshots are stable.
+func sortSamples(samples []metrics.Sample) {
+	key := func(s metrics.Sample) string { return s.Labels["operation"] + "\x00" + s.Labels["state"] }
+	sort.Slice(samples, func(i, j int) bool { return key(samples[i]) < key(samples[j]) })
+}
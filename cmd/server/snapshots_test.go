@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"linyapsmanager/internal/streaming"
+)
+
+func TestSnapshotFiles(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(envMetricsFile, filepath.Join(dir, "linyaps.prom"))
+	t.Setenv(envHealthFile, filepath.Join(dir, "health.json"))
+	t.Setenv(envSnapshotInterval, "1h")
+
+	m := &LinyapsManager{ready: newReadiness()}
+	w := startSnapshots(m)
+	if w == nil {
+		t.Fatal("snapshots not started")
+	}
+	start := time.Now()
+	w.record(streaming.Operation{
+		State:     streaming.StateFailed,
+		StartTime: start,
+		EndTime:   start.Add(1500 * time.Millisecond),
+		Labels:    map[string]string{"command": "ll-cli", "operation": "install"},
+	})
+	w.stop()
+
+	prom, err := os.ReadFile(filepath.Join(dir, "linyaps.prom"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"linyaps_manager_up 0",
+		"linyaps_manager_backend_ready 0",
+		`linyaps_manager_operations_total{operation="install",state="failed"} 1`,
+		`linyaps_manager_operation_seconds_total{operation="install"} 1.5`,
+	} {
+		if !strings.Contains(string(prom), line+"\n") {
+			t.Errorf("metrics lack %q:\n%s", line, prom)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "health.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h healthSnapshot
+	if err := json.Unmarshal(data, &h); err != nil {
+		t.Fatal(err)
+	}
+	if h.Status != healthStopped || h.PID != os.Getpid() || h.BackendReady {
+		t.Errorf("health = %+v", h)
+	}
+}
+
+func TestSnapshotsDisabled(t *testing.T) {
+	t.Setenv(envMetricsFile, "")
+	t.Setenv(envHealthFile, "")
+	if w := startSnapshots(&LinyapsManager{}); w != nil {
+		t.Error("snapshots started without paths")
+	}
+	var w *snapshotWriter
+	w.stop()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/proxy"
+)
+
+// stateFileName is the file in the runtime base that announces the running
+// instance, so session helpers and container launch wrappers can find the
+// service and its sockets without parsing the log.
+const stateFileName = "linyaps-manager.json"
+
+// serviceState is the content of the state file.
+type serviceState struct {
+	BusName            string    `json:"bus_name"`
+	Aliases            []string  `json:"aliases,omitempty"` // additional well-known names
+	UniqueName         string    `json:"unique_name"`
+	ObjectPath         string    `json:"object_path"`
+	Interface          string    `json:"interface"`
+	Version            string    `json:"version"`
+	PID                int       `json:"pid"`
+	StartTime          time.Time `json:"start_time"`
+	ProxySocket        string    `json:"proxy_socket,omitempty"`         // system bus proxy for containers
+	SessionProxySocket string    `json:"session_proxy_socket,omitempty"` // session bus proxy for apps
+	DconfDir           string    `json:"dconf_dir,omitempty"`
+}
+
+func newServiceState(uniqueName string, started time.Time) serviceState {
+	return serviceState{
+		BusName:    dbusconsts.BusName,
+		UniqueName: uniqueName,
+		ObjectPath: dbusconsts.ObjectPath,
+		Interface:  dbusconsts.Interface,
+		Version:    version,
+		PID:        os.Getpid(),
+		StartTime:  started.UTC(),
+	}
+}
+
+func stateFilePath() string {
+	return filepath.Join(proxy.RuntimeBase(), stateFileName)
+}
+
+// writeStateFile replaces the state file with st.
+func writeStateFile(path string, st serviceState) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// removeStateFile removes the state file if it still describes this
+// process; after a takeover it describes the new instance and is kept.
+func removeStateFile(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return
+	}
+	var st serviceState
+	if json.Unmarshal(data, &st) == nil && st.PID == os.Getpid() {
+		_ = os.Remove(path)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), stateFileName)
+	st := newServiceState(":1.42", time.Now())
+	st.ProxySocket = "/run/user/1000/linglong/linyaps-proxy.sock"
+	if err := writeStateFile(path, st); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["unique_name"] != ":1.42" || got["pid"] != float64(os.Getpid()) || got["proxy_socket"] != st.ProxySocket {
+		t.Errorf("state file = %s", data)
+	}
+	if _, ok := got["session_proxy_socket"]; ok {
+		t.Errorf("unset socket written: %s", data)
+	}
+
+	// A successor's state file survives our exit
+	st.PID = os.Getpid() + 1
+	writeStateFile(path, st)
+	removeStateFile(path)
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("successor's state file removed: %v", err)
+	}
+	st.PID = os.Getpid()
+	writeStateFile(path, st)
+	removeStateFile(path)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("state file left behind: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/llcli"
+)
+
+// packageRef validates an app ID and optional version and returns the ll-cli
+// ref for them.
+func packageRef(appID, version string) (string, error) {
+	s := appID
+	if version != "" {
+		s += "/" + version
+	}
+	r, err := llcli.ParseRef(s)
+	if err != nil || r.Channel != "" || r.Arch != "" {
+		return "", fmt.Errorf("invalid app id %q or version %q", appID, version)
+	}
+	return r.String(), nil
+}
+
+// InstallStream installs appID, or the given version of it when version is
+// not empty. It returns an operation ID like ExecuteCommand; install
+// progress arrives as Output signals and the result as Complete. options
+// takes the keys of ExecuteCommandWithOptions and:
+//   - autoLaunch (b): start the app once it is installed, or right away if
+//     it already was; see installAndLaunch
+//   - module (s): install this module, such as develop, instead of binary
+//   - channel (s): install from this channel, such as stable or testing
+//   - repo (s): install from this configured repository instead of the
+//     default one
+func (m *LinyapsManager) InstallStream(sender dbus.Sender, appID, version string, options map[string]dbus.Variant) (string, *dbus.Error) {
+	install, rest, err := parseInstallOptions(options)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	opts, err := parseExecOptions(rest)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	ref, err := packageRef(appID, version)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	if install.channel != "" {
+		r, err := llcli.ParseRef(install.channel + ":" + ref)
+		if err != nil {
+			return "", dbus.MakeFailedError(fmt.Errorf("invalid channel %q", install.channel))
+		}
+		ref = r.String()
+	}
+	opts.autoLaunch = install.autoLaunch
+	return m.execute(sender, "ll-cli", install.args(ref), opts)
+}
+
+// modulePattern matches the package module names ll-cli knows, such as
+// binary and develop.
+var modulePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// installOptions are the InstallStream options beyond execOptions.
+type installOptions struct {
+	autoLaunch bool
+	module     string
+	channel    string
+	repo       string
+}
+
+// args returns the ll-cli install command line for ref. Options go after
+// the ref in --name=value form, so nothing in them is taken for the ref.
+func (o installOptions) args(ref string) []string {
+	args := []string{"install", ref}
+	if o.module != "" {
+		args = append(args, "--module="+o.module)
+	}
+	if o.repo != "" {
+		args = append(args, "--repo="+o.repo)
+	}
+	return args
+}
+
+// parseInstallOptions takes the install option keys out of options and
+// returns the others.
+func parseInstallOptions(options map[string]dbus.Variant) (installOptions, map[string]dbus.Variant, error) {
+	var o installOptions
+	rest := make(map[string]dbus.Variant, len(options))
+	for key, v := range options {
+		switch key {
+		case "autoLaunch":
+			b, ok := v.Value().(bool)
+			if !ok {
+				return installOptions{}, nil, fmt.Errorf("option autoLaunch must be b, got %s", v.Signature())
+			}
+			o.autoLaunch = b
+		case "module":
+			name, ok := v.Value().(string)
+			if !ok || (name != "" && !modulePattern.MatchString(name)) {
+				return installOptions{}, nil, fmt.Errorf("invalid module %s", v)
+			}
+			o.module = name
+		case "channel":
+			name, ok := v.Value().(string)
+			if !ok {
+				return installOptions{}, nil, fmt.Errorf("option channel must be s, got %s", v.Signature())
+			}
+			o.channel = name // checked as part of the ref
+		case "repo":
+			name, ok := v.Value().(string)
+			if !ok || (name != "" && !repoNamePattern.MatchString(name)) {
+				return installOptions{}, nil, fmt.Errorf("invalid repository %s", v)
+			}
+			o.repo = name
+		default:
+			rest[key] = v
+		}
+	}
+	return o, rest, nil
+}
+
+// UninstallStream removes appID, or only the given version of it when
+// version is not empty. It returns an operation ID like ExecuteCommand;
+// removal progress arrives as Output signals and the result as Complete.
+// options takes the keys of ExecuteCommandWithOptions, such as timeout.
+func (m *LinyapsManager) UninstallStream(sender dbus.Sender, appID, version string, options map[string]dbus.Variant) (string, *dbus.Error) {
+	opts, err := parseExecOptions(options)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	ref, err := packageRef(appID, version)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return m.execute(sender, "ll-cli", []string{"uninstall", ref}, opts)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestParseInstallOptions(t *testing.T) {
+	o, rest, err := parseInstallOptions(map[string]dbus.Variant{
+		"autoLaunch": dbus.MakeVariant(true),
+		"timeout":    dbus.MakeVariant(uint32(60)),
+	})
+	if err != nil || !o.autoLaunch || len(rest) != 1 {
+		t.Errorf("parseInstallOptions = %+v, %v, %v", o, rest, err)
+	}
+	if _, ok := rest["timeout"]; !ok {
+		t.Errorf("timeout not passed on: %v", rest)
+	}
+	if _, _, err := parseInstallOptions(map[string]dbus.Variant{"autoLaunch": dbus.MakeVariant("yes")}); err == nil {
+		t.Error("autoLaunch as a string accepted")
+	}
+
+	o, _, err = parseInstallOptions(map[string]dbus.Variant{"module": dbus.MakeVariant("develop")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(o.args("org.example.app/1.0"), " "); got != "install org.example.app/1.0 --module=develop" {
+		t.Errorf("args = %q", got)
+	}
+	for _, bad := range []string{"--force", "develop binary", "Develop", "a=b"} {
+		if _, _, err := parseInstallOptions(map[string]dbus.Variant{"module": dbus.MakeVariant(bad)}); err == nil {
+			t.Errorf("module %q accepted", bad)
+		}
+	}
+	for _, bad := range []string{"--force", "stable testing", "a=b", "../x"} {
+		if _, _, err := parseInstallOptions(map[string]dbus.Variant{"repo": dbus.MakeVariant(bad)}); err == nil {
+			t.Errorf("repo %q accepted", bad)
+		}
+	}
+
+	o, _, err = parseInstallOptions(map[string]dbus.Variant{"repo": dbus.MakeVariant("testing-repo")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(o.args("org.example.app"), " "); got != "install org.example.app --repo=testing-repo" {
+		t.Errorf("args = %q", got)
+	}
+}
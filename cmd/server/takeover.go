@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/jobqueue"
+	"linyapsmanager/internal/streaming"
+)
+
+// requestBusName acquires the well-known name. Every instance allows being
+// replaced; with takeover set the current owner is replaced, and its unique
+// name is returned so lookups for its operations can be forwarded to it
+// while it drains. Nothing else is handed over: the predecessor keeps its
+// operations until they finish, and the new instance binds fresh proxy
+// sockets at the same paths, so containers connected through the
+// predecessor's proxies lose those connections when it exits.
+func requestBusName(conn *dbus.Conn, takeover bool) (predecessor string, err error) {
+	flags := dbus.NameFlagAllowReplacement | dbus.NameFlagDoNotQueue
+	if takeover {
+		flags |= dbus.NameFlagReplaceExisting
+		if err := conn.BusObject().Call("org.freedesktop.DBus.GetNameOwner", 0, dbusconsts.BusName).Store(&predecessor); err != nil {
+			log.Printf("[WARN] --takeover: no running instance found: %v", err)
+			predecessor = ""
+		}
+	}
+
+	reply, err := conn.RequestName(dbusconsts.BusName, flags)
+	if err != nil {
+		return "", fmt.Errorf("request name failed: %w", err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return "", fmt.Errorf("name %s already taken (start with --takeover to replace the running instance)", dbusconsts.BusName)
+	}
+	if predecessor != "" {
+		log.Printf("[INFO] took over %s from %s", dbusconsts.BusName, predecessor)
+	}
+	return predecessor, nil
+}
+
+// watchNameLost returns a channel that is closed once another instance
+// replaces us as owner of the well-known name.
+func watchNameLost(conn *dbus.Conn) (<-chan struct{}, error) {
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameLost"),
+	); err != nil {
+		return nil, fmt.Errorf("add NameLost match: %w", err)
+	}
+
+	sigCh := make(chan *dbus.Signal, 8)
+	conn.Signal(sigCh)
+
+	lost := make(chan struct{})
+	go func() {
+		for sig := range sigCh {
+			if sig.Name != "org.freedesktop.DBus.NameLost" || len(sig.Body) == 0 {
+				continue
+			}
+			if name, _ := sig.Body[0].(string); name == dbusconsts.BusName {
+				close(lost)
+				return
+			}
+		}
+	}()
+	return lost, nil
+}
+
+// drain waits until no operation is running or queued, however long that
+// takes, or until a signal arrives on stop. Output and Complete signals of
+// in-flight operations keep flowing from this connection, so subscribed
+// clients still see them finish.
+func drain(stop <-chan os.Signal) {
+	tick := time.NewTicker(time.Second)
+	defer tick.Stop()
+	for {
+		n := streaming.DefaultRegistry.RunningCount()
+		if n == 0 {
+			log.Printf("[INFO] all operations finished, exiting")
+			return
+		}
+		select {
+		case sig := <-stop:
+			log.Printf("[WARN] %s while draining, exiting with %d operations unfinished", sig, n)
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// holdQueue keeps queue busy until the predecessor has released its
+// connection, i.e. drained and exited, so operations queued here do not
+// race its queued and running ones on the linglong backend.
+func holdQueue(conn *dbus.Conn, queue *jobqueue.Queue, predecessor string) error {
+	if predecessor == "" {
+		return nil
+	}
+	if err := conn.AddMatchSignal(
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg(0, predecessor),
+	); err != nil {
+		return fmt.Errorf("add NameOwnerChanged match: %w", err)
+	}
+	release, err := queue.Acquire(context.Background(), "")
+	if err != nil {
+		return err
+	}
+
+	sigCh := make(chan *dbus.Signal, 8)
+	conn.Signal(sigCh)
+	go func() {
+		defer conn.RemoveSignal(sigCh)
+		defer release()
+		var alive bool
+		if err := conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, predecessor).Store(&alive); err == nil && !alive {
+			return
+		}
+		log.Printf("[INFO] holding the job queue until %s has finished its operations", predecessor)
+		for sig := range sigCh {
+			if sig.Name != "org.freedesktop.DBus.NameOwnerChanged" || len(sig.Body) < 3 {
+				continue
+			}
+			if name, _ := sig.Body[0].(string); name != predecessor {
+				continue
+			}
+			if owner, _ := sig.Body[2].(string); owner == "" {
+				log.Printf("[INFO] %s exited, starting queued operations", predecessor)
+				return
+			}
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/polkit"
+	"linyapsmanager/internal/streaming"
+	"linyapsmanager/internal/telemetry"
+)
+
+// telemetrySink reports the result of ll-cli installs to the reporter.
+// Reports are only sent when the user has opted in; installs the user
+// cancelled are not reported.
+type telemetrySink struct {
+	streaming.OutputSink
+	reporter *telemetry.Reporter
+}
+
+func (s telemetrySink) EmitComplete(operationID string, exitCode int, errorMsg string, details map[string]interface{}) error {
+	if op, ok := streaming.DefaultRegistry.Lookup(operationID); ok && op.State != streaming.StateCancelled && s.reporter.Consent() &&
+		op.Labels["command"] == "ll-cli" && op.Labels["operation"] == "install" && op.Labels["ref"] != "" {
+		ev := telemetry.Event{Kind: "install", Ref: op.Labels["ref"], Success: exitCode == 0, ExitCode: exitCode}
+		if err := s.reporter.Submit(ev); err != nil {
+			log.Printf("[WARN] telemetry: %v", err)
+		}
+	}
+	return s.OutputSink.EmitComplete(operationID, exitCode, errorMsg, details)
+}
+
+// GetTelemetryConsent reports whether the user opted in to sending install
+// results and ratings.
+func (m *LinyapsManager) GetTelemetryConsent() (bool, *dbus.Error) {
+	return m.telemetry.Consent(), nil
+}
+
+// SetTelemetryConsent stores the user's telemetry choice. Only the user the
+// manager runs as, or root, may make it.
+func (m *LinyapsManager) SetTelemetryConsent(sender dbus.Sender, consent bool) *dbus.Error {
+	uid, err := polkit.SenderUID(m.conn, sender)
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	if uid != 0 && int(uid) != os.Getuid() {
+		return dbus.MakeFailedError(fmt.Errorf("uid %d may not change the telemetry consent", uid))
+	}
+	if err := m.telemetry.SetConsent(consent); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	log.Printf("[INFO] telemetry consent set to %v by uid %d", consent, uid)
+	return nil
+}
+
+// SubmitRating sends a 1-5 rating for an application. It fails without
+// sending anything unless the user opted in.
+func (m *LinyapsManager) SubmitRating(ref string, rating int32) *dbus.Error {
+	if _, err := llcli.ParseRef(ref); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	if rating < 1 || rating > 5 {
+		return dbus.MakeFailedError(fmt.Errorf("rating %d out of range 1-5", rating))
+	}
+	if err := m.telemetry.Submit(telemetry.Event{Kind: "rating", Ref: ref, Rating: int(rating)}); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	return nil
+}
+
+// GetTelemetryPayloads returns, as JSON, the exact payloads recently sent and
+// the endpoint's response, so users can inspect what was shared.
+func (m *LinyapsManager) GetTelemetryPayloads() (string, *dbus.Error) {
+	data, err := json.Marshal(m.telemetry.Payloads())
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	return string(data), nil
+}
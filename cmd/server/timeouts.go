@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/limits"
+	"linyapsmanager/internal/streaming"
+)
+
+// envTimeoutPrefix is the prefix of per-class timeout overrides, e.g.
+// LINYAPS_TIMEOUT_INSTALL=2h or LINYAPS_TIMEOUT_DEFAULT=10m. A value of 0
+// disables the timeout.
+const envTimeoutPrefix = "LINYAPS_TIMEOUT_"
+
+// defaultTimeouts holds the built-in timeout per method class: the ll-cli
+// subcommand or the operation of a task. Downloads of large apps can take
+// far longer than any fixed cap, so installs and upgrades have none; they
+// can be stopped with CancelOperation. Launches last as long as the app
+// runs, so they have none either.
+var defaultTimeouts = map[string]time.Duration{
+	"run":                   0,
+	"install":               0,
+	"upgrade":               0,
+	"downgrade":             0,
+	"switch-channel":        0,
+	limits.DefaultOperation: cmdTimeout,
+}
+
+// timeoutFor returns the effective timeout of a method class, 0 for none.
+func timeoutFor(class string) time.Duration {
+	d, known := defaultTimeouts[class]
+	if !known {
+		d = defaultTimeouts[limits.DefaultOperation]
+	}
+	spec := os.Getenv(envTimeoutPrefix + strings.ToUpper(strings.ReplaceAll(class, "-", "_")))
+	if spec == "" && !known {
+		spec = os.Getenv(envTimeoutPrefix + "DEFAULT")
+	}
+	if spec == "" {
+		return d
+	}
+	parsed, err := time.ParseDuration(spec)
+	if spec == "0" {
+		parsed, err = 0, nil
+	}
+	if err != nil || parsed < 0 {
+		log.Printf("[WARN] invalid %s timeout %q, using %s", class, spec, d)
+		return d
+	}
+	return parsed
+}
+
+// execOptions are the options of ExecuteCommandWithOptions and the typed
+// streaming methods.
+type execOptions struct {
+	timeout    time.Duration
+	hasTimeout bool // timeout was given, overriding the class default
+	// pty runs the command on a pseudo-terminal; see RunStream and
+	// ExecStream.
+	pty bool
+	// autoLaunch starts the app after an install; see InstallStream.
+	autoLaunch bool
+}
+
+// parseExecOptions reads the recognised option keys:
+//   - timeout (u or i): seconds, 0 for no timeout
+func parseExecOptions(options map[string]dbus.Variant) (execOptions, error) {
+	var o execOptions
+	for key, v := range options {
+		switch key {
+		case "timeout":
+			var secs int64
+			switch n := v.Value().(type) {
+			case uint32:
+				secs = int64(n)
+			case int32:
+				secs = int64(n)
+			default:
+				return execOptions{}, fmt.Errorf("option timeout must be u or i, got %s", v.Signature())
+			}
+			if secs < 0 {
+				return execOptions{}, fmt.Errorf("invalid timeout %d", secs)
+			}
+			o.timeout, o.hasTimeout = time.Duration(secs)*time.Second, true
+		default:
+			return execOptions{}, fmt.Errorf("unknown option %q", key)
+		}
+	}
+	return o, nil
+}
+
+// timeoutFor returns the timeout given in the options, or else the default
+// of class.
+func (o execOptions) timeoutFor(class string) time.Duration {
+	if o.hasTimeout {
+		return o.timeout
+	}
+	return timeoutFor(class)
+}
+
+// operationContext returns the context of an operation carrying labels,
+// with its run time bounded by d unless d is 0. Mutating operations wait in
+// the job queue first; the bound only counts from when they leave it.
+func (m *LinyapsManager) operationContext(labels map[string]string, d time.Duration) context.Context {
+	ctx := streaming.WithRunTimeout(streaming.WithLabels(context.Background(), m.labelOwner(labels)), d)
+	if m.queue != nil && queuedOperations[labels["operation"]] {
+		ctx = streaming.WithGate(ctx, m.queue.Acquire)
+	}
+	return ctx
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/streaming"
+)
+
+func TestTimeoutFor(t *testing.T) {
+	t.Setenv("LINYAPS_TIMEOUT_UNINSTALL", "30m")
+	t.Setenv("LINYAPS_TIMEOUT_UPGRADE", "1h")
+	t.Setenv("LINYAPS_TIMEOUT_DEFAULT", "0")
+	t.Setenv("LINYAPS_TIMEOUT_SEARCH", "soon")
+
+	tests := []struct {
+		class string
+		want  time.Duration
+	}{
+		{"install", 0},
+		{"upgrade", time.Hour},
+		{"uninstall", 30 * time.Minute},
+		{"killall", 0},         // unknown class, LINYAPS_TIMEOUT_DEFAULT
+		{"default", 0},         // LINYAPS_TIMEOUT_DEFAULT
+		{"search", cmdTimeout}, // invalid override is ignored
+		{"run", 0},
+		{"switch-channel", 0},
+	}
+	for _, tt := range tests {
+		if got := timeoutFor(tt.class); got != tt.want {
+			t.Errorf("timeoutFor(%q) = %s, want %s", tt.class, got, tt.want)
+		}
+	}
+}
+
+func TestParseExecOptions(t *testing.T) {
+	opts, err := parseExecOptions(map[string]dbus.Variant{"timeout": dbus.MakeVariant(uint32(90))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := opts.timeoutFor("install"); got != 90*time.Second {
+		t.Errorf("timeout = %s, want 90s", got)
+	}
+	opts, err = parseExecOptions(map[string]dbus.Variant{"timeout": dbus.MakeVariant(int32(0))})
+	if err != nil || opts.timeoutFor("run") != 0 {
+		t.Errorf("timeout 0 = %s, %v; want no timeout", opts.timeoutFor("run"), err)
+	}
+	if opts, _ := parseExecOptions(nil); opts.timeoutFor("uninstall") != cmdTimeout {
+		t.Errorf("default uninstall timeout = %s", opts.timeoutFor("uninstall"))
+	}
+
+	for _, bad := range []map[string]dbus.Variant{
+		{"timeout": dbus.MakeVariant("60")},
+		{"timeout": dbus.MakeVariant(int32(-1))},
+		{"force": dbus.MakeVariant(true)},
+	} {
+		if _, err := parseExecOptions(bad); err == nil {
+			t.Errorf("parseExecOptions(%v) succeeded", bad)
+		}
+	}
+}
+
+func TestRunOperationHasNoTimeout(t *testing.T) {
+	_, _, labels := confineLLCli("ll-cli", []string{"run", "org.example.app"})
+	m := &LinyapsManager{}
+	ctx := m.operationContext(labels, execOptions{}.timeoutFor(labels["operation"]))
+	release := make(chan struct{})
+	defer close(release)
+	opID := streaming.RunTask(ctx, streaming.NewWriterSink(io.Discard, nil), "ll-cli", func(ctx context.Context, out func(string, bool)) error {
+		<-release
+		return nil
+	})
+	if op, _ := streaming.DefaultRegistry.Lookup(opID); op.Timeout != 0 {
+		t.Errorf("run operation timeout = %s, want none", op.Timeout)
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/polkit"
+)
+
+const (
+	defaultTokenTTL = time.Minute
+	maxTokenTTL     = 10 * time.Minute
+	// maxLaunchTokens bounds outstanding tokens so a caller cannot grow the
+	// store without limit.
+	maxLaunchTokens = 256
+)
+
+// runWithTokenAction is the polkit action gating RunWithToken. It is meant to
+// be granted to active local sessions without authentication.
+const runWithTokenAction = "org.linglong_store.LinyapsManager.run-with-token"
+
+var errInvalidToken = errors.New("invalid or expired launch token")
+
+// noAuditSession is what /proc/<pid>/sessionid holds for a process outside
+// any login session.
+const noAuditSession = "4294967295"
+
+type launchToken struct {
+	ref     string
+	creator tokenCaller
+	expires time.Time
+}
+
+// tokenCaller identifies who created or presents a launch token.
+type tokenCaller struct {
+	name    string // unique bus name
+	uid     uint32
+	session string // audit session ID, empty outside a login session
+}
+
+// tokenStore holds one-time launch tokens.
+type tokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]launchToken
+	now    func() time.Time
+}
+
+func newTokenStore() *tokenStore {
+	return &tokenStore{tokens: map[string]launchToken{}, now: time.Now}
+}
+
+// create returns a new token for ref valid for ttl, which only a process
+// of the creator's user and session can redeem.
+func (s *tokenStore) create(ref string, creator tokenCaller, ttl time.Duration) (string, error) {
+	var raw [16]byte
+	if _, err := rand.Read(raw[:]); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	token := hex.EncodeToString(raw[:])
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked()
+	if len(s.tokens) >= maxLaunchTokens {
+		return "", fmt.Errorf("too many outstanding launch tokens (max %d)", maxLaunchTokens)
+	}
+	s.tokens[token] = launchToken{ref: ref, creator: creator, expires: s.now().Add(ttl)}
+	return token, nil
+}
+
+// redeem consumes token and returns what it was issued for. A token
+// presented by another user or session is refused and left for its owner.
+func (s *tokenStore) redeem(token string, caller tokenCaller) (launchToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.expireLocked()
+	t, ok := s.tokens[token]
+	if !ok || t.creator.uid != caller.uid || t.creator.session != caller.session {
+		return launchToken{}, errInvalidToken
+	}
+	delete(s.tokens, token)
+	return t, nil
+}
+
+func (s *tokenStore) expireLocked() {
+	now := s.now()
+	for k, t := range s.tokens {
+		if !now.Before(t.expires) {
+			delete(s.tokens, k)
+		}
+	}
+}
+
+// CreateLaunchToken returns a one-time token that lets another process start
+// appID (an app id or ref) through RunWithToken within ttlSec seconds
+// (default 60, at most 600). Tokens are a convenience for handing a launch
+// to another process of the same user in the same login session, such as
+// a helper the frontend spawns; they grant no access to the bus the
+// redeeming process does not already have.
+func (m *LinyapsManager) CreateLaunchToken(sender dbus.Sender, appID string, ttlSec uint32) (string, *dbus.Error) {
+	if _, err := llcli.ParseRef(appID); err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	creator, err := m.tokenCaller(sender)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	ttl := time.Duration(ttlSec) * time.Second
+	switch {
+	case ttl == 0:
+		ttl = defaultTokenTTL
+	case ttl > maxTokenTTL:
+		return "", dbus.MakeFailedError(fmt.Errorf("token ttl %s exceeds %s", ttl, maxTokenTTL))
+	}
+	token, err := m.tokens.create(appID, creator, ttl)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	log.Printf("[INFO] launch token for %s created by %s (ttl %s)", appID, sender, ttl)
+	return token, nil
+}
+
+// RunWithToken redeems a launch token and runs "ll-cli run" for the app it
+// was issued for, returning the operation ID. Callers must be allowed to
+// call the manager by the bus policy like any other method, hold the
+// polkit action org.linglong_store.LinyapsManager.run-with-token and run
+// as the user, in the login session, the token was created by. The call is
+// refused when polkit cannot be reached.
+func (m *LinyapsManager) RunWithToken(sender dbus.Sender, token string) (string, *dbus.Error) {
+	if dbusErr := m.authorizeTokenCaller(sender); dbusErr != nil {
+		return "", dbusErr
+	}
+	caller, err := m.tokenCaller(sender)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	t, err := m.tokens.redeem(token, caller)
+	if err != nil {
+		log.Printf("[WARN] %s (uid %d) presented an invalid launch token", sender, caller.uid)
+		return "", dbus.MakeFailedError(err)
+	}
+	log.Printf("[INFO] %s redeemed launch token for %s issued to %s", sender, t.ref, t.creator.name)
+	return m.ExecuteCommand(sender, "ll-cli", []string{"run", t.ref})
+}
+
+func (m *LinyapsManager) authorizeTokenCaller(sender dbus.Sender) *dbus.Error {
+	ok, err := polkit.CheckSender(m.conn, sender, runWithTokenAction)
+	if err != nil {
+		return dbus.MakeFailedError(fmt.Errorf("cannot authorize %s: %w", runWithTokenAction, err))
+	}
+	if !ok {
+		return dbus.MakeFailedError(fmt.Errorf("not authorized for %s", runWithTokenAction))
+	}
+	return nil
+}
+
+// tokenCaller returns the user and login session of sender.
+func (m *LinyapsManager) tokenCaller(sender dbus.Sender) (tokenCaller, error) {
+	pid, err := polkit.SenderPID(m.conn, sender)
+	if err != nil {
+		return tokenCaller{}, err
+	}
+	uid, err := polkit.SenderUID(m.conn, sender)
+	if err != nil {
+		return tokenCaller{}, err
+	}
+	session, err := auditSession(pid)
+	if err != nil {
+		return tokenCaller{}, err
+	}
+	return tokenCaller{name: string(sender), uid: uid, session: session}, nil
+}
+
+// auditSession returns the audit session ID of pid, which the process
+// cannot change, or "" if it belongs to no login session.
+func auditSession(pid uint32) (string, error) {
+	data, err := os.ReadFile("/proc/" + strconv.FormatUint(uint64(pid), 10) + "/sessionid")
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil // kernel without audit support
+	}
+	if err != nil {
+		return "", fmt.Errorf("read session of pid %d: %w", pid, err)
+	}
+	if id := strings.TrimSpace(string(data)); id != noAuditSession {
+		return id, nil
+	}
+	return "", nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+var testCaller = tokenCaller{name: ":1.5", uid: 1000, session: "3"}
+
+func TestTokenStoreOneTime(t *testing.T) {
+	s := newTokenStore()
+	token, err := s.create("org.example.app", testCaller, time.Minute)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	got, err := s.redeem(token, tokenCaller{name: ":1.9", uid: 1000, session: "3"})
+	if err != nil {
+		t.Fatalf("redeem: %v", err)
+	}
+	if got.ref != "org.example.app" || got.creator != testCaller {
+		t.Errorf("redeem = %+v", got)
+	}
+	if _, err := s.redeem(token, testCaller); err != errInvalidToken {
+		t.Errorf("second redeem err = %v, want errInvalidToken", err)
+	}
+}
+
+func TestTokenStoreExpiry(t *testing.T) {
+	now := time.Unix(1000, 0)
+	s := newTokenStore()
+	s.now = func() time.Time { return now }
+	token, err := s.create("org.example.app", testCaller, time.Minute)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	now = now.Add(time.Minute)
+	if _, err := s.redeem(token, testCaller); err != errInvalidToken {
+		t.Errorf("redeem after ttl err = %v, want errInvalidToken", err)
+	}
+}
+
+func TestTokenStoreLimit(t *testing.T) {
+	s := newTokenStore()
+	for i := 0; i < maxLaunchTokens; i++ {
+		if _, err := s.create("org.example.app", testCaller, time.Minute); err != nil {
+			t.Fatalf("create %d: %v", i, err)
+		}
+	}
+	if _, err := s.create("org.example.app", testCaller, time.Minute); err == nil {
+		t.Error("expected error past the outstanding token limit")
+	}
+}
+
+func TestTokenStoreOtherCaller(t *testing.T) {
+	s := newTokenStore()
+	token, err := s.create("org.example.app", testCaller, time.Minute)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	for _, other := range []tokenCaller{
+		{name: ":1.5", uid: 1001, session: "3"},
+		{name: ":1.5", uid: 1000, session: "4"},
+		{name: ":1.5", uid: 1000},
+	} {
+		if _, err := s.redeem(token, other); err != errInvalidToken {
+			t.Errorf("redeem by %+v err = %v, want errInvalidToken", other, err)
+		}
+	}
+	if _, err := s.redeem(token, testCaller); err != nil {
+		t.Errorf("redeem by the creator after refused attempts: %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"log"
+
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/otlp"
+	"linyapsmanager/internal/streaming"
+)
+
+// Labels linking an operation to the span of the method call that started
+// it, so its span becomes a child in the same trace.
+const (
+	labelTraceID = "trace_id"
+	labelSpanID  = "span_id"
+)
+
+// openTracer returns the OTLP exporter configured through the standard
+// OTEL_EXPORTER_OTLP_* variables, or nil when tracing is off. Every finished
+// operation is exported as a span.
+func openTracer() *otlp.Exporter {
+	exp, err := otlp.FromEnv("linyaps-manager", version)
+	if err != nil {
+		log.Printf("[WARN] tracing disabled: %v", err)
+		return nil
+	}
+	if exp == nil {
+		return nil
+	}
+	streaming.DefaultRegistry.Watch(func(op streaming.Operation) {
+		if op.State != streaming.StateRunning {
+			exp.Export(operationSpan(op))
+		}
+	})
+	log.Printf("[INFO] exporting traces to %s", exp.URL())
+	return exp
+}
+
+// operationSpan describes a finished operation: the child process, or the
+// task, from start to exit. Time spent in the job queue before the start is
+// recorded as linyaps.queue_ms.
+func operationSpan(op streaming.Operation) otlp.Span {
+	name := op.Program
+	if c := op.Labels["command"]; c != "" {
+		name = c
+	}
+	if o := op.Labels["operation"]; o != "" {
+		name += " " + o
+	}
+	s := otlp.Span{Name: name, Kind: otlp.KindInternal, Start: op.StartTime, End: op.EndTime}
+	s.TraceID, _ = otlp.ParseTraceID(op.Labels[labelTraceID])
+	s.Parent, _ = otlp.ParseSpanID(op.Labels[labelSpanID])
+
+	s.SetAttr("linyaps.operation_id", op.ID)
+	s.SetAttr("linyaps.state", string(op.State))
+	s.SetAttr("process.exit_code", op.ExitCode)
+	for _, key := range []string{"operation", "ref", "caller", "version", "scope"} {
+		if v := op.Labels[key]; v != "" {
+			s.SetAttr("linyaps."+key, v)
+		}
+	}
+	if ref := op.Labels["ref"]; ref != "" {
+		s.SetAttr("linyaps.app_id", llcli.AppIDFromRef(ref))
+	}
+	if op.QueueWait > 0 {
+		s.SetAttr("linyaps.queue_ms", op.QueueWait.Milliseconds())
+	}
+	if op.State != streaming.StateCompleted {
+		s.Error = op.ErrorMsg
+		if s.Error == "" {
+			s.Error = string(op.State)
+		}
+	}
+	return s
+}
+
+// linkSpan records span in labels so the operation started with them is
+// exported as its child.
+func linkSpan(labels map[string]string, span *otlp.Span) {
+	if span == nil {
+		return
+	}
+	labels[labelTraceID] = span.TraceID.String()
+	labels[labelSpanID] = span.ID.String()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/polkit"
+	"linyapsmanager/internal/sdnotify"
+	"linyapsmanager/internal/selfupdate"
+	"linyapsmanager/internal/streaming"
+)
+
+// version is set at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+const updateTimeout = 10 * time.Minute
+
+// selfUpdateAction is the polkit action required to replace the service
+// binary.
+const selfUpdateAction = "org.linglong_store.LinyapsManager.self-update"
+
+// updating guards against concurrent SelfUpdate runs.
+var updating atomic.Bool
+
+// CheckUpdate reports the latest published version and whether it is newer
+// than the running one. It fails when self-update is not configured.
+func (m *LinyapsManager) CheckUpdate() (string, bool, *dbus.Error) {
+	cfg, err := selfupdate.ConfigFromEnv()
+	if err != nil {
+		return "", false, dbus.MakeFailedError(err)
+	}
+	ctx, cancel := replyContext()
+	defer cancel()
+
+	rel, err := selfupdate.Check(ctx, http.DefaultClient, cfg)
+	if err != nil {
+		return "", false, dbus.MakeFailedError(err)
+	}
+	return rel.Version, selfupdate.Newer(rel.Version, version), nil
+}
+
+// SelfUpdate downloads, verifies and installs a newer manager release, then
+// starts it with --takeover so in-flight operations keep running in this
+// instance until they finish. Progress is reported through the Output and
+// Complete signals of the returned operation. The caller must be
+// authorized for self-update; the call is refused when polkit cannot be
+// reached.
+func (m *LinyapsManager) SelfUpdate(sender dbus.Sender) (string, *dbus.Error) {
+	cfg, err := selfupdate.ConfigFromEnv()
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	ok, err := polkit.CheckSenderInteractive(m.conn, sender, selfUpdateAction)
+	if err != nil {
+		return "", dbus.MakeFailedError(fmt.Errorf("cannot authorize %s: %w", selfUpdateAction, err))
+	}
+	if !ok {
+		return "", dbus.MakeFailedError(fmt.Errorf("not authorized for %s", selfUpdateAction))
+	}
+	if !updating.CompareAndSwap(false, true) {
+		return "", dbus.MakeFailedError(errors.New("an update is already in progress"))
+	}
+
+	labels := m.labelOwner(map[string]string{"caller": string(sender)})
+	ctx, cancel := context.WithTimeout(streaming.WithLabels(context.Background(), labels), updateTimeout)
+	opID := streaming.RunTask(ctx, m.sink, "self-update", func(ctx context.Context, out func(string, bool)) error {
+		defer cancel()
+		defer updating.Store(false)
+		return runSelfUpdate(ctx, cfg, out)
+	})
+	log.Printf("[INFO] self-update started by %s: opID=%s", sender, opID)
+	return opID, nil
+}
+
+func runSelfUpdate(ctx context.Context, cfg selfupdate.Config, out func(string, bool)) error {
+	out(fmt.Sprintf("checking %s\n", cfg.URL), false)
+	rel, err := selfupdate.Check(ctx, http.DefaultClient, cfg)
+	if err != nil {
+		return err
+	}
+	if !selfupdate.Newer(rel.Version, version) {
+		out(fmt.Sprintf("already up to date (running %s, latest %s)\n", version, rel.Version), false)
+		return nil
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate executable: %w", err)
+	}
+	out(fmt.Sprintf("downloading %s %s\n", rel.Version, rel.URL), false)
+	if err := selfupdate.Stage(ctx, http.DefaultClient, cfg, rel, exe, version); err != nil {
+		return err
+	}
+	out(fmt.Sprintf("verified and installed %s to %s\n", rel.Version, exe), false)
+
+	pid, err := startSuccessor(exe)
+	if err != nil {
+		return err
+	}
+	out(fmt.Sprintf("started new instance (pid %d), this instance exits after draining\n", pid), false)
+	return nil
+}
+
+// startSuccessor launches exe with --takeover in its own session. Under
+// systemd the new process is announced as the service's main PID so the unit
+// keeps tracking it after this process exits (requires NotifyAccess=all).
+func startSuccessor(exe string) (int, error) {
+	cmd := exec.Command(exe, "--takeover")
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("start new instance: %w", err)
+	}
+	pid := cmd.Process.Pid
+	if _, err := sdnotify.Notify("MAINPID=" + strconv.Itoa(pid)); err != nil {
+		log.Printf("[WARN] failed to hand over main PID to systemd: %v", err)
+	}
+	_ = cmd.Process.Release()
+	return pid, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/polkit"
+)
+
+// installFromURIAction is the polkit action confirming installs requested
+// by deep links. Its policy decides whether the user is asked.
+const installFromURIAction = "org.linglong_store.LinyapsManager.install-from-uri"
+
+// HandleURI performs the request carried by a store deep link such as
+// linglong://install/<app id>?version=<version>&channel=<channel>, once the
+// user has confirmed it through polkit. It returns an operation ID like
+// ExecuteCommand. Deep links come from web pages, so the install is refused
+// when polkit cannot be reached to confirm it.
+func (m *LinyapsManager) HandleURI(sender dbus.Sender, uri string) (string, *dbus.Error) {
+	if m.draining.Load() {
+		return "", dbus.MakeFailedError(errors.New("service is being replaced by a new instance, retry"))
+	}
+	req, err := llcli.ParseURI(uri)
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	log.Printf("[INFO] HandleURI %s from %s", uri, sender)
+
+	ok, err := polkit.CheckSenderInteractive(m.conn, sender, installFromURIAction)
+	if err != nil {
+		log.Printf("[WARN] cannot confirm %s: %v", uri, err)
+		return "", dbus.MakeFailedError(fmt.Errorf("cannot confirm install of %s: %w", req.Ref, err))
+	}
+	if !ok {
+		log.Printf("[INFO] install of %s from URI not confirmed", req.Ref)
+		return "", dbus.MakeFailedError(fmt.Errorf("install of %s was not confirmed", req.Ref))
+	}
+	return m.ExecuteCommand(sender, "ll-cli", []string{"install", req.Ref.String()})
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/llcli"
+)
+
+// replyBudget bounds all the work a blocking method does before it replies,
+// so callers keeping the default 25s D-Bus reply timeout get an answer
+// rather than a timeout error while the work goes on. Anything that may
+// take longer runs as an operation and the method returns its ID.
+const replyBudget = 20 * time.Second
+
+// replyContext returns the context bounding the work of a blocking method.
+func replyContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), replyBudget)
+}
+
+// llcliOutput runs ll-cli synchronously and returns its stdout. The error
+// includes the last line ll-cli printed to stderr.
+func llcliOutput(ctx context.Context, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "ll-cli", args...)
+	cmd.Env = buildCommandEnv("ll-cli")
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("ll-cli %s: %v: %s", args[0], err, lastLine(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("ll-cli %s: %w", args[0], err)
+	}
+	return string(out), nil
+}
+
+// remoteVersions returns the packages of appID available in the configured
+// repositories.
+func remoteVersions(ctx context.Context, appID string) ([]llcli.Package, error) {
+	out, err := llcliOutput(ctx, "search", appID, "--json")
+	if err != nil {
+		return nil, err
+	}
+	pkgs, err := llcli.ParseSearch(out)
+	if err != nil {
+		return nil, err
+	}
+	matching := pkgs[:0]
+	for _, p := range pkgs {
+		if p.ID == appID {
+			matching = append(matching, p)
+		}
+	}
+	return matching, nil
+}
+
+// versionEntry is one row of ListVersions.
+type versionEntry struct {
+	pkg       llcli.Package
+	installed bool
+	remote    bool
+}
+
+// ListVersions returns the versions of appID, newest first. Each entry holds
+// version, channel, arch, module, repo (s), installed, remote and disabled
+// (b); a version both installed and available appears once with both flags
+// set, and installed versions of an app disabled with DisableApp are marked
+// disabled.
+// Remote versions are only looked up when includeRemote is true. An app
+// hidden from the caller by the visibility policy has no versions.
+func (m *LinyapsManager) ListVersions(sender dbus.Sender, appID string, includeRemote bool) ([]map[string]dbus.Variant, *dbus.Error) {
+	order, dbusErr := m.versions(sender, appID, includeRemote)
+	if dbusErr != nil {
+		return nil, dbusErr
+	}
+	disabled := m.appDisabled(appID)
+	out := make([]map[string]dbus.Variant, 0, len(order))
+	for _, e := range order {
+		out = append(out, map[string]dbus.Variant{
+			"version":   dbus.MakeVariant(e.pkg.Version),
+			"channel":   dbus.MakeVariant(e.pkg.Channel),
+			"arch":      dbus.MakeVariant(e.pkg.Arch),
+			"module":    dbus.MakeVariant(e.pkg.Module),
+			"repo":      dbus.MakeVariant(e.pkg.Repo),
+			"installed": dbus.MakeVariant(e.installed),
+			"remote":    dbus.MakeVariant(e.remote),
+			"disabled":  dbus.MakeVariant(disabled && e.installed),
+		})
+	}
+	return out, nil
+}
+
+// GetAvailableVersions returns the versions of appID available in the
+// configured repositories, newest first, for version pickers. Each entry
+// holds version, channel, arch, module, repo (s), size (t: download size,
+// 0 if the repository does not report it) and installed (b). An app hidden
+// from the caller by the visibility policy has no versions.
+func (m *LinyapsManager) GetAvailableVersions(sender dbus.Sender, appID string) ([]map[string]dbus.Variant, *dbus.Error) {
+	order, dbusErr := m.versions(sender, appID, true)
+	if dbusErr != nil {
+		return nil, dbusErr
+	}
+	out := []map[string]dbus.Variant{}
+	for _, e := range order {
+		if !e.remote {
+			continue
+		}
+		out = append(out, map[string]dbus.Variant{
+			"version":   dbus.MakeVariant(e.pkg.Version),
+			"channel":   dbus.MakeVariant(e.pkg.Channel),
+			"arch":      dbus.MakeVariant(e.pkg.Arch),
+			"module":    dbus.MakeVariant(e.pkg.Module),
+			"repo":      dbus.MakeVariant(e.pkg.Repo),
+			"size":      dbus.MakeVariant(uint64(max(e.pkg.Size, 0))),
+			"installed": dbus.MakeVariant(e.installed),
+		})
+	}
+	return out, nil
+}
+
+// versions returns the installed versions of appID and, if includeRemote
+// is set, those in the repositories, newest first and each once.
+func (m *LinyapsManager) versions(sender dbus.Sender, appID string, includeRemote bool) ([]*versionEntry, *dbus.Error) {
+	ref, err := llcli.ParseRef(appID)
+	if err != nil || ref.String() != ref.ID {
+		return nil, dbus.MakeFailedError(fmt.Errorf("invalid app id %q", appID))
+	}
+	if m.appHidden(sender, appID) {
+		return nil, nil
+	}
+	if dbusErr := m.ready.check(); dbusErr != nil {
+		return nil, dbusErr
+	}
+	ctx, cancel := replyContext()
+	defer cancel()
+
+	entries := map[string]*versionEntry{}
+	var order []*versionEntry
+	add := func(p llcli.Package, installed bool) {
+		key := strings.Join([]string{p.Version, p.Channel, p.Module, p.Arch}, "/")
+		e, ok := entries[key]
+		if !ok {
+			e = &versionEntry{pkg: p}
+			entries[key] = e
+			order = append(order, e)
+		}
+		if installed {
+			e.installed = true
+		} else {
+			e.remote = true
+			if e.pkg.Repo == "" {
+				e.pkg.Repo = p.Repo
+			}
+			if e.pkg.Size == 0 {
+				e.pkg.Size = p.Size
+			}
+		}
+	}
+
+	pkgs, err := m.installed.packages(ctx)
+	if err != nil {
+		return nil, dbus.MakeFailedError(err)
+	}
+	for _, p := range pkgs {
+		if p.ID == appID {
+			add(p, true)
+		}
+	}
+	if includeRemote {
+		remote, err := remoteVersions(ctx, appID)
+		if err != nil {
+			return nil, dbus.MakeFailedError(err)
+		}
+		for _, p := range remote {
+			add(p, false)
+		}
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return llcli.CompareVersions(order[i].pkg.Version, order[j].pkg.Version) > 0
+	})
+	return order, nil
+}
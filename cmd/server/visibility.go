@@ -0,0 +1,106 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/history"
+	"linyapsmanager/internal/polkit"
+	"linyapsmanager/internal/visibility"
+)
+
+// manageVisibilityAction is the polkit action guarding the visibility policy API.
+const manageVisibilityAction = "org.linglong_store.LinyapsManager.manage-visibility"
+
+var errVisibilityDisabled = errors.New("app visibility policy is not available")
+
+// openVisibility loads the per-user app visibility policy.
+func openVisibility() *visibility.Store {
+	dir := history.StateDir()
+	if dir == "" {
+		log.Printf("[WARN] app visibility policy unavailable: no state directory")
+		return nil
+	}
+	store, err := visibility.Open(filepath.Join(dir, "visibility.json"))
+	if err != nil {
+		log.Printf("[WARN] app visibility policy unavailable: %v", err)
+		return nil
+	}
+	return store
+}
+
+// appHidden reports whether the visibility policy hides appID from the
+// caller. Root is never restricted. If the caller cannot be identified
+// while a policy is in place, the app is treated as hidden.
+func (m *LinyapsManager) appHidden(sender dbus.Sender, appID string) bool {
+	if m.visibility == nil || m.visibility.Empty() || sender == "" {
+		return false
+	}
+	uid, err := polkit.SenderUID(m.conn, sender)
+	if err != nil {
+		log.Printf("[WARN] visibility check for %s: %v", appID, err)
+		return true
+	}
+	return uid != 0 && !m.visibility.Visible(uid, appID)
+}
+
+// appHiddenError is returned for an app hidden from the caller; it reads
+// like the error for an app that is not installed.
+func appHiddenError(appID string) *dbus.Error {
+	return dbus.MakeFailedError(fmt.Errorf("app %s not found", appID))
+}
+
+// SetVisibilityPolicy replaces the rule limiting the apps the user uid can
+// list, inspect and run. With a non-empty allow list only matching apps are
+// visible; apps matching deny never are. Patterns are app IDs or prefixes
+// ending in "*". Empty lists remove the rule. The caller needs the
+// manage-visibility polkit authorization.
+func (m *LinyapsManager) SetVisibilityPolicy(sender dbus.Sender, uid uint32, allow, deny []string) *dbus.Error {
+	if dbusErr := m.checkManageVisibility(sender); dbusErr != nil {
+		return dbusErr
+	}
+	if err := m.visibility.Set(visibility.Rule{UID: uid, Allow: allow, Deny: deny}); err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	log.Printf("[INFO] visibility rule for uid %d set by %s: allow=%v deny=%v", uid, sender, allow, deny)
+	return nil
+}
+
+// GetVisibilityPolicy returns every rule, ordered by uid. Entries hold uid
+// (u), allow and deny (as). It needs the same authorization as
+// SetVisibilityPolicy.
+func (m *LinyapsManager) GetVisibilityPolicy(sender dbus.Sender) ([]map[string]dbus.Variant, *dbus.Error) {
+	if dbusErr := m.checkManageVisibility(sender); dbusErr != nil {
+		return nil, dbusErr
+	}
+	rules := m.visibility.Rules()
+	out := make([]map[string]dbus.Variant, 0, len(rules))
+	for _, r := range rules {
+		out = append(out, map[string]dbus.Variant{
+			"uid":   dbus.MakeVariant(r.UID),
+			"allow": dbus.MakeVariant(append([]string{}, r.Allow...)),
+			"deny":  dbus.MakeVariant(append([]string{}, r.Deny...)),
+		})
+	}
+	return out, nil
+}
+
+// checkManageVisibility authorizes the caller, letting polkit ask for
+// authentication. It fails closed when polkit is unavailable.
+func (m *LinyapsManager) checkManageVisibility(sender dbus.Sender) *dbus.Error {
+	if m.visibility == nil {
+		return dbus.MakeFailedError(errVisibilityDisabled)
+	}
+	ok, err := polkit.CheckSenderInteractive(m.conn, sender, manageVisibilityAction)
+	if err != nil {
+		return dbus.MakeFailedError(err)
+	}
+	if !ok {
+		return dbus.MakeFailedError(fmt.Errorf("not authorized for %s", manageVisibilityAction))
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"golang.org/x/sys/unix"
+
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/streaming"
+)
+
+// exitPollInterval is how often WaitForExit checks the container processes.
+const exitPollInterval = 500 * time.Millisecond
+
+// runningContainers returns the containers listed by "ll-cli ps".
+func runningContainers(ctx context.Context) ([]llcli.Container, error) {
+	out, err := llcliOutput(ctx, "ps")
+	if err != nil {
+		return nil, err
+	}
+	return llcli.ParsePs(out)
+}
+
+// WaitForExit returns an operation that completes once every container
+// matching target has exited. target is a container ID (or a prefix of at
+// least 4 characters) or an app ID. With a timeoutSec of 0 the operation
+// waits indefinitely; otherwise it fails after that many seconds with
+// details timed_out=true.
+//
+// Complete details hold container_ids (as). The exit status of the app
+// itself is not known to the manager; exit code 0 only means it has exited.
+func (m *LinyapsManager) WaitForExit(sender dbus.Sender, target string, timeoutSec uint32) (string, *dbus.Error) {
+	if target == "" || strings.ContainsAny(target, " \t\n/:") {
+		return "", dbus.MakeFailedError(fmt.Errorf("invalid app or container id %q", target))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), installedTimeout)
+	all, err := runningContainers(ctx)
+	cancel()
+	if err != nil {
+		return "", dbus.MakeFailedError(err)
+	}
+	var matched []llcli.Container
+	for _, c := range all {
+		if c.Matches(target) {
+			matched = append(matched, c)
+		}
+	}
+	if len(matched) == 0 {
+		return "", dbus.MakeFailedError(fmt.Errorf("no running container matches %q", target))
+	}
+
+	labels := map[string]string{
+		"caller":    string(sender),
+		"operation": "wait-exit",
+		"ref":       matched[0].App,
+	}
+	ctx = streaming.WithLabels(context.Background(), m.labelOwner(labels))
+	if timeoutSec > 0 {
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeoutSec)*time.Second)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	opID := streaming.RunDetailedTask(ctx, m.sink, "wait", func(ctx context.Context, out func(string, bool)) (map[string]interface{}, error) {
+		defer cancel()
+		ids := make([]string, len(matched))
+		for i, c := range matched {
+			ids[i] = c.ID
+			out(fmt.Sprintf("waiting for %s (container %s, pid %d)\n", c.App, c.ID, c.PID), false)
+		}
+		details := map[string]interface{}{"container_ids": ids}
+		err := waitContainers(ctx, matched, func(c llcli.Container) {
+			out(fmt.Sprintf("%s exited (container %s)\n", c.App, c.ID), false)
+		})
+		if errors.Is(err, context.DeadlineExceeded) {
+			details[streaming.DetailTimedOut] = true
+			err = fmt.Errorf("timed out after %ds", timeoutSec)
+		}
+		return details, err
+	})
+	log.Printf("[INFO] waiting for %d container(s) of %q: opID=%s", len(matched), target, opID)
+	return opID, nil
+}
+
+// waitContainers blocks until all containers have exited or ctx is done,
+// calling exited for each as it goes. Containers with a known PID are
+// checked by signalling the process; the others through "ll-cli ps".
+func waitContainers(ctx context.Context, containers []llcli.Container, exited func(llcli.Container)) error {
+	pending := append([]llcli.Container(nil), containers...)
+	ticker := time.NewTicker(exitPollInterval)
+	defer ticker.Stop()
+	for {
+		var listed []llcli.Container
+		listedOK := false
+		still := pending[:0]
+		for _, c := range pending {
+			alive := true
+			if c.PID > 0 {
+				alive = unix.Kill(c.PID, 0) != syscall.ESRCH
+			} else {
+				if !listedOK {
+					var err error
+					if listed, err = runningContainers(ctx); err != nil {
+						return err
+					}
+					listedOK = true
+				}
+				alive = false
+				for _, l := range listed {
+					if l.ID == c.ID {
+						alive = true
+						break
+					}
+				}
+			}
+			if alive {
+				still = append(still, c)
+			} else {
+				exited(c)
+			}
+		}
+		pending = still
+		if len(pending) == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,427 @@
+// Command linyaps-smoketest is an acceptance test of an installed
+// LinyapsManager for packagers. It talks to the running service like a
+// store frontend would and reports each step as TAP (the default) or JSON:
+//
+//	linyaps-smoketest -app org.example.hello
+//	linyaps-smoketest -app org.example.hello -format json > report.json
+//
+// With -app it installs that app, launches it, kills it and uninstalls it
+// again, so pick a small app that is not installed yet. Without -app only
+// the steps that change nothing run. Steps the service does not support are
+// skipped. The exit code is 0 when no step failed and 1 otherwise.
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/godbus/dbus/v5/introspect"
+
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/dbusutil"
+	"linyapsmanager/internal/llcli"
+	"linyapsmanager/internal/streaming"
+)
+
+// Step results.
+const (
+	statusOK   = "ok"
+	statusFail = "not ok"
+	statusSkip = "skip"
+)
+
+// result is one step of the report.
+type result struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// report is the JSON output.
+type report struct {
+	Tests   []result `json:"tests"`
+	Passed  int      `json:"passed"`
+	Failed  int      `json:"failed"`
+	Skipped int      `json:"skipped"`
+}
+
+// errSkip makes a step report itself as skipped with the error's message.
+type errSkip struct{ reason string }
+
+func (e errSkip) Error() string { return e.reason }
+
+type suite struct {
+	conn    *dbus.Conn
+	obj     dbus.BusObject
+	methods map[string]bool
+	timeout time.Duration
+	tap     bool
+	results []result
+}
+
+func main() {
+	app := flag.String("app", "", "ref of a small app that is not installed, to install, run, kill and uninstall")
+	keyword := flag.String("search", "", "search keyword (default: the app ID)")
+	format := flag.String("format", "tap", "report format: tap or json")
+	timeout := flag.Duration("timeout", 10*time.Minute, "time limit for each step")
+	runTime := flag.Duration("run-time", 5*time.Second, "how long the launched app must keep running before it is killed")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-app REF] [-search KEYWORD] [-format tap|json] [-timeout DURATION]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 0 || (*format != "tap" && *format != "json") {
+		flag.Usage()
+		os.Exit(2)
+	}
+	appID := ""
+	if *app != "" {
+		ref, err := llcli.ParseRef(*app)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		appID = ref.ID
+	}
+	if *keyword == "" {
+		*keyword = appID
+	}
+
+	s := &suite{timeout: *timeout, tap: *format == "tap"}
+	if s.tap {
+		fmt.Println("TAP version 13")
+	}
+	if s.step("connect", s.connect) {
+		s.step("wait ready", s.waitReady)
+		s.step("list installed", s.list)
+		s.step("stream and replay output", s.streamReplay)
+		if *keyword != "" {
+			s.step("search "+*keyword, func() error { return s.search(*keyword) })
+		} else {
+			s.skip("search", "no -app or -search given")
+		}
+		if *app != "" {
+			if s.step("install "+*app, func() error { return s.install(*app, appID) }) {
+				s.step("run and kill "+appID, func() error { return s.runAndKill(*app, appID, *runTime) })
+				s.step("uninstall "+appID, func() error { return s.uninstall(appID) })
+			}
+		} else {
+			s.skip("install, run, kill, uninstall", "no -app given")
+		}
+		s.step("prune preview", s.prunePreview)
+	}
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	os.Exit(s.finish())
+}
+
+// step runs fn as a named step and reports whether it passed.
+func (s *suite) step(name string, fn func() error) bool {
+	start := time.Now()
+	err := fn()
+	r := result{Name: name, Status: statusOK, DurationMs: time.Since(start).Milliseconds()}
+	var skip errSkip
+	switch {
+	case errors.As(err, &skip):
+		r.Status, r.Detail = statusSkip, skip.reason
+	case err != nil:
+		r.Status, r.Detail = statusFail, err.Error()
+	}
+	s.record(r)
+	return err == nil
+}
+
+func (s *suite) skip(name, reason string) {
+	s.record(result{Name: name, Status: statusSkip, Detail: reason})
+}
+
+func (s *suite) record(r result) {
+	s.results = append(s.results, r)
+	if !s.tap {
+		return
+	}
+	n := len(s.results)
+	switch r.Status {
+	case statusSkip:
+		fmt.Printf("ok %d - %s # SKIP %s\n", n, r.Name, r.Detail)
+	case statusFail:
+		fmt.Printf("not ok %d - %s\n", n, r.Name)
+		for _, l := range strings.Split(strings.TrimSpace(r.Detail), "\n") {
+			fmt.Printf("# %s\n", l)
+		}
+	default:
+		fmt.Printf("ok %d - %s # %dms\n", n, r.Name, r.DurationMs)
+	}
+}
+
+// finish prints the plan or the JSON report and returns the exit code.
+func (s *suite) finish() int {
+	rep := report{Tests: s.results}
+	for _, r := range s.results {
+		switch r.Status {
+		case statusOK:
+			rep.Passed++
+		case statusFail:
+			rep.Failed++
+		case statusSkip:
+			rep.Skipped++
+		}
+	}
+	if s.tap {
+		fmt.Printf("1..%d\n# passed %d, failed %d, skipped %d\n", len(s.results), rep.Passed, rep.Failed, rep.Skipped)
+	} else {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(rep)
+	}
+	if rep.Failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+func (s *suite) connect() error {
+	conn, err := dbusutil.Connect("")
+	if err != nil {
+		return fmt.Errorf("failed to connect to D-Bus: %w", err)
+	}
+	s.conn = conn
+	s.obj = conn.Object(dbusconsts.BusName, dbus.ObjectPath(dbusconsts.ObjectPath))
+	var pong string
+	if err := s.obj.Call(dbusconsts.Interface+".Ping", 0).Store(&pong); err != nil {
+		return err
+	}
+	if pong != "pong" {
+		return fmt.Errorf("unexpected Ping reply %q", pong)
+	}
+
+	// Which methods exist decides which steps can run
+	s.methods = map[string]bool{}
+	var data string
+	if err := s.obj.Call("org.freedesktop.DBus.Introspectable.Introspect", 0).Store(&data); err != nil {
+		return nil
+	}
+	var node introspect.Node
+	if err := xml.Unmarshal([]byte(data), &node); err != nil {
+		return nil
+	}
+	for _, iface := range node.Interfaces {
+		if iface.Name != dbusconsts.Interface {
+			continue
+		}
+		for _, m := range iface.Methods {
+			s.methods[m.Name] = true
+		}
+	}
+	return nil
+}
+
+func (s *suite) waitReady() error {
+	var ready bool
+	if err := s.obj.Call(dbusconsts.Interface+".WaitReady", 0, uint32(s.timeout.Milliseconds())).Store(&ready); err != nil {
+		return err
+	}
+	if !ready {
+		return errors.New("linglong backend did not become ready")
+	}
+	return nil
+}
+
+func (s *suite) list() error {
+	out, err := s.llcli("list", "--json")
+	if err != nil {
+		return err
+	}
+	if _, err := llcli.ParseList(out); err != nil {
+		return err
+	}
+	return nil
+}
+
+// streamReplay checks that the streamed output of an operation matches
+// what the service kept for replay.
+func (s *suite) streamReplay() error {
+	if !s.methods["ReplayOutput"] {
+		return errSkip{"service has no ReplayOutput"}
+	}
+	opID, streamed, err := s.execute([]string{"repo", "show"})
+	if err != nil {
+		return err
+	}
+	var chunks []streaming.Chunk
+	var complete bool
+	if err := s.obj.Call(dbusconsts.Interface+".ReplayOutput", 0, opID, uint64(0)).Store(&chunks, &complete); err != nil {
+		return err
+	}
+	var replayed strings.Builder
+	for _, c := range chunks {
+		replayed.WriteString(c.Data)
+	}
+	if !complete {
+		return errors.New("replay does not report the operation complete")
+	}
+	if replayed.String() != streamed {
+		return fmt.Errorf("replayed output differs from streamed output:\n%q\n%q", replayed.String(), streamed)
+	}
+	return nil
+}
+
+func (s *suite) search(keyword string) error {
+	out, err := s.llcli("search", keyword, "--json")
+	if err != nil {
+		return err
+	}
+	pkgs, err := llcli.ParseSearch(out)
+	if err != nil {
+		return err
+	}
+	if len(pkgs) == 0 {
+		return fmt.Errorf("no results for %q", keyword)
+	}
+	return nil
+}
+
+func (s *suite) install(ref, appID string) error {
+	if _, err := s.llcli("install", ref); err != nil {
+		return err
+	}
+	installed, err := s.installed(appID)
+	if err != nil {
+		return err
+	}
+	if !installed {
+		return fmt.Errorf("%s not listed after install", appID)
+	}
+	return nil
+}
+
+// runAndKill launches the app, checks that it keeps running for runTime
+// and kills it.
+func (s *suite) runAndKill(ref, appID string, runTime time.Duration) error {
+	op, err := s.start([]string{"run", ref})
+	if err != nil {
+		return err
+	}
+	defer op.receiver.Stop()
+
+	select {
+	case <-op.done:
+		return fmt.Errorf("%s exited after launch with code %d %s\n%s", appID, op.code, op.msg, op.output.String())
+	case <-time.After(runTime):
+	}
+	if _, err := s.llcli("kill", appID); err != nil {
+		return err
+	}
+	select {
+	case <-op.done:
+		return nil
+	case <-time.After(s.timeout):
+		return fmt.Errorf("%s still running after ll-cli kill", appID)
+	}
+}
+
+func (s *suite) uninstall(appID string) error {
+	if _, err := s.llcli("uninstall", appID); err != nil {
+		return err
+	}
+	installed, err := s.installed(appID)
+	if err != nil {
+		return err
+	}
+	if installed {
+		return fmt.Errorf("%s still listed after uninstall", appID)
+	}
+	return nil
+}
+
+func (s *suite) prunePreview() error {
+	if !s.methods["PrunePreview"] {
+		return errSkip{"service has no PrunePreview"}
+	}
+	return s.obj.Call(dbusconsts.Interface+".PrunePreview", 0).Err
+}
+
+// installed reports whether appID is listed by ll-cli list.
+func (s *suite) installed(appID string) (bool, error) {
+	out, err := s.llcli("list", "--json")
+	if err != nil {
+		return false, err
+	}
+	pkgs, err := llcli.ParseList(out)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range pkgs {
+		if p.ID == appID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// llcli runs ll-cli with args through the service and returns its output,
+// or an error with the output if it fails.
+func (s *suite) llcli(args ...string) (string, error) {
+	_, out, err := s.execute(args)
+	return out, err
+}
+
+// operation is an ll-cli command started through ExecuteCommand.
+type operation struct {
+	id       string
+	receiver *streaming.Receiver
+	output   strings.Builder
+	done     chan struct{} // closed on Complete, after code and msg are set
+	code     int
+	msg      string
+}
+
+// start runs ll-cli with args through ExecuteCommand and collects its
+// output in the background. The caller stops op.receiver.
+func (s *suite) start(args []string) (*operation, error) {
+	receiver, err := streaming.NewReceiver(s.conn)
+	if err != nil {
+		return nil, err
+	}
+	op := &operation{receiver: receiver, done: make(chan struct{})}
+	if err := s.obj.Call(dbusconsts.Interface+".ExecuteCommand", 0, "ll-cli", args).Store(&op.id); err != nil {
+		receiver.Stop()
+		return nil, err
+	}
+	go func() {
+		op.code, op.msg = receiver.WaitForOperation(op.id, func(data string, _ bool) { op.output.WriteString(data) })
+		close(op.done)
+	}()
+	return op, nil
+}
+
+// execute runs ll-cli with args and waits for it, cancelling it after the
+// step timeout. It returns the operation ID and output.
+func (s *suite) execute(args []string) (string, string, error) {
+	op, err := s.start(args)
+	if err != nil {
+		return "", "", err
+	}
+	defer op.receiver.Stop()
+
+	select {
+	case <-op.done:
+		out := op.output.String()
+		if op.code != 0 || op.msg != "" {
+			return op.id, out, fmt.Errorf("ll-cli %s: exit code %d %s\n%s", strings.Join(args, " "), op.code, op.msg, out)
+		}
+		return op.id, out, nil
+	case <-time.After(s.timeout):
+		s.obj.Call(dbusconsts.Interface+".CancelOperation", 0, op.id)
+		return op.id, "", fmt.Errorf("ll-cli %s: no result after %s", strings.Join(args, " "), s.timeout)
+	}
+}
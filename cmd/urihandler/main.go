@@ -0,0 +1,158 @@
+// Command linyaps-uri-handler is the desktop's x-scheme-handler for
+// linglong:// links. Invoked with a link, it hands the link to the
+// UriHandler session service, starting it through D-Bus activation if
+// needed. With --service it is that session service: it validates each link
+// and forwards it to the manager's HandleURI, so the manager itself needs no
+// per-user desktop registration.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusconsts"
+	"linyapsmanager/internal/dbusutil"
+	"linyapsmanager/internal/llcli"
+)
+
+// idleTimeout is how long the service stays up without requests; D-Bus
+// activation starts it again on the next link.
+const idleTimeout = 30 * time.Second
+
+func main() {
+	service := flag.Bool("service", false, "run the UriHandler session service")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s <linglong://install/APPID>\n       %s --service\n", os.Args[0], os.Args[0])
+	}
+	flag.Parse()
+
+	if *service {
+		log.SetFlags(log.LstdFlags | log.Lmicroseconds)
+		if err := serve(); err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		return
+	}
+
+	log.SetFlags(0)
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	opID, err := open(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Println(opID)
+}
+
+// open passes uri to the session service and returns the operation ID.
+func open(uri string) (string, error) {
+	if _, err := llcli.ParseURI(uri); err != nil {
+		return "", err
+	}
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return "", fmt.Errorf("connect session bus: %w", err)
+	}
+	defer conn.Close()
+
+	var opID string
+	obj := conn.Object(dbusconsts.UriHandlerBusName, dbus.ObjectPath(dbusconsts.UriHandlerPath))
+	if err := obj.Call(dbusconsts.UriHandlerInterface+".Open", 0, uri).Store(&opID); err != nil {
+		return "", err
+	}
+	return opID, nil
+}
+
+// handler implements the UriHandler interface.
+type handler struct {
+	mu         sync.Mutex
+	busy       int // calls in progress, which may wait for the user
+	lastActive time.Time
+}
+
+// done marks the end of a call.
+func (h *handler) done() {
+	h.mu.Lock()
+	h.busy--
+	h.lastActive = time.Now()
+	h.mu.Unlock()
+}
+
+// idle reports whether no call has run for idleTimeout.
+func (h *handler) idle() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.busy == 0 && time.Since(h.lastActive) >= idleTimeout
+}
+
+// Open validates uri and forwards it to the manager's HandleURI, which asks
+// the user to confirm. It returns the manager's operation ID.
+func (h *handler) Open(sender dbus.Sender, uri string) (string, *dbus.Error) {
+	h.mu.Lock()
+	h.busy++
+	h.mu.Unlock()
+	defer h.done()
+
+	req, err := llcli.ParseURI(uri)
+	if err != nil {
+		log.Printf("[WARN] rejected link from %s: %v", sender, err)
+		return "", dbus.MakeFailedError(err)
+	}
+	log.Printf("[INFO] %s %s requested by %s", req.Action, req.Ref, sender)
+
+	conn, err := dbusutil.Connect("")
+	if err != nil {
+		return "", dbus.MakeFailedError(fmt.Errorf("connect to the manager: %w", err))
+	}
+	defer conn.Close()
+
+	var opID string
+	obj := conn.Object(dbusconsts.BusName, dbus.ObjectPath(dbusconsts.ObjectPath))
+	if err := obj.Call(dbusconsts.Interface+".HandleURI", 0, uri).Store(&opID); err != nil {
+		log.Printf("[WARN] HandleURI %s: %v", uri, err)
+		return "", dbus.MakeFailedError(err)
+	}
+	log.Printf("[INFO] forwarded %s: opID=%s", uri, opID)
+	return opID, nil
+}
+
+func serve() error {
+	conn, err := dbus.ConnectSessionBus()
+	if err != nil {
+		return fmt.Errorf("connect session bus: %w", err)
+	}
+	defer conn.Close()
+
+	h := &handler{lastActive: time.Now()}
+	if err := conn.Export(h, dbus.ObjectPath(dbusconsts.UriHandlerPath), dbusconsts.UriHandlerInterface); err != nil {
+		return err
+	}
+	reply, err := conn.RequestName(dbusconsts.UriHandlerBusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return fmt.Errorf("request name failed: %w", err)
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return errors.New("name " + dbusconsts.UriHandlerBusName + " already taken")
+	}
+	log.Printf("[INFO] %s started", dbusconsts.UriHandlerBusName)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		if h.idle() {
+			break
+		}
+	}
+	log.Printf("[INFO] idle for %s, exiting", idleTimeout)
+	return nil
+}
@@ -7,4 +7,4 @@ require (
 	github.com/godbus/dbus/v5 v5.2.0
 )
 
-require golang.org/x/sys v0.27.0 // indirect
+require golang.org/x/sys v0.27.0
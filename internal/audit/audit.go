@@ -0,0 +1,320 @@
+// Package audit keeps an append-only log of the D-Bus calls the manager
+// served, one JSON object per line.
+//
+// Entries form a hash chain: each holds the SHA-256 of its own encoding,
+// which includes the hash of the entry before it, so changing, removing or
+// inserting an entry breaks the chain from there on. With a signing key,
+// the hash of every CheckpointInterval-th entry is also signed, so the
+// chain up to a checkpoint cannot be rewritten without the key.
+package audit
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxRecent is how many entries Recent can return.
+	MaxRecent = 1000
+	// maxSize is the log size at which it is rotated to <path>.1,
+	// replacing the previous rotated log.
+	maxSize = 8 << 20
+	// CheckpointInterval is how many entries apart checkpoints are signed;
+	// the first entry written after Open is a checkpoint too.
+	CheckpointInterval = 100
+)
+
+// Entry is one served call.
+type Entry struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"` // interface.member
+	Path       string    `json:"path"`
+	Args       string    `json:"args"`   // in GVariant text format, possibly redacted or truncated
+	Caller     string    `json:"caller"` // unique bus name
+	UID        int64     `json:"uid"`    // -1 if it could not be resolved
+	PID        int64     `json:"pid"`    // -1 if it could not be resolved
+	Result     string    `json:"result"` // "ok", or the D-Bus error name
+	Error      string    `json:"error,omitempty"`
+	DurationMs int64     `json:"duration_ms"`
+
+	Prev string `json:"prev,omitempty"` // hash of the previous entry
+	Hash string `json:"hash,omitempty"` // hex SHA-256 of the entry without Hash and Sig
+	Sig  string `json:"sig,omitempty"`  // base64 ed25519 signature of Hash at checkpoints
+}
+
+// digest returns the hash of e, which covers every field but Hash and Sig.
+func (e Entry) digest() (string, error) {
+	e.Hash, e.Sig = "", ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Log appends entries to a file and keeps the most recent ones in memory.
+type Log struct {
+	path string
+
+	mu     sync.Mutex
+	f      *os.File
+	size   int64
+	recent []Entry // ring of the last MaxRecent entries
+	next   int     // index in recent of the next entry once it is full
+
+	last    string             // hash of the last entry written
+	key     ed25519.PrivateKey // signs checkpoints; nil for none
+	written int                // entries written since Open
+}
+
+// Open opens the log at path for appending, creating it if needed, and
+// loads its last entries.
+func Open(path string) (*Log, error) {
+	l := &Log{path: path}
+	if err := l.load(); err != nil {
+		return nil, err
+	}
+	if err := l.openFile(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func (l *Log) load() error {
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var e Entry
+		if json.Unmarshal(scanner.Bytes(), &e) == nil {
+			l.remember(e)
+			l.last = e.Hash
+		}
+	}
+	return scanner.Err()
+}
+
+func (l *Log) openFile() error {
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	l.f, l.size = f, st.Size()
+	return nil
+}
+
+// SignWith makes the log sign its checkpoints with key.
+func (l *Log) SignWith(key ed25519.PrivateKey) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.key = key
+}
+
+// Append links e to the previous entry and writes it to the log. Prev,
+// Hash and Sig are set by Append.
+func (l *Log) Append(e Entry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e.Prev, e.Sig = l.last, ""
+	hash, err := e.digest()
+	if err != nil {
+		return err
+	}
+	e.Hash = hash
+	if l.key != nil && l.written%CheckpointInterval == 0 {
+		e.Sig = base64.StdEncoding.EncodeToString(ed25519.Sign(l.key, []byte(hash)))
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	l.last = hash
+	l.written++
+	l.remember(e)
+	if l.f == nil {
+		return errors.New("audit log is closed")
+	}
+	if l.size+int64(len(data)) > maxSize {
+		if err := l.rotateLocked(); err != nil {
+			return err
+		}
+	}
+	n, err := l.f.Write(data)
+	l.size += int64(n)
+	return err
+}
+
+func (l *Log) rotateLocked() error {
+	l.f.Close()
+	l.f = nil
+	renameErr := os.Rename(l.path, l.path+".1")
+	if err := l.openFile(); err != nil {
+		return err
+	}
+	return renameErr
+}
+
+func (l *Log) remember(e Entry) {
+	if len(l.recent) < MaxRecent {
+		l.recent = append(l.recent, e)
+		return
+	}
+	l.recent[l.next] = e
+	l.next = (l.next + 1) % MaxRecent
+}
+
+// Recent returns up to n of the latest entries, newest first.
+func (l *Log) Recent(n int) []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n = min(n, len(l.recent))
+	out := make([]Entry, 0, n)
+	for i := 0; i < n; i++ {
+		// The newest entry sits just before next (mod len)
+		j := (l.next - 1 - i + 2*len(l.recent)) % len(l.recent)
+		out = append(out, l.recent[j])
+	}
+	return out
+}
+
+// Close closes the log file. Entries appended later are only kept in memory.
+func (l *Log) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return nil
+	}
+	err := l.f.Close()
+	l.f = nil
+	return err
+}
+
+// Report is the result of Verify.
+type Report struct {
+	Entries     int // entries read
+	Unchained   int // leading entries written before the log was chained
+	Checkpoints int // checkpoint signatures that verified
+	// SignedThrough is the number of entries up to and including the last
+	// verified checkpoint; entries after it are only protected by the chain.
+	SignedThrough int
+	// Broken is the 1-based number of the first entry that does not verify,
+	// 0 if all do, and Problem says why.
+	Broken  int
+	Problem string
+}
+
+// Verify checks the chain of the rotated and the current log, in that
+// order, and the checkpoint signatures if pub is not nil. The first entry
+// may link to an entry that was rotated away.
+func (l *Log) Verify(pub ed25519.PublicKey) (Report, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var r Report
+	v := verifier{pub: pub, r: &r}
+	for _, path := range []string{l.path + ".1", l.path} {
+		if err := v.file(path); err != nil {
+			return Report{}, err
+		}
+		if r.Broken != 0 {
+			break
+		}
+	}
+	return r, nil
+}
+
+type verifier struct {
+	pub     ed25519.PublicKey
+	r       *Report
+	last    string
+	chained bool
+}
+
+func (v *verifier) file(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		v.r.Entries++
+		if problem := v.entry(scanner.Bytes()); problem != "" {
+			v.r.Broken, v.r.Problem = v.r.Entries, problem
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// entry checks one line and returns what is wrong with it.
+func (v *verifier) entry(line []byte) string {
+	var e Entry
+	if err := json.Unmarshal(line, &e); err != nil {
+		return fmt.Sprintf("unreadable entry: %v", err)
+	}
+	if e.Hash == "" {
+		if v.chained {
+			return "entry is not chained"
+		}
+		v.r.Unchained++
+		return ""
+	}
+	if v.chained && e.Prev != v.last {
+		return "entry does not link to the one before it"
+	}
+	if hash, err := e.digest(); err != nil || hash != e.Hash {
+		return "entry does not match its hash"
+	}
+	v.chained, v.last = true, e.Hash
+	if e.Sig != "" && v.pub != nil {
+		sig, err := base64.StdEncoding.DecodeString(e.Sig)
+		if err != nil || !ed25519.Verify(v.pub, []byte(e.Hash), sig) {
+			return "checkpoint signature does not verify"
+		}
+		v.r.Checkpoints++
+		v.r.SignedThrough = v.r.Entries
+	}
+	return ""
+}
+
+// ParseKey decodes a base64 ed25519 private key, either its 32-byte seed
+// or the 64-byte key.
+func ParseKey(s string) (ed25519.PrivateKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("audit key is not base64")
+	}
+	if len(raw) != ed25519.SeedSize && len(raw) != ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("audit key has %d bytes, want an ed25519 seed or private key", len(raw))
+	}
+	// A private key holds the seed followed by the public key
+	return ed25519.NewKeyFromSeed(raw[:ed25519.SeedSize]), nil
+}
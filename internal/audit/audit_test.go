@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, method := range []string{"Ping", "ExecuteCommand", "ListOperations"} {
+		e := Entry{Time: time.Unix(int64(i), 0).UTC(), Method: method, UID: 1000, PID: 42, Result: "ok"}
+		if err := l.Append(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := l.Recent(2); len(got) != 2 || got[0].Method != "ListOperations" || got[1].Method != "ExecuteCommand" {
+		t.Errorf("Recent(2) = %+v", got)
+	}
+	l.Close()
+
+	if st, _ := os.Stat(path); st.Mode().Perm() != 0o600 {
+		t.Errorf("mode = %v", st.Mode())
+	}
+
+	// Entries survive a restart and new ones are appended
+	l, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	l.Append(Entry{Method: "Quit"})
+	got := l.Recent(10)
+	if len(got) != 4 || got[0].Method != "Quit" || got[3].Method != "Ping" || got[3].UID != 1000 {
+		t.Errorf("Recent(10) after reopening = %+v", got)
+	}
+}
+
+func TestLogRing(t *testing.T) {
+	l, err := Open(filepath.Join(t.TempDir(), "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for i := 0; i < MaxRecent+5; i++ {
+		l.Append(Entry{DurationMs: int64(i)})
+	}
+	got := l.Recent(MaxRecent + 5)
+	if len(got) != MaxRecent || got[0].DurationMs != MaxRecent+4 || got[MaxRecent-1].DurationMs != 5 {
+		t.Errorf("len = %d, newest = %d, oldest = %d", len(got), got[0].DurationMs, got[len(got)-1].DurationMs)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	// An entry from before the log was chained
+	if err := os.WriteFile(path, []byte(`{"method":"Ping","result":"ok"}`+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	key, err := ParseKey("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.SignWith(key)
+	for i := 0; i < CheckpointInterval+2; i++ {
+		l.Append(Entry{Time: time.Unix(int64(i), 0).UTC(), Method: "ExecuteCommand", Result: "ok"})
+	}
+	defer l.Close()
+
+	r, err := l.Verify(key.Public().(ed25519.PublicKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Report{Entries: CheckpointInterval + 3, Unchained: 1, Checkpoints: 2, SignedThrough: CheckpointInterval + 2}
+	if r != want {
+		t.Errorf("Verify = %+v, want %+v", r, want)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	for name, tamper := range map[string]func() string{
+		"edited": func() string {
+			return strings.Join(lines[:3], "") + strings.Replace(lines[3], "ExecuteCommand", "Ping", 1) + strings.Join(lines[4:], "")
+		},
+		"removed": func() string { return strings.Join(lines[:3], "") + strings.Join(lines[4:], "") },
+	} {
+		if err := os.WriteFile(path, []byte(tamper()), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if r, err := l.Verify(nil); err != nil || r.Broken != 4 {
+			t.Errorf("%s entry: Verify = %+v, %v; want broken at 4", name, r, err)
+		}
+	}
+
+	other, _ := ParseKey("AQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQEBAQE=")
+	os.WriteFile(path, data, 0o600)
+	if r, _ := l.Verify(other.Public().(ed25519.PublicKey)); r.Broken != 2 {
+		t.Errorf("Verify with another key = %+v, want broken at the first checkpoint", r)
+	}
+}
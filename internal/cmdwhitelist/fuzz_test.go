@@ -0,0 +1,35 @@
+package cmdwhitelist_test
+
+import (
+	"strings"
+	"testing"
+
+	"linyapsmanager/internal/cmdwhitelist"
+)
+
+// FuzzValidateCommand drives the rules with arbitrary caller-supplied
+// arguments. Accepted commands must resolve to a program and must not let
+// NUL bytes through to exec.
+func FuzzValidateCommand(f *testing.F) {
+	f.Add("ll-cli", "install\norg.example.app")
+	f.Add("ll-cli", "run\norg.example.app\n--\nbash")
+	f.Add("killall", "-9\nll-box")
+	f.Add("pkexec", "ll-cli\nuninstall\norg.example.app")
+	f.Add("kill", "-s\nTERM\n1234")
+
+	f.Fuzz(func(t *testing.T, command, joined string) {
+		args := strings.Split(joined, "\n")
+		program, validated, err := cmdwhitelist.ValidateCommand(command, args)
+		if err != nil {
+			return
+		}
+		if program == "" {
+			t.Fatalf("ValidateCommand(%q, %q) accepted with empty program", command, args)
+		}
+		for _, a := range validated {
+			if strings.ContainsRune(a, 0) {
+				t.Fatalf("ValidateCommand(%q, %q) passed NUL byte in %q", command, args, a)
+			}
+		}
+	})
+}
@@ -1,6 +1,9 @@
 package cmdwhitelist
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // ValidationError represents a command validation error.
 type ValidationError struct {
@@ -23,6 +26,16 @@ func ValidateCommand(cmdName string, args []string) (program string, validatedAr
 		}
 	}
 
+	// exec cannot pass NUL bytes; reject them before any rule sees them
+	for _, arg := range args {
+		if strings.ContainsRune(arg, 0) {
+			return "", nil, &ValidationError{
+				Command: cmdName,
+				Reason:  "argument contains NUL byte",
+			}
+		}
+	}
+
 	// Delegate validation to the rule
 	validatedArgs, err = rule.Validate(args)
 	if err != nil {
@@ -0,0 +1,247 @@
+// Package container reads the sandbox configuration of a running app
+// container from /proc.
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProcRoot is the procfs mount point. Tests point it at a fixture tree.
+var ProcRoot = "/proc"
+
+// Mount is one entry of the container's mount table.
+type Mount struct {
+	Target  string // mount point inside the container
+	Root    string // path within Source that is mounted; the host path for bind mounts
+	Source  string
+	FSType  string
+	Options string
+}
+
+// IDMap is one line of a user namespace uid_map or gid_map.
+type IDMap struct {
+	Inside  uint32
+	Outside uint32
+	Count   uint32
+}
+
+// Info is what Inspect reports about a container process.
+type Info struct {
+	PID           int
+	Env           []string
+	Cgroup        string
+	UserNamespace string // e.g. "user:[4026532840]"
+	UIDMap        []IDMap
+	GIDMap        []IDMap
+	Mounts        []Mount
+}
+
+// Inspect reads the configuration of the process pid, normally the init
+// process of a container.
+func Inspect(pid int) (Info, error) {
+	dir := filepath.Join(ProcRoot, strconv.Itoa(pid))
+	if _, err := os.Stat(dir); err != nil {
+		return Info{}, fmt.Errorf("process %d: %w", pid, err)
+	}
+	info := Info{PID: pid}
+	var err error
+	if info.Mounts, err = readMountInfo(filepath.Join(dir, "mountinfo")); err != nil {
+		return Info{}, err
+	}
+	info.Env, _ = Environ(pid)
+	if data, err := os.ReadFile(filepath.Join(dir, "cgroup")); err == nil {
+		info.Cgroup = parseCgroup(string(data))
+	}
+	if link, err := os.Readlink(filepath.Join(dir, "ns", "user")); err == nil {
+		info.UserNamespace = link
+	}
+	if info.UIDMap, err = readIDMap(filepath.Join(dir, "uid_map")); err != nil {
+		return Info{}, err
+	}
+	if info.GIDMap, err = readIDMap(filepath.Join(dir, "gid_map")); err != nil {
+		return Info{}, err
+	}
+	return info, nil
+}
+
+// PIDNamespace returns the pid namespace of the process pid, e.g.
+// "pid:[4026532842]". Processes of one container share it.
+func PIDNamespace(pid int) (string, error) {
+	return os.Readlink(filepath.Join(ProcRoot, strconv.Itoa(pid), "ns", "pid"))
+}
+
+// userHZ is the unit of the times in /proc/<pid>/stat, fixed at 100 per
+// second whatever the kernel's internal tick rate.
+const userHZ = 100
+
+// StartTime returns when the process pid started, from its start time in
+// /proc/<pid>/stat, counted in ticks since boot, and the boot time in
+// /proc/stat.
+func StartTime(pid int) (time.Time, error) {
+	data, err := os.ReadFile(filepath.Join(ProcRoot, strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	// The command name in parentheses may hold spaces and parentheses
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return time.Time{}, fmt.Errorf("malformed stat of process %d", pid)
+	}
+	// Fields after the name start with the third, state; starttime is the 22nd
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 20 {
+		return time.Time{}, fmt.Errorf("malformed stat of process %d", pid)
+	}
+	ticks, err := strconv.ParseUint(fields[19], 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("malformed stat of process %d", pid)
+	}
+	boot, err := bootTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return boot.Add(time.Duration(ticks) * (time.Second / userHZ)), nil
+}
+
+// bootTime reads the btime line of /proc/stat.
+func bootTime() (time.Time, error) {
+	data, err := os.ReadFile(filepath.Join(ProcRoot, "stat"))
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if v, ok := strings.CutPrefix(line, "btime "); ok {
+			secs, err := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+			if err != nil {
+				return time.Time{}, fmt.Errorf("malformed btime line %q", line)
+			}
+			return time.Unix(secs, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no btime in %s", filepath.Join(ProcRoot, "stat"))
+}
+
+// Environ returns the initial environment of the process pid.
+func Environ(pid int) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(ProcRoot, strconv.Itoa(pid), "environ"))
+	if err != nil {
+		return nil, err
+	}
+	var env []string
+	for _, kv := range bytes.Split(data, []byte{0}) {
+		if len(kv) > 0 {
+			env = append(env, string(kv))
+		}
+	}
+	return env, nil
+}
+
+// readMountInfo parses a /proc/<pid>/mountinfo file:
+//
+//	36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+func readMountInfo(path string) ([]Mount, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var mounts []Mount
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		sep := -1
+		for i := 6; i < len(fields); i++ {
+			if fields[i] == "-" {
+				sep = i
+				break
+			}
+		}
+		if sep < 0 || len(fields) < sep+3 {
+			return nil, fmt.Errorf("malformed mountinfo line %q", scanner.Text())
+		}
+		mounts = append(mounts, Mount{
+			Target:  unescapeOctal(fields[4]),
+			Root:    unescapeOctal(fields[3]),
+			Source:  unescapeOctal(fields[sep+2]),
+			FSType:  fields[sep+1],
+			Options: fields[5],
+		})
+	}
+	return mounts, scanner.Err()
+}
+
+// unescapeOctal decodes the \ooo escapes the kernel uses for spaces, tabs,
+// newlines and backslashes in mount paths.
+func unescapeOctal(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// parseCgroup returns the unified (v2) cgroup path, or the first v1 path.
+func parseCgroup(data string) string {
+	var first string
+	for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		if parts[0] == "0" && parts[1] == "" {
+			return parts[2]
+		}
+		if first == "" {
+			first = parts[2]
+		}
+	}
+	return first
+}
+
+func readIDMap(path string) ([]IDMap, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var maps []IDMap
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("malformed id map line %q in %s", line, path)
+		}
+		var vals [3]uint32
+		for i, f := range fields {
+			n, err := strconv.ParseUint(f, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("malformed id map line %q in %s", line, path)
+			}
+			vals[i] = uint32(n)
+		}
+		maps = append(maps, IDMap{Inside: vals[0], Outside: vals[1], Count: vals[2]})
+	}
+	return maps, nil
+}
@@ -0,0 +1,139 @@
+package container
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeProc(t *testing.T, pid string, files map[string]string) {
+	t.Helper()
+	dir := filepath.Join(ProcRoot, pid)
+	if err := os.MkdirAll(filepath.Join(dir, "ns"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	old := ProcRoot
+	ProcRoot = t.TempDir()
+	defer func() { ProcRoot = old }()
+
+	writeProc(t, "4242", map[string]string{
+		"mountinfo": `1 0 0:30 / / rw,relatime - overlay overlay rw
+2 1 8:2 /home/user/Music /home/user/Music\040Box rw,nosuid - ext4 /dev/sda2 rw
+3 1 0:5 / /dev rw shared:2 master:1 - devtmpfs udev rw,size=10k
+`,
+		"environ": "HOME=/home/user\x00LINGLONG_APPID=org.example.app\x00",
+		"cgroup":  "0::/user.slice/user-1000.slice/app-linglong-org.example.app.scope\n",
+		"uid_map": "         0       1000          1\n",
+		"gid_map": "0 1000 1\n1 100000 65536\n",
+	})
+	if err := os.Symlink("user:[4026532840]", filepath.Join(ProcRoot, "4242", "ns", "user")); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := Inspect(4242)
+	if err != nil {
+		t.Fatalf("Inspect: %v", err)
+	}
+	want := Info{
+		PID:           4242,
+		Env:           []string{"HOME=/home/user", "LINGLONG_APPID=org.example.app"},
+		Cgroup:        "/user.slice/user-1000.slice/app-linglong-org.example.app.scope",
+		UserNamespace: "user:[4026532840]",
+		UIDMap:        []IDMap{{0, 1000, 1}},
+		GIDMap:        []IDMap{{0, 1000, 1}, {1, 100000, 65536}},
+		Mounts: []Mount{
+			{Target: "/", Root: "/", Source: "overlay", FSType: "overlay", Options: "rw,relatime"},
+			{Target: "/home/user/Music Box", Root: "/home/user/Music", Source: "/dev/sda2", FSType: "ext4", Options: "rw,nosuid"},
+			{Target: "/dev", Root: "/", Source: "udev", FSType: "devtmpfs", Options: "rw"},
+		},
+	}
+	if !reflect.DeepEqual(info, want) {
+		t.Errorf("Inspect =\n%+v\nwant\n%+v", info, want)
+	}
+}
+
+func TestInspectMissingProcess(t *testing.T) {
+	old := ProcRoot
+	ProcRoot = t.TempDir()
+	defer func() { ProcRoot = old }()
+
+	if _, err := Inspect(1); err == nil {
+		t.Error("expected error for a missing process")
+	}
+}
+
+func TestParseCgroupV1(t *testing.T) {
+	data := "12:pids:/user.slice\n1:name=systemd:/user.slice/app.scope\n"
+	if got := parseCgroup(data); got != "/user.slice" {
+		t.Errorf("parseCgroup = %q, want /user.slice", got)
+	}
+}
+
+func TestUnescapeOctal(t *testing.T) {
+	for in, want := range map[string]string{
+		`/a\040b`:   "/a b",
+		`/a\134b`:   `/a\b`,
+		`/trail\04`: `/trail\04`,
+		`/plain`:    "/plain",
+	} {
+		if got := unescapeOctal(in); got != want {
+			t.Errorf("unescapeOctal(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestPIDNamespace(t *testing.T) {
+	old := ProcRoot
+	ProcRoot = t.TempDir()
+	defer func() { ProcRoot = old }()
+
+	writeProc(t, "4242", nil)
+	if err := os.Symlink("pid:[4026532842]", filepath.Join(ProcRoot, "4242", "ns", "pid")); err != nil {
+		t.Fatal(err)
+	}
+	if ns, err := PIDNamespace(4242); err != nil || ns != "pid:[4026532842]" {
+		t.Errorf("PIDNamespace = %q, %v", ns, err)
+	}
+	if _, err := PIDNamespace(4243); err == nil {
+		t.Error("missing process has a namespace")
+	}
+}
+
+func TestStartTime(t *testing.T) {
+	old := ProcRoot
+	ProcRoot = t.TempDir()
+	defer func() { ProcRoot = old }()
+
+	writeProc(t, "4242", map[string]string{
+		"stat": "4242 (ll-box (init) x) S 1 4242 4242 0 -1 4194560 1 0 0 0 0 0 0 0 20 0 1 0 12345 1000 10 18446744073709551615\n",
+	})
+	if err := os.WriteFile(filepath.Join(ProcRoot, "stat"), []byte("cpu  1 2 3\nbtime 1700000000\nprocesses 10\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	want := time.Unix(1700000000, 0).Add(123450 * time.Millisecond)
+	if got, err := StartTime(4242); err != nil || !got.Equal(want) {
+		t.Errorf("StartTime = %v, %v, want %v", got, err, want)
+	}
+	if _, err := StartTime(4243); err == nil {
+		t.Error("missing process has a start time")
+	}
+
+	// Started four years after boot: ticks * time.Second would overflow
+	writeProc(t, "4244", map[string]string{
+		"stat": "4244 (app) S 1 4244 4244 0 -1 4194560 1 0 0 0 0 0 0 0 20 0 1 0 12614400000 1000 10 18446744073709551615\n",
+	})
+	want = time.Unix(1700000000, 0).Add(126144000 * time.Second)
+	if got, err := StartTime(4244); err != nil || !got.Equal(want) {
+		t.Errorf("StartTime after a long uptime = %v, %v, want %v", got, err, want)
+	}
+}
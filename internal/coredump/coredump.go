@@ -0,0 +1,99 @@
+// Package coredump lists crashes recorded by systemd-coredump in the journal.
+package coredump
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// messageID identifies the journal entries systemd-coredump writes.
+const messageID = "fc2e22bc6ee647b6b90729ab34a250b1"
+
+// Dump is one recorded crash.
+type Dump struct {
+	Time     time.Time
+	PID      int
+	Signal   string // e.g. "SIGSEGV"
+	Exe      string
+	Unit     string // user unit if any, otherwise the system unit
+	Filename string // stored core file, empty if it was not kept
+}
+
+// ParseJournal parses "journalctl -o json" output of coredump entries.
+// Fields holding binary data are encoded by journalctl as arrays and are
+// ignored.
+func ParseJournal(output string) ([]Dump, error) {
+	var dumps []Dump
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var raw map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(line), &raw); err != nil {
+			return nil, fmt.Errorf("parse journal entry: %w", err)
+		}
+		field := func(k string) string {
+			var s string
+			if v, ok := raw[k]; ok {
+				_ = json.Unmarshal(v, &s)
+			}
+			return s
+		}
+
+		d := Dump{
+			Signal:   field("COREDUMP_SIGNAL_NAME"),
+			Exe:      field("COREDUMP_EXE"),
+			Unit:     field("COREDUMP_USER_UNIT"),
+			Filename: field("COREDUMP_FILENAME"),
+		}
+		if d.Unit == "" {
+			d.Unit = field("COREDUMP_UNIT")
+		}
+		d.PID, _ = strconv.Atoi(field("COREDUMP_PID"))
+		ts := field("COREDUMP_TIMESTAMP")
+		if ts == "" {
+			ts = field("__REALTIME_TIMESTAMP")
+		}
+		if usec, err := strconv.ParseInt(ts, 10, 64); err == nil {
+			d.Time = time.UnixMicro(usec)
+		}
+		dumps = append(dumps, d)
+	}
+	return dumps, scanner.Err()
+}
+
+// List returns up to limit of the most recent crashes of processes in units
+// whose name starts with unitPrefix, newest first.
+func List(ctx context.Context, unitPrefix string, limit int) ([]Dump, error) {
+	// Filter by unit ourselves: journalctl matches exact values only. Read a
+	// generous window so other crashes do not crowd out the ones we want.
+	cmd := exec.CommandContext(ctx, "journalctl", "--no-pager", "-o", "json",
+		"-r", "-n", strconv.Itoa(limit*20), "MESSAGE_ID="+messageID)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("journalctl: %w", err)
+	}
+	all, err := ParseJournal(string(out))
+	if err != nil {
+		return nil, err
+	}
+	var dumps []Dump
+	for _, d := range all {
+		if strings.HasPrefix(d.Unit, unitPrefix) {
+			dumps = append(dumps, d)
+			if len(dumps) == limit {
+				break
+			}
+		}
+	}
+	return dumps, nil
+}
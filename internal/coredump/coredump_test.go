@@ -0,0 +1,39 @@
+package coredump
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseJournal(t *testing.T) {
+	out := `{"__REALTIME_TIMESTAMP":"1700000001000000","MESSAGE_ID":"fc2e22bc6ee647b6b90729ab34a250b1","COREDUMP_PID":"4242","COREDUMP_SIGNAL_NAME":"SIGSEGV","COREDUMP_EXE":"/opt/apps/org.example.app/files/bin/app","COREDUMP_UNIT":"user@1000.service","COREDUMP_USER_UNIT":"app-linglong-org.example.app-3.scope","COREDUMP_FILENAME":"/var/lib/systemd/coredump/core.app.1000.zst","COREDUMP_TIMESTAMP":"1700000000000000","COREDUMP":[1,2,3]}
+{"__REALTIME_TIMESTAMP":"1700000005000000","COREDUMP_PID":"7","COREDUMP_SIGNAL_NAME":"SIGABRT","COREDUMP_UNIT":"foo.service"}
+`
+	dumps, err := ParseJournal(out)
+	if err != nil {
+		t.Fatalf("ParseJournal: %v", err)
+	}
+	if len(dumps) != 2 {
+		t.Fatalf("got %d dumps, want 2", len(dumps))
+	}
+	want := Dump{
+		Time:     time.UnixMicro(1700000000000000),
+		PID:      4242,
+		Signal:   "SIGSEGV",
+		Exe:      "/opt/apps/org.example.app/files/bin/app",
+		Unit:     "app-linglong-org.example.app-3.scope",
+		Filename: "/var/lib/systemd/coredump/core.app.1000.zst",
+	}
+	if dumps[0] != want {
+		t.Errorf("dumps[0] = %+v, want %+v", dumps[0], want)
+	}
+	if dumps[1].Unit != "foo.service" || !dumps[1].Time.Equal(time.UnixMicro(1700000005000000)) {
+		t.Errorf("dumps[1] = %+v", dumps[1])
+	}
+}
+
+func TestParseJournalInvalid(t *testing.T) {
+	if _, err := ParseJournal("not json\n"); err == nil {
+		t.Error("expected error")
+	}
+}
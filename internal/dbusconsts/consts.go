@@ -8,7 +8,91 @@ const (
 	ObjectPath = "/org/linglong_store/LinyapsManager"
 	Interface  = "org.linglong_store.LinyapsManager"
 
-	// Signal names for streaming output
-	SignalOutput   = "Output"   // Emitted for each chunk of output (operationID, data string, isStderr bool)
-	SignalComplete = "Complete" // Emitted when operation completes (operationID, exitCode int, errorMsg string)
+	// Signal names for streaming output. Output data normally ends in "\n";
+	// a chunk without it is part of an oversized line continued in the next chunk.
+	SignalOutput   = "Output"   // Emitted for each chunk of output (operationID, data string, isStderr bool, seq uint64)
+	SignalComplete = "Complete" // Emitted when operation completes (operationID, exitCode int, errorMsg string, finalSeq uint64, details a{sv})
+
+	// SignalProgress is emitted alongside an Output line that ll-cli printed
+	// as a progress report (operationID, percent float64, bytesPerSec uint64,
+	// phase string); bytesPerSec is 0 when no speed was shown.
+	SignalProgress = "Progress"
+
+	// SignalQueued is emitted while a mutating operation (install, uninstall,
+	// upgrade, prune, downgrade, switch-channel) waits for the one before it
+	// (operationID, position uint32): 1 when it is next, and finally 0 when
+	// it starts. Operations that do not have to wait get no Queued signal.
+	SignalQueued = "Queued"
+
+	// SignalAppCrashed is emitted when an app launched through ll-cli run exits
+	// nonzero shortly after starting (appID string, reportPath string). The
+	// report directory holds report.json and output.log.
+	SignalAppCrashed = "AppCrashed"
+
+	// App change signals are emitted once an operation that changes the
+	// installed set has finished and the installed apps were listed again,
+	// so they reflect what actually changed: AppInstalled(appID, version
+	// string), AppRemoved(appID, version string) and AppUpgraded(appID,
+	// oldVersion, newVersion string), the latter for downgrades too.
+	SignalAppInstalled = "AppInstalled"
+	SignalAppRemoved   = "AppRemoved"
+	SignalAppUpgraded  = "AppUpgraded"
+
+	// SignalRepoConfigIssue is emitted for each problem found in the
+	// repository configuration that was not reported before (repo, problem,
+	// hint string): problem is one of duplicate_name, duplicate_url,
+	// missing_default, unknown_default, invalid_url or unreachable, repo is
+	// empty for missing_default, and hint suggests the ll-cli repo command
+	// that fixes it. GetRepoIssues lists the current problems.
+	SignalRepoConfigIssue = "RepoConfigIssue"
+
+	// OperationsPath is the parent of one object per running operation, listed
+	// by org.freedesktop.DBus.ObjectManager on ObjectPath.
+	OperationsPath = ObjectPath + "/operations"
+	// OperationInterface carries the properties of an operation object:
+	// Id, Command, Ref, Caller (s, constant), State (s), Progress (i, percent
+	// from the last Progress signal, -1 while unknown) and ExitCode (i), with
+	// PropertiesChanged on change.
+	OperationInterface = Interface + ".Operation"
+
+	// AppsPath is the parent of one object per installed app, listed by
+	// org.freedesktop.DBus.ObjectManager on ObjectPath next to the operation
+	// objects. Path elements are app IDs with dots and hyphens replaced by
+	// underscores.
+	AppsPath = ObjectPath + "/apps"
+	// AppInterface carries the properties of an app object: Id (s, constant),
+	// Version, Channel (s), Size (t, bytes on disk, 0 while unknown) and
+	// Running (b, an ll-cli run of it is in progress), with PropertiesChanged
+	// on change.
+	AppInterface = Interface + ".App"
+
+	// ErrorNotReady is returned while the linglong backend cannot be reached yet.
+	ErrorNotReady = Interface + ".Error.NotReady"
+	// ErrorAppDisabled is returned when starting an app disabled with DisableApp.
+	ErrorAppDisabled = Interface + ".Error.AppDisabled"
+	// ErrorExecDenied is returned when the exec policy refuses the command
+	// to run inside an app container.
+	ErrorExecDenied = Interface + ".Error.ExecDenied"
+	// ErrorGuestDenied is returned when a GuestInterface request does not
+	// come from an app container or the guest policy refuses it.
+	ErrorGuestDenied = Interface + ".Error.GuestDenied"
+
+	// GuestPath carries GuestInterface, the requests apps may make from
+	// inside their container: RequestInstallMissingRuntime(ref string) →
+	// operationID, OpenHostURL(url string) and Version() → u. The calling
+	// app is identified by its container, and each request is checked
+	// against the guest policy for that app.
+	GuestPath      = ObjectPath + "/Guest"
+	GuestInterface = Interface + ".Guest1"
+	// GuestProtocolVersion is returned by GuestInterface.Version; it grows
+	// when methods are added, and the interface name changes when existing
+	// ones change incompatibly.
+	GuestProtocolVersion = 1
+
+	// The session service handling linglong:// links for the desktop. Its
+	// Open(uri string) method validates a link and forwards it to HandleURI,
+	// returning the operation ID (s).
+	UriHandlerBusName   = BusName + ".UriHandler"
+	UriHandlerPath      = ObjectPath + "/UriHandler"
+	UriHandlerInterface = Interface + ".UriHandler"
 )
@@ -24,8 +24,9 @@ func DefaultProxyPath() string {
 
 // Connect returns a D-Bus connection using an explicit address if provided.
 // If addr is empty, it falls back to DBUS_SYSTEM_BUS_ADDRESS and finally the
-// default proxy path (if present) and finally the default system bus.
-func Connect(addr string) (*dbus.Conn, error) {
+// default proxy path (if present) and finally the default system bus. opts
+// are applied to the connection whichever bus it reaches.
+func Connect(addr string, opts ...dbus.ConnOption) (*dbus.Conn, error) {
 	triedProxy := false
 
 	// If no explicit address is provided, prefer the Session Bus if available.
@@ -33,7 +34,7 @@ func Connect(addr string) (*dbus.Conn, error) {
 	// we connect directly to the session bus instead of falling back to the proxy
 	// (which might be pointing to the system bus).
 	if addr == "" && os.Getenv("DBUS_SESSION_BUS_ADDRESS") != "" {
-		if conn, err := dbus.ConnectSessionBus(); err == nil {
+		if conn, err := dbus.ConnectSessionBus(opts...); err == nil {
 			return conn, nil
 		}
 	}
@@ -55,24 +56,24 @@ func Connect(addr string) (*dbus.Conn, error) {
 	}
 	if addr != "" {
 		log.Printf("[INFO] Connecting to D-Bus at address: %s", addr)
-		conn, err := dialAndAuth(addr)
+		conn, err := dialAndAuth(addr, opts...)
 		if err != nil {
 			// If we tried to reuse a stale proxy socket, drop it and fall back to the system bus.
 			if triedProxy && errors.Is(err, syscall.ECONNREFUSED) {
 				if p := DefaultProxyPath(); p != "" {
 					_ = os.Remove(p)
 				}
-				return dbus.ConnectSystemBus()
+				return dbus.ConnectSystemBus(opts...)
 			}
 			return nil, err
 		}
 		return conn, nil
 	}
-	return dbus.ConnectSystemBus()
+	return dbus.ConnectSystemBus(opts...)
 }
 
-func dialAndAuth(addr string) (*dbus.Conn, error) {
-	conn, err := dbus.Dial(addr)
+func dialAndAuth(addr string, opts ...dbus.ConnOption) (*dbus.Conn, error) {
+	conn, err := dbus.Dial(addr, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("dial bus %q: %w", addr, err)
 	}
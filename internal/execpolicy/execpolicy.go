@@ -0,0 +1,170 @@
+// Package execpolicy restricts the commands that may be run inside app
+// containers, globally or per app, from a policy file written by the
+// administrator:
+//
+//	{
+//	  "default": {"deny": ["sh -c", "bash -c"]},
+//	  "apps": {
+//	    "org.example.app": {"allow": ["ls", "cat", "/usr/bin/journalctl"]}
+//	  }
+//	}
+//
+// A pattern is a command followed by leading arguments, separated by spaces:
+// "sh -c" matches any command line starting with sh and -c. A command
+// without a slash matches by base name, so "sh" also matches /bin/sh; "*"
+// matches every command line. Deny patterns of the default and the app
+// both apply and always win. The app's allow list, or the default one if
+// the app has none, admits only the command lines it matches; an empty
+// allow list admits all.
+//
+// A rule's "env" lists the environment variables callers may set for a
+// launch, by name or by a prefix ending in "*" such as "LC_*". The app's
+// list, or the default one if the app has none, replaces DefaultEnv.
+package execpolicy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultPath is where the policy is read from unless overridden.
+const DefaultPath = "/etc/linyaps-manager/exec-policy.json"
+
+// DefaultEnv is the environment variables callers may set when the policy
+// lists none: locale, time zone and display scaling, none of which makes
+// the app load other code.
+var DefaultEnv = []string{
+	"LANG", "LANGUAGE", "LC_*", "TZ",
+	"GDK_SCALE", "GDK_DPI_SCALE", "QT_SCALE_FACTOR", "QT_SCREEN_SCALE_FACTORS", "QT_FONT_DPI",
+}
+
+// Rule lists the patterns allowed and denied for some apps.
+type Rule struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+	Env   []string `json:"env,omitempty"`
+}
+
+// Policy is the parsed policy file. A nil Policy allows everything.
+type Policy struct {
+	Default Rule            `json:"default"`
+	Apps    map[string]Rule `json:"apps,omitempty"`
+}
+
+// DeniedError reports a command line refused by the policy.
+type DeniedError struct {
+	AppID   string
+	Command []string
+	Reason  string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("running %q in %s is not allowed: %s", strings.Join(e.Command, " "), e.AppID, e.Reason)
+}
+
+// Load reads the policy at path. A missing file is a nil Policy; a file
+// that cannot be parsed is an error, so that a broken policy does not
+// silently lift the restrictions.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	rules := []Rule{p.Default}
+	for _, r := range p.Apps {
+		rules = append(rules, r)
+	}
+	for _, r := range rules {
+		for _, patterns := range [][]string{r.Allow, r.Deny} {
+			for _, pattern := range patterns {
+				if len(strings.Fields(pattern)) == 0 {
+					return nil, fmt.Errorf("parse %s: empty pattern", path)
+				}
+			}
+		}
+	}
+	return &p, nil
+}
+
+// Check returns a *DeniedError if the policy refuses running command in
+// appID.
+func (p *Policy) Check(appID string, command []string) error {
+	if p == nil {
+		return nil
+	}
+	app := p.Apps[appID]
+	for _, patterns := range [][]string{p.Default.Deny, app.Deny} {
+		for _, pattern := range patterns {
+			if matches(pattern, command) {
+				return &DeniedError{AppID: appID, Command: command, Reason: fmt.Sprintf("denied by %q", pattern)}
+			}
+		}
+	}
+	allow := app.Allow
+	if len(allow) == 0 {
+		allow = p.Default.Allow
+	}
+	if len(allow) == 0 {
+		return nil
+	}
+	for _, pattern := range allow {
+		if matches(pattern, command) {
+			return nil
+		}
+	}
+	return &DeniedError{AppID: appID, Command: command, Reason: "not in the allow list"}
+}
+
+// CheckEnv returns a *DeniedError if callers may not set the environment
+// variable name when launching appID.
+func (p *Policy) CheckEnv(appID, name string) error {
+	allowed := DefaultEnv
+	if p != nil {
+		if env := p.Apps[appID].Env; len(env) > 0 {
+			allowed = env
+		} else if len(p.Default.Env) > 0 {
+			allowed = p.Default.Env
+		}
+	}
+	for _, pattern := range allowed {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); (ok && strings.HasPrefix(name, prefix)) || pattern == name {
+			return nil
+		}
+	}
+	return &DeniedError{AppID: appID, Command: []string{name}, Reason: "environment variable not in the env list"}
+}
+
+// matches reports whether command starts with the words of pattern.
+func matches(pattern string, command []string) bool {
+	words := strings.Fields(pattern)
+	if len(words) == 1 && words[0] == "*" {
+		return true
+	}
+	if len(words) == 0 || len(command) < len(words) {
+		return false
+	}
+	program := command[0]
+	if !strings.Contains(words[0], "/") {
+		program = filepath.Base(program)
+	}
+	if program != words[0] {
+		return false
+	}
+	for i, w := range words[1:] {
+		if command[i+1] != w {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package execpolicy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	p := &Policy{
+		Default: Rule{Deny: []string{"sh -c", "bash -c"}},
+		Apps: map[string]Rule{
+			"org.example.locked": {Allow: []string{"ls", "/usr/bin/journalctl"}},
+			"org.example.closed": {Deny: []string{"*"}},
+		},
+	}
+	tests := []struct {
+		app     string
+		command string
+		allowed bool
+	}{
+		{"org.example.app", "bash", true},
+		{"org.example.app", "sh -c id", false},
+		{"org.example.app", "/bin/sh -c id", false},
+		{"org.example.app", "sh script.sh", true},
+		{"org.example.locked", "ls -l /", true},
+		{"org.example.locked", "/usr/bin/journalctl -b", true},
+		{"org.example.locked", "/opt/journalctl", false},
+		{"org.example.locked", "cat /etc/passwd", false},
+		{"org.example.locked", "bash -c ls", false},
+		{"org.example.closed", "ls", false},
+	}
+	for _, tt := range tests {
+		err := p.Check(tt.app, strings.Fields(tt.command))
+		if (err == nil) != tt.allowed {
+			t.Errorf("Check(%s, %q) = %v, want allowed %v", tt.app, tt.command, err, tt.allowed)
+		}
+	}
+
+	var none *Policy
+	if err := none.Check("org.example.app", []string{"sh", "-c", "id"}); err != nil {
+		t.Errorf("nil policy: %v", err)
+	}
+}
+
+func TestCheckEnv(t *testing.T) {
+	p := &Policy{
+		Apps: map[string]Rule{"org.example.dev": {Env: []string{"DEBUG", "QT_LOGGING_*"}}},
+	}
+	tests := []struct {
+		policy  *Policy
+		app     string
+		name    string
+		allowed bool
+	}{
+		{nil, "org.example.app", "LANG", true},
+		{nil, "org.example.app", "LC_ALL", true},
+		{nil, "org.example.app", "LD_PRELOAD", false},
+		{nil, "org.example.app", "QT_PLUGIN_PATH", false},
+		{p, "org.example.app", "TZ", true},
+		{p, "org.example.dev", "DEBUG", true},
+		{p, "org.example.dev", "QT_LOGGING_RULES", true},
+		{p, "org.example.dev", "LANG", false},
+		{&Policy{Default: Rule{Env: []string{"*"}}}, "org.example.app", "LD_PRELOAD", true},
+	}
+	for _, tt := range tests {
+		err := tt.policy.CheckEnv(tt.app, tt.name)
+		if (err == nil) != tt.allowed {
+			t.Errorf("CheckEnv(%s, %s) = %v, want allowed %v", tt.app, tt.name, err, tt.allowed)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	if p, err := Load(filepath.Join(dir, "missing.json")); p != nil || err != nil {
+		t.Errorf("missing file: %v, %v", p, err)
+	}
+
+	path := filepath.Join(dir, "exec-policy.json")
+	for _, content := range []string{`{"default": {"deny": [" "]}}`, `{"default": `} {
+		os.WriteFile(path, []byte(content), 0o644)
+		if _, err := Load(path); err == nil {
+			t.Errorf("%s accepted", content)
+		}
+	}
+
+	os.WriteFile(path, []byte(`{"apps": {"org.example.app": {"allow": ["ls"]}}}`), 0o644)
+	p, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Check("org.example.app", []string{"cat"}) == nil || p.Check("org.other.app", []string{"cat"}) != nil {
+		t.Errorf("loaded policy: %+v", p)
+	}
+}
@@ -0,0 +1,91 @@
+// Package guestpolicy decides which requests apps may make to the host from
+// inside their container, from a policy file written by the administrator:
+//
+//	{
+//	  "default": {"install_runtime": true, "url_schemes": ["https", "http"]},
+//	  "apps": {
+//	    "org.example.kiosk": {"url_schemes": []}
+//	  }
+//	}
+//
+// An app's entry overrides the default field by field; a field it leaves
+// out keeps the default. Without a file, apps may install their own missing
+// runtime and base and open http and https URLs.
+package guestpolicy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultPath is where the policy is read from unless overridden.
+const DefaultPath = "/etc/linyaps-manager/guest-policy.json"
+
+// Rule is what some apps may request. Nil fields are unset.
+type Rule struct {
+	// InstallRuntime allows installing the runtime or base the app declares.
+	InstallRuntime *bool `json:"install_runtime,omitempty"`
+	// URLSchemes lists the URL schemes the app may open on the host.
+	URLSchemes []string `json:"url_schemes,omitempty"`
+}
+
+// Policy is the parsed policy file.
+type Policy struct {
+	Default Rule            `json:"default"`
+	Apps    map[string]Rule `json:"apps,omitempty"`
+}
+
+// Default is the policy in effect without a policy file.
+func Default() *Policy {
+	allow := true
+	return &Policy{Default: Rule{InstallRuntime: &allow, URLSchemes: []string{"https", "http"}}}
+}
+
+// Load reads the policy at path, or returns Default if there is no file.
+// Unset default fields are denied.
+func Load(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return Default(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var p Policy
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+// rule returns the effective rule of appID.
+func (p *Policy) rule(appID string) Rule {
+	r := p.Default
+	app := p.Apps[appID]
+	if app.InstallRuntime != nil {
+		r.InstallRuntime = app.InstallRuntime
+	}
+	if app.URLSchemes != nil {
+		r.URLSchemes = app.URLSchemes
+	}
+	return r
+}
+
+// MayInstallRuntime reports whether appID may install its missing runtime.
+func (p *Policy) MayInstallRuntime(appID string) bool {
+	r := p.rule(appID)
+	return r.InstallRuntime != nil && *r.InstallRuntime
+}
+
+// MayOpenScheme reports whether appID may open URLs with scheme.
+func (p *Policy) MayOpenScheme(appID, scheme string) bool {
+	for _, s := range p.rule(appID).URLSchemes {
+		if strings.EqualFold(s, scheme) {
+			return true
+		}
+	}
+	return false
+}
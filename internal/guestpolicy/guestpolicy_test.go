@@ -0,0 +1,62 @@
+package guestpolicy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDefault(t *testing.T) {
+	p, err := Load(filepath.Join(t.TempDir(), "missing.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !p.MayInstallRuntime("org.example.app") || !p.MayOpenScheme("org.example.app", "HTTPS") || p.MayOpenScheme("org.example.app", "file") {
+		t.Errorf("default policy: %+v", p)
+	}
+}
+
+func TestAppOverrides(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "guest-policy.json")
+	os.WriteFile(path, []byte(`{
+		"default": {"install_runtime": true, "url_schemes": ["https"]},
+		"apps": {
+			"org.example.kiosk": {"url_schemes": []},
+			"org.example.mail": {"install_runtime": false, "url_schemes": ["https", "mailto"]}
+		}
+	}`), 0o644)
+	p, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		app            string
+		installRuntime bool
+		scheme         string
+		open           bool
+	}{
+		{"org.example.app", true, "https", true},
+		{"org.example.app", true, "mailto", false},
+		{"org.example.kiosk", true, "https", false},
+		{"org.example.mail", false, "mailto", true},
+	}
+	for _, tt := range tests {
+		if got := p.MayInstallRuntime(tt.app); got != tt.installRuntime {
+			t.Errorf("MayInstallRuntime(%s) = %v", tt.app, got)
+		}
+		if got := p.MayOpenScheme(tt.app, tt.scheme); got != tt.open {
+			t.Errorf("MayOpenScheme(%s, %s) = %v", tt.app, tt.scheme, got)
+		}
+	}
+
+	// Unset default fields deny
+	os.WriteFile(path, []byte(`{"apps": {"org.example.app": {"url_schemes": ["https"]}}}`), 0o644)
+	if p, _ = Load(path); p.MayInstallRuntime("org.example.app") || p.MayOpenScheme("org.other.app", "https") {
+		t.Errorf("policy without defaults: %+v", p)
+	}
+
+	os.WriteFile(path, []byte(`{`), 0o644)
+	if _, err := Load(path); err == nil {
+		t.Error("broken policy accepted")
+	}
+}
@@ -0,0 +1,103 @@
+package history
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Variants converts e to the a{sv} form returned by GetHistory. Times are
+// unix seconds.
+func (e Entry) Variants() map[string]dbus.Variant {
+	phases := e.Phases
+	if phases == nil {
+		phases = map[string]int64{}
+	}
+	return map[string]dbus.Variant{
+		"id":            dbus.MakeVariant(e.ID),
+		"command":       dbus.MakeVariant(e.Command),
+		"operation":     dbus.MakeVariant(e.Operation),
+		"ref":           dbus.MakeVariant(e.Ref),
+		"app_id":        dbus.MakeVariant(e.AppID),
+		"caller":        dbus.MakeVariant(e.Caller),
+		"version":       dbus.MakeVariant(e.Version),
+		"scope":         dbus.MakeVariant(e.Scope),
+		"state":         dbus.MakeVariant(e.State),
+		"exit_code":     dbus.MakeVariant(int32(e.ExitCode)),
+		"error":         dbus.MakeVariant(e.Error),
+		"start_time":    dbus.MakeVariant(e.StartTime.Unix()),
+		"end_time":      dbus.MakeVariant(e.EndTime.Unix()),
+		"duration_ms":   dbus.MakeVariant(e.DurationMs),
+		"phases":        dbus.MakeVariant(phases),
+		"bytes_per_sec": dbus.MakeVariant(e.BytesPerSec),
+	}
+}
+
+// EntryFromVariants is the inverse of Variants. Missing or mistyped keys are
+// left at their zero value.
+func EntryFromVariants(m map[string]dbus.Variant) Entry {
+	str := func(k string) string { s, _ := m[k].Value().(string); return s }
+	i64 := func(k string) int64 { n, _ := m[k].Value().(int64); return n }
+	code, _ := m["exit_code"].Value().(int32)
+	speed, _ := m["bytes_per_sec"].Value().(uint64)
+	phases, _ := m["phases"].Value().(map[string]int64)
+	if len(phases) == 0 {
+		phases = nil
+	}
+	return Entry{
+		ID:          str("id"),
+		Command:     str("command"),
+		Operation:   str("operation"),
+		Ref:         str("ref"),
+		AppID:       str("app_id"),
+		Caller:      str("caller"),
+		Version:     str("version"),
+		Scope:       str("scope"),
+		State:       str("state"),
+		ExitCode:    int(code),
+		Error:       str("error"),
+		StartTime:   time.Unix(i64("start_time"), 0),
+		EndTime:     time.Unix(i64("end_time"), 0),
+		DurationMs:  i64("duration_ms"),
+		Phases:      phases,
+		BytesPerSec: speed,
+	}
+}
+
+// QueryFromVariants parses the GetHistory filter dictionary:
+// since, until (x, unix seconds), app_id, cursor (s) and limit (i or u).
+func QueryFromVariants(m map[string]dbus.Variant) (Query, error) {
+	var q Query
+	for k, v := range m {
+		var ok bool
+		switch k {
+		case "since", "until":
+			var ts int64
+			if ts, ok = v.Value().(int64); ok {
+				if k == "since" {
+					q.Since = time.Unix(ts, 0)
+				} else {
+					q.Until = time.Unix(ts, 0)
+				}
+			}
+		case "app_id":
+			q.AppID, ok = v.Value().(string)
+		case "cursor":
+			q.Cursor, ok = v.Value().(string)
+		case "limit":
+			switch n := v.Value().(type) {
+			case int32:
+				q.Limit, ok = int(n), true
+			case uint32:
+				q.Limit, ok = int(n), true
+			}
+		default:
+			return Query{}, fmt.Errorf("unknown history filter %q", k)
+		}
+		if !ok {
+			return Query{}, fmt.Errorf("history filter %q has wrong type %s", k, v.Signature())
+		}
+	}
+	return q, nil
+}
@@ -0,0 +1,322 @@
+// Package history persists finished operations as JSON lines and answers
+// filtered, paginated queries over them.
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variables configuring the retention policy.
+const (
+	EnvMaxEntries = "LINYAPS_HISTORY_MAX_ENTRIES" // e.g. 1000
+	EnvMaxAge     = "LINYAPS_HISTORY_MAX_AGE"     // Go duration or days, e.g. 90d
+)
+
+const (
+	defaultMaxEntries = 1000
+	defaultMaxAge     = 90 * 24 * time.Hour
+
+	// DefaultLimit and MaxLimit bound the page size of a query.
+	DefaultLimit = 50
+	MaxLimit     = 1000
+)
+
+// Entry is one finished operation.
+type Entry struct {
+	ID         string    `json:"id"`
+	Command    string    `json:"command"`
+	Operation  string    `json:"operation,omitempty"`
+	Ref        string    `json:"ref,omitempty"`
+	AppID      string    `json:"app_id,omitempty"`
+	Caller     string    `json:"caller,omitempty"`
+	Version    string    `json:"version,omitempty"` // app version launched, if known
+	Scope      string    `json:"scope,omitempty"`   // transient scope of an app launch
+	State      string    `json:"state"`
+	ExitCode   int       `json:"exit_code"`
+	Error      string    `json:"error,omitempty"`
+	StartTime  time.Time `json:"start_time"`
+	EndTime    time.Time `json:"end_time"`
+	DurationMs int64     `json:"duration_ms"`
+	// Phases holds launch phase timings in ms since start, if traced.
+	Phases map[string]int64 `json:"phases,omitempty"`
+	// BytesPerSec is the average download speed ll-cli reported, if any.
+	BytesPerSec uint64 `json:"bytes_per_sec,omitempty"`
+}
+
+// Query selects entries, newest first.
+type Query struct {
+	Since  time.Time // entries that ended at or after Since; zero for no bound
+	Until  time.Time // entries that ended before Until; zero for no bound
+	AppID  string    // only entries for this app
+	Cursor string    // continue after the page that returned this cursor
+	Limit  int       // page size; 0 means DefaultLimit
+}
+
+// Retention bounds how much history is kept. Zero fields disable that bound.
+type Retention struct {
+	MaxEntries int
+	MaxAge     time.Duration
+}
+
+// RetentionFromEnv returns the default retention overridden by EnvMaxEntries
+// and EnvMaxAge.
+func RetentionFromEnv() (Retention, error) {
+	r := Retention{MaxEntries: defaultMaxEntries, MaxAge: defaultMaxAge}
+	if v := os.Getenv(EnvMaxEntries); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return Retention{}, fmt.Errorf("invalid %s %q", EnvMaxEntries, v)
+		}
+		r.MaxEntries = n
+	}
+	if v := os.Getenv(EnvMaxAge); v != "" {
+		d, err := parseAge(v)
+		if err != nil {
+			return Retention{}, fmt.Errorf("invalid %s %q: %w", EnvMaxAge, v, err)
+		}
+		r.MaxAge = d
+	}
+	return r, nil
+}
+
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, errors.New("want a number of days like 90d")
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// StateDir returns $XDG_STATE_HOME/linyaps-manager, falling back to
+// ~/.local/state, or "" if neither is known.
+func StateDir() string {
+	base := os.Getenv("XDG_STATE_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		base = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(base, "linyaps-manager")
+}
+
+// DefaultPath returns the history file in StateDir.
+func DefaultPath() string {
+	dir := StateDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "history.jsonl")
+}
+
+// Store is an append-only history file pruned to its retention policy.
+type Store struct {
+	mu        sync.Mutex
+	path      string
+	retention Retention
+	count     int // entries in the file
+}
+
+// Open opens or creates the history file at path and applies retention.
+func Open(path string, retention Retention) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	s := &Store{path: path, retention: retention}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.pruneLocked(time.Now()); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Append records a finished operation.
+func (s *Store) Append(e Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	s.count++
+
+	// Rewrite the file only once it is 10% over the limit
+	if max := s.retention.MaxEntries; max > 0 && s.count > max+max/10 {
+		return s.pruneLocked(time.Now())
+	}
+	return nil
+}
+
+// Query returns one page of matching entries, newest first, and the cursor
+// for the next page ("" on the last page).
+func (s *Store) Query(q Query) ([]Entry, string, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	limit = min(limit, MaxLimit)
+
+	var after *Entry
+	if q.Cursor != "" {
+		c, err := parseCursor(q.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		after = &c
+	}
+
+	s.mu.Lock()
+	entries, err := s.readLocked()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Slice(entries, func(i, j int) bool { return newer(entries[i], entries[j]) })
+
+	var page []Entry
+	for _, e := range entries {
+		if after != nil && !newer(*after, e) {
+			continue
+		}
+		if !q.Since.IsZero() && e.EndTime.Before(q.Since) {
+			continue
+		}
+		if !q.Until.IsZero() && !e.EndTime.Before(q.Until) {
+			continue
+		}
+		if q.AppID != "" && e.AppID != q.AppID {
+			continue
+		}
+		if len(page) == limit {
+			last := page[len(page)-1]
+			return page, formatCursor(last), nil
+		}
+		page = append(page, e)
+	}
+	return page, "", nil
+}
+
+// newer orders entries newest first, breaking ties by ID.
+func newer(a, b Entry) bool {
+	if !a.EndTime.Equal(b.EndTime) {
+		return a.EndTime.After(b.EndTime)
+	}
+	return a.ID > b.ID
+}
+
+// Cursors identify the last entry of a page by end time and ID, so they stay
+// valid when older entries are pruned.
+func formatCursor(e Entry) string {
+	return strconv.FormatInt(e.EndTime.UnixNano(), 10) + "/" + e.ID
+}
+
+func parseCursor(c string) (Entry, error) {
+	ts, id, ok := strings.Cut(c, "/")
+	ns, err := strconv.ParseInt(ts, 10, 64)
+	if !ok || err != nil {
+		return Entry{}, fmt.Errorf("invalid cursor %q", c)
+	}
+	return Entry{ID: id, EndTime: time.Unix(0, ns)}, nil
+}
+
+func (s *Store) readLocked() ([]Entry, error) {
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// Skip lines torn by a crash mid-write
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// pruneLocked drops entries beyond the retention policy and rewrites the file.
+func (s *Store) pruneLocked(now time.Time) error {
+	entries, err := s.readLocked()
+	if err != nil {
+		return err
+	}
+	kept := entries[:0]
+	for _, e := range entries {
+		if s.retention.MaxAge > 0 && now.Sub(e.EndTime) > s.retention.MaxAge {
+			continue
+		}
+		kept = append(kept, e)
+	}
+	if max := s.retention.MaxEntries; max > 0 && len(kept) > max {
+		kept = kept[len(kept)-max:]
+	}
+	s.count = len(kept)
+	if len(kept) == len(entries) {
+		return nil
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".history-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, e := range kept {
+		if err := enc.Encode(e); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	log.Printf("[INFO] history: pruned %d entries", len(entries)-len(kept))
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,101 @@
+package history
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func entryAt(i int, end time.Time, app string) Entry {
+	return Entry{ID: fmt.Sprintf("op-%d-%d", 1, i), Command: "ll-cli", AppID: app, State: "completed", EndTime: end}
+}
+
+func TestQueryPagination(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "history.jsonl"), Retention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Now().Add(-time.Hour)
+	for i := 0; i < 7; i++ {
+		app := "org.a"
+		if i%2 == 1 {
+			app = "org.b"
+		}
+		if err := s.Append(entryAt(i, base.Add(time.Duration(i)*time.Minute), app)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var ids []string
+	cursor := ""
+	pages := 0
+	for {
+		page, next, err := s.Query(Query{AppID: "org.a", Limit: 2, Cursor: cursor})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages++
+		for _, e := range page {
+			ids = append(ids, e.ID)
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	want := []string{"op-1-6", "op-1-4", "op-1-2", "op-1-0"}
+	if fmt.Sprint(ids) != fmt.Sprint(want) || pages != 2 {
+		t.Errorf("ids = %v in %d pages, want %v in 2", ids, pages, want)
+	}
+
+	page, _, err := s.Query(Query{Since: base.Add(2 * time.Minute), Until: base.Add(4 * time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 2 || page[0].ID != "op-1-3" || page[1].ID != "op-1-2" {
+		t.Errorf("time range page = %+v", page)
+	}
+}
+
+func TestRetention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	s, err := Open(path, Retention{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	s.Append(entryAt(0, now.Add(-48*time.Hour), "org.old"))
+	for i := 1; i <= 5; i++ {
+		s.Append(entryAt(i, now.Add(-time.Duration(6-i)*time.Minute), "org.new"))
+	}
+
+	s, err = Open(path, Retention{MaxEntries: 3, MaxAge: 24 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _, err := s.Query(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 3 || page[0].ID != "op-1-5" || page[2].ID != "op-1-3" {
+		t.Errorf("after pruning got %+v", page)
+	}
+	for _, e := range page {
+		if e.AppID == "org.old" {
+			t.Errorf("expired entry kept: %+v", e)
+		}
+	}
+}
+
+func TestParseAge(t *testing.T) {
+	if d, err := parseAge("90d"); err != nil || d != 90*24*time.Hour {
+		t.Errorf("parseAge(90d) = %s, %v", d, err)
+	}
+	if d, err := parseAge("36h"); err != nil || d != 36*time.Hour {
+		t.Errorf("parseAge(36h) = %s, %v", d, err)
+	}
+	if _, err := parseAge("xd"); err == nil {
+		t.Error("parseAge(xd) succeeded")
+	}
+}
@@ -0,0 +1,64 @@
+// Package i18n translates user-facing client messages.
+//
+// Messages are identified by their English text, gettext style: T returns the
+// translation for the current locale, or the English text when none exists.
+// The locale is taken from LC_ALL, LC_MESSAGES or LANG, in that order.
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// catalogs maps a language tag to translations keyed by the English message.
+var catalogs = map[string]map[string]string{
+	"zh_CN": zhCN,
+}
+
+var (
+	langOnce sync.Once
+	lang     string
+)
+
+// Lang returns the catalog language selected from the environment, or "en".
+func Lang() string {
+	langOnce.Do(func() {
+		lang = detectLang(os.Getenv("LC_ALL"), os.Getenv("LC_MESSAGES"), os.Getenv("LANG"))
+	})
+	return lang
+}
+
+// detectLang picks the first non-empty locale and maps it to a catalog.
+// Any Chinese locale other than Taiwan/Hong Kong selects zh_CN.
+func detectLang(locales ...string) string {
+	for _, l := range locales {
+		if l == "" {
+			continue
+		}
+		// Strip encoding and modifier, e.g. zh_CN.UTF-8@pinyin -> zh_CN
+		if i := strings.IndexAny(l, ".@"); i >= 0 {
+			l = l[:i]
+		}
+		if _, ok := catalogs[l]; ok {
+			return l
+		}
+		if strings.HasPrefix(l, "zh") && l != "zh_TW" && l != "zh_HK" {
+			return "zh_CN"
+		}
+		return "en"
+	}
+	return "en"
+}
+
+// T translates msg and formats it with args like fmt.Sprintf.
+func T(msg string, args ...interface{}) string {
+	if tr, ok := catalogs[Lang()][msg]; ok {
+		msg = tr
+	}
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
@@ -0,0 +1,33 @@
+package i18n
+
+import "testing"
+
+func TestDetectLang(t *testing.T) {
+	tests := []struct {
+		locales []string
+		want    string
+	}{
+		{[]string{"", "", "zh_CN.UTF-8"}, "zh_CN"},
+		{[]string{"zh_SG.UTF-8"}, "zh_CN"},
+		{[]string{"zh_TW.UTF-8"}, "en"},
+		{[]string{"en_US.UTF-8", "", "zh_CN.UTF-8"}, "en"},
+		{[]string{"C", "", ""}, "en"},
+		{[]string{"", "", ""}, "en"},
+	}
+
+	for _, tt := range tests {
+		if got := detectLang(tt.locales...); got != tt.want {
+			t.Errorf("detectLang(%q) = %q, want %q", tt.locales, got, tt.want)
+		}
+	}
+}
+
+func TestCatalogsAreComplete(t *testing.T) {
+	for lang, catalog := range catalogs {
+		for msg, tr := range catalog {
+			if tr == "" {
+				t.Errorf("%s: empty translation for %q", lang, msg)
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package i18n
+
+// zhCN holds the Simplified Chinese translations.
+var zhCN = map[string]string{
+	"LinyapsManager Client": "LinyapsManager 客户端",
+	"This program should be invoked via symlinks named after the command to execute.": "本程序应通过以目标命令命名的符号链接调用。",
+	"Example:":                                         "示例：",
+	"Allowed commands:":                                "允许的命令：",
+	"Error: command %q is not allowed\n":               "错误：命令 %q 不在允许列表中\n",
+	"Error: failed to connect to D-Bus: %v\n":          "错误：连接 D-Bus 失败：%v\n",
+	"Error: %v\n":                                      "错误：%v\n",
+	"failed to create signal receiver: %w":             "创建信号接收器失败：%w",
+	"D-Bus call failed: %w":                            "D-Bus 调用失败：%w",
+	"command failed: %s":                               "命令执行失败：%s",
+	"Commands:":                                        "子命令：",
+	"Error: unknown command %q\n":                      "错误：未知子命令 %q\n",
+	"unknown option --%s for %s":                       "%[2]s 不支持选项 --%[1]s",
+	"option --%s does not take a value":                "选项 --%s 不接受参数",
+	"option --%s requires %s":                          "选项 --%s 需要参数 %s",
+	"Run 'linyapsctl --help-all' to list all options.": "运行 'linyapsctl --help-all' 查看全部选项。",
+	"Show help for linyapsctl":                         "显示 linyapsctl 帮助",
+	"Also list the options of every command":           "同时列出每个子命令的选项",
+	"Generate the linyapsctl(1) man page":              "生成 linyapsctl(1) 手册页",
+	"Writes the troff source of the man page, generated from the same command specs as this help text.": "输出手册页的 troff 源码，与本帮助信息由同一份命令定义生成。",
+	"Write to FILE instead of standard output":                                                          "写入 FILE 而不是标准输出",
+	"Measure or change the configured repositories":                                                     "测量或修改已配置的仓库",
+	"probe times a request to every repository from 'll-cli repo show'; bench fetches the ostree ref pointer <ref> from each repository several times. add, remove, set-default and update change the repository configuration through the service, which asks for administrator authentication.": "probe 对 'll-cli repo show' 中的每个仓库计时一次请求；bench 从每个仓库多次获取 ostree 引用 <ref>。add、remove、set-default 和 update 通过服务修改仓库配置，服务会要求管理员认证。",
+	"Per-request timeout (default 5)":                    "单次请求超时秒数（默认 5）",
+	"Number of bench fetches per repository (default 3)": "每个仓库的测试次数（默认 3）",
+	"Error: invalid --timeout %q\n":                      "错误：无效的 --timeout %q\n",
+	"Error: invalid --count %q\n":                        "错误：无效的 --count %q\n",
+	"failed to connect to D-Bus: %w":                     "连接 D-Bus 失败：%w",
+	"ll-cli repo show exited with code %d":               "ll-cli repo show 退出码为 %d",
+	"NAME\tURL\tLATENCY\tSTATUS":                         "名称\t地址\t延迟\t状态",
+	"NAME\tMIN\tAVG\tMAX\tFAILED":                        "名称\t最小\t平均\t最大\t失败",
+	"Export the operation history":                       "导出操作历史",
+	"export writes every recorded operation matching the filters to stdout, newest first. Dates are YYYY-MM-DD or RFC 3339 times.": "export 将符合筛选条件的全部操作记录按时间倒序输出到标准输出。日期格式为 YYYY-MM-DD 或 RFC 3339 时间。",
+	"Output format (default csv)":                 "输出格式（默认 csv）",
+	"Only operations that ended at or after DATE": "仅包含在 DATE 及之后结束的操作",
+	"Only operations that ended before DATE":      "仅包含在 DATE 之前结束的操作",
+	"Only operations on APPID":                    "仅包含针对 APPID 的操作",
+	"Error: invalid --format %q\n":                "错误：无效的 --format %q\n",
+	"Error: invalid --%s %q\n":                    "错误：无效的 --%s %q\n",
+	"Handle a store link":                         "处理商店链接",
+	"open performs the request of a linglong://install/<appid> link, as opened from a store web page, after the user confirms it, and shows the install output.": "open 在用户确认后执行 linglong://install/<appid> 链接（如从商店网页打开）中的请求，并显示安装输出。",
+	"Wait for an operation to finish": "等待操作结束",
+	"Blocks until the operation finishes and exits with its exit code, printing nothing. An operation that failed without an exit code gives 1, a cancelled one 130; 124 means --timeout expired first and 125 that the operation could not be waited for.": "阻塞直到操作结束，并以其退出码退出，不输出任何内容。没有自身退出码的失败操作返回 1，被取消的操作返回 130；124 表示 --timeout 先到期，125 表示无法等待该操作。",
+	"Give up after DURATION (e.g. 90s, 5m; plain numbers are seconds)": "DURATION 后放弃等待（如 90s、5m；纯数字表示秒）",
+	"Run an app with arguments and environment variables":              "使用参数和环境变量运行应用",
+	"run starts the app through the service and prints the operation ID. As with ll-cli run, arguments after -- replace the command the app starts with and are checked against the exec policy. With --attach it shows the app's console output until it exits and exits with its exit code; without arguments or --env the app then runs on a terminal.": "run 通过服务启动应用并输出操作 ID。与 ll-cli run 相同，-- 之后的参数将替换应用的启动命令，并受执行策略检查。使用 --attach 时将显示应用的控制台输出直至其退出，并以其退出码退出；未指定参数或 --env 时应用将在终端中运行。",
+	"Show the app's output and wait for it to exit":                                "显示应用输出并等待其退出",
+	"Run VERSION instead of the newest installed version":                          "运行 VERSION 版本而非已安装的最新版本",
+	"Set an environment variable in the app; may be repeated":                      "为应用设置环境变量；可重复指定",
+	"Error: invalid --env %q, want KEY=VALUE\n":                                    "错误：无效的 --env %q，应为 KEY=VALUE\n",
+	"Error: local fallback: %v\n":                                                  "错误：本地回退：%v\n",
+	"linyapsctl: service unavailable (%v), running %s directly (local fallback)\n": "linyapsctl：服务不可用（%v），直接运行 %s（本地回退）\n",
+	"%s not found in PATH":                                                         "在 PATH 中找不到 %s",
+	"Install a package from a local .layer or .uab file":                           "从本地 .layer 或 .uab 文件安装软件包",
+	"install-file installs the package in a .layer or .uab file through the service, without a repository, and shows the install output. The file must be readable by the service.": "install-file 通过服务安装 .layer 或 .uab 文件中的软件包，无需仓库，并显示安装输出。该文件必须可被服务读取。",
+	"Replace the installed version of the package": "替换已安装的软件包版本",
+	"List installed apps, runtimes or bases":       "列出已安装的应用、运行时或基础环境",
+	"list shows the installed packages of one type. Runtimes and bases are listed separately from apps, so cleanup tools can tell which layers are installed.": "list 显示某一类型的已安装软件包。运行时和基础环境与应用分开列出，便于清理工具了解已安装了哪些层。",
+	"Type of packages to list (default app)": "要列出的软件包类型（默认为 app）",
+	"Print JSON instead of a table":          "输出 JSON 而非表格",
+	"Install apps":                           "安装应用",
+	"install installs the apps through the service and shows the install output. Several apps are installed one after the other as one operation, which fails if any install failed. With --launch the app is started once it is installed, or right away if it already was.": "install 通过服务安装应用并显示安装输出。多个应用将作为一个操作依次安装，任一安装失败则整个操作失败。使用 --launch 时，应用安装完成后即启动；若已安装则立即启动。",
+	"Install VERSION instead of the newest version":                     "安装 VERSION 版本而非最新版本",
+	"Start the app once it is installed":                                "安装完成后启动应用",
+	"Install MODULE, such as develop, instead of binary":                "安装 MODULE 模块（如 develop）而非 binary",
+	"Install from CHANNEL, such as stable or testing":                   "从 CHANNEL 渠道（如 stable 或 testing）安装",
+	"Install from the repository NAME instead of the default one":       "从名为 NAME 的仓库而非默认仓库安装",
+	"Reinstall apps that are already installed; only with several apps": "重新安装已安装的应用；仅适用于多个应用",
+	"Error: --%s takes a single app\n":                                  "错误：--%s 只能用于单个应用\n",
+	"Error: --%s takes several apps\n":                                  "错误：--%s 只能用于多个应用\n",
+	"Uninstall apps":                                                    "卸载应用",
+	"uninstall removes the apps through the service and shows the uninstall output. Several apps are removed one after the other as one operation, which fails if any removal failed.": "uninstall 通过服务卸载应用并显示卸载输出。多个应用将作为一个操作依次卸载，任一卸载失败则整个操作失败。",
+	"Search the repositories": "搜索仓库",
+	"search looks up packages matching the keyword in the configured repositories through the service, optionally only of one type, in one repository or from one channel.": "search 通过服务在已配置的仓库中查找与关键词匹配的软件包，可限定类型、仓库或渠道。",
+	"Type of packages to search (default app)":      "要搜索的软件包类型（默认为 app）",
+	"Search only the repository NAME":               "仅搜索 NAME 仓库",
+	"Show only packages from CHANNEL, such as main": "仅显示来自 CHANNEL 渠道（如 main）的软件包",
+	"Upgrade the given apps":                        "升级指定的应用",
+	"upgrade upgrades only the given apps through the service, one after the other as one operation, and shows the upgrade output. The operation fails if any upgrade failed.": "upgrade 通过服务仅升级指定的应用，作为一个操作依次执行，并显示升级输出。任一升级失败则整个操作失败。",
+}
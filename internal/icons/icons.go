@@ -0,0 +1,211 @@
+// Package icons resolves icons of installed linglong applications to local
+// files, resizing them on demand.
+package icons
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	// EntriesDir is where linglong exports desktop entries and icons of
+	// installed applications.
+	EntriesDir = "/var/lib/linglong/entries/share"
+	// CacheDir holds resized icons; empty means <user cache dir>/linyaps-manager/icons.
+	CacheDir = ""
+)
+
+// ErrNotFound is returned when the application exports no icon.
+var ErrNotFound = errors.New("icon not found")
+
+var validAppID = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// candidate is an exported icon file; size 0 marks a scalable icon.
+type candidate struct {
+	path string
+	size int
+}
+
+// Resolve returns a local file showing appID's icon at size x size pixels.
+// An exported PNG of exactly that size is returned as is; otherwise a
+// scalable SVG is preferred, and failing that the closest PNG is resized
+// into the cache. size 0 selects the largest available icon.
+func Resolve(appID string, size int) (string, error) {
+	if !validAppID.MatchString(appID) || strings.Contains(appID, "..") {
+		return "", fmt.Errorf("invalid app id %q", appID)
+	}
+	if size < 0 || size > 1024 {
+		return "", fmt.Errorf("invalid icon size %d", size)
+	}
+
+	cands := findIcons(appID)
+	if len(cands) == 0 {
+		return "", fmt.Errorf("%s: %w", appID, ErrNotFound)
+	}
+	var pngs []candidate
+	var svg string
+	for _, c := range cands {
+		if c.size == 0 {
+			svg = c.path
+		} else {
+			pngs = append(pngs, c)
+		}
+	}
+	sort.Slice(pngs, func(i, j int) bool { return pngs[i].size < pngs[j].size })
+
+	if size == 0 {
+		if svg != "" {
+			return svg, nil
+		}
+		return pngs[len(pngs)-1].path, nil
+	}
+	for _, c := range pngs {
+		if c.size == size {
+			return c.path, nil
+		}
+	}
+	if svg != "" {
+		return svg, nil
+	}
+
+	// Downscale the smallest larger icon, or upscale the largest one
+	src := pngs[len(pngs)-1]
+	for _, c := range pngs {
+		if c.size > size {
+			src = c
+			break
+		}
+	}
+	return resized(appID, src.path, size)
+}
+
+// findIcons lists the hicolor icons exported for appID.
+func findIcons(appID string) []candidate {
+	var cands []candidate
+	hicolor := filepath.Join(EntriesDir, "icons", "hicolor")
+	dirs, _ := os.ReadDir(hicolor)
+	for _, d := range dirs {
+		name := d.Name()
+		if name == "scalable" {
+			p := filepath.Join(hicolor, name, "apps", appID+".svg")
+			if fileExists(p) {
+				cands = append(cands, candidate{path: p})
+			}
+			continue
+		}
+		w, h, ok := strings.Cut(name, "x")
+		if !ok || w != h {
+			continue
+		}
+		n, err := strconv.Atoi(w)
+		if err != nil || n <= 0 {
+			continue
+		}
+		p := filepath.Join(hicolor, name, "apps", appID+".png")
+		if fileExists(p) {
+			cands = append(cands, candidate{path: p, size: n})
+		}
+	}
+	return cands
+}
+
+// resized returns a cached copy of src scaled to size, regenerating it when
+// the source is newer than the cache.
+func resized(appID, src string, size int) (string, error) {
+	dir := CacheDir
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(base, "linyaps-manager", "icons")
+	}
+	dst := filepath.Join(dir, fmt.Sprintf("%s-%d.png", appID, size))
+
+	srcInfo, err := os.Stat(src)
+	if err != nil {
+		return "", err
+	}
+	if dstInfo, err := os.Stat(dst); err == nil && !dstInfo.ModTime().Before(srcInfo.ModTime()) {
+		return dst, nil
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	img, err := png.Decode(f)
+	f.Close()
+	if err != nil {
+		return "", fmt.Errorf("decode %s: %w", src, err)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp(dir, ".icon-*")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	if err := png.Encode(tmp, scale(img, size)); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	return dst, os.Rename(tmp.Name(), dst)
+}
+
+// scale resizes img to size x size, averaging the source pixels covered by
+// each destination pixel so downscaled icons stay smooth.
+func scale(img image.Image, size int) *image.NRGBA {
+	b := img.Bounds()
+	dst := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		y0 := b.Min.Y + y*b.Dy()/size
+		y1 := max(b.Min.Y+(y+1)*b.Dy()/size, y0+1)
+		for x := 0; x < size; x++ {
+			x0 := b.Min.X + x*b.Dx()/size
+			x1 := max(b.Min.X+(x+1)*b.Dx()/size, x0+1)
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					c := color.NRGBA64Model.Convert(img.At(sx, sy)).(color.NRGBA64)
+					// Weight colour by alpha so transparent pixels don't darken edges
+					r += uint64(c.R) * uint64(c.A)
+					g += uint64(c.G) * uint64(c.A)
+					bl += uint64(c.B) * uint64(c.A)
+					a += uint64(c.A)
+					n++
+				}
+			}
+			if a == 0 {
+				continue
+			}
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(r / a >> 8),
+				G: uint8(g / a >> 8),
+				B: uint8(bl / a >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
+
+func fileExists(p string) bool {
+	info, err := os.Stat(p)
+	return err == nil && info.Mode().IsRegular()
+}
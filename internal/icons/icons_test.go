@@ -0,0 +1,103 @@
+package icons
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func writeIcon(t *testing.T, dir string, size int, c color.Color) string {
+	t.Helper()
+	p := filepath.Join(dir, "icons", "hicolor", strconv.Itoa(size)+"x"+strconv.Itoa(size), "apps", "org.example.app.png")
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func setup(t *testing.T) string {
+	t.Helper()
+	entries := t.TempDir()
+	oldEntries, oldCache := EntriesDir, CacheDir
+	EntriesDir, CacheDir = entries, t.TempDir()
+	t.Cleanup(func() { EntriesDir, CacheDir = oldEntries, oldCache })
+	return entries
+}
+
+func TestResolveExactAndResized(t *testing.T) {
+	entries := setup(t)
+	red := color.NRGBA{R: 255, A: 255}
+	exact := writeIcon(t, entries, 48, red)
+	writeIcon(t, entries, 128, red)
+
+	got, err := Resolve("org.example.app", 48)
+	if err != nil || got != exact {
+		t.Fatalf("Resolve(48) = %q, %v; want %q", got, err, exact)
+	}
+
+	got, err = Resolve("org.example.app", 64)
+	if err != nil {
+		t.Fatalf("Resolve(64): %v", err)
+	}
+	if filepath.Dir(got) != CacheDir {
+		t.Errorf("resized icon %q not in cache dir", got)
+	}
+	f, err := os.Open(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := img.Bounds(); b.Dx() != 64 || b.Dy() != 64 {
+		t.Errorf("resized to %v, want 64x64", b)
+	}
+	if c := color.NRGBAModel.Convert(img.At(10, 10)).(color.NRGBA); c != red {
+		t.Errorf("pixel = %v, want %v", c, red)
+	}
+}
+
+func TestResolvePrefersScalable(t *testing.T) {
+	entries := setup(t)
+	writeIcon(t, entries, 48, color.Black)
+	svg := filepath.Join(entries, "icons", "hicolor", "scalable", "apps", "org.example.app.svg")
+	os.MkdirAll(filepath.Dir(svg), 0o755)
+	os.WriteFile(svg, []byte("<svg/>"), 0o644)
+
+	if got, err := Resolve("org.example.app", 256); err != nil || got != svg {
+		t.Errorf("Resolve(256) = %q, %v; want %q", got, err, svg)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	setup(t)
+	if _, err := Resolve("org.example.missing", 48); !errors.Is(err, ErrNotFound) {
+		t.Errorf("missing icon: err = %v, want ErrNotFound", err)
+	}
+	for _, id := range []string{"../etc/passwd", "a/b", "", "org..x"} {
+		if _, err := Resolve(id, 48); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Resolve(%q) err = %v, want invalid id", id, err)
+		}
+	}
+}
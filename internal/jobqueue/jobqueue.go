@@ -0,0 +1,117 @@
+// Package jobqueue runs operations that must not overlap one at a time, in
+// the order they were submitted.
+package jobqueue
+
+import (
+	"context"
+	"sync"
+)
+
+// Queue admits one operation at a time; the others wait in line.
+type Queue struct {
+	notify func(operationID string, position int)
+
+	mu      sync.Mutex
+	busy    bool
+	waiting []*waiter
+}
+
+type waiter struct {
+	id    string
+	ready chan struct{}
+}
+
+// New creates an empty queue. notify, if not nil, is called with the
+// position of an operation each time it changes: 1 for the next to run, and
+// 0 when an operation that had to wait starts. It is called with the queue
+// locked, so notifications arrive in order, and must not call back into q.
+func New(notify func(operationID string, position int)) *Queue {
+	return &Queue{notify: notify}
+}
+
+// Acquire blocks until operationID may run or ctx is done. On success the
+// returned func must be called once the operation finished, to let the
+// next one in. If ctx is done first, operationID leaves the line and the
+// cause of ctx is returned.
+func (q *Queue) Acquire(ctx context.Context, operationID string) (func(), error) {
+	q.mu.Lock()
+	if !q.busy {
+		q.busy = true
+		q.mu.Unlock()
+		return q.releaser(), nil
+	}
+	w := &waiter{id: operationID, ready: make(chan struct{})}
+	q.waiting = append(q.waiting, w)
+	q.report(w.id, len(q.waiting))
+	q.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		return q.releaser(), nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	i := q.indexLocked(w)
+	if i < 0 {
+		// The slot was handed over while ctx was done; pass it on
+		q.mu.Unlock()
+		<-w.ready
+		q.release()
+		return nil, context.Cause(ctx)
+	}
+	q.waiting = append(q.waiting[:i], q.waiting[i+1:]...)
+	for j := i; j < len(q.waiting); j++ {
+		q.report(q.waiting[j].id, j+1)
+	}
+	q.mu.Unlock()
+	return nil, context.Cause(ctx)
+}
+
+// Waiting returns the IDs of the operations waiting in line, next first.
+func (q *Queue) Waiting() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	ids := make([]string, len(q.waiting))
+	for i, w := range q.waiting {
+		ids[i] = w.id
+	}
+	return ids
+}
+
+func (q *Queue) releaser() func() {
+	var once sync.Once
+	return func() { once.Do(q.release) }
+}
+
+// release hands the slot to the next operation in line, if any.
+func (q *Queue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiting) == 0 {
+		q.busy = false
+		return
+	}
+	next := q.waiting[0]
+	q.waiting = q.waiting[1:]
+	q.report(next.id, 0)
+	close(next.ready)
+	for i, w := range q.waiting {
+		q.report(w.id, i+1)
+	}
+}
+
+func (q *Queue) indexLocked(w *waiter) int {
+	for i, x := range q.waiting {
+		if x == w {
+			return i
+		}
+	}
+	return -1
+}
+
+func (q *Queue) report(operationID string, position int) {
+	if q.notify != nil {
+		q.notify(operationID, position)
+	}
+}
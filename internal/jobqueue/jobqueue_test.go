@@ -0,0 +1,111 @@
+package jobqueue
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *recorder) notify(id string, position int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, id+":"+string(rune('0'+position)))
+}
+
+func (r *recorder) get() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+// waitFor polls until the queue holds want.
+func waitFor(t *testing.T, q *Queue, want ...string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for got := q.Waiting(); !reflect.DeepEqual(got, want) && (len(got) > 0 || len(want) > 0); got = q.Waiting() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Waiting() = %v, want %v", q.Waiting(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestQueueOrder(t *testing.T) {
+	rec := &recorder{}
+	q := New(rec.notify)
+	ctx := context.Background()
+
+	releaseA, err := q.Acquire(ctx, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan string, 2)
+	for _, id := range []string{"b", "c"} {
+		id := id
+		go func() {
+			release, err := q.Acquire(ctx, id)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			started <- id
+			release()
+		}()
+		waitFor(t, q, append(q.Waiting(), id)...)
+	}
+
+	releaseA()
+	releaseA() // releasing twice is harmless
+	if first, second := <-started, <-started; first != "b" || second != "c" {
+		t.Errorf("started %s then %s", first, second)
+	}
+	want := []string{"b:1", "c:2", "b:0", "c:1", "c:0"}
+	if got := rec.get(); !reflect.DeepEqual(got, want) {
+		t.Errorf("notifications = %v, want %v", got, want)
+	}
+
+	// The queue is free again
+	release, err := q.Acquire(ctx, "d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+}
+
+func TestQueueCancelWaiting(t *testing.T) {
+	rec := &recorder{}
+	q := New(rec.notify)
+
+	releaseA, _ := q.Acquire(context.Background(), "a")
+	errStop := errors.New("stop")
+	ctx, cancel := context.WithCancelCause(context.Background())
+	done := make(chan error)
+	go func() {
+		_, err := q.Acquire(ctx, "b")
+		done <- err
+	}()
+	waitFor(t, q, "b")
+	go q.Acquire(context.Background(), "c")
+	waitFor(t, q, "b", "c")
+
+	cancel(errStop)
+	if err := <-done; !errors.Is(err, errStop) {
+		t.Errorf("Acquire error = %v, want the cause", err)
+	}
+	waitFor(t, q, "c")
+	releaseA()
+	waitFor(t, q)
+
+	want := []string{"b:1", "c:2", "c:1", "c:0"}
+	if got := rec.get(); !reflect.DeepEqual(got, want) {
+		t.Errorf("notifications = %v, want %v", got, want)
+	}
+}
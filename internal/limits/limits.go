@@ -0,0 +1,257 @@
+// Package limits confines spawned children so a runaway command cannot
+// exhaust the memory, task or file descriptor budget of the whole daemon.
+//
+// Limits are applied by wrapping the command line: a transient systemd scope
+// (systemd-run --scope) enforces memory and task limits when a systemd manager
+// is reachable, and util-linux prlimit sets the file descriptor limit.
+package limits
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Limits describes the resource caps for one child process tree.
+// Zero values mean unlimited.
+type Limits struct {
+	MemoryMax uint64 // bytes
+	TasksMax  uint64
+	NoFile    uint64
+}
+
+// IsZero reports whether no limit is set.
+func (l Limits) IsZero() bool {
+	return l == Limits{}
+}
+
+// String renders the limits in the same form accepted by the environment overrides.
+func (l Limits) String() string {
+	var parts []string
+	if l.MemoryMax > 0 {
+		parts = append(parts, "memory="+strconv.FormatUint(l.MemoryMax, 10))
+	}
+	if l.TasksMax > 0 {
+		parts = append(parts, "tasks="+strconv.FormatUint(l.TasksMax, 10))
+	}
+	if l.NoFile > 0 {
+		parts = append(parts, "nofile="+strconv.FormatUint(l.NoFile, 10))
+	}
+	return strings.Join(parts, ",")
+}
+
+// DefaultOperation is the operation type used when no specific entry exists.
+const DefaultOperation = "default"
+
+// defaults holds the built-in limits per operation type (ll-cli subcommand).
+// Apps started through run/exec live in their own containers and are not capped here.
+var defaults = map[string]Limits{
+	"install":        {MemoryMax: 4 << 30, TasksMax: 1024, NoFile: 8192},
+	"upgrade":        {MemoryMax: 4 << 30, TasksMax: 1024, NoFile: 8192},
+	"run":            {},
+	"exec":           {},
+	DefaultOperation: {MemoryMax: 1 << 30, TasksMax: 256, NoFile: 4096},
+}
+
+// envPrefix is the prefix of per-operation overrides, e.g.
+// LINYAPS_LIMITS_INSTALL=memory=2G,tasks=512,nofile=4096.
+// LINYAPS_LIMITS_DEFAULT applies to operation types without their own entry.
+const envPrefix = "LINYAPS_LIMITS_"
+
+// ForOperation returns the effective limits for an operation type.
+// Environment overrides replace individual fields of the built-in values.
+func ForOperation(op string) Limits {
+	l, known := defaults[op]
+	if !known {
+		l = defaults[DefaultOperation]
+	}
+	spec := os.Getenv(envPrefix + strings.ToUpper(strings.ReplaceAll(op, "-", "_")))
+	if spec == "" && !known {
+		spec = os.Getenv(envPrefix + "DEFAULT")
+	}
+	if spec != "" {
+		if parsed, err := Parse(spec, l); err == nil {
+			l = parsed
+		}
+	}
+	return l
+}
+
+// Parse applies a "memory=2G,tasks=512,nofile=4096" specification on top of base.
+// A value of 0 or "infinity" removes the corresponding limit.
+func Parse(spec string, base Limits) (Limits, error) {
+	l := base
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return base, fmt.Errorf("invalid limit %q: want key=value", field)
+		}
+		n, err := parseSize(value)
+		if err != nil {
+			return base, fmt.Errorf("invalid limit %q: %w", field, err)
+		}
+		switch strings.ToLower(key) {
+		case "memory":
+			l.MemoryMax = n
+		case "tasks":
+			l.TasksMax = n
+		case "nofile":
+			l.NoFile = n
+		default:
+			return base, fmt.Errorf("unknown limit %q", key)
+		}
+	}
+	return l, nil
+}
+
+// parseSize parses a number with an optional K/M/G/T suffix (powers of 1024).
+func parseSize(s string) (uint64, error) {
+	s = strings.TrimSpace(s)
+	if s == "infinity" {
+		return 0, nil
+	}
+	mult := uint64(1)
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'K', 'k':
+			mult = 1 << 10
+		case 'M', 'm':
+			mult = 1 << 20
+		case 'G', 'g':
+			mult = 1 << 30
+		case 'T', 't':
+			mult = 1 << 40
+		}
+		if mult != 1 {
+			s = s[:n-1]
+		}
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return v * mult, nil
+}
+
+// lookPath is replaced in tests.
+var lookPath = exec.LookPath
+
+// Wrap returns a command line that runs program with args under l.
+// When neither systemd-run nor prlimit is available the command is returned
+// unchanged; limits are best-effort and never prevent a command from running.
+func Wrap(l Limits, program string, args []string) (string, []string) {
+	if l.IsZero() {
+		return program, args
+	}
+
+	var prefix []string
+	if l.MemoryMax > 0 || l.TasksMax > 0 {
+		prefix = scopePrefix("", l)
+	}
+	if l.NoFile > 0 {
+		if bin, err := lookPath("prlimit"); err == nil {
+			n := strconv.FormatUint(l.NoFile, 10)
+			prefix = append(prefix, bin, "--nofile="+n+":"+n, "--")
+		}
+	}
+	return prepend(prefix, program, args)
+}
+
+// WrapScope returns a command line that runs program inside a transient systemd
+// scope named unit, so session managers can track and clean up the process tree.
+// The command is returned unchanged when no systemd manager is reachable.
+func WrapScope(unit string, program string, args []string) (string, []string) {
+	return prepend(scopePrefix(unit, Limits{}), program, args)
+}
+
+func prepend(prefix []string, program string, args []string) (string, []string) {
+	if len(prefix) == 0 {
+		return program, args
+	}
+	wrapped := make([]string, 0, len(prefix)+len(args))
+	wrapped = append(wrapped, prefix[1:]...)
+	wrapped = append(wrapped, program)
+	wrapped = append(wrapped, args...)
+	return prefix[0], wrapped
+}
+
+// scopePrefix returns the systemd-run command line starting a transient scope
+// owned by the manager matching our uid, or nil if systemd is unavailable.
+func scopePrefix(unit string, l Limits) []string {
+	bin, err := lookPath("systemd-run")
+	if err != nil || !systemdAvailable() {
+		return nil
+	}
+	prefix := []string{bin}
+	if os.Getuid() != 0 {
+		prefix = append(prefix, "--user")
+	}
+	prefix = append(prefix, "--scope", "--quiet", "--collect")
+	if unit != "" {
+		prefix = append(prefix, "--unit="+unit)
+	}
+	if l.MemoryMax > 0 {
+		prefix = append(prefix, "-p", "MemoryMax="+strconv.FormatUint(l.MemoryMax, 10))
+	}
+	if l.TasksMax > 0 {
+		prefix = append(prefix, "-p", "TasksMax="+strconv.FormatUint(l.TasksMax, 10))
+	}
+	return append(prefix, "--")
+}
+
+var scopeCounter uint64
+
+// AppScopeName returns a unique transient scope name for a launch of appID,
+// of the form app-linglong-<appID>-<pid>.<n>.scope. The manager's pid keeps
+// the name unique against scopes started before a restart or takeover,
+// which outlive the process that numbered them.
+func AppScopeName(appID string) string {
+	n := atomic.AddUint64(&scopeCounter, 1)
+	return fmt.Sprintf("%s%d.%d.scope", AppScopePrefix(appID), os.Getpid(), n)
+}
+
+// AppScopePrefix returns the common prefix of the scope names of appID's
+// launches, or of all app launches if appID is empty.
+func AppScopePrefix(appID string) string {
+	if appID == "" {
+		return "app-linglong-"
+	}
+	return "app-linglong-" + escapeUnitName(appID) + "-"
+}
+
+// escapeUnitName replaces characters that are not valid in systemd unit names.
+func escapeUnitName(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9',
+			r == '.', r == '_', r == ':':
+			b.WriteRune(r)
+		default:
+			b.WriteByte('_')
+		}
+	}
+	return b.String()
+}
+
+// systemdAvailable reports whether the systemd manager for our uid can be reached.
+func systemdAvailable() bool {
+	if os.Getuid() == 0 {
+		_, err := os.Stat("/run/systemd/private")
+		return err == nil
+	}
+	runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+	if runtimeDir == "" {
+		runtimeDir = filepath.Join("/run/user", strconv.Itoa(os.Getuid()))
+	}
+	_, err := os.Stat(filepath.Join(runtimeDir, "systemd", "private"))
+	return err == nil
+}
@@ -0,0 +1,91 @@
+package limits
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	base := Limits{MemoryMax: 1, TasksMax: 2, NoFile: 3}
+	tests := []struct {
+		spec    string
+		want    Limits
+		wantErr bool
+	}{
+		{"memory=2G", Limits{MemoryMax: 2 << 30, TasksMax: 2, NoFile: 3}, false},
+		{"tasks=64, nofile=1024", Limits{MemoryMax: 1, TasksMax: 64, NoFile: 1024}, false},
+		{"memory=infinity", Limits{TasksMax: 2, NoFile: 3}, false},
+		{"memory=512M,tasks=0", Limits{MemoryMax: 512 << 20, NoFile: 3}, false},
+		{"cpu=1", base, true},
+		{"memory", base, true},
+		{"memory=lots", base, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := Parse(tt.spec, base)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestForOperationOverride(t *testing.T) {
+	t.Setenv("LINYAPS_LIMITS_INSTALL", "memory=1G")
+	t.Setenv("LINYAPS_LIMITS_DEFAULT", "nofile=100")
+
+	if got := ForOperation("install"); got.MemoryMax != 1<<30 || got.NoFile != defaults["install"].NoFile {
+		t.Errorf("ForOperation(install) = %+v", got)
+	}
+	if got := ForOperation("search"); got.NoFile != 100 {
+		t.Errorf("ForOperation(search) = %+v, want nofile 100", got)
+	}
+	if got := ForOperation("run"); !got.IsZero() {
+		t.Errorf("ForOperation(run) = %+v, want no limits", got)
+	}
+}
+
+func TestWrap(t *testing.T) {
+	defer func(orig func(string) (string, error)) { lookPath = orig }(lookPath)
+
+	lookPath = func(name string) (string, error) {
+		if name == "prlimit" {
+			return "/usr/bin/prlimit", nil
+		}
+		return "", errors.New("not found")
+	}
+
+	prog, args := Wrap(Limits{NoFile: 64}, "ll-cli", []string{"install", "app"})
+	if prog != "/usr/bin/prlimit" {
+		t.Errorf("program = %q, want prlimit", prog)
+	}
+	want := []string{"--nofile=64:64", "--", "ll-cli", "install", "app"}
+	if !reflect.DeepEqual(args, want) {
+		t.Errorf("args = %q, want %q", args, want)
+	}
+
+	prog, args = Wrap(Limits{}, "ll-cli", []string{"list"})
+	if prog != "ll-cli" || !reflect.DeepEqual(args, []string{"list"}) {
+		t.Errorf("Wrap with no limits changed the command: %q %q", prog, args)
+	}
+}
+
+func TestAppScopeName(t *testing.T) {
+	a := AppScopeName("org.example/app 1")
+	b := AppScopeName("org.example/app 1")
+	if a == b {
+		t.Errorf("AppScopeName returned duplicate names %q", a)
+	}
+	prefix := "app-linglong-org.example_app_1-" + strconv.Itoa(os.Getpid()) + "."
+	if !strings.HasPrefix(a, prefix) || !strings.HasSuffix(a, ".scope") {
+		t.Errorf("AppScopeName = %q, want app-linglong-org.example_app_1-<pid>.<n>.scope", a)
+	}
+}
@@ -0,0 +1,43 @@
+package llcli
+
+import (
+	"strings"
+	"testing"
+)
+
+// FuzzParseRepoShow feeds arbitrary ll-cli output to the parser; it must not
+// panic and every parsed repo must have a name without whitespace.
+func FuzzParseRepoShow(f *testing.F) {
+	f.Add("Default: stable\nName Url Alias Priority\nstable https://repo stable 0\n")
+	f.Add(`{"defaultRepo":"stable","repos":[{"name":"stable","url":"https://repo","priority":0}]}`)
+	f.Add("{")
+	f.Add("")
+
+	f.Fuzz(func(t *testing.T, output string) {
+		cfg, err := ParseRepoShow(output)
+		if err != nil {
+			return
+		}
+		for _, r := range cfg.Repos {
+			if strings.TrimSpace(output)[0] != '{' && strings.ContainsAny(r.Name, " \t\n") {
+				t.Fatalf("table repo name %q contains whitespace", r.Name)
+			}
+		}
+	})
+}
+
+// FuzzParseRef checks that accepted refs round-trip through String.
+func FuzzParseRef(f *testing.F) {
+	f.Add("main:org.deepin.calculator/5.7.16.1/x86_64")
+	f.Add("org.example.app/1.0")
+	f.Fuzz(func(t *testing.T, s string) {
+		r, err := ParseRef(s)
+		if err != nil {
+			return
+		}
+		again, err := ParseRef(r.String())
+		if err != nil || again != r {
+			t.Fatalf("ParseRef(%q) = %+v does not round-trip: %+v, %v", s, r, again, err)
+		}
+	})
+}
@@ -0,0 +1,81 @@
+package llcli
+
+import (
+	"regexp"
+	"strings"
+)
+
+// InstallResult describes what an "ll-cli install" actually did.
+type InstallResult struct {
+	Installed []string // refs of applications installed
+	Skipped   []string // refs that were already installed
+	Runtimes  []string // runtimes and bases pulled in as dependencies
+}
+
+// refToken matches things that look like refs: a reverse-DNS id with an
+// optional channel prefix and version/arch suffix.
+var refToken = regexp.MustCompile(`(?:[A-Za-z0-9_-]+:)?[A-Za-z0-9][A-Za-z0-9_-]*(?:\.[A-Za-z0-9_-]+)+(?:/[0-9A-Za-z.+~_-]+(?:/[A-Za-z0-9_-]+)?)?`)
+
+// InstallParser extracts an InstallResult from ll-cli install output fed to
+// it line by line. It recognises the messages of ll-cli 1.4 to 1.7:
+//
+//	Install main:org.example.app/1.0.0/x86_64 success
+//	org.example.app/1.0.0 installed successfully
+//	Installing runtime main:org.deepin.runtime.dtk/23.1.0/x86_64
+//	Application already installed / org.example.app is already installed
+//
+// Lines that match none of them are ignored, so unknown output only makes
+// the result less complete.
+type InstallParser struct {
+	requested string
+	result    InstallResult
+	seen      map[string]bool
+}
+
+// NewInstallParser creates a parser for installing requested, the ref given
+// on the command line. It is reported as skipped for messages that do not
+// name a ref.
+func NewInstallParser(requested string) *InstallParser {
+	return &InstallParser{requested: requested, seen: make(map[string]bool)}
+}
+
+// Line feeds one line of output to the parser.
+func (p *InstallParser) Line(line string) {
+	lower := strings.ToLower(line)
+	if !strings.Contains(lower, "install") {
+		return
+	}
+	ref := refToken.FindString(line)
+	if ref != "" {
+		if _, err := ParseRef(ref); err != nil {
+			ref = ""
+		}
+	}
+
+	switch {
+	case strings.Contains(lower, "already installed"):
+		if ref == "" {
+			ref = p.requested
+		}
+		p.add(&p.result.Skipped, ref)
+	case ref == "":
+		return
+	case strings.Contains(lower, "runtime") || strings.Contains(lower, "base") || strings.Contains(lower, "dependenc"):
+		p.add(&p.result.Runtimes, ref)
+	case strings.Contains(lower, "success"):
+		p.add(&p.result.Installed, ref)
+	}
+}
+
+func (p *InstallParser) add(list *[]string, ref string) {
+	if ref == "" || p.seen[ref] {
+		return
+	}
+	p.seen[ref] = true
+	*list = append(*list, ref)
+}
+
+// Result returns what was parsed so far.
+func (p *InstallParser) Result() InstallResult {
+	return p.result
+}
@@ -0,0 +1,62 @@
+package llcli
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func parseInstall(requested, output string) InstallResult {
+	p := NewInstallParser(requested)
+	for _, line := range strings.Split(output, "\n") {
+		p.Line(line)
+	}
+	return p.Result()
+}
+
+func TestInstallParser(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   InstallResult
+	}{
+		{
+			name: "fresh install with runtime",
+			output: `Beginning to install org.example.app
+Installing runtime main:org.deepin.runtime.dtk/23.1.0/x86_64
+Installing base main:org.deepin.base/23.1.0/x86_64
+Downloading files 45%
+Install main:org.example.app/1.0.0/x86_64 success`,
+			want: InstallResult{
+				Installed: []string{"main:org.example.app/1.0.0/x86_64"},
+				Runtimes:  []string{"main:org.deepin.runtime.dtk/23.1.0/x86_64", "main:org.deepin.base/23.1.0/x86_64"},
+			},
+		},
+		{
+			name:   "already installed with ref",
+			output: "org.example.app/1.0.0 is already installed",
+			want:   InstallResult{Skipped: []string{"org.example.app/1.0.0"}},
+		},
+		{
+			name:   "already installed without ref",
+			output: "Application already installed",
+			want:   InstallResult{Skipped: []string{"org.example.app"}},
+		},
+		{
+			name:   "older wording",
+			output: "org.example.app/1.0.0 installed successfully\norg.example.app/1.0.0 installed successfully",
+			want:   InstallResult{Installed: []string{"org.example.app/1.0.0"}},
+		},
+		{
+			name:   "failure",
+			output: "Error: install org.example.app failed: network unreachable",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseInstall("org.example.app", tt.output); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
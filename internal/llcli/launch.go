@@ -0,0 +1,38 @@
+package llcli
+
+import "strings"
+
+// missingPhrases are what ll-cli 1.4 to 1.7 say about a layer that is not
+// there, e.g.
+//
+//	runtime main:org.deepin.runtime.dtk/23.1.0/x86_64 not found
+//	failed to get layer dir of org.deepin.base/23.1.0: not installed
+//	can not find base org.deepin.base/23.1.0
+var missingPhrases = []string{"not found", "not installed", "not exist", "can not find", "cannot find", "no such"}
+
+// MissingDependency reports whether a line of "ll-cli run" output says the
+// runtime or base of appID is missing, and returns its ref if the line names
+// one. Refs of appID itself are skipped, since failing launches usually name
+// the app too.
+func MissingDependency(line, appID string) (string, bool) {
+	lower := strings.ToLower(line)
+	if !strings.Contains(lower, "runtime") && !strings.Contains(lower, "base") {
+		return "", false
+	}
+	missing := false
+	for _, p := range missingPhrases {
+		if strings.Contains(lower, p) {
+			missing = true
+			break
+		}
+	}
+	if !missing {
+		return "", false
+	}
+	for _, tok := range refToken.FindAllString(line, -1) {
+		if r, err := ParseRef(tok); err == nil && r.ID != appID {
+			return tok, true
+		}
+	}
+	return "", true
+}
@@ -0,0 +1,26 @@
+package llcli
+
+import "testing"
+
+func TestMissingDependency(t *testing.T) {
+	const app = "org.example.app"
+	tests := []struct {
+		line    string
+		ref     string
+		missing bool
+	}{
+		{"runtime main:org.deepin.runtime.dtk/23.1.0/x86_64 not found", "main:org.deepin.runtime.dtk/23.1.0/x86_64", true},
+		{"failed to run org.example.app/1.0: failed to get layer dir of org.deepin.base/23.1.0: not installed", "org.deepin.base/23.1.0", true},
+		{"Can not find base org.deepin.base/23.1.0", "org.deepin.base/23.1.0", true},
+		{"runtime not installed", "", true},
+		{"org.example.app/1.0 not found", "", false},
+		{"using runtime org.deepin.runtime.dtk/23.1.0", "", false},
+		{"starting org.example.app", "", false},
+	}
+	for _, tt := range tests {
+		ref, missing := MissingDependency(tt.line, app)
+		if ref != tt.ref || missing != tt.missing {
+			t.Errorf("MissingDependency(%q) = %q, %v, want %q, %v", tt.line, ref, missing, tt.ref, tt.missing)
+		}
+	}
+}
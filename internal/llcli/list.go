@@ -0,0 +1,165 @@
+package llcli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Package is one entry of "ll-cli list --json".
+type Package struct {
+	ID          string `json:"id"`
+	Name        string `json:"name,omitempty"`
+	Version     string `json:"version"`
+	Arch        string `json:"arch,omitempty"`
+	Channel     string `json:"channel,omitempty"`
+	Module      string `json:"module,omitempty"`
+	Kind        string `json:"kind,omitempty"`
+	Runtime     string `json:"runtime,omitempty"`
+	Base        string `json:"base,omitempty"`
+	Description string `json:"description,omitempty"`
+	Size        int64  `json:"size,omitempty"` // bytes, if the release reports it
+	Repo        string `json:"repo,omitempty"` // set by ParseSearch for per-repo output
+}
+
+// rawPackage accepts the field spellings of different ll-cli releases:
+// "appid" before 1.5, "id" after, and arch as a string or a list.
+type rawPackage struct {
+	ID          string          `json:"id"`
+	AppID       string          `json:"appid"`
+	Name        string          `json:"name"`
+	Version     string          `json:"version"`
+	Arch        json.RawMessage `json:"arch"`
+	Channel     string          `json:"channel"`
+	Module      string          `json:"module"`
+	Kind        string          `json:"kind"`
+	Runtime     string          `json:"runtime"`
+	Base        string          `json:"base"`
+	Description string          `json:"description"`
+	Size        int64           `json:"size"`
+}
+
+// ParseList parses the output of "ll-cli list --json".
+func ParseList(output string) ([]Package, error) {
+	trimmed := strings.TrimSpace(output)
+	if trimmed == "" {
+		return nil, nil
+	}
+	var raws []rawPackage
+	if err := json.Unmarshal([]byte(trimmed), &raws); err != nil {
+		return nil, fmt.Errorf("parse list json: %w", err)
+	}
+	return convertPackages(raws, ""), nil
+}
+
+// ParseSearch parses the output of "ll-cli search --json". Depending on the
+// release it is a plain list like ll-cli list, or an object mapping each
+// repository name to its list of results.
+func ParseSearch(output string) ([]Package, error) {
+	trimmed := strings.TrimSpace(output)
+	if !strings.HasPrefix(trimmed, "{") {
+		return ParseList(trimmed)
+	}
+	var byRepo map[string][]rawPackage
+	if err := json.Unmarshal([]byte(trimmed), &byRepo); err != nil {
+		return nil, fmt.Errorf("parse search json: %w", err)
+	}
+	repos := make([]string, 0, len(byRepo))
+	for repo := range byRepo {
+		repos = append(repos, repo)
+	}
+	sort.Strings(repos)
+
+	var pkgs []Package
+	for _, repo := range repos {
+		pkgs = append(pkgs, convertPackages(byRepo[repo], repo)...)
+	}
+	return pkgs, nil
+}
+
+// ParseInfo parses the output of "ll-cli info", the JSON description of an
+// installed package, which some releases print as a list of one.
+func ParseInfo(output string) (Package, error) {
+	trimmed := strings.TrimSpace(output)
+	if !strings.HasPrefix(trimmed, "{") {
+		pkgs, err := ParseList(trimmed)
+		if err != nil {
+			return Package{}, err
+		}
+		if len(pkgs) != 1 {
+			return Package{}, fmt.Errorf("parse info json: got %d packages", len(pkgs))
+		}
+		return pkgs[0], nil
+	}
+	var raw rawPackage
+	if err := json.Unmarshal([]byte(trimmed), &raw); err != nil {
+		return Package{}, fmt.Errorf("parse info json: %w", err)
+	}
+	pkgs := convertPackages([]rawPackage{raw}, "")
+	if len(pkgs) == 0 {
+		return Package{}, errors.New("parse info json: no package id")
+	}
+	return pkgs[0], nil
+}
+
+func convertPackages(raws []rawPackage, repo string) []Package {
+	pkgs := make([]Package, 0, len(raws))
+	for _, r := range raws {
+		p := Package{
+			ID:          r.ID,
+			Name:        r.Name,
+			Version:     r.Version,
+			Channel:     r.Channel,
+			Module:      r.Module,
+			Kind:        r.Kind,
+			Runtime:     r.Runtime,
+			Base:        r.Base,
+			Description: r.Description,
+			Size:        r.Size,
+			Repo:        repo,
+		}
+		if p.ID == "" {
+			p.ID = r.AppID
+		}
+		if p.ID == "" {
+			continue
+		}
+		var archs []string
+		if err := json.Unmarshal(r.Arch, &archs); err == nil {
+			p.Arch = strings.Join(archs, ",")
+		} else {
+			json.Unmarshal(r.Arch, &p.Arch)
+		}
+		pkgs = append(pkgs, p)
+	}
+	return pkgs
+}
+
+// Matches reports whether p satisfies ref: same id, and same version and
+// arch where ref specifies them.
+func (p Package) Matches(ref Ref) bool {
+	if p.ID != ref.ID {
+		return false
+	}
+	if ref.Version != "" && p.Version != ref.Version {
+		return false
+	}
+	if ref.Arch != "" && !containsField(p.Arch, ref.Arch) {
+		return false
+	}
+	if ref.Channel != "" && p.Channel != "" && p.Channel != ref.Channel {
+		return false
+	}
+	return true
+}
+
+func containsField(list, s string) bool {
+	for _, f := range strings.Split(list, ",") {
+		if f == s {
+			return true
+		}
+	}
+	return false
+}
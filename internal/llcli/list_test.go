@@ -0,0 +1,93 @@
+package llcli
+
+import "testing"
+
+func TestParseList(t *testing.T) {
+	out := `[
+  {"id":"org.example.app","version":"1.0.0","arch":["x86_64"],"channel":"main","module":"binary","kind":"app"},
+  {"appid":"org.deepin.runtime.dtk","version":"23.1.0","arch":"x86_64","kind":"runtime"},
+  {"version":"0"}
+]`
+	pkgs, err := ParseList(out)
+	if err != nil {
+		t.Fatalf("ParseList: %v", err)
+	}
+	if len(pkgs) != 2 {
+		t.Fatalf("got %d packages, want 2: %+v", len(pkgs), pkgs)
+	}
+	if pkgs[0].ID != "org.example.app" || pkgs[0].Arch != "x86_64" || pkgs[0].Module != "binary" {
+		t.Errorf("pkgs[0] = %+v", pkgs[0])
+	}
+	if pkgs[1].ID != "org.deepin.runtime.dtk" || pkgs[1].Arch != "x86_64" {
+		t.Errorf("pkgs[1] = %+v", pkgs[1])
+	}
+
+	if _, err := ParseList("not json"); err == nil {
+		t.Error("ParseList accepted invalid output")
+	}
+	if pkgs, err := ParseList(""); err != nil || len(pkgs) != 0 {
+		t.Errorf("ParseList(\"\") = %v, %v", pkgs, err)
+	}
+}
+
+func TestParseInfo(t *testing.T) {
+	for _, out := range []string{
+		`{"id":"org.example.app","version":"1.0.0","arch":["x86_64"],"base":"main:org.deepin.base/23.1.0/x86_64","runtime":"main:org.deepin.runtime.dtk/23.1.0/x86_64"}`,
+		`[{"appid":"org.example.app","version":"1.0.0","arch":"x86_64","base":"main:org.deepin.base/23.1.0/x86_64","runtime":"main:org.deepin.runtime.dtk/23.1.0/x86_64"}]`,
+	} {
+		p, err := ParseInfo(out)
+		if err != nil {
+			t.Fatalf("ParseInfo(%s): %v", out, err)
+		}
+		if p.ID != "org.example.app" || p.Arch != "x86_64" || p.Base != "main:org.deepin.base/23.1.0/x86_64" || p.Runtime != "main:org.deepin.runtime.dtk/23.1.0/x86_64" {
+			t.Errorf("ParseInfo(%s) = %+v", out, p)
+		}
+	}
+	for _, out := range []string{"not json", "[]", `{"version":"1"}`} {
+		if _, err := ParseInfo(out); err == nil {
+			t.Errorf("ParseInfo(%s) succeeded", out)
+		}
+	}
+}
+
+func TestPackageMatches(t *testing.T) {
+	p := Package{ID: "org.example.app", Version: "1.0.0", Arch: "x86_64", Channel: "main"}
+	tests := []struct {
+		ref  string
+		want bool
+	}{
+		{"org.example.app", true},
+		{"org.example.app/1.0.0", true},
+		{"main:org.example.app/1.0.0/x86_64", true},
+		{"org.example.app/2.0.0", false},
+		{"org.example.app/1.0.0/arm64", false},
+		{"beta:org.example.app", false},
+		{"org.example.other", false},
+	}
+	for _, tt := range tests {
+		ref, err := ParseRef(tt.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := p.Matches(ref); got != tt.want {
+			t.Errorf("Matches(%q) = %v, want %v", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestParseSearch(t *testing.T) {
+	byRepo := `{"testing":[{"id":"org.example.app","version":"1.1.0","channel":"main"}],
+"stable":[{"id":"org.example.app","version":"1.0.0","channel":"main","size":1048576}]}`
+	pkgs, err := ParseSearch(byRepo)
+	if err != nil {
+		t.Fatalf("ParseSearch: %v", err)
+	}
+	if len(pkgs) != 2 || pkgs[0].Repo != "stable" || pkgs[0].Size != 1048576 || pkgs[1].Version != "1.1.0" {
+		t.Errorf("pkgs = %+v", pkgs)
+	}
+
+	pkgs, err = ParseSearch(`[{"appid":"org.example.app","version":"1.0.0"}]`)
+	if err != nil || len(pkgs) != 1 || pkgs[0].Repo != "" {
+		t.Errorf("plain list: %+v, %v", pkgs, err)
+	}
+}
@@ -0,0 +1,80 @@
+package llcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Container is one running app container.
+type Container struct {
+	App string `json:"app"` // ref of the running app
+	ID  string `json:"id"`
+	PID int    `json:"pid"`
+}
+
+// rawContainer accepts the key spellings used by different ll-cli releases.
+type rawContainer struct {
+	App         string `json:"app"`
+	Package     string `json:"package"`
+	ID          string `json:"id"`
+	ContainerID string `json:"containerID"`
+	PID         int    `json:"pid"`
+}
+
+// ParsePs parses "ll-cli ps" output in either the JSON form (--json) or the
+// table form:
+//
+//	App                                          ContainerID      Pid
+//	main:org.deepin.calculator/5.7.16.1/x86_64   c3b5ce363172     539079
+func ParsePs(output string) ([]Container, error) {
+	trimmed := strings.TrimSpace(output)
+	if strings.HasPrefix(trimmed, "[") {
+		var raw []rawContainer
+		if err := json.Unmarshal([]byte(trimmed), &raw); err != nil {
+			return nil, fmt.Errorf("parse ps json: %w", err)
+		}
+		out := make([]Container, 0, len(raw))
+		for _, r := range raw {
+			c := Container{App: r.App, ID: r.ID, PID: r.PID}
+			if c.App == "" {
+				c.App = r.Package
+			}
+			if c.ID == "" {
+				c.ID = r.ContainerID
+			}
+			out = append(out, c)
+		}
+		return out, nil
+	}
+
+	var out []Container
+	for _, line := range strings.Split(trimmed, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.EqualFold(fields[0], "App") {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected ps line %q", line)
+		}
+		pid, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("invalid pid in ps line %q", line)
+		}
+		out = append(out, Container{App: fields[0], ID: fields[1], PID: pid})
+	}
+	return out, nil
+}
+
+// Matches reports whether target names this container: its ID, a prefix of
+// it of at least 4 characters, or the app id of its ref.
+func (c Container) Matches(target string) bool {
+	if target == "" {
+		return false
+	}
+	if target == c.ID || (len(target) >= 4 && strings.HasPrefix(c.ID, target)) {
+		return true
+	}
+	return AppIDFromRef(c.App) == target
+}
@@ -0,0 +1,62 @@
+package llcli
+
+import "testing"
+
+func TestParsePsTable(t *testing.T) {
+	out := `App                                          ContainerID      Pid
+main:org.deepin.calculator/5.7.16.1/x86_64   c3b5ce363172     539079
+main:org.example.editor/1.0/x86_64           0f1e2d3c4b5a     1200
+`
+	cs, err := ParsePs(out)
+	if err != nil {
+		t.Fatalf("ParsePs: %v", err)
+	}
+	if len(cs) != 2 {
+		t.Fatalf("got %d containers, want 2", len(cs))
+	}
+	want := Container{App: "main:org.deepin.calculator/5.7.16.1/x86_64", ID: "c3b5ce363172", PID: 539079}
+	if cs[0] != want {
+		t.Errorf("cs[0] = %+v, want %+v", cs[0], want)
+	}
+}
+
+func TestParsePsEmpty(t *testing.T) {
+	cs, err := ParsePs("App    ContainerID    Pid\n")
+	if err != nil || len(cs) != 0 {
+		t.Errorf("ParsePs = %+v, %v; want none", cs, err)
+	}
+}
+
+func TestParsePsJSON(t *testing.T) {
+	out := `[{"package":"main:org.example.editor/1.0/x86_64","containerID":"0f1e2d3c4b5a","pid":1200}]`
+	cs, err := ParsePs(out)
+	if err != nil {
+		t.Fatalf("ParsePs: %v", err)
+	}
+	want := Container{App: "main:org.example.editor/1.0/x86_64", ID: "0f1e2d3c4b5a", PID: 1200}
+	if len(cs) != 1 || cs[0] != want {
+		t.Errorf("ParsePs = %+v, want [%+v]", cs, want)
+	}
+}
+
+func TestParsePsInvalid(t *testing.T) {
+	if _, err := ParsePs("main:org.a/1.0 abc notapid\n"); err == nil {
+		t.Error("expected error for non-numeric pid")
+	}
+}
+
+func TestContainerMatches(t *testing.T) {
+	c := Container{App: "main:org.example.editor/1.0/x86_64", ID: "0f1e2d3c4b5a", PID: 1200}
+	for target, want := range map[string]bool{
+		"0f1e2d3c4b5a":       true,
+		"0f1e":               true,
+		"0f1":                false,
+		"org.example.editor": true,
+		"org.example":        false,
+		"":                   false,
+	} {
+		if got := c.Matches(target); got != want {
+			t.Errorf("Matches(%q) = %v, want %v", target, got, want)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package llcli
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Ref is a linglong package reference, written [channel:]id[/version[/arch]],
+// e.g. "main:org.deepin.calculator/5.7.16.1/x86_64".
+type Ref struct {
+	Channel string
+	ID      string
+	Version string
+	Arch    string
+}
+
+var (
+	refIDPattern      = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+	refVersionPattern = regexp.MustCompile(`^[0-9A-Za-z][0-9A-Za-z.+~_-]*$`)
+	refWordPattern    = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+)
+
+// ParseRef parses and validates a package reference.
+func ParseRef(s string) (Ref, error) {
+	var r Ref
+	rest := s
+	if channel, after, ok := strings.Cut(rest, ":"); ok {
+		if !refWordPattern.MatchString(channel) {
+			return Ref{}, fmt.Errorf("invalid channel in ref %q", s)
+		}
+		r.Channel, rest = channel, after
+	}
+
+	parts := strings.Split(rest, "/")
+	if len(parts) > 3 {
+		return Ref{}, fmt.Errorf("invalid ref %q: too many components", s)
+	}
+	r.ID = parts[0]
+	if !refIDPattern.MatchString(r.ID) || strings.Contains(r.ID, "..") {
+		return Ref{}, fmt.Errorf("invalid app id in ref %q", s)
+	}
+	if len(parts) > 1 {
+		r.Version = parts[1]
+		if !refVersionPattern.MatchString(r.Version) {
+			return Ref{}, fmt.Errorf("invalid version in ref %q", s)
+		}
+	}
+	if len(parts) > 2 {
+		r.Arch = parts[2]
+		if !refWordPattern.MatchString(r.Arch) {
+			return Ref{}, fmt.Errorf("invalid arch in ref %q", s)
+		}
+	}
+	return r, nil
+}
+
+// AppIDFromRef returns the app id of ref, or ref itself if it does not parse.
+func AppIDFromRef(ref string) string {
+	r, err := ParseRef(ref)
+	if err != nil {
+		return ref
+	}
+	return r.ID
+}
+
+// String formats the reference in the form ParseRef accepts.
+func (r Ref) String() string {
+	s := r.ID
+	if r.Channel != "" {
+		s = r.Channel + ":" + s
+	}
+	if r.Version != "" {
+		s += "/" + r.Version
+		if r.Arch != "" {
+			s += "/" + r.Arch
+		}
+	}
+	return s
+}
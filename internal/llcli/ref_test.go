@@ -0,0 +1,33 @@
+package llcli
+
+import "testing"
+
+func TestParseRef(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Ref
+	}{
+		{"org.deepin.calculator", Ref{ID: "org.deepin.calculator"}},
+		{"org.deepin.calculator/5.7.16.1", Ref{ID: "org.deepin.calculator", Version: "5.7.16.1"}},
+		{"main:org.deepin.calculator/5.7.16.1/x86_64", Ref{Channel: "main", ID: "org.deepin.calculator", Version: "5.7.16.1", Arch: "x86_64"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseRef(tt.in)
+		if err != nil {
+			t.Errorf("ParseRef(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseRef(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+		if got.String() != tt.in {
+			t.Errorf("String() = %q, want %q", got.String(), tt.in)
+		}
+	}
+
+	for _, bad := range []string{"", ":app", "main:", "../x", "a/b/c/d", "app/", "app//x86_64", "app/1.0/x 86", "-app"} {
+		if _, err := ParseRef(bad); err == nil {
+			t.Errorf("ParseRef(%q) succeeded, want error", bad)
+		}
+	}
+}
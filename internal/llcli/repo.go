@@ -0,0 +1,129 @@
+// Package llcli parses the output of ll-cli commands.
+package llcli
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Repo is one configured repository.
+type Repo struct {
+	Name     string `json:"name"`
+	URL      string `json:"url"`
+	Alias    string `json:"alias,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+}
+
+// RepoConfig is the parsed output of "ll-cli repo show".
+type RepoConfig struct {
+	Default string `json:"defaultRepo"`
+	Repos   []Repo `json:"repos"`
+}
+
+// ParseRepoShow parses "ll-cli repo show" output in either the JSON form
+// (--json) or the table form:
+//
+//	Default: stable
+//	Name     Url                                      Alias    Priority
+//	stable   https://mirror-repo-linglong.deepin.com  stable   0
+//
+// Older releases print only the Name and Url columns.
+func ParseRepoShow(output string) (RepoConfig, error) {
+	trimmed := strings.TrimSpace(output)
+	if strings.HasPrefix(trimmed, "{") {
+		var cfg RepoConfig
+		if err := json.Unmarshal([]byte(trimmed), &cfg); err != nil {
+			return RepoConfig{}, fmt.Errorf("parse repo json: %w", err)
+		}
+		return cfg, nil
+	}
+
+	var cfg RepoConfig
+	for _, line := range strings.Split(trimmed, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if v, ok := strings.CutPrefix(line, "Default:"); ok {
+			cfg.Default = strings.TrimSpace(v)
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 || strings.EqualFold(fields[0], "name") {
+			continue
+		}
+		if !strings.Contains(fields[1], "://") {
+			continue
+		}
+		repo := Repo{Name: fields[0], URL: fields[1]}
+		if len(fields) >= 3 {
+			repo.Alias = fields[2]
+		}
+		if len(fields) >= 4 {
+			repo.Priority, _ = strconv.Atoi(fields[3])
+		}
+		cfg.Repos = append(cfg.Repos, repo)
+	}
+	if len(cfg.Repos) == 0 {
+		return RepoConfig{}, fmt.Errorf("no repositories found in repo show output")
+	}
+	return cfg, nil
+}
+
+// Repository configuration problems reported by CheckRepoConfig and, for
+// RepoUnreachable, by callers probing the URLs.
+const (
+	RepoDuplicateName  = "duplicate_name"
+	RepoDuplicateURL   = "duplicate_url"
+	RepoMissingDefault = "missing_default"
+	RepoUnknownDefault = "unknown_default"
+	RepoInvalidURL     = "invalid_url"
+	RepoUnreachable    = "unreachable"
+)
+
+// RepoIssue is a misconfiguration of the repositories, with a hint on how
+// to fix it.
+type RepoIssue struct {
+	Repo    string `json:"repo"` // empty for problems of the whole configuration
+	Problem string `json:"problem"`
+	Hint    string `json:"hint"`
+}
+
+// CheckRepoConfig returns the problems that can be seen in cfg without
+// network access.
+func CheckRepoConfig(cfg RepoConfig) []RepoIssue {
+	var issues []RepoIssue
+	names := make(map[string]bool)
+	urls := make(map[string]string)
+	for _, r := range cfg.Repos {
+		if names[r.Name] {
+			issues = append(issues, RepoIssue{Repo: r.Name, Problem: RepoDuplicateName,
+				Hint: fmt.Sprintf("remove the extra entries with ll-cli repo remove %s and add the repository again", r.Name)})
+		}
+		names[r.Name] = true
+
+		if u, err := url.Parse(r.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			issues = append(issues, RepoIssue{Repo: r.Name, Problem: RepoInvalidURL,
+				Hint: fmt.Sprintf("set an http(s) URL with ll-cli repo update %s <url>", r.Name)})
+			continue
+		}
+		key := strings.TrimRight(r.URL, "/")
+		if other, ok := urls[key]; ok && other != r.Name {
+			issues = append(issues, RepoIssue{Repo: r.Name, Problem: RepoDuplicateURL,
+				Hint: fmt.Sprintf("%s points at the same URL as %s; remove one of them with ll-cli repo remove", r.Name, other)})
+		}
+		urls[key] = r.Name
+	}
+	switch {
+	case cfg.Default == "":
+		issues = append(issues, RepoIssue{Problem: RepoMissingDefault,
+			Hint: "choose a default repository with ll-cli repo set-default <name>"})
+	case !names[cfg.Default]:
+		issues = append(issues, RepoIssue{Repo: cfg.Default, Problem: RepoUnknownDefault,
+			Hint: fmt.Sprintf("the default repository %s is not configured; add it or choose another with ll-cli repo set-default <name>", cfg.Default)})
+	}
+	return issues
+}
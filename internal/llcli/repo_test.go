@@ -0,0 +1,86 @@
+package llcli
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRepoShowTable(t *testing.T) {
+	out := `Default: stable
+Name                Url                                           Alias               Priority
+stable              https://mirror-repo-linglong.deepin.com       stable              0
+testing             https://ci.example.com/repo                   beta                10
+`
+	cfg, err := ParseRepoShow(out)
+	if err != nil {
+		t.Fatalf("ParseRepoShow: %v", err)
+	}
+	if cfg.Default != "stable" {
+		t.Errorf("Default = %q, want stable", cfg.Default)
+	}
+	if len(cfg.Repos) != 2 {
+		t.Fatalf("got %d repos, want 2", len(cfg.Repos))
+	}
+	want := Repo{Name: "testing", URL: "https://ci.example.com/repo", Alias: "beta", Priority: 10}
+	if cfg.Repos[1] != want {
+		t.Errorf("Repos[1] = %+v, want %+v", cfg.Repos[1], want)
+	}
+}
+
+func TestParseRepoShowLegacyTable(t *testing.T) {
+	out := "Default: repo\nName    Url\nrepo    https://mirror-repo-linglong.deepin.com/repos/\n"
+	cfg, err := ParseRepoShow(out)
+	if err != nil {
+		t.Fatalf("ParseRepoShow: %v", err)
+	}
+	if len(cfg.Repos) != 1 || cfg.Repos[0].URL != "https://mirror-repo-linglong.deepin.com/repos/" {
+		t.Errorf("Repos = %+v", cfg.Repos)
+	}
+}
+
+func TestParseRepoShowJSON(t *testing.T) {
+	out := `{"defaultRepo":"stable","repos":[{"name":"stable","url":"https://a.example","alias":"stable","priority":0}]}`
+	cfg, err := ParseRepoShow(out)
+	if err != nil {
+		t.Fatalf("ParseRepoShow: %v", err)
+	}
+	if cfg.Default != "stable" || len(cfg.Repos) != 1 || cfg.Repos[0].URL != "https://a.example" {
+		t.Errorf("cfg = %+v", cfg)
+	}
+}
+
+func TestParseRepoShowEmpty(t *testing.T) {
+	if _, err := ParseRepoShow("Default: stable\n"); err == nil {
+		t.Error("ParseRepoShow without repos should fail")
+	}
+}
+
+func TestCheckRepoConfig(t *testing.T) {
+	cfg := RepoConfig{
+		Default: "main",
+		Repos: []Repo{
+			{Name: "stable", URL: "https://mirror.example.com/"},
+			{Name: "stable", URL: "https://other.example.com"},
+			{Name: "mirror", URL: "https://mirror.example.com"},
+			{Name: "broken", URL: "ftp://mirror.example.com"},
+		},
+	}
+	var got []string
+	for _, issue := range CheckRepoConfig(cfg) {
+		if issue.Hint == "" {
+			t.Errorf("%+v has no hint", issue)
+		}
+		got = append(got, issue.Repo+":"+issue.Problem)
+	}
+	want := []string{"stable:duplicate_name", "mirror:duplicate_url", "broken:invalid_url", "main:unknown_default"}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("CheckRepoConfig = %v, want %v", got, want)
+	}
+
+	if issues := CheckRepoConfig(RepoConfig{Repos: []Repo{{Name: "stable", URL: "https://a.example"}}}); len(issues) != 1 || issues[0].Problem != RepoMissingDefault {
+		t.Errorf("without default = %+v, want missing_default", issues)
+	}
+	if issues := CheckRepoConfig(RepoConfig{Default: "stable", Repos: []Repo{{Name: "stable", URL: "https://a.example"}}}); len(issues) != 0 {
+		t.Errorf("valid config = %+v, want no issues", issues)
+	}
+}
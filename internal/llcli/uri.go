@@ -0,0 +1,62 @@
+package llcli
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// URIScheme is the scheme of store deep links.
+const URIScheme = "linglong"
+
+// URIRequest is the action carried by a deep link such as
+// "linglong://install/org.deepin.calculator?version=5.7.16.1&channel=main".
+type URIRequest struct {
+	Action string // only "install" for now
+	Ref    Ref
+}
+
+// ParseURI parses and validates a deep link. Unknown actions and query
+// parameters are rejected so links cannot smuggle in ll-cli options.
+func ParseURI(s string) (URIRequest, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return URIRequest{}, fmt.Errorf("invalid URI %q", s)
+	}
+	if u.Scheme != URIScheme || u.Opaque != "" || u.User != nil || u.Port() != "" || u.Fragment != "" {
+		return URIRequest{}, fmt.Errorf("invalid URI %q: want %s://install/<app id>", s, URIScheme)
+	}
+	if u.Host != "install" {
+		return URIRequest{}, fmt.Errorf("unsupported action %q in URI %q", u.Host, s)
+	}
+
+	id := strings.TrimPrefix(u.Path, "/")
+	if id == "" || strings.Contains(id, "/") {
+		return URIRequest{}, fmt.Errorf("invalid URI %q: want %s://install/<app id>", s, URIScheme)
+	}
+	r := Ref{ID: id}
+	query, err := url.ParseQuery(u.RawQuery)
+	if err != nil {
+		return URIRequest{}, fmt.Errorf("invalid query in URI %q", s)
+	}
+	for key, values := range query {
+		if len(values) != 1 {
+			return URIRequest{}, fmt.Errorf("parameter %q repeated in URI %q", key, s)
+		}
+		switch key {
+		case "version":
+			r.Version = values[0]
+		case "channel":
+			r.Channel = values[0]
+		default:
+			return URIRequest{}, fmt.Errorf("unsupported parameter %q in URI %q", key, s)
+		}
+	}
+
+	// Round-trip through ParseRef to validate every component
+	parsed, err := ParseRef(r.String())
+	if err != nil || parsed != r {
+		return URIRequest{}, fmt.Errorf("invalid package in URI %q", s)
+	}
+	return URIRequest{Action: u.Host, Ref: r}, nil
+}
@@ -0,0 +1,44 @@
+package llcli
+
+import "testing"
+
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Ref
+	}{
+		{"linglong://install/org.deepin.calculator", Ref{ID: "org.deepin.calculator"}},
+		{"linglong://install/org.deepin.calculator?version=5.7.16.1", Ref{ID: "org.deepin.calculator", Version: "5.7.16.1"}},
+		{"linglong://install/org.deepin.calculator?channel=main&version=5.7.16.1", Ref{Channel: "main", ID: "org.deepin.calculator", Version: "5.7.16.1"}},
+	}
+	for _, tt := range tests {
+		got, err := ParseURI(tt.in)
+		if err != nil {
+			t.Errorf("ParseURI(%q): %v", tt.in, err)
+			continue
+		}
+		if got.Action != "install" || got.Ref != tt.want {
+			t.Errorf("ParseURI(%q) = %+v, want install %+v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, bad := range []string{
+		"",
+		"https://install/org.a",
+		"linglong:install/org.a",
+		"linglong://run/org.a",
+		"linglong://install/",
+		"linglong://install/org.a/1.0",
+		"linglong://install/org.a?force=1",
+		"linglong://install/org.a?version=1.0&version=2.0",
+		"linglong://install/org.a?version=1.0/x86_64",
+		"linglong://install/org.a?version=--force",
+		"linglong://install/-org.a",
+		"linglong://install/org.a#x",
+		"linglong://user@install/org.a",
+	} {
+		if _, err := ParseURI(bad); err == nil {
+			t.Errorf("ParseURI(%q) succeeded, want error", bad)
+		}
+	}
+}
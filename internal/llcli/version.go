@@ -0,0 +1,45 @@
+package llcli
+
+import (
+	"strconv"
+	"strings"
+)
+
+// CompareVersions orders linglong versions such as "5.7.16.1": dot-separated
+// components compare numerically when both are numbers and lexically
+// otherwise, and a missing component sorts first. It returns -1, 0 or 1.
+func CompareVersions(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		if i >= len(as) {
+			return -1
+		}
+		if i >= len(bs) {
+			return 1
+		}
+		if c := compareComponent(as[i], bs[i]); c != 0 {
+			return c
+		}
+	}
+	return 0
+}
+
+func compareComponent(a, b string) int {
+	an, aerr := strconv.ParseUint(a, 10, 64)
+	bn, berr := strconv.ParseUint(b, 10, 64)
+	switch {
+	case aerr == nil && berr == nil:
+		switch {
+		case an < bn:
+			return -1
+		case an > bn:
+			return 1
+		}
+		return 0
+	case aerr == nil:
+		return 1 // numeric components sort after textual ones like "beta"
+	case berr == nil:
+		return -1
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,25 @@
+package llcli
+
+import "testing"
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"5.7.16.1", "5.7.9.1", 1},
+		{"1.0", "1.0.0", -1},
+		{"2.0.0", "10.0.0", -1},
+		{"1.0.beta", "1.0.0", -1},
+		{"1.0.alpha", "1.0.beta", -1},
+	}
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := CompareVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
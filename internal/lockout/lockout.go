@@ -0,0 +1,270 @@
+// Package lockout keeps the set of disabled apps: apps that stay installed,
+// with their data and layers, but cannot be started and are hidden from
+// application menus.
+//
+// Menus are hidden per user by placing a desktop entry with Hidden=true and
+// the same file name in $XDG_DATA_HOME/applications, which takes precedence
+// over the entries linglong exports.
+package lockout
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// EntriesDir is where linglong exports the desktop entries of installed apps.
+	EntriesDir = "/var/lib/linglong/entries/share/applications"
+	// OverrideDir receives the entries hiding disabled apps; empty means
+	// $XDG_DATA_HOME/applications.
+	OverrideDir = ""
+)
+
+// markerKey marks the override entries written by this package; its value
+// is the app ID, so entries are only ever removed by the app that wrote them.
+const markerKey = "X-Linyaps-Disabled"
+
+// Store is the persisted set of disabled apps.
+type Store struct {
+	path string
+
+	mu   sync.Mutex
+	apps map[string]time.Time // app ID -> when it was disabled
+}
+
+type file struct {
+	Apps map[string]time.Time `json:"apps"`
+}
+
+// Open loads the set stored at path; a missing file is an empty set.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, apps: make(map[string]time.Time)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for id, t := range f.Apps {
+		s.apps[id] = t
+	}
+	return s, nil
+}
+
+// Disabled reports whether appID is disabled.
+func (s *Store) Disabled(appID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.apps[appID]
+	return ok
+}
+
+// List returns the disabled app IDs, sorted.
+func (s *Store) List() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.apps))
+	for id := range s.apps {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Disable records appID as disabled and hides its desktop entries. It
+// returns the names of the entries hidden.
+func (s *Store) Disable(appID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.apps[appID]; !ok {
+		s.apps[appID] = time.Now().UTC()
+		if err := s.saveLocked(); err != nil {
+			delete(s.apps, appID)
+			return nil, err
+		}
+	}
+	return HideEntries(appID)
+}
+
+// Enable removes appID from the set and restores its desktop entries.
+func (s *Store) Enable(appID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if t, ok := s.apps[appID]; ok {
+		delete(s.apps, appID)
+		if err := s.saveLocked(); err != nil {
+			s.apps[appID] = t
+			return err
+		}
+	}
+	return UnhideEntries(appID)
+}
+
+func (s *Store) saveLocked() error {
+	data, err := json.MarshalIndent(file{Apps: s.apps}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+func overrideDir() string {
+	if OverrideDir != "" {
+		return OverrideDir
+	}
+	base := os.Getenv("XDG_DATA_HOME")
+	if base == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		base = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(base, "applications")
+}
+
+// HideEntries writes a hiding override for every desktop entry exported for
+// appID and returns their names. Entries the user overrides themselves are
+// left alone.
+func HideEntries(appID string) ([]string, error) {
+	dir := overrideDir()
+	if dir == "" {
+		return nil, errors.New("no user data directory for desktop entries")
+	}
+	names, err := exportedEntries(appID)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	content := fmt.Sprintf("[Desktop Entry]\nType=Application\nName=%s\nHidden=true\n%s=%s\n", appID, markerKey, appID)
+	var hidden []string
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if owner, err := overrideOwner(path); err == nil && owner != appID {
+			continue // the user's own override, or another app's
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return hidden, err
+		}
+		hidden = append(hidden, name)
+	}
+	return hidden, nil
+}
+
+// UnhideEntries removes the overrides HideEntries wrote for appID.
+func UnhideEntries(appID string) error {
+	dir := overrideDir()
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, e := range entries {
+		if !strings.HasSuffix(e.Name(), ".desktop") {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		if owner, err := overrideOwner(path); err == nil && owner == appID {
+			if err := os.Remove(path); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// overrideOwner returns the app ID recorded in an override written by
+// HideEntries, or "" for any other desktop entry.
+func overrideOwner(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if v, ok := strings.CutPrefix(scanner.Text(), markerKey+"="); ok {
+			return strings.TrimSpace(v), nil
+		}
+	}
+	return "", scanner.Err()
+}
+
+// exportedEntries returns the names of the desktop entries linglong exports
+// for appID: the one named after it and any whose Exec line runs it.
+func exportedEntries(appID string) ([]string, error) {
+	entries, err := os.ReadDir(EntriesDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, ".desktop") {
+			continue
+		}
+		if name == appID+".desktop" {
+			names = append(names, name)
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(EntriesDir, name))
+		if err == nil && runsApp(string(data), appID) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// runsApp reports whether a desktop entry's Exec line is "ll-cli run appID".
+func runsApp(entry, appID string) bool {
+	for _, line := range strings.Split(entry, "\n") {
+		exec, ok := strings.CutPrefix(strings.TrimSpace(line), "Exec=")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(exec)
+		for i := 0; i+1 < len(fields); i++ {
+			if fields[i] == "run" && strings.HasSuffix(fields[0], "ll-cli") {
+				id, _, _ := strings.Cut(fields[i+1], "/")
+				if id == appID {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
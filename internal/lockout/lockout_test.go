@@ -0,0 +1,87 @@
+package lockout
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func setup(t *testing.T) (entries, overrides string) {
+	t.Helper()
+	entries, overrides = t.TempDir(), t.TempDir()
+	oldEntries, oldOverrides := EntriesDir, OverrideDir
+	EntriesDir, OverrideDir = entries, overrides
+	t.Cleanup(func() { EntriesDir, OverrideDir = oldEntries, oldOverrides })
+
+	write := func(dir, name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(entries, "org.example.app.desktop", "[Desktop Entry]\nExec=ll-cli run org.example.app\n")
+	write(entries, "example-helper.desktop", "[Desktop Entry]\nExec=/usr/bin/ll-cli run org.example.app/1.0 -- helper %F\n")
+	write(entries, "org.example.app.plugin.desktop", "[Desktop Entry]\nExec=ll-cli run org.example.app.plugin\n")
+	write(entries, "other.desktop", "[Desktop Entry]\nExec=ll-cli run org.other\n")
+	// The user's own override of the other app must survive
+	write(overrides, "other.desktop", "[Desktop Entry]\nName=Mine\n")
+	return entries, overrides
+}
+
+func TestDisableEnable(t *testing.T) {
+	_, overrides := setup(t)
+	path := filepath.Join(t.TempDir(), "disabled.json")
+
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hidden, err := s.Disable("org.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"example-helper.desktop", "org.example.app.desktop"}; !reflect.DeepEqual(hidden, want) {
+		t.Errorf("hidden = %v, want %v", hidden, want)
+	}
+	if owner, _ := overrideOwner(filepath.Join(overrides, "example-helper.desktop")); owner != "org.example.app" {
+		t.Errorf("override owner = %q", owner)
+	}
+
+	// The set survives a restart
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Disabled("org.example.app") || s.Disabled("org.example.app.plugin") {
+		t.Errorf("List() = %v after reopening", s.List())
+	}
+
+	if err := s.Enable("org.example.app"); err != nil {
+		t.Fatal(err)
+	}
+	if s.Disabled("org.example.app") {
+		t.Error("still disabled after Enable")
+	}
+	left, _ := os.ReadDir(overrides)
+	if len(left) != 1 || left[0].Name() != "other.desktop" {
+		t.Errorf("overrides left: %v", left)
+	}
+}
+
+func TestHideKeepsUserOverrides(t *testing.T) {
+	entries, overrides := setup(t)
+	os.WriteFile(filepath.Join(entries, "other.desktop"), []byte("Exec=ll-cli run org.example.app\n"), 0o644)
+
+	hidden, err := HideEntries("org.example.app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range hidden {
+		if name == "other.desktop" {
+			t.Error("user override replaced")
+		}
+	}
+	if data, _ := os.ReadFile(filepath.Join(overrides, "other.desktop")); string(data) != "[Desktop Entry]\nName=Mine\n" {
+		t.Errorf("user override changed: %q", data)
+	}
+}
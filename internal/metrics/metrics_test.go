@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	families := []Family{
+		Single("linyaps_up", "Whether the manager runs.", Gauge, 1),
+		{
+			Name: "linyaps_operations_total",
+			Help: "Finished operations,\nby state.",
+			Type: Counter,
+			Samples: []Sample{
+				{Labels: map[string]string{"state": "failed", "operation": `in"stall`}, Value: 2},
+				{Labels: map[string]string{"state": "completed"}, Value: 1.5},
+			},
+		},
+	}
+	var b strings.Builder
+	if err := WriteText(&b, families); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP linyaps_up Whether the manager runs.
+# TYPE linyaps_up gauge
+linyaps_up 1
+# HELP linyaps_operations_total Finished operations,\nby state.
+# TYPE linyaps_operations_total counter
+linyaps_operations_total{operation="in\"stall",state="failed"} 2
+linyaps_operations_total{state="completed"} 1.5
+`
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "linyaps.prom")
+	for _, content := range []string{"first\n", "second\n"} {
+		if err := WriteFile(path, []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+		if data, _ := os.ReadFile(path); string(data) != content {
+			t.Errorf("content = %q, want %q", data, content)
+		}
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("temporary files left: %v", entries)
+	}
+}
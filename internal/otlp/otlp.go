@@ -0,0 +1,431 @@
+// Package otlp exports trace spans to an OpenTelemetry collector using OTLP
+// over HTTP with the JSON encoding. Export is opt-in: nothing is sent unless
+// an endpoint is configured through the standard OTEL_EXPORTER_OTLP_*
+// variables.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Environment variables configuring the exporter, as defined by the
+// OpenTelemetry specification.
+const (
+	EnvEndpoint       = "OTEL_EXPORTER_OTLP_ENDPOINT"        // base URL, /v1/traces is appended
+	EnvTracesEndpoint = "OTEL_EXPORTER_OTLP_TRACES_ENDPOINT" // full URL, wins over EnvEndpoint
+	EnvHeaders        = "OTEL_EXPORTER_OTLP_HEADERS"         // key=value pairs separated by commas
+	EnvServiceName    = "OTEL_SERVICE_NAME"
+)
+
+const (
+	maxBatch      = 256
+	maxQueued     = 2048
+	flushInterval = 5 * time.Second
+	sendTimeout   = 10 * time.Second
+)
+
+// Span kinds, as numbered by OTLP.
+const (
+	KindInternal = 1
+	KindServer   = 2
+)
+
+// TraceID and SpanID identify a trace and a span within it.
+type (
+	TraceID [16]byte
+	SpanID  [8]byte
+)
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// IsValid reports whether t is not all zeros.
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// IsValid reports whether s is not all zeros.
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// ParseTraceID parses the hex form returned by TraceID.String.
+func ParseTraceID(s string) (TraceID, error) {
+	var t TraceID
+	if err := parseHex(s, t[:]); err != nil {
+		return TraceID{}, fmt.Errorf("invalid trace ID %q", s)
+	}
+	return t, nil
+}
+
+// ParseSpanID parses the hex form returned by SpanID.String.
+func ParseSpanID(s string) (SpanID, error) {
+	var id SpanID
+	if err := parseHex(s, id[:]); err != nil {
+		return SpanID{}, fmt.Errorf("invalid span ID %q", s)
+	}
+	return id, nil
+}
+
+func parseHex(s string, dst []byte) error {
+	if hex.DecodedLen(len(s)) != len(dst) {
+		return fmt.Errorf("want %d bytes", len(dst))
+	}
+	_, err := hex.Decode(dst, []byte(s))
+	return err
+}
+
+// Span is one timed unit of work. Attribute values may be strings, bools
+// or integers.
+type Span struct {
+	TraceID TraceID
+	ID      SpanID
+	Parent  SpanID // zero for a root span
+	Name    string
+	Kind    int
+	Start   time.Time
+	End     time.Time
+	Attrs   map[string]interface{}
+	Error   string // non-empty marks the span as failed
+
+	exp *Exporter
+}
+
+// SetAttr sets an attribute. It is a no-op on a nil span, so callers need
+// not check whether tracing is enabled.
+func (s *Span) SetAttr(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	if s.Attrs == nil {
+		s.Attrs = make(map[string]interface{})
+	}
+	s.Attrs[key] = value
+}
+
+// SetError marks the span as failed with err's message.
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.Error = err.Error()
+}
+
+// Finish ends the span now and queues it for export.
+func (s *Span) Finish() {
+	if s == nil {
+		return
+	}
+	s.End = time.Now()
+	s.exp.Export(*s)
+}
+
+// Exporter batches spans and POSTs them to a collector. A nil *Exporter
+// is valid and drops everything.
+type Exporter struct {
+	url     string
+	headers map[string]string
+	service string
+	version string
+	client  *http.Client
+
+	queue chan Span
+	done  chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	dropped int
+}
+
+// FromEnv returns an exporter for the endpoint configured in the
+// environment, or nil if none is. service defaults to EnvServiceName when
+// that is set.
+func FromEnv(service, version string) (*Exporter, error) {
+	url := os.Getenv(EnvTracesEndpoint)
+	if url == "" {
+		base := os.Getenv(EnvEndpoint)
+		if base == "" {
+			return nil, nil
+		}
+		url = strings.TrimSuffix(base, "/") + "/v1/traces"
+	}
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: want an http or https URL", url)
+	}
+	headers, err := parseHeaders(os.Getenv(EnvHeaders))
+	if err != nil {
+		return nil, err
+	}
+	if s := os.Getenv(EnvServiceName); s != "" {
+		service = s
+	}
+	return New(url, headers, service, version), nil
+}
+
+// parseHeaders parses "k1=v1,k2=v2".
+func parseHeaders(s string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, kv := range strings.Split(s, ",") {
+		if strings.TrimSpace(kv) == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			return nil, fmt.Errorf("invalid %s entry %q", EnvHeaders, kv)
+		}
+		headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return headers, nil
+}
+
+// New starts an exporter POSTing to url. Call Close to flush it.
+func New(url string, headers map[string]string, service, version string) *Exporter {
+	e := &Exporter{
+		url:     url,
+		headers: headers,
+		service: service,
+		version: version,
+		client:  &http.Client{Timeout: sendTimeout},
+		queue:   make(chan Span, maxQueued),
+		done:    make(chan struct{}),
+	}
+	go e.loop()
+	return e
+}
+
+// URL returns the endpoint spans are sent to.
+func (e *Exporter) URL() string {
+	if e == nil {
+		return ""
+	}
+	return e.url
+}
+
+// Start begins a root span. It returns nil on a nil exporter.
+func (e *Exporter) Start(name string, kind int) *Span {
+	if e == nil {
+		return nil
+	}
+	return &Span{TraceID: NewTraceID(), ID: NewSpanID(), Name: name, Kind: kind, Start: time.Now(), exp: e}
+}
+
+// Export queues a finished span. Spans are dropped when the queue is full
+// rather than blocking the caller.
+func (e *Exporter) Export(s Span) {
+	if e == nil {
+		return
+	}
+	if !s.TraceID.IsValid() {
+		s.TraceID = NewTraceID()
+	}
+	if !s.ID.IsValid() {
+		s.ID = NewSpanID()
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return
+	}
+	select {
+	case e.queue <- s:
+	default:
+		e.dropped++
+	}
+}
+
+// Close sends the queued spans and stops the exporter.
+func (e *Exporter) Close() {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return
+	}
+	e.closed = true
+	close(e.queue)
+	dropped := e.dropped
+	e.mu.Unlock()
+
+	<-e.done
+	if dropped > 0 {
+		log.Printf("[WARN] otlp: dropped %d spans, export queue full", dropped)
+	}
+}
+
+func (e *Exporter) loop() {
+	defer close(e.done)
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var batch []Span
+	flush := func() {
+		if len(batch) > 0 {
+			e.send(batch)
+			batch = nil
+		}
+	}
+	for {
+		select {
+		case s, ok := <-e.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, s)
+			if len(batch) >= maxBatch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (e *Exporter) send(spans []Span) {
+	body, err := json.Marshal(e.request(spans))
+	if err != nil {
+		log.Printf("[WARN] otlp: %v", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		log.Printf("[WARN] otlp: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		log.Printf("[WARN] otlp: failed to export %d spans: %v", len(spans), err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		log.Printf("[WARN] otlp: failed to export %d spans: %s", len(spans), resp.Status)
+	}
+}
+
+// The types below mirror the JSON mapping of ExportTraceServiceRequest.
+type (
+	exportRequest struct {
+		ResourceSpans []resourceSpans `json:"resourceSpans"`
+	}
+	resourceSpans struct {
+		Resource   resource     `json:"resource"`
+		ScopeSpans []scopeSpans `json:"scopeSpans"`
+	}
+	resource struct {
+		Attributes []keyValue `json:"attributes"`
+	}
+	scopeSpans struct {
+		Scope scope      `json:"scope"`
+		Spans []jsonSpan `json:"spans"`
+	}
+	scope struct {
+		Name    string `json:"name"`
+		Version string `json:"version,omitempty"`
+	}
+	jsonSpan struct {
+		TraceID           string     `json:"traceId"`
+		SpanID            string     `json:"spanId"`
+		ParentSpanID      string     `json:"parentSpanId,omitempty"`
+		Name              string     `json:"name"`
+		Kind              int        `json:"kind"`
+		StartTimeUnixNano string     `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+		Attributes        []keyValue `json:"attributes,omitempty"`
+		Status            *status    `json:"status,omitempty"`
+	}
+	status struct {
+		Code    int    `json:"code"` // 2 = error
+		Message string `json:"message,omitempty"`
+	}
+	keyValue struct {
+		Key   string   `json:"key"`
+		Value anyValue `json:"value"`
+	}
+	anyValue struct {
+		StringValue *string `json:"stringValue,omitempty"`
+		BoolValue   *bool   `json:"boolValue,omitempty"`
+		IntValue    *string `json:"intValue,omitempty"` // int64 travels as a string
+	}
+)
+
+func (e *Exporter) request(spans []Span) exportRequest {
+	out := make([]jsonSpan, 0, len(spans))
+	for _, s := range spans {
+		js := jsonSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.ID.String(),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+			Attributes:        attributes(s.Attrs),
+		}
+		if s.Parent.IsValid() {
+			js.ParentSpanID = s.Parent.String()
+		}
+		if s.Error != "" {
+			js.Status = &status{Code: 2, Message: s.Error}
+		}
+		out = append(out, js)
+	}
+	res := attributes(map[string]interface{}{"service.name": e.service, "service.version": e.version})
+	return exportRequest{ResourceSpans: []resourceSpans{{
+		Resource:   resource{Attributes: res},
+		ScopeSpans: []scopeSpans{{Scope: scope{Name: e.service, Version: e.version}, Spans: out}},
+	}}}
+}
+
+func attributes(attrs map[string]interface{}) []keyValue {
+	out := make([]keyValue, 0, len(attrs))
+	for k, v := range attrs {
+		var av anyValue
+		switch v := v.(type) {
+		case string:
+			av.StringValue = &v
+		case bool:
+			av.BoolValue = &v
+		case int:
+			s := strconv.Itoa(v)
+			av.IntValue = &s
+		case int64:
+			s := strconv.FormatInt(v, 10)
+			av.IntValue = &s
+		default:
+			s := fmt.Sprint(v)
+			av.StringValue = &s
+		}
+		out = append(out, keyValue{Key: k, Value: av})
+	}
+	return out
+}
+
+// NewTraceID returns a random trace ID.
+func NewTraceID() TraceID {
+	var t TraceID
+	rand.Read(t[:])
+	return t
+}
+
+// NewSpanID returns a random span ID.
+func NewSpanID() SpanID {
+	var s SpanID
+	rand.Read(s[:])
+	return s
+}
@@ -0,0 +1,107 @@
+package otlp
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestExport(t *testing.T) {
+	bodies := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer x" {
+			t.Errorf("Authorization = %q", got)
+		}
+		data, _ := io.ReadAll(r.Body)
+		bodies <- data
+	}))
+	defer srv.Close()
+
+	exp := New(srv.URL, map[string]string{"Authorization": "Bearer x"}, "linyaps-manager", "1.0")
+	parent := exp.Start("ExecuteCommand", KindServer)
+	parent.SetAttr("linyaps.operation_id", "op1")
+	parent.SetError(errors.New("boom"))
+	parent.Finish()
+	exp.Export(Span{TraceID: parent.TraceID, Parent: parent.ID, Name: "ll-cli install", Kind: KindInternal,
+		Start: parent.Start, End: parent.End, Attrs: map[string]interface{}{"process.exit_code": 1}})
+	exp.Close()
+
+	var req struct {
+		ResourceSpans []struct {
+			ScopeSpans []struct {
+				Spans []struct {
+					TraceID      string `json:"traceId"`
+					SpanID       string `json:"spanId"`
+					ParentSpanID string `json:"parentSpanId"`
+					Name         string `json:"name"`
+					Attributes   []struct {
+						Key   string
+						Value map[string]interface{}
+					}
+					Status *struct {
+						Code    int
+						Message string
+					}
+				}
+			}
+		}
+	}
+	if err := json.Unmarshal(<-bodies, &req); err != nil {
+		t.Fatal(err)
+	}
+	spans := req.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	if spans[0].Status == nil || spans[0].Status.Code != 2 || spans[0].Status.Message != "boom" {
+		t.Errorf("status = %+v", spans[0].Status)
+	}
+	if a := spans[0].Attributes; len(a) != 1 || a[0].Value["stringValue"] != "op1" {
+		t.Errorf("attributes = %+v", a)
+	}
+	if spans[1].TraceID != spans[0].TraceID || spans[1].ParentSpanID != spans[0].SpanID {
+		t.Errorf("child not linked to parent: %+v", spans)
+	}
+	if a := spans[1].Attributes; len(a) != 1 || a[0].Value["intValue"] != "1" {
+		t.Errorf("attributes = %+v", a)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv(EnvEndpoint, "")
+	t.Setenv(EnvTracesEndpoint, "")
+	if exp, err := FromEnv("svc", "1"); exp != nil || err != nil {
+		t.Fatalf("FromEnv() = %v, %v; want disabled", exp, err)
+	}
+
+	t.Setenv(EnvEndpoint, "http://collector:4318/")
+	t.Setenv(EnvHeaders, "a=1, b = 2")
+	exp, err := FromEnv("svc", "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer exp.Close()
+	if exp.URL() != "http://collector:4318/v1/traces" {
+		t.Errorf("URL = %q", exp.URL())
+	}
+	if exp.headers["b"] != "2" {
+		t.Errorf("headers = %v", exp.headers)
+	}
+
+	t.Setenv(EnvHeaders, "novalue")
+	if _, err := FromEnv("svc", "1"); err == nil {
+		t.Error("invalid headers accepted")
+	}
+}
+
+func TestNilExporter(t *testing.T) {
+	var exp *Exporter
+	s := exp.Start("x", KindServer)
+	s.SetAttr("k", "v")
+	s.Finish()
+	exp.Export(Span{})
+	exp.Close()
+}
@@ -0,0 +1,160 @@
+// Package polkit checks authorizations with the polkit authority on the
+// system bus.
+package polkit
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	authorityName      = "org.freedesktop.PolicyKit1"
+	authorityPath      = "/org/freedesktop/PolicyKit1/Authority"
+	authorityInterface = "org.freedesktop.PolicyKit1.Authority"
+)
+
+// ErrUnavailable is returned when no polkit authority can be reached.
+var ErrUnavailable = errors.New("polkit authority unavailable")
+
+type subject struct {
+	Kind    string
+	Details map[string]dbus.Variant
+}
+
+type authResult struct {
+	IsAuthorized bool
+	IsChallenge  bool
+	Details      map[string]string
+}
+
+// allowUserInteraction is the CheckAuthorization flag letting polkit ask
+// the user through their authentication agent.
+const allowUserInteraction = 1
+
+// CheckSender asks polkit whether the bus peer sender of conn may perform
+// actionID, without interactive authentication.
+func CheckSender(conn *dbus.Conn, sender dbus.Sender, actionID string) (bool, error) {
+	return check(conn, sender, actionID, 0)
+}
+
+// CheckSenderInteractive is CheckSender, but lets polkit ask the user to
+// confirm or authenticate when the action's policy requires it. It blocks
+// until the user answers.
+func CheckSenderInteractive(conn *dbus.Conn, sender dbus.Sender, actionID string) (bool, error) {
+	return check(conn, sender, actionID, allowUserInteraction)
+}
+
+func check(conn *dbus.Conn, sender dbus.Sender, actionID string, flags uint32) (bool, error) {
+	system, err := dbus.SystemBus()
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	subj, err := senderSubject(conn, system, sender)
+	if err != nil {
+		return false, err
+	}
+	var res authResult
+	obj := system.Object(authorityName, authorityPath)
+	err = obj.Call(authorityInterface+".CheckAuthorization", 0, subj, actionID, map[string]string{}, flags, "").Store(&res)
+	if err != nil {
+		var dbusErr dbus.Error
+		if errors.As(err, &dbusErr) && dbusErr.Name == "org.freedesktop.DBus.Error.ServiceUnknown" {
+			return false, fmt.Errorf("%w: %v", ErrUnavailable, err)
+		}
+		return false, fmt.Errorf("polkit check for %s: %w", actionID, err)
+	}
+	return res.IsAuthorized, nil
+}
+
+// senderSubject returns the polkit subject for sender. On the system bus
+// that is its unique name, which polkit resolves itself and which is never
+// reused, so the check cannot be raced by the sender exiting and another
+// process taking over its pid. polkit cannot resolve names on other buses;
+// there the subject is the sender's process, pinned by its start time.
+func senderSubject(conn, system *dbus.Conn, sender dbus.Sender) (subject, error) {
+	onSystem, err := sameBus(conn, system)
+	if err != nil {
+		return subject{}, err
+	}
+	if onSystem {
+		return subject{
+			Kind:    "system-bus-name",
+			Details: map[string]dbus.Variant{"name": dbus.MakeVariant(string(sender))},
+		}, nil
+	}
+	pid, err := SenderPID(conn, sender)
+	if err != nil {
+		return subject{}, err
+	}
+	start, err := processStartTime(pid)
+	if err != nil {
+		return subject{}, err
+	}
+	return subject{
+		Kind: "unix-process",
+		Details: map[string]dbus.Variant{
+			"pid":        dbus.MakeVariant(pid),
+			"start-time": dbus.MakeVariant(start),
+		},
+	}, nil
+}
+
+// sameBus reports whether a and b are connected to the same bus.
+func sameBus(a, b *dbus.Conn) (bool, error) {
+	if a == b {
+		return true, nil
+	}
+	var idA, idB string
+	if err := a.BusObject().Call("org.freedesktop.DBus.GetId", 0).Store(&idA); err != nil {
+		return false, fmt.Errorf("get bus id: %w", err)
+	}
+	if err := b.BusObject().Call("org.freedesktop.DBus.GetId", 0).Store(&idB); err != nil {
+		return false, fmt.Errorf("get system bus id: %w", err)
+	}
+	return idA == idB, nil
+}
+
+// processStartTime returns the start time of pid in clock ticks since
+// boot, the 22nd field of /proc/<pid>/stat.
+func processStartTime(pid uint32) (uint64, error) {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, fmt.Errorf("read start time of %d: %w", pid, err)
+	}
+	// The command name in field 2 may contain spaces; skip past it.
+	i := bytes.LastIndexByte(data, ')')
+	if i < 0 {
+		return 0, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	fields := strings.Fields(string(data[i+1:]))
+	if len(fields) < 20 {
+		return 0, fmt.Errorf("malformed /proc/%d/stat", pid)
+	}
+	return strconv.ParseUint(fields[19], 10, 64)
+}
+
+// SenderPID returns the process ID of a bus peer.
+func SenderPID(conn *dbus.Conn, sender dbus.Sender) (uint32, error) {
+	var pid uint32
+	err := conn.BusObject().Call("org.freedesktop.DBus.GetConnectionUnixProcessID", 0, string(sender)).Store(&pid)
+	if err != nil {
+		return 0, fmt.Errorf("look up pid of %s: %w", sender, err)
+	}
+	return pid, nil
+}
+
+// SenderUID returns the user ID of a bus peer.
+func SenderUID(conn *dbus.Conn, sender dbus.Sender) (uint32, error) {
+	var uid uint32
+	err := conn.BusObject().Call("org.freedesktop.DBus.GetConnectionUnixUser", 0, string(sender)).Store(&uid)
+	if err != nil {
+		return 0, fmt.Errorf("look up uid of %s: %w", sender, err)
+	}
+	return uid, nil
+}
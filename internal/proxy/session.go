@@ -50,6 +50,7 @@ func SpawnSessionProxy(sessionBusAddr string) (string, func(), error) {
 		return "", nil, err
 	}
 
+	socketInfo, _ := os.Stat(proxyPath)
 	cleanup := func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		defer cancel()
@@ -58,7 +59,7 @@ func SpawnSessionProxy(sessionBusAddr string) (string, func(), error) {
 		select {
 		case <-ctx.Done():
 		}
-		_ = os.Remove(proxyPath)
+		removeOwnSocket(proxyPath, socketInfo)
 	}
 	return proxyPath, cleanup, nil
 }
@@ -52,6 +52,7 @@ func SpawnSystemProxy(busAddress string) (string, func(), error) {
 		return "", nil, err
 	}
 
+	socketInfo, _ := os.Stat(proxyPath)
 	cleanup := func() {
 		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
 		defer cancel()
@@ -60,7 +61,7 @@ func SpawnSystemProxy(busAddress string) (string, func(), error) {
 		select {
 		case <-ctx.Done():
 		}
-		_ = os.Remove(proxyPath)
+		removeOwnSocket(proxyPath, socketInfo)
 	}
 	return proxyPath, cleanup, nil
 }
@@ -69,6 +70,18 @@ func defaultProxyPath() string {
 	return filepath.Join(runtimeBase(), defaultProxyName)
 }
 
+// removeOwnSocket removes the socket at p only if it is still the one we created.
+// A replacement instance (see --takeover) may have bound a new socket at the
+// same path, which must survive our shutdown.
+func removeOwnSocket(p string, created os.FileInfo) {
+	if created == nil {
+		return
+	}
+	if cur, err := os.Stat(p); err == nil && os.SameFile(created, cur) {
+		_ = os.Remove(p)
+	}
+}
+
 func waitForSocket(p string, timeout time.Duration) error {
 	deadline := time.Now().Add(timeout)
 	for time.Now().Before(deadline) {
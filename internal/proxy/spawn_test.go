@@ -0,0 +1,50 @@
+package proxy
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemoveOwnSocketAfterTakeover(t *testing.T) {
+	p := filepath.Join(t.TempDir(), defaultProxyName)
+
+	// The running instance's proxy listens at p.
+	old, err := net.ListenUnix("unix", &net.UnixAddr{Name: p, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	old.SetUnlinkOnClose(false) // xdg-dbus-proxy is killed, not closed
+	oldInfo, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The new instance replaces the socket while the old proxy still runs.
+	if err := os.Remove(p); err != nil {
+		t.Fatal(err)
+	}
+	cur, err := net.ListenUnix("unix", &net.UnixAddr{Name: p, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur.SetUnlinkOnClose(false)
+	defer cur.Close()
+	curInfo, err := os.Stat(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The old instance exits once drained.
+	old.Close()
+	removeOwnSocket(p, oldInfo)
+	if info, err := os.Stat(p); err != nil || !os.SameFile(info, curInfo) {
+		t.Fatalf("old instance removed the new socket: %v", err)
+	}
+
+	removeOwnSocket(p, curInfo)
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Errorf("own socket not removed: %v", err)
+	}
+}
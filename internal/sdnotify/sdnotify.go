@@ -0,0 +1,33 @@
+// Package sdnotify implements the client side of the systemd service
+// notification protocol (sd_notify(3)) without linking libsystemd.
+package sdnotify
+
+import (
+	"net"
+	"os"
+)
+
+// Notify sends state (e.g. "READY=1" or "STATUS=...") to the service manager.
+// It returns false without error when not running under a manager that
+// listens for notifications.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// Abstract namespace sockets are announced with a leading '@'.
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,251 @@
+// Package selfupdate checks for, verifies and stages new manager releases.
+//
+// Releases are described by a JSON manifest published at a configured URL:
+//
+//	{
+//	  "version": "1.4.0",
+//	  "binaries": {
+//	    "linux-amd64": {"url": "...", "sha256": "<hex>", "signature": "<base64>"}
+//	  }
+//	}
+//
+// The signature is an ed25519 signature of SignedMessage for the release,
+// which binds the version and platform to the SHA-256 digest of the binary,
+// made with the key whose public half is configured on the device. A signed
+// older release therefore cannot be passed off as a newer one.
+// Self-update stays disabled unless both the URL and the key are configured.
+package selfupdate
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// Environment variables configuring self-update.
+const (
+	EnvURL       = "LINYAPS_UPDATE_URL"    // manifest URL
+	EnvPublicKey = "LINYAPS_UPDATE_PUBKEY" // base64 ed25519 public key
+)
+
+// maxBinarySize bounds downloads so a broken mirror cannot fill the disk.
+const maxBinarySize = 256 << 20
+
+// ErrDisabled is returned when self-update is not configured.
+var ErrDisabled = errors.New("self-update is not configured")
+
+// Config holds the update source and the key releases must be signed with.
+type Config struct {
+	URL       string
+	PublicKey ed25519.PublicKey
+}
+
+// ConfigFromEnv reads the configuration from the environment.
+// It returns ErrDisabled when either setting is missing.
+func ConfigFromEnv() (Config, error) {
+	url := os.Getenv(EnvURL)
+	key := os.Getenv(EnvPublicKey)
+	if url == "" || key == "" {
+		return Config{}, ErrDisabled
+	}
+	raw, err := base64.StdEncoding.DecodeString(key)
+	if err != nil || len(raw) != ed25519.PublicKeySize {
+		return Config{}, fmt.Errorf("%s is not a base64 ed25519 public key", EnvPublicKey)
+	}
+	return Config{URL: url, PublicKey: ed25519.PublicKey(raw)}, nil
+}
+
+// Release is the artifact published for this platform.
+type Release struct {
+	Version   string `json:"-"`
+	Platform  string `json:"-"`
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256"`
+	Signature string `json:"signature"`
+}
+
+type manifest struct {
+	Version  string             `json:"version"`
+	Binaries map[string]Release `json:"binaries"`
+}
+
+// Platform returns the manifest key for the running binary, e.g. linux-amd64.
+func Platform() string {
+	return runtime.GOOS + "-" + runtime.GOARCH
+}
+
+// Check fetches the manifest and returns the release for this platform.
+func Check(ctx context.Context, client *http.Client, cfg Config) (Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, cfg.URL, nil)
+	if err != nil {
+		return Release{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Release{}, fmt.Errorf("fetch manifest: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Release{}, fmt.Errorf("fetch manifest: %s", resp.Status)
+	}
+
+	var m manifest
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&m); err != nil {
+		return Release{}, fmt.Errorf("parse manifest: %w", err)
+	}
+	rel, ok := m.Binaries[Platform()]
+	if !ok {
+		return Release{}, fmt.Errorf("manifest has no binary for %s", Platform())
+	}
+	rel.Version, rel.Platform = m.Version, Platform()
+	return rel, nil
+}
+
+// Stage downloads rel next to exePath, verifies its digest and signature and
+// atomically replaces exePath with it. Releases not newer than running are
+// refused. The running process keeps executing the old image; the caller is
+// responsible for starting the new one.
+func Stage(ctx context.Context, client *http.Client, cfg Config, rel Release, exePath, running string) error {
+	if !Newer(rel.Version, running) {
+		return fmt.Errorf("release %s is not newer than the running %s", rel.Version, running)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rel.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("download: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download: %s", resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(exePath), "."+filepath.Base(exePath)+".new-*")
+	if err != nil {
+		return fmt.Errorf("stage: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	h := sha256.New()
+	n, err := io.Copy(io.MultiWriter(tmp, h), io.LimitReader(resp.Body, maxBinarySize+1))
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("download: %w", err)
+	}
+	if n > maxBinarySize {
+		return fmt.Errorf("download exceeds %d bytes", maxBinarySize)
+	}
+
+	if err := Verify(cfg.PublicKey, h.Sum(nil), rel); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return fmt.Errorf("stage: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), exePath); err != nil {
+		return fmt.Errorf("stage: %w", err)
+	}
+	return nil
+}
+
+// SignedMessage is what the signature of a release covers:
+//
+//	linyaps-manager-release
+//	version <version>
+//	platform <os-arch>
+//	sha256 <lowercase hex digest>
+//
+// each line ending in a newline.
+func SignedMessage(version, platform, sha256Hex string) []byte {
+	return []byte(fmt.Sprintf("linyaps-manager-release\nversion %s\nplatform %s\nsha256 %s\n",
+		version, platform, strings.ToLower(sha256Hex)))
+}
+
+// Verify checks that digest matches the published SHA-256 and that pub
+// signed it together with the version and platform of rel.
+func Verify(pub ed25519.PublicKey, digest []byte, rel Release) error {
+	want, err := hex.DecodeString(rel.SHA256)
+	if err != nil || len(want) != sha256.Size {
+		return fmt.Errorf("manifest sha256 %q is invalid", rel.SHA256)
+	}
+	if !bytes.Equal(digest, want) {
+		return fmt.Errorf("sha256 mismatch: got %x, want %s", digest, rel.SHA256)
+	}
+	if _, ok := parseVersion(rel.Version); !ok || strings.ContainsAny(rel.Version, " \t\n") {
+		return fmt.Errorf("manifest version %q is invalid", rel.Version)
+	}
+	if rel.Platform != Platform() {
+		return fmt.Errorf("release is for %q, not %s", rel.Platform, Platform())
+	}
+	sig, err := base64.StdEncoding.DecodeString(rel.Signature)
+	if err != nil {
+		return fmt.Errorf("signature is not valid base64: %w", err)
+	}
+	if !ed25519.Verify(pub, SignedMessage(rel.Version, rel.Platform, rel.SHA256), sig) {
+		return errors.New("signature verification failed")
+	}
+	return nil
+}
+
+// Newer reports whether version a is newer than b. Versions are compared as
+// dot-separated numbers; a leading "v" and any "-suffix" are ignored.
+// Non-release builds ("dev") are always considered older.
+func Newer(a, b string) bool {
+	pa, okA := parseVersion(a)
+	pb, okB := parseVersion(b)
+	if !okA {
+		return false
+	}
+	if !okB {
+		return true
+	}
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		var x, y int
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+		if x != y {
+			return x > y
+		}
+	}
+	return false
+}
+
+func parseVersion(v string) ([]int, bool) {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+~"); i >= 0 {
+		v = v[:i]
+	}
+	if v == "" {
+		return nil, false
+	}
+	var parts []int
+	for _, p := range strings.Split(v, ".") {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
@@ -0,0 +1,97 @@
+package selfupdate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"1.2.0", "1.1.9", true},
+		{"v1.10", "1.9.5", true},
+		{"1.2", "1.2.0", false},
+		{"1.2.0-rc1", "1.2.0", false},
+		{"1.0.0", "dev", true},
+		{"dev", "1.0.0", false},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.a, tt.b); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCheckAndStage(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	binary := []byte("#!/bin/sh\necho new\n")
+	digest := sha256.Sum256(binary)
+	sum := hex.EncodeToString(digest[:])
+	sign := func(version string) string {
+		return base64.StdEncoding.EncodeToString(ed25519.Sign(priv, SignedMessage(version, Platform(), sum)))
+	}
+	manifestVersion, sig := "2.0.0", sign("2.0.0")
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+	mux.HandleFunc("/manifest.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"version":%q,"binaries":{%q:{"url":%q,"sha256":%q,"signature":%q}}}`,
+			manifestVersion, Platform(), srv.URL+"/bin", sum, sig)
+	})
+	mux.HandleFunc("/bin", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(binary)
+	})
+
+	cfg := Config{URL: srv.URL + "/manifest.json", PublicKey: pub}
+	rel, err := Check(context.Background(), srv.Client(), cfg)
+	if err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if rel.Version != "2.0.0" {
+		t.Errorf("Version = %q, want 2.0.0", rel.Version)
+	}
+
+	exe := filepath.Join(t.TempDir(), "linyaps-dbus-server")
+	if err := os.WriteFile(exe, []byte("old"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := Stage(context.Background(), srv.Client(), cfg, rel, exe, "2.0.0"); err == nil {
+		t.Error("Stage accepted a release that is not newer")
+	}
+	if err := Stage(context.Background(), srv.Client(), cfg, rel, exe, "1.0.0"); err != nil {
+		t.Fatalf("Stage: %v", err)
+	}
+	if got, _ := os.ReadFile(exe); string(got) != string(binary) {
+		t.Errorf("staged binary = %q", got)
+	}
+
+	// A release signed by another key must be rejected and leave the binary alone.
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+	if err := Stage(context.Background(), srv.Client(), Config{PublicKey: otherPub}, rel, exe, "1.0.0"); err == nil {
+		t.Error("Stage accepted a release signed with the wrong key")
+	}
+
+	// The signature of an old release must not pass for a newer version.
+	manifestVersion, sig = "3.0.0", sign("1.5.0")
+	if rel, err = Check(context.Background(), srv.Client(), cfg); err != nil {
+		t.Fatalf("Check: %v", err)
+	}
+	if err := Stage(context.Background(), srv.Client(), cfg, rel, exe, "2.0.0"); err == nil {
+		t.Error("Stage accepted a signature made for another version")
+	}
+}
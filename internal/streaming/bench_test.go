@@ -0,0 +1,151 @@
+package streaming
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// loopbackSender marshals each signal the way dbus.Conn.Emit does and
+// discards the bytes, so benchmarks include encoding cost but no bus.
+type loopbackSender struct {
+	sent atomic.Uint64
+	// onSend, if set, is called with the signal body after encoding.
+	onSend func(values []interface{})
+}
+
+func (l *loopbackSender) send(path dbus.ObjectPath, name string, values ...interface{}) error {
+	i := strings.LastIndex(name, ".")
+	msg := &dbus.Message{
+		Type: dbus.TypeSignal,
+		Headers: map[dbus.HeaderField]dbus.Variant{
+			dbus.FieldPath:      dbus.MakeVariant(path),
+			dbus.FieldInterface: dbus.MakeVariant(name[:i]),
+			dbus.FieldMember:    dbus.MakeVariant(name[i+1:]),
+		},
+		Body: values,
+	}
+	if len(values) > 0 {
+		msg.Headers[dbus.FieldSignature] = dbus.MakeVariant(dbus.SignatureOf(values...))
+	}
+	if err := msg.EncodeTo(io.Discard, binary.LittleEndian); err != nil {
+		return err
+	}
+	l.sent.Add(1)
+	if l.onSend != nil {
+		l.onSend(values)
+	}
+	return nil
+}
+
+// discardSink counts output without doing anything else.
+type discardSink struct{ lines atomic.Uint64 }
+
+func (d *discardSink) EmitOutput(string, string, bool) error { d.lines.Add(1); return nil }
+func (d *discardSink) EmitComplete(string, int, string, map[string]interface{}) error {
+	return nil
+}
+
+// progressLines returns n lines resembling ll-cli download progress.
+func progressLines(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "Downloading org.example.app/1.0.%d [%-20s] %d%%\r", i, strings.Repeat("=", i%20), i%100)
+	}
+	return b.String()
+}
+
+func reportLinesPerSec(b *testing.B, lines int) {
+	b.ReportMetric(float64(lines)/b.Elapsed().Seconds(), "lines/s")
+}
+
+// BenchmarkStreamReader measures splitting child output into chunks.
+func BenchmarkStreamReader(b *testing.B) {
+	const linesPerOp = 1000
+	input := progressLines(linesPerOp)
+	sink := &discardSink{}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		streamReader(sink, "op", strings.NewReader(input), false)
+	}
+	b.StopTimer()
+	reportLinesPerSec(b, b.N*linesPerOp)
+}
+
+// BenchmarkEmitterOutput measures queueing and encoding Output signals.
+func BenchmarkEmitterOutput(b *testing.B) {
+	lb := &loopbackSender{}
+	e := newEmitter(lb.send, defaultEmitQueueSize)
+	line := "Downloading org.example.app/1.0.0 [==========          ] 50%\n"
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := e.EmitOutput("op", line, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+	e.Close()
+	b.StopTimer()
+
+	st := e.Stats()
+	b.ReportMetric(float64(st.Dropped)/float64(b.N), "dropped/op")
+	reportLinesPerSec(b, int(lb.sent.Load()))
+}
+
+// BenchmarkPipelineLatency pushes timestamped lines through a pipe, the
+// reader and the emitter, and reports the mean time from write to encode.
+func BenchmarkPipelineLatency(b *testing.B) {
+	var (
+		mu    sync.Mutex
+		total time.Duration
+		count int
+	)
+	lb := &loopbackSender{onSend: func(values []interface{}) {
+		data, ok := values[1].(string)
+		if !ok {
+			return // Complete
+		}
+		ns, err := strconv.ParseInt(strings.TrimSpace(data), 10, 64)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		total += time.Duration(time.Now().UnixNano() - ns)
+		count++
+		mu.Unlock()
+	}}
+	e := newEmitter(lb.send, defaultEmitQueueSize)
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		streamReader(e, "op", pr, false)
+		close(done)
+	}()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		fmt.Fprintf(pw, "%d\n", time.Now().UnixNano())
+	}
+	pw.Close()
+	<-done
+	e.Close()
+	b.StopTimer()
+
+	if count > 0 {
+		b.ReportMetric(float64(total.Nanoseconds())/float64(count), "ns-latency/line")
+	}
+	reportLinesPerSec(b, count)
+}
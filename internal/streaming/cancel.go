@@ -0,0 +1,89 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ErrCancelled is the cause of contexts cancelled through Registry.Cancel and
+// the error message of the Complete signal of cancelled operations.
+var ErrCancelled = errors.New("operation cancelled")
+
+// cancelGrace is how long a cancelled child may take to exit after SIGTERM
+// before it is killed.
+var cancelGrace = 5 * time.Second
+
+// cancelGracefully runs cmd in its own process group and makes the group
+// receive SIGTERM when the operation is cancelled, so ll-cli and its helpers
+// can clean up, followed by SIGKILL after cancelGrace. When ctx ends for any
+// other reason, such as its deadline, the group is killed at once. The
+// returned func must be called once cmd.Wait has returned.
+func cancelGracefully(ctx context.Context, cmd *exec.Cmd) (stop func()) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	var mu sync.Mutex
+	var escalate *time.Timer
+	done := false
+	killGroup := func(sig syscall.Signal) error {
+		if err := syscall.Kill(-cmd.Process.Pid, sig); err != nil && err != syscall.ESRCH {
+			return err
+		}
+		return nil
+	}
+	cmd.Cancel = func() error {
+		if !errors.Is(context.Cause(ctx), ErrCancelled) {
+			return killGroup(syscall.SIGKILL)
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if done {
+			return nil
+		}
+		escalate = time.AfterFunc(cancelGrace, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if !done {
+				killGroup(syscall.SIGKILL)
+			}
+		})
+		return killGroup(syscall.SIGTERM)
+	}
+	return func() {
+		mu.Lock()
+		done = true
+		if escalate != nil {
+			escalate.Stop()
+		}
+		mu.Unlock()
+	}
+}
+
+// Cancel aborts a running operation: its child is terminated, or the context
+// of its task cancelled, and it completes with exit code -1, ErrCancelled as
+// the error and details cancelled=true.
+func (r *Registry) Cancel(id string) error {
+	r.mu.Lock()
+	op, ok := r.ops[id]
+	switch {
+	case !ok:
+		r.mu.Unlock()
+		return fmt.Errorf("unknown operation %q", id)
+	case op.State != StateRunning:
+		r.mu.Unlock()
+		return fmt.Errorf("operation %s already %s", id, op.State)
+	case op.cancel == nil:
+		r.mu.Unlock()
+		return fmt.Errorf("operation %s cannot be cancelled", id)
+	}
+	op.cancelled = true
+	cancel := op.cancel
+	r.mu.Unlock()
+
+	cancel(ErrCancelled)
+	return nil
+}
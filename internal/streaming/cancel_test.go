@@ -0,0 +1,98 @@
+package streaming
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// detailsSink records the Complete of one operation.
+type detailsSink struct {
+	OutputSink
+	done chan map[string]interface{}
+	code chan int
+}
+
+func newDetailsSink() *detailsSink {
+	return &detailsSink{OutputSink: MultiSink{}, done: make(chan map[string]interface{}, 1), code: make(chan int, 1)}
+}
+
+func (s *detailsSink) EmitComplete(_ string, exitCode int, _ string, details map[string]interface{}) error {
+	s.code <- exitCode
+	s.done <- details
+	return nil
+}
+
+func (s *detailsSink) wait(t *testing.T, within time.Duration) (int, map[string]interface{}) {
+	t.Helper()
+	select {
+	case code := <-s.code:
+		return code, <-s.done
+	case <-time.After(within):
+		t.Fatal("operation did not complete")
+		return 0, nil
+	}
+}
+
+func TestCancelCommand(t *testing.T) {
+	sink := newDetailsSink()
+	opID, err := RunCommandStreaming(context.Background(), sink, nil, "/bin/sh", "-c", "sleep 30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := DefaultRegistry.Cancel(opID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	code, details := sink.wait(t, 3*time.Second)
+	if code != -1 || details[DetailCancelled] != true {
+		t.Errorf("exit = %d details = %v, want -1 and cancelled", code, details)
+	}
+	op, _ := DefaultRegistry.Lookup(opID)
+	if op.State != StateCancelled || op.ErrorMsg != ErrCancelled.Error() {
+		t.Errorf("state = %s error = %q", op.State, op.ErrorMsg)
+	}
+	if err := DefaultRegistry.Cancel(opID); err == nil {
+		t.Error("cancelling a finished operation succeeded")
+	}
+}
+
+func TestCancelKillsAfterGrace(t *testing.T) {
+	old := cancelGrace
+	cancelGrace = 200 * time.Millisecond
+	defer func() { cancelGrace = old }()
+
+	sink := newDetailsSink()
+	script := `trap "" TERM; echo ready; while :; do sleep 0.05; done`
+	opID, err := RunCommandStreaming(context.Background(), sink, nil, "/bin/sh", "-c", script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(100 * time.Millisecond) // let the trap be installed
+	if err := DefaultRegistry.Cancel(opID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if code, _ := sink.wait(t, 3*time.Second); code != -1 {
+		t.Errorf("exit = %d, want -1", code)
+	}
+}
+
+func TestCancelTask(t *testing.T) {
+	sink := newDetailsSink()
+	opID := RunTask(context.Background(), sink, "wait", func(ctx context.Context, out func(string, bool)) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+	if err := DefaultRegistry.Cancel(opID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	code, details := sink.wait(t, 3*time.Second)
+	if code != -1 || details[DetailCancelled] != true {
+		t.Errorf("exit = %d details = %v, want -1 and cancelled", code, details)
+	}
+}
+
+func TestCancelUnknown(t *testing.T) {
+	if err := DefaultRegistry.Cancel("op-does-not-exist"); err == nil {
+		t.Error("cancelling an unknown operation succeeded")
+	}
+}
@@ -0,0 +1,68 @@
+package streaming
+
+import (
+	"bytes"
+	"io"
+	"unicode/utf8"
+)
+
+// maxChunkSize bounds the memory held per output stream and the size of a
+// single Output signal.
+const maxChunkSize = 64 * 1024
+
+// readChunks splits r into lines and calls emit for each one, reading until
+// EOF or a read error. Like the terminal, both \n and \r end a line: progress
+// bars redraw with \r and every redraw becomes its own line. Emitted lines
+// end in "\n".
+//
+// A line longer than max is emitted as several chunks; every chunk except the
+// last has no trailing "\n", marking it as continued in the next chunk.
+// Chunks are cut on UTF-8 rune boundaries where possible.
+func readChunks(r io.Reader, max int, emit func(data string)) {
+	// One spare byte lets a line of exactly max bytes keep its terminator
+	buf := make([]byte, max+1)
+	n := 0
+	for {
+		m, err := r.Read(buf[n:])
+		n += m
+
+		// Emit every complete line in the buffer
+		start := 0
+		for {
+			i := bytes.IndexAny(buf[start:n], "\r\n")
+			if i < 0 {
+				break
+			}
+			emit(string(buf[start:start+i]) + "\n")
+			start += i + 1
+		}
+		n = copy(buf, buf[start:n])
+
+		if n > max {
+			cut := runeBoundary(buf[:max])
+			emit(string(buf[:cut]))
+			n = copy(buf, buf[cut:n])
+		}
+
+		if err != nil {
+			if n > 0 {
+				emit(string(buf[:n]) + "\n")
+			}
+			return
+		}
+	}
+}
+
+// runeBoundary returns the length of the longest prefix of b that does not
+// end in a truncated UTF-8 sequence. Invalid input is cut at len(b).
+func runeBoundary(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if utf8.FullRune(b[i:]) || i == 0 {
+				return len(b)
+			}
+			return i
+		}
+	}
+	return len(b)
+}
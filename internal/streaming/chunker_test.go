@@ -0,0 +1,82 @@
+package streaming
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf8"
+)
+
+func collectChunks(input string, max int) []string {
+	var chunks []string
+	// OneByteReader exercises lines split across many reads
+	readChunks(iotest.OneByteReader(strings.NewReader(input)), max, func(data string) {
+		chunks = append(chunks, data)
+	})
+	return chunks
+}
+
+func TestReadChunksLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{"empty", "", nil},
+		{"lines", "a\nb\n", []string{"a\n", "b\n"}},
+		{"no trailing newline", "a\nb", []string{"a\n", "b\n"}},
+		{"carriage returns", "10%\r50%\r100%\n", []string{"10%\n", "50%\n", "100%\n"}},
+		{"exactly max", "abcd\n", []string{"abcd\n"}},
+		{"oversized line", "abcdefghij\nk\n", []string{"abcdefgh", "ij\n", "k\n"}},
+		{"oversized without newline", strings.Repeat("x", 17), []string{"xxxxxxxx", "xxxxxxxx", "x\n"}},
+		{"exact multiple", strings.Repeat("y", 16) + "\n", []string{"yyyyyyyy", "yyyyyyyy\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			max := 8
+			if tt.name == "exactly max" {
+				max = 4
+			}
+			if got := collectChunks(tt.input, max); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("chunks = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadChunksRuneBoundary(t *testing.T) {
+	// 7 ASCII bytes followed by a 3-byte rune: the rune must not be split
+	input := "abcdefg中文\n"
+	chunks := collectChunks(input, 8)
+	want := []string{"abcdefg", "中文\n"}
+	if !reflect.DeepEqual(chunks, want) {
+		t.Fatalf("chunks = %q, want %q", chunks, want)
+	}
+	for _, c := range chunks {
+		if !utf8.ValidString(c) {
+			t.Errorf("chunk %q is not valid UTF-8", c)
+		}
+	}
+}
+
+func TestReadChunksHugeLine(t *testing.T) {
+	// A line far above the old 1MB scanner limit must come through intact
+	line := strings.Repeat("0123456789", 300*1024)
+	var total, count int
+	var joined strings.Builder
+	readChunks(strings.NewReader(line+"\nafter\n"), maxChunkSize, func(data string) {
+		if len(data) > maxChunkSize+1 {
+			t.Fatalf("chunk of %d bytes exceeds max", len(data))
+		}
+		total += len(data)
+		count++
+		joined.WriteString(data)
+	})
+	if joined.String() != line+"\nafter\n" {
+		t.Fatalf("reassembled output differs (got %d bytes)", total)
+	}
+	if count < len(line)/maxChunkSize {
+		t.Errorf("got %d chunks, expected the line to be split", count)
+	}
+}
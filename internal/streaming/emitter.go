@@ -0,0 +1,280 @@
+package streaming
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusconsts"
+)
+
+// defaultEmitQueueSize bounds the number of signals waiting for the bus writer.
+const defaultEmitQueueSize = 4096
+
+// ErrEmitterClosed is returned when emitting on an emitter that has been closed.
+var ErrEmitterClosed = errors.New("emitter closed")
+
+// signalSender matches dbus.Conn.Emit so the queue can be driven without a bus.
+type signalSender func(path dbus.ObjectPath, name string, values ...interface{}) error
+
+// emitItem is a signal waiting in the queue.
+type emitItem struct {
+	member    string
+	values    []interface{}
+	droppable bool
+	enqueued  time.Time
+}
+
+// EmitStats is a snapshot of the emitter queue metrics.
+type EmitStats struct {
+	Emitted    uint64        // signals written to the bus
+	Failed     uint64        // signals the bus refused
+	Dropped    uint64        // Output signals discarded because the queue was full
+	Queued     int           // signals currently waiting
+	AvgLatency time.Duration // mean time from enqueue to bus write
+	MaxLatency time.Duration // worst time from enqueue to bus write
+}
+
+// Emitter wraps a D-Bus connection for emitting streaming signals.
+//
+// Signals are queued and written by a dedicated goroutine so a slow bus never
+// stalls the goroutines reading child output. When the queue is full the
+// oldest Output signal is dropped; Complete signals are never dropped.
+type Emitter struct {
+	send     signalSender
+	maxQueue int
+
+	mu     sync.Mutex
+	cond   *sync.Cond
+	queue  []emitItem
+	seqs   map[string]uint64 // last Output sequence number per operation
+	closed bool
+	done   chan struct{}
+
+	replay     map[string]*replayBuffer // recent output per operation
+	replayDone []string                 // finished operations in replay, oldest first
+
+	progress        map[string]Progress // last progress per operation
+	steps           map[string]step     // set by ScaleProgress
+	progressWatches []func(operationID string, p Progress)
+
+	emitted      uint64
+	failed       uint64
+	dropped      uint64
+	totalLatency time.Duration
+	maxLatency   time.Duration
+}
+
+// NewEmitter creates a new signal emitter and starts its writer goroutine.
+func NewEmitter(conn *dbus.Conn) *Emitter {
+	return newEmitter(conn.Emit, defaultEmitQueueSize)
+}
+
+func newEmitter(send signalSender, maxQueue int) *Emitter {
+	e := &Emitter{
+		send:     send,
+		maxQueue: maxQueue,
+		seqs:     make(map[string]uint64),
+		replay:   make(map[string]*replayBuffer),
+		progress: make(map[string]Progress),
+		steps:    make(map[string]step),
+		done:     make(chan struct{}),
+	}
+	e.cond = sync.NewCond(&e.mu)
+	go e.writeLoop()
+	return e
+}
+
+// EmitOutput queues an Output signal with command output data.
+// Each Output of an operation carries a sequence number starting at 1,
+// assigned in queue order. A line recognised by ParseProgress is followed
+// by a Progress signal, unless it repeats the previous report.
+func (e *Emitter) EmitOutput(operationID, data string, isStderr bool) error {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		return ErrEmitterClosed
+	}
+	e.seqs[operationID]++
+	seq := e.seqs[operationID]
+	e.recordLocked(operationID, Chunk{Data: data, IsStderr: isStderr, Seq: seq})
+	e.enqueueLocked(dbusconsts.SignalOutput, true, operationID, data, isStderr, seq)
+
+	p, ok := ParseProgress(data)
+	if s, scaled := e.steps[operationID]; ok && scaled {
+		p.Percent = (float64(s.n)*100 + p.Percent) / float64(s.of)
+	}
+	if ok && p != e.progress[operationID] {
+		e.progress[operationID] = p
+		e.enqueueLocked(dbusconsts.SignalProgress, true, operationID, p.Percent, p.BytesPerSec, p.Phase)
+	} else {
+		ok = false
+	}
+	watches := e.progressWatches
+	e.mu.Unlock()
+
+	if ok {
+		for _, fn := range watches {
+			fn(operationID, p)
+		}
+	}
+	return nil
+}
+
+// step is the part of an operation running one of several commands.
+type step struct{ n, of int }
+
+// ScaleProgress makes the progress parsed from the output of operationID
+// count as step n, from 0, of steps equal ones, for operations that run
+// several commands in turn: 50% during step 1 of 4 is reported as 37.5%.
+// It lasts until the operation completes.
+func (e *Emitter) ScaleProgress(operationID string, n, steps int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if steps > 0 && n >= 0 && n < steps {
+		e.steps[operationID] = step{n, steps}
+	}
+}
+
+// WatchProgress registers fn to be called with each new progress report,
+// outside the emitter lock.
+func (e *Emitter) WatchProgress(fn func(operationID string, p Progress)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.progressWatches = append(e.progressWatches, fn)
+}
+
+// EmitQueued queues a Queued signal reporting the position of an operation
+// waiting for conflicting operations to finish; 0 means it is starting.
+func (e *Emitter) EmitQueued(operationID string, position int) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return ErrEmitterClosed
+	}
+	e.enqueueLocked(dbusconsts.SignalQueued, true, operationID, uint32(position))
+	return nil
+}
+
+// EmitComplete queues a Complete signal when operation finishes.
+// The signal carries the sequence number of the last Output so receivers
+// can tell whether trailing output is still in flight, followed by the
+// details dictionary (a{sv}).
+func (e *Emitter) EmitComplete(operationID string, exitCode int, errorMsg string, details map[string]interface{}) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return ErrEmitterClosed
+	}
+	finalSeq := e.seqs[operationID]
+	delete(e.seqs, operationID)
+	delete(e.progress, operationID)
+	delete(e.steps, operationID)
+	e.completeLocked(operationID)
+	if details == nil {
+		details = map[string]interface{}{}
+	}
+	e.enqueueLocked(dbusconsts.SignalComplete, false, operationID, exitCode, errorMsg, finalSeq, details)
+	return nil
+}
+
+func (e *Emitter) enqueueLocked(member string, droppable bool, values ...interface{}) {
+	if len(e.queue) >= e.maxQueue && droppable {
+		e.dropOldestLocked()
+	}
+	e.queue = append(e.queue, emitItem{
+		member:    member,
+		values:    values,
+		droppable: droppable,
+		enqueued:  time.Now(),
+	})
+	e.cond.Signal()
+}
+
+// dropOldestLocked discards the oldest droppable signal. If only Complete
+// signals are queued the queue is allowed to grow past its limit.
+func (e *Emitter) dropOldestLocked() {
+	for i, item := range e.queue {
+		if !item.droppable {
+			continue
+		}
+		e.queue = append(e.queue[:i], e.queue[i+1:]...)
+		e.dropped++
+		if e.dropped == 1 || e.dropped%1000 == 0 {
+			log.Printf("[streaming] emit queue full, dropped %d output signals so far", e.dropped)
+		}
+		return
+	}
+}
+
+func (e *Emitter) writeLoop() {
+	defer close(e.done)
+	for {
+		e.mu.Lock()
+		for len(e.queue) == 0 && !e.closed {
+			e.cond.Wait()
+		}
+		if len(e.queue) == 0 {
+			e.mu.Unlock()
+			return
+		}
+		item := e.queue[0]
+		e.queue[0] = emitItem{}
+		e.queue = e.queue[1:]
+		e.mu.Unlock()
+
+		err := e.send(
+			dbus.ObjectPath(dbusconsts.ObjectPath),
+			dbusconsts.Interface+"."+item.member,
+			item.values...,
+		)
+		latency := time.Since(item.enqueued)
+
+		e.mu.Lock()
+		if err != nil {
+			e.failed++
+			log.Printf("[streaming] failed to emit %s: %v", item.member, err)
+		} else {
+			e.emitted++
+		}
+		e.totalLatency += latency
+		if latency > e.maxLatency {
+			e.maxLatency = latency
+		}
+		e.mu.Unlock()
+	}
+}
+
+// Stats returns a snapshot of the queue metrics.
+func (e *Emitter) Stats() EmitStats {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	s := EmitStats{
+		Emitted:    e.emitted,
+		Failed:     e.failed,
+		Dropped:    e.dropped,
+		Queued:     len(e.queue),
+		MaxLatency: e.maxLatency,
+	}
+	if n := e.emitted + e.failed; n > 0 {
+		s.AvgLatency = e.totalLatency / time.Duration(n)
+	}
+	return s
+}
+
+// Close stops accepting new signals and waits for queued ones to be written.
+func (e *Emitter) Close() {
+	e.mu.Lock()
+	if !e.closed {
+		e.closed = true
+		e.cond.Broadcast()
+	}
+	e.mu.Unlock()
+	<-e.done
+}
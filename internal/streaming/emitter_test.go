@@ -0,0 +1,214 @@
+package streaming
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"linyapsmanager/internal/dbusconsts"
+)
+
+// recordingSender collects emitted signals; it blocks until release is closed.
+type recordingSender struct {
+	mu      sync.Mutex
+	names   []string
+	bodies  [][]interface{}
+	release chan struct{}
+}
+
+func (r *recordingSender) send(path dbus.ObjectPath, name string, values ...interface{}) error {
+	if r.release != nil {
+		<-r.release
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.names = append(r.names, name)
+	r.bodies = append(r.bodies, values)
+	return nil
+}
+
+func TestEmitterPreservesOrder(t *testing.T) {
+	rec := &recordingSender{}
+	e := newEmitter(rec.send, 16)
+
+	for _, line := range []string{"a\n", "b\n", "c\n"} {
+		if err := e.EmitOutput("op", line, false); err != nil {
+			t.Fatalf("EmitOutput: %v", err)
+		}
+	}
+	if err := e.EmitComplete("op", 0, "", nil); err != nil {
+		t.Fatalf("EmitComplete: %v", err)
+	}
+	e.Close()
+
+	if len(rec.bodies) != 4 {
+		t.Fatalf("got %d signals, want 4", len(rec.bodies))
+	}
+	for i, want := range []string{"a\n", "b\n", "c\n"} {
+		if got := rec.bodies[i][1]; got != want {
+			t.Errorf("signal %d data = %v, want %q", i, got, want)
+		}
+	}
+	if st := e.Stats(); st.Emitted != 4 || st.Dropped != 0 {
+		t.Errorf("stats = %+v, want 4 emitted and none dropped", st)
+	}
+}
+
+func TestEmitterDropsOldestOutput(t *testing.T) {
+	rec := &recordingSender{release: make(chan struct{})}
+	e := newEmitter(rec.send, 2)
+
+	// The writer picks up the first signal and blocks inside send, so the
+	// following ones pile up in the queue.
+	_ = e.EmitOutput("op", "first\n", false)
+	for e.Stats().Queued != 0 {
+		time.Sleep(time.Millisecond)
+	}
+	_ = e.EmitOutput("op", "old\n", false)
+	_ = e.EmitOutput("op", "mid\n", false)
+	_ = e.EmitOutput("op", "new\n", false)
+	_ = e.EmitComplete("op", 0, "", nil)
+	close(rec.release)
+	e.Close()
+
+	if st := e.Stats(); st.Dropped != 1 {
+		t.Errorf("dropped = %d, want 1", st.Dropped)
+	}
+	var got []interface{}
+	for _, b := range rec.bodies {
+		got = append(got, b[1])
+	}
+	want := []interface{}{"first\n", "mid\n", "new\n", 0}
+	if len(got) != len(want) {
+		t.Fatalf("signals = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("signal %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestEmitterClosed(t *testing.T) {
+	rec := &recordingSender{}
+	e := newEmitter(rec.send, 4)
+	e.Close()
+
+	if err := e.EmitOutput("op", "x", false); err != ErrEmitterClosed {
+		t.Errorf("EmitOutput after Close = %v, want ErrEmitterClosed", err)
+	}
+}
+
+func TestEmitterSequenceNumbers(t *testing.T) {
+	rec := &recordingSender{}
+	e := newEmitter(rec.send, 16)
+
+	_ = e.EmitOutput("op-a", "1\n", false)
+	_ = e.EmitOutput("op-b", "1\n", false)
+	_ = e.EmitOutput("op-a", "2\n", true)
+	_ = e.EmitComplete("op-a", 0, "", nil)
+	_ = e.EmitComplete("op-b", 1, "", nil)
+	e.Close()
+
+	want := []uint64{1, 1, 2, 2, 1}
+	for i, b := range rec.bodies {
+		if got := b[3]; got != want[i] {
+			t.Errorf("signal %d seq = %v, want %d", i, got, want[i])
+		}
+	}
+}
+
+func TestEmitterReplay(t *testing.T) {
+	rec := &recordingSender{}
+	e := newEmitter(rec.send, 16)
+	defer e.Close()
+
+	if _, _, ok := e.Replay("op", 0); ok {
+		t.Fatal("Replay of unknown operation succeeded")
+	}
+	for _, line := range []string{"a\n", "b\n", "c\n"} {
+		e.EmitOutput("op", line, line == "b\n")
+	}
+	chunks, complete, ok := e.Replay("op", 1)
+	if !ok || complete {
+		t.Fatalf("Replay() ok=%v complete=%v, want running operation", ok, complete)
+	}
+	want := []Chunk{{"b\n", true, 2}, {"c\n", false, 3}}
+	if len(chunks) != len(want) || chunks[0] != want[0] || chunks[1] != want[1] {
+		t.Errorf("Replay() = %+v, want %+v", chunks, want)
+	}
+
+	e.EmitComplete("op", 0, "", nil)
+	if chunks, complete, _ := e.Replay("op", 0); !complete || len(chunks) != 3 {
+		t.Errorf("after Complete: %d chunks, complete=%v", len(chunks), complete)
+	}
+
+	big := string(make([]byte, replayBytes/2+1))
+	for i := 0; i < 3; i++ {
+		e.EmitOutput("big", big, false)
+	}
+	if chunks, _, _ := e.Replay("big", 0); len(chunks) != 1 || chunks[0].Seq != 3 {
+		t.Errorf("buffer not bounded: %d chunks", len(chunks))
+	}
+
+	for i := 0; i < replayFinished; i++ {
+		e.EmitComplete(fmt.Sprintf("done-%d", i), 0, "", nil)
+	}
+	if _, _, ok := e.Replay("op", 0); ok {
+		t.Error("oldest finished operation not evicted")
+	}
+}
+
+func TestEmitterProgress(t *testing.T) {
+	rec := &recordingSender{}
+	e := newEmitter(rec.send, 16)
+
+	var watched []Progress
+	e.WatchProgress(func(id string, p Progress) { watched = append(watched, p) })
+	for _, line := range []string{"Downloading files 10%\n", "Downloading files 10%\n", "plain\n", "Downloading files 20%\n"} {
+		e.EmitOutput("op", line, false)
+	}
+	e.Close()
+
+	want := []string{"Output", "Progress", "Output", "Output", "Output", "Progress"}
+	if len(rec.names) != len(want) {
+		t.Fatalf("got signals %v", rec.names)
+	}
+	for i, name := range want {
+		if got := rec.names[i]; got != dbusconsts.Interface+"."+name {
+			t.Errorf("signal %d = %s, want %s", i, got, name)
+		}
+	}
+	if got := rec.bodies[5]; got[1] != 20.0 || got[2] != uint64(0) || got[3] != "Downloading files" {
+		t.Errorf("Progress body = %v", got)
+	}
+	if len(watched) != 2 || watched[1].Percent != 20 {
+		t.Errorf("watched = %+v", watched)
+	}
+}
+
+func TestEmitterScaleProgress(t *testing.T) {
+	rec := &recordingSender{}
+	e := newEmitter(rec.send, 16)
+
+	e.ScaleProgress("op", 0, 4)
+	e.EmitOutput("op", "Downloading files 100%\n", false)
+	e.ScaleProgress("op", 1, 4)
+	e.EmitOutput("op", "Downloading files 50%\n", false)
+	e.EmitComplete("op", 0, "", nil)
+	e.EmitOutput("op", "Downloading files 50%\n", false)
+	e.Close()
+
+	var got []interface{}
+	for i, name := range rec.names {
+		if name == dbusconsts.Interface+".Progress" {
+			got = append(got, rec.bodies[i][1])
+		}
+	}
+	if want := []interface{}{25.0, 37.5, 50.0}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("progress = %v, want %v", got, want)
+	}
+}
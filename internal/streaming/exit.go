@@ -0,0 +1,201 @@
+package streaming
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Keys of the details dictionary carried by the Complete signal.
+const (
+	DetailSignal     = "signal"      // string: name of the signal that terminated the child
+	DetailCoreDumped = "core_dumped" // bool: the child dumped core
+	DetailOOMKilled  = "oom_killed"  // bool: the kernel OOM killer terminated the child
+	DetailTimedOut   = "timed_out"   // bool: the operation exceeded its deadline
+	DetailCancelled  = "cancelled"   // bool: the operation was cancelled
+
+	DetailStartTime      = "start_time"       // int64: wall-clock start, unix seconds
+	DetailDurationMs     = "duration_ms"      // int64: run time on the monotonic clock
+	DetailWallDurationMs = "wall_duration_ms" // int64: end minus start on the wall clock
+	DetailQueueMs        = "queue_ms"         // int64: time spent waiting at the operation's gate, if any
+)
+
+// addTiming records the operation's timing in a Complete details dictionary.
+func addTiming(details map[string]interface{}, op Operation) {
+	details[DetailStartTime] = op.StartTime.Unix()
+	details[DetailDurationMs] = op.Duration().Milliseconds()
+	details[DetailWallDurationMs] = op.WallDuration().Milliseconds()
+	if op.QueueWait > 0 {
+		details[DetailQueueMs] = op.QueueWait.Milliseconds()
+	}
+}
+
+// exitStatus converts the result of cmd.Wait into the exit code, error message
+// and details reported in the Complete signal. oom watches the cgroup the
+// child ran in; it may be nil.
+func exitStatus(ctx context.Context, cmd *exec.Cmd, waitErr error, oom *oomWatch) (int, string, map[string]interface{}) {
+	details := map[string]interface{}{}
+	if waitErr == nil {
+		return 0, "", details
+	}
+	if errors.Is(context.Cause(ctx), ErrCancelled) {
+		details[DetailCancelled] = true
+		return -1, ErrCancelled.Error(), details
+	}
+	// A child stopped at the deadline may exit on its own after SIGTERM,
+	// so the deadline is checked before whether a signal ended it.
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+	if timedOut {
+		details[DetailTimedOut] = true
+	}
+
+	var exitErr *exec.ExitError
+	var ws syscall.WaitStatus
+	signaled := false
+	if errors.As(waitErr, &exitErr) {
+		ws, signaled = exitErr.Sys().(syscall.WaitStatus)
+		signaled = signaled && ws.Signaled()
+	}
+	switch {
+	case signaled:
+	case timedOut:
+		return -1, "operation timed out", details
+	case exitErr != nil:
+		return exitErr.ExitCode(), "", details
+	default:
+		return -1, waitErr.Error(), details
+	}
+
+	sig := ws.Signal()
+	name := unix.SignalName(sig)
+	if name == "" {
+		name = "signal " + strconv.Itoa(int(sig))
+	}
+	details[DetailSignal] = name
+	details[DetailCoreDumped] = ws.CoreDump()
+
+	var msg string
+	switch {
+	case timedOut:
+		msg = "operation timed out"
+	case sig == syscall.SIGKILL && oom.killed():
+		details[DetailOOMKilled] = true
+		msg = "killed by the OOM killer"
+	default:
+		msg = fmt.Sprintf("terminated by %s", name)
+		if ws.CoreDump() {
+			msg += " (core dumped)"
+		}
+	}
+	return -1, msg, details
+}
+
+// oomWatch attributes a SIGKILL to the OOM killer when the oom_kill counter
+// of the child's memory cgroup grew while it ran: memory.events on cgroup
+// v2, memory.oom_control on v1. Kills elsewhere on the system do not count.
+type oomWatch struct {
+	path   string // counter file, empty if unavailable
+	before uint64
+}
+
+// watchOOM samples the counter of pid's memory cgroup. Called with our own
+// pid before starting a child, it watches the cgroup the child inherits.
+func watchOOM(pid int) *oomWatch {
+	w := &oomWatch{path: oomCounterPath(pid)}
+	w.before, _ = readOOMKills(w.path)
+	return w
+}
+
+// follow switches to the cgroup pid is in now, if it moved, such as into a
+// transient scope started by systemd-run; that cgroup is new, so every OOM
+// kill counted there happened to the child. It must be called before pid
+// is reaped. An exited child no longer shows its cgroup and is ignored.
+func (w *oomWatch) follow(pid int) {
+	if exited(pid) {
+		return
+	}
+	path := oomCounterPath(pid)
+	if path != "" && path != w.path && !exited(pid) {
+		w.path, w.before = path, 0
+	}
+}
+
+// exited reports whether pid, a child not reaped yet, is a zombie.
+func exited(pid int) bool {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return true
+	}
+	// The state follows the command name, which is in parentheses
+	i := strings.LastIndexByte(string(data), ')')
+	return i < 0 || i+2 >= len(data) || data[i+2] == 'Z'
+}
+
+func (w *oomWatch) killed() bool {
+	if w == nil {
+		return false
+	}
+	n, ok := readOOMKills(w.path)
+	return ok && n > w.before
+}
+
+// oomCounterPath returns the file holding the OOM kill counter of pid's
+// memory cgroup, or "" if there is none.
+func oomCounterPath(pid int) string {
+	data, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/cgroup")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		_, rest, _ := strings.Cut(line, ":")
+		controllers, dir, ok := strings.Cut(rest, ":")
+		var path string
+		switch {
+		case !ok:
+			continue
+		case controllers == "":
+			path = filepath.Join("/sys/fs/cgroup", dir, "memory.events")
+		case slices.Contains(strings.Split(controllers, ","), "memory"):
+			path = filepath.Join("/sys/fs/cgroup/memory", dir, "memory.oom_control")
+		default:
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return ""
+}
+
+// readOOMKills returns the oom_kill value of a memory.events or
+// memory.oom_control file.
+func readOOMKills(path string) (uint64, bool) {
+	if path == "" {
+		return 0, false
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), " ")
+		if ok && key == "oom_kill" {
+			n, err := strconv.ParseUint(value, 10, 64)
+			return n, err == nil
+		}
+	}
+	return 0, false
+}
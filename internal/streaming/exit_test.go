@@ -0,0 +1,80 @@
+package streaming
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExitStatusSignal(t *testing.T) {
+	cmd := exec.Command("/bin/sh", "-c", "kill -TERM $$")
+	code, msg, details := exitStatus(context.Background(), cmd, cmd.Run(), nil)
+
+	if code != -1 {
+		t.Errorf("exit code = %d, want -1", code)
+	}
+	if details[DetailSignal] != "SIGTERM" {
+		t.Errorf("signal = %v, want SIGTERM", details[DetailSignal])
+	}
+	if msg != "terminated by SIGTERM" {
+		t.Errorf("message = %q", msg)
+	}
+}
+
+func TestExitStatusTimeout(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", "sleep 5")
+	_, msg, details := exitStatus(ctx, cmd, cmd.Run(), nil)
+
+	if details[DetailTimedOut] != true {
+		t.Errorf("details = %v, want timed_out", details)
+	}
+	if msg != "operation timed out" {
+		t.Errorf("message = %q", msg)
+	}
+}
+
+func TestExitStatusTimeoutExitCode(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	// Like a child that handles SIGTERM at the deadline by exiting
+	cmd := exec.Command("/bin/sh", "-c", "sleep 0.1; exit 3")
+	_, msg, details := exitStatus(ctx, cmd, cmd.Run(), nil)
+
+	if details[DetailTimedOut] != true || msg != "operation timed out" {
+		t.Errorf("exitStatus = (%q, %v), want timed out", msg, details)
+	}
+}
+
+func TestOOMWatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memory.events")
+	os.WriteFile(path, []byte("low 0\nhigh 0\nmax 4\noom 2\noom_kill 1\n"), 0o644)
+	w := &oomWatch{path: path}
+	w.before, _ = readOOMKills(path)
+	if w.before != 1 || w.killed() {
+		t.Fatalf("before = %d, killed = %v, want 1, false", w.before, w.killed())
+	}
+	os.WriteFile(path, []byte("oom_kill_disable 0\nunder_oom 0\noom_kill 2\n"), 0o644)
+	if !w.killed() {
+		t.Error("killed = false after the counter grew")
+	}
+	var none *oomWatch
+	if none.killed() {
+		t.Error("nil watch reports a kill")
+	}
+}
+
+func TestExitStatusExitCode(t *testing.T) {
+	cmd := exec.Command("/bin/sh", "-c", "exit 7")
+	code, msg, details := exitStatus(context.Background(), cmd, cmd.Run(), nil)
+
+	if code != 7 || msg != "" || len(details) != 0 {
+		t.Errorf("exitStatus = (%d, %q, %v), want (7, \"\", {})", code, msg, details)
+	}
+}
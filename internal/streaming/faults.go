@@ -0,0 +1,166 @@
+package streaming
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// EnvFaults enables fault injection for frontend development, e.g.
+// "delay=200ms,jitter=300ms,fail=0.05,garble=0.1". Never set it in production.
+const EnvFaults = "LINYAPS_FAULTS"
+
+// FaultConfig describes the faults injected into streamed operations.
+type FaultConfig struct {
+	Delay  time.Duration // added before every output chunk
+	Jitter time.Duration // random extra delay in [0, Jitter)
+	// FailRate is the per-chunk probability of failing the operation there:
+	// later output is swallowed and completion reports an injected error.
+	FailRate float64
+	// GarbleRate is the per-chunk probability of emitting a malformed line
+	// (truncated escape sequence or invalid UTF-8) before the real chunk.
+	GarbleRate float64
+}
+
+// IsZero reports whether no fault is configured.
+func (c FaultConfig) IsZero() bool {
+	return c == FaultConfig{}
+}
+
+// String formats the config in the same form ParseFaults accepts.
+func (c FaultConfig) String() string {
+	return fmt.Sprintf("delay=%s,jitter=%s,fail=%g,garble=%g", c.Delay, c.Jitter, c.FailRate, c.GarbleRate)
+}
+
+// FaultsFromEnv parses EnvFaults; an unset variable yields the zero config.
+func FaultsFromEnv() (FaultConfig, error) {
+	spec := os.Getenv(EnvFaults)
+	if spec == "" {
+		return FaultConfig{}, nil
+	}
+	return ParseFaults(spec)
+}
+
+// ParseFaults parses a comma-separated key=value fault specification.
+func ParseFaults(spec string) (FaultConfig, error) {
+	var c FaultConfig
+	for _, field := range strings.Split(spec, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(field, "=")
+		if !ok {
+			return FaultConfig{}, fmt.Errorf("invalid fault %q: want key=value", field)
+		}
+		var err error
+		switch key {
+		case "delay":
+			c.Delay, err = time.ParseDuration(value)
+		case "jitter":
+			c.Jitter, err = time.ParseDuration(value)
+		case "fail":
+			c.FailRate, err = parseRate(value)
+		case "garble":
+			c.GarbleRate, err = parseRate(value)
+		default:
+			return FaultConfig{}, fmt.Errorf("unknown fault %q", key)
+		}
+		if err != nil {
+			return FaultConfig{}, fmt.Errorf("invalid fault %q: %w", field, err)
+		}
+	}
+	return c, nil
+}
+
+func parseRate(s string) (float64, error) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if f < 0 || f > 1 {
+		return 0, fmt.Errorf("rate %g out of range [0,1]", f)
+	}
+	return f, nil
+}
+
+// garbledLines are malformed lines a frontend parser must survive.
+var garbledLines = []string{
+	"\x1b[2K\x1b[",
+	"\xff\xfe invalid utf-8 \xc3\n",
+	"\r[=====>    ] 4",
+	"{\"partial\": \n",
+}
+
+// FaultSink wraps a sink and injects delays, failures and malformed output
+// so progress and error UIs can be exercised without real network problems.
+// Faults only affect what the sink reports; the child process keeps running.
+type FaultSink struct {
+	next OutputSink
+	cfg  FaultConfig
+
+	mu     sync.Mutex
+	rnd    *rand.Rand
+	failed map[string]bool
+}
+
+// NewFaultSink creates a sink injecting the faults described by cfg into next.
+func NewFaultSink(next OutputSink, cfg FaultConfig) *FaultSink {
+	return &FaultSink{
+		next:   next,
+		cfg:    cfg,
+		rnd:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		failed: make(map[string]bool),
+	}
+}
+
+// EmitOutput delays the chunk and may garble or fail the operation.
+// Sleeping here applies backpressure, mimicking a slow download.
+func (s *FaultSink) EmitOutput(operationID, data string, isStderr bool) error {
+	s.mu.Lock()
+	if s.failed[operationID] {
+		s.mu.Unlock()
+		return nil
+	}
+	delay := s.cfg.Delay
+	if s.cfg.Jitter > 0 {
+		delay += time.Duration(s.rnd.Int63n(int64(s.cfg.Jitter)))
+	}
+	garble := s.rnd.Float64() < s.cfg.GarbleRate
+	fail := s.rnd.Float64() < s.cfg.FailRate
+	line := garbledLines[s.rnd.Intn(len(garbledLines))]
+	if fail {
+		s.failed[operationID] = true
+	}
+	s.mu.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+	if garble {
+		if err := s.next.EmitOutput(operationID, line, isStderr); err != nil {
+			return err
+		}
+	}
+	if fail {
+		return s.next.EmitOutput(operationID, "injected fault: connection reset by peer\n", true)
+	}
+	return s.next.EmitOutput(operationID, data, isStderr)
+}
+
+// EmitComplete reports an injected failure for operations failed mid-stream.
+func (s *FaultSink) EmitComplete(operationID string, exitCode int, errorMsg string, details map[string]interface{}) error {
+	s.mu.Lock()
+	failed := s.failed[operationID]
+	delete(s.failed, operationID)
+	s.mu.Unlock()
+
+	if failed {
+		exitCode, errorMsg = 1, "injected fault"
+	}
+	return s.next.EmitComplete(operationID, exitCode, errorMsg, details)
+}
@@ -0,0 +1,52 @@
+package streaming
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseFaults(t *testing.T) {
+	c, err := ParseFaults("delay=200ms, jitter=1s,fail=0.5,garble=1")
+	if err != nil {
+		t.Fatalf("ParseFaults: %v", err)
+	}
+	want := FaultConfig{Delay: 200 * time.Millisecond, Jitter: time.Second, FailRate: 0.5, GarbleRate: 1}
+	if c != want {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+
+	for _, spec := range []string{"delay", "fail=2", "loss=0.1", "delay=fast"} {
+		if _, err := ParseFaults(spec); err == nil {
+			t.Errorf("ParseFaults(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+func TestFaultSinkFail(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewFaultSink(NewWriterSink(&buf, nil), FaultConfig{FailRate: 1})
+
+	s.EmitOutput("op", "first\n", false)
+	s.EmitOutput("op", "second\n", false)
+	s.EmitComplete("op", 0, "", nil)
+
+	got := buf.String()
+	if strings.Contains(got, "first") || strings.Contains(got, "second") {
+		t.Errorf("output after injected failure leaked: %q", got)
+	}
+	if !strings.Contains(got, "exited with code 1: injected fault") {
+		t.Errorf("completion not failed: %q", got)
+	}
+}
+
+func TestFaultSinkGarble(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewFaultSink(NewWriterSink(&buf, nil), FaultConfig{GarbleRate: 1})
+
+	s.EmitOutput("op", "real\n", false)
+	if got := buf.String(); !strings.HasSuffix(got, "real\n") || got == "real\n" {
+		t.Errorf("expected a garbled line before the real chunk, got %q", got)
+	}
+}
@@ -0,0 +1,62 @@
+package streaming
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// FuzzReadChunks checks that chunking child output never loses or invents
+// bytes, keeps chunks bounded and only splits on rune boundaries of valid
+// UTF-8 input.
+func FuzzReadChunks(f *testing.F) {
+	f.Add([]byte("line1\nline2\n"), 8)
+	f.Add([]byte("[==>   ] 10%\r[=====>] 50%\r\n"), 4)
+	f.Add([]byte("\x1b[2K\x1b[1Gdone"), 5)
+	f.Add([]byte("中文中文中文\r\n\n"), 7)
+
+	f.Fuzz(func(t *testing.T, data []byte, max int) {
+		if max < utf8.UTFMax || max > 1<<16 {
+			return
+		}
+		var chunks []string
+		readChunks(bytes.NewReader(data), max, func(chunk string) {
+			chunks = append(chunks, chunk)
+		})
+
+		var got, want bytes.Buffer
+		for _, c := range chunks {
+			if len(c) > max+1 {
+				t.Fatalf("chunk of %d bytes exceeds max %d", len(c), max)
+			}
+			if utf8.Valid(data) && !utf8.ValidString(c) {
+				t.Fatalf("chunk %q splits a rune", c)
+			}
+			got.WriteString(strings.TrimSuffix(c, "\n"))
+		}
+		for _, b := range data {
+			if b != '\r' && b != '\n' {
+				want.WriteByte(b)
+			}
+		}
+		if got.String() != want.String() {
+			t.Fatalf("chunks %q do not reassemble %q", chunks, data)
+		}
+	})
+}
+
+// FuzzParseFaults checks that accepted fault specs are within range.
+func FuzzParseFaults(f *testing.F) {
+	f.Add("delay=200ms,jitter=1s,fail=0.5,garble=1")
+	f.Add("fail=,")
+	f.Fuzz(func(t *testing.T, spec string) {
+		c, err := ParseFaults(spec)
+		if err != nil {
+			return
+		}
+		if c.FailRate < 0 || c.FailRate > 1 || c.GarbleRate < 0 || c.GarbleRate > 1 {
+			t.Fatalf("ParseFaults(%q) accepted out-of-range rates: %+v", spec, c)
+		}
+	})
+}
@@ -0,0 +1,73 @@
+package streaming
+
+import (
+	"context"
+	"time"
+)
+
+// Gate holds an operation back until it may start, e.g. until operations it
+// conflicts with have finished. It blocks until then or until ctx is done,
+// and returns a func to call once the operation finished.
+type Gate func(ctx context.Context, operationID string) (release func(), err error)
+
+type gateKey struct{}
+
+type runTimeoutKey struct{}
+
+// WithGate makes operations started with the returned context wait at gate.
+// They are registered, and can be cancelled, while they wait; the operation
+// ID is returned before gate admits them.
+func WithGate(ctx context.Context, gate Gate) context.Context {
+	return context.WithValue(ctx, gateKey{}, gate)
+}
+
+// WithRunTimeout bounds the run time of operations started with the
+// returned context. Unlike a context deadline it is counted from when the
+// operation passes its gate, so time spent waiting does not count. d <= 0
+// means no bound.
+func WithRunTimeout(ctx context.Context, d time.Duration) context.Context {
+	if d <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, runTimeoutKey{}, d)
+}
+
+func gateFrom(ctx context.Context) Gate {
+	gate, _ := ctx.Value(gateKey{}).(Gate)
+	return gate
+}
+
+// timeoutOf returns the run timeout of an operation started with ctx, or 0.
+func timeoutOf(ctx context.Context) time.Duration {
+	if deadline, ok := ctx.Deadline(); ok {
+		return time.Until(deadline).Round(time.Second)
+	}
+	d, _ := ctx.Value(runTimeoutKey{}).(time.Duration)
+	return d
+}
+
+// admit waits at the gate of ctx, if any, and applies its run timeout. It
+// returns the context to run the operation in and a func to call once the
+// operation finished.
+func admit(ctx context.Context, operationID string) (context.Context, func(), error) {
+	done := func() {}
+	if gate := gateFrom(ctx); gate != nil {
+		waitStart := time.Now()
+		release, err := gate(ctx, operationID)
+		if err != nil {
+			return ctx, done, err
+		}
+		DefaultRegistry.admitted(operationID, time.Since(waitStart))
+		done = release
+	}
+	if d, ok := ctx.Value(runTimeoutKey{}).(time.Duration); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		release := done
+		done = func() {
+			cancel()
+			release()
+		}
+	}
+	return ctx, done, nil
+}
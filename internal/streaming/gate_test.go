@@ -0,0 +1,73 @@
+package streaming
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testGate admits operations once open is closed.
+func testGate(open chan struct{}) Gate {
+	return func(ctx context.Context, _ string) (func(), error) {
+		select {
+		case <-open:
+			return func() {}, nil
+		case <-ctx.Done():
+			return nil, context.Cause(ctx)
+		}
+	}
+}
+
+func TestGatedCommand(t *testing.T) {
+	open := make(chan struct{})
+	ctx := WithRunTimeout(WithGate(context.Background(), testGate(open)), 200*time.Millisecond)
+	sink := newDetailsSink()
+	opID, err := RunCommandStreaming(ctx, sink, nil, "/bin/sh", "-c", "sleep 30")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if op, _ := DefaultRegistry.Lookup(opID); !op.Queued || op.Timeout != 200*time.Millisecond {
+		t.Errorf("queued = %v timeout = %v", op.Queued, op.Timeout)
+	}
+
+	// The run timeout only starts once the gate opens
+	time.Sleep(300 * time.Millisecond)
+	if op, _ := DefaultRegistry.Lookup(opID); op.State != StateRunning {
+		t.Fatalf("state = %s while queued", op.State)
+	}
+	close(open)
+	code, details := sink.wait(t, 3*time.Second)
+	if code != -1 || details[DetailTimedOut] != true {
+		t.Errorf("exit = %d details = %v, want -1 and timed_out", code, details)
+	}
+	if ms, _ := details[DetailQueueMs].(int64); ms < 300 {
+		t.Errorf("queue_ms = %v", details[DetailQueueMs])
+	}
+}
+
+func TestCancelGatedOperation(t *testing.T) {
+	ctx := WithGate(context.Background(), testGate(make(chan struct{})))
+
+	sink := newDetailsSink()
+	opID, err := RunCommandStreaming(ctx, sink, nil, "/bin/true")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := DefaultRegistry.Cancel(opID); err != nil {
+		t.Fatalf("Cancel: %v", err)
+	}
+	if code, details := sink.wait(t, 3*time.Second); code != -1 || details[DetailCancelled] != true {
+		t.Errorf("exit = %d details = %v, want -1 and cancelled", code, details)
+	}
+
+	called := false
+	sink = newDetailsSink()
+	opID = RunTask(ctx, sink, "task", func(context.Context, func(string, bool)) error {
+		called = true
+		return nil
+	})
+	DefaultRegistry.Cancel(opID)
+	if code, _ := sink.wait(t, 3*time.Second); code != -1 || called {
+		t.Errorf("exit = %d, task called = %v", code, called)
+	}
+}
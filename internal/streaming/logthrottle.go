@@ -0,0 +1,57 @@
+package streaming
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// logRepeatWindow is how long identical log lines are folded together.
+const logRepeatWindow = 10 * time.Second
+
+// repeatLogger folds identical consecutive log lines, such as an emit error
+// repeated for every line of a chatty operation, into a single entry followed
+// by a count of the suppressed repeats.
+type repeatLogger struct {
+	mu         sync.Mutex
+	last       string
+	since      time.Time
+	suppressed int
+	now        func() time.Time
+	printf     func(format string, args ...interface{})
+}
+
+var emitErrorLog = &repeatLogger{now: time.Now, printf: log.Printf}
+
+// Printf logs the formatted message unless it repeats the previous one
+// within logRepeatWindow.
+func (l *repeatLogger) Printf(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if msg == l.last && now.Sub(l.since) < logRepeatWindow {
+		l.suppressed++
+		return
+	}
+	l.flushLocked()
+	l.printf("%s", msg)
+	l.last, l.since = msg, now
+}
+
+// Flush logs the count of suppressed repeats, if any.
+func (l *repeatLogger) Flush() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.flushLocked()
+}
+
+func (l *repeatLogger) flushLocked() {
+	if l.suppressed > 0 {
+		l.printf("[streaming] last message repeated %d more times", l.suppressed)
+		l.suppressed = 0
+	}
+}
@@ -0,0 +1,37 @@
+package streaming
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRepeatLogger(t *testing.T) {
+	now := time.Unix(0, 0)
+	var lines []string
+	l := &repeatLogger{
+		now:    func() time.Time { return now },
+		printf: func(format string, args ...interface{}) { lines = append(lines, fmt.Sprintf(format, args...)) },
+	}
+
+	for i := 0; i < 5; i++ {
+		l.Printf("emit failed: %s", "closed")
+	}
+	l.Printf("other")
+	l.Printf("other")
+	now = now.Add(logRepeatWindow)
+	l.Printf("other")
+	l.Flush()
+
+	want := []string{
+		"emit failed: closed",
+		"[streaming] last message repeated 4 more times",
+		"other",
+		"[streaming] last message repeated 1 more times",
+		"other",
+	}
+	if !reflect.DeepEqual(lines, want) {
+		t.Errorf("lines = %q\nwant %q", lines, want)
+	}
+}
@@ -0,0 +1,37 @@
+package streaming
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"regexp"
+	"sync/atomic"
+)
+
+var operationCounter uint64
+
+var (
+	uuidOperationID   = regexp.MustCompile(`^op-[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}$`)
+	legacyOperationID = regexp.MustCompile(`^op-[0-9]+-[0-9]+$`)
+)
+
+// GenerateOperationID generates a unique operation ID for tracking streaming
+// operations, of the form "op-<uuid>". Unlike the older "op-<pid>-<counter>"
+// form it does not repeat after the daemon restarts.
+func GenerateOperationID() string {
+	var u [16]byte
+	if _, err := rand.Read(u[:]); err != nil {
+		// Fall back to the legacy form rather than failing the operation
+		id := atomic.AddUint64(&operationCounter, 1)
+		return fmt.Sprintf("op-%d-%d", os.Getpid(), id)
+	}
+	u[6] = u[6]&0x0f | 0x40 // version 4
+	u[8] = u[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("op-%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
+
+// ValidOperationID reports whether id is an operation ID in either the
+// current "op-<uuid>" form or the legacy "op-<pid>-<counter>" form.
+func ValidOperationID(id string) bool {
+	return uuidOperationID.MatchString(id) || legacyOperationID.MatchString(id)
+}
@@ -0,0 +1,78 @@
+package streaming
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Progress is a progress report recognised in a line of output.
+type Progress struct {
+	Percent     float64 // 0 to 100
+	BytesPerSec uint64  // 0 when the line shows no speed
+	Phase       string  // the message next to the percentage, e.g. "Downloading files"
+}
+
+var (
+	ansiEscape   = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+	percentToken = regexp.MustCompile(`(\d{1,3}(?:\.\d+)?)\s*%`)
+	speedToken   = regexp.MustCompile(`(\d+(?:\.\d+)?)\s*([KMGT]i?)?B/s`)
+)
+
+var speedUnits = map[string]float64{
+	"": 1, "K": 1e3, "M": 1e6, "G": 1e9, "T": 1e12,
+	"Ki": 1 << 10, "Mi": 1 << 20, "Gi": 1 << 30, "Ti": 1 << 40,
+}
+
+// ParseProgress recognises the progress lines ll-cli prints while
+// installing or upgrading, such as
+//
+//	Downloading files 45%
+//	Downloading files:45% (1.2 MB/s)
+//	[ 80%] Installing application
+//
+// Terminal escape sequences are ignored. ok is false for lines without a
+// percentage.
+func ParseProgress(line string) (p Progress, ok bool) {
+	if !strings.Contains(line, "%") {
+		return Progress{}, false
+	}
+	clean := ansiEscape.ReplaceAllString(line, "")
+	m := percentToken.FindStringSubmatchIndex(clean)
+	if m == nil {
+		return Progress{}, false
+	}
+	pct, err := strconv.ParseFloat(clean[m[2]:m[3]], 64)
+	if err != nil || pct > 100 {
+		return Progress{}, false
+	}
+	p.Percent = pct
+
+	before, after := clean[:m[0]], clean[m[1]:]
+	if s := speedToken.FindStringSubmatch(after); s != nil {
+		p.BytesPerSec = parseSpeed(s[1], s[2])
+		after = strings.Replace(after, s[0], "", 1)
+	} else if s := speedToken.FindStringSubmatch(before); s != nil {
+		p.BytesPerSec = parseSpeed(s[1], s[2])
+		before = strings.Replace(before, s[0], "", 1)
+	}
+	p.Phase = phaseText(before)
+	if p.Phase == "" {
+		p.Phase = phaseText(after)
+	}
+	return p, true
+}
+
+func parseSpeed(value, unit string) uint64 {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return uint64(v * speedUnits[unit])
+}
+
+// phaseText strips the punctuation around a progress message.
+func phaseText(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.Trim(s, " :[]()|-")
+}
@@ -0,0 +1,28 @@
+package streaming
+
+import "testing"
+
+func TestParseProgress(t *testing.T) {
+	tests := []struct {
+		line string
+		want Progress
+	}{
+		{"Downloading files 45%\n", Progress{Percent: 45, Phase: "Downloading files"}},
+		{"\r\x1b[K\x1b[?25lDownloading files:45%\x1b[?25h\n", Progress{Percent: 45, Phase: "Downloading files"}},
+		{"Downloading files:12.5% (1.5 MB/s)\n", Progress{Percent: 12.5, BytesPerSec: 1500000, Phase: "Downloading files"}},
+		{"[ 80%] Installing application\n", Progress{Percent: 80, Phase: "Installing application"}},
+		{"Downloading 3 KiB/s 100%\n", Progress{Percent: 100, BytesPerSec: 3072, Phase: "Downloading"}},
+	}
+	for _, tt := range tests {
+		got, ok := ParseProgress(tt.line)
+		if !ok || got != tt.want {
+			t.Errorf("ParseProgress(%q) = %+v, %v; want %+v", tt.line, got, ok, tt.want)
+		}
+	}
+
+	for _, line := range []string{"", "Install main:org.example.app/1.0.0/x86_64 success\n", "load 150%\n", "warning: 5 percent left\n"} {
+		if p, ok := ParseProgress(line); ok {
+			t.Errorf("ParseProgress(%q) = %+v, want no progress", line, p)
+		}
+	}
+}
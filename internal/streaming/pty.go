@@ -0,0 +1,106 @@
+package streaming
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+
+	"github.com/creack/pty"
+	"golang.org/x/sys/unix"
+)
+
+// RunCommandPTY is RunCommandStreaming with the child attached to a
+// pseudo-terminal, like RunChildPTY. Registry.SendInput writes to the
+// terminal while it runs, so interactive programs such as shells can be
+// driven remotely.
+func RunCommandPTY(ctx context.Context, sink OutputSink, env []string, cmdPath string, args ...string) (string, error) {
+	return runCommand(ctx, sink, env, cmdPath, args, startChildPTY)
+}
+
+// startChildPTY is startChild for a child on a pseudo-terminal.
+func startChildPTY(ctx context.Context, env []string, cmdPath string, args []string) (*child, error) {
+	cmd := exec.CommandContext(ctx, cmdPath, args...)
+	cmd.Env = env
+	stopCancel := cancelGracefully(ctx, cmd)
+	ptm, tty, err := openPTY(cmd)
+	if err != nil {
+		stopCancel()
+		return nil, fmt.Errorf("failed to open terminal: %w", err)
+	}
+	oom := watchOOM(os.Getpid())
+	err = cmd.Start()
+	tty.Close()
+	if err != nil {
+		ptm.Close()
+		stopCancel()
+		return nil, fmt.Errorf("failed to start command: %w", err)
+	}
+	oom.follow(cmd.Process.Pid)
+	return &child{cmd: cmd, stdout: ptm, pty: ptm, stopCancel: stopCancel, oom: oom}, nil
+}
+
+// RunChildPTY is RunChild with the child attached to a pseudo-terminal
+// instead of pipes, so programs that buffer or hide their output when not
+// on a terminal show it as they would in a console. stdout and stderr
+// share the terminal and all output is reported as stdout.
+func RunChildPTY(ctx context.Context, out func(data string, isStderr bool), env []string, cmdPath string, args ...string) error {
+	cmd := exec.CommandContext(ctx, cmdPath, args...)
+	cmd.Env = env
+	stopCancel := cancelGracefully(ctx, cmd)
+	oom := watchOOM(os.Getpid())
+	ptm, tty, err := openPTY(cmd)
+	if err != nil {
+		stopCancel()
+		return fmt.Errorf("failed to open terminal: %w", err)
+	}
+	err = cmd.Start()
+	tty.Close()
+	if err != nil {
+		ptm.Close()
+		stopCancel()
+		return fmt.Errorf("failed to start command: %w", err)
+	}
+	oom.follow(cmd.Process.Pid)
+
+	// Reads fail with EIO once the child and its descendants have closed
+	// the terminal
+	readChunks(ptm, maxChunkSize, func(data string) { out(data, false) })
+	ptm.Close()
+	oom.follow(cmd.Process.Pid)
+
+	waitErr := cmd.Wait()
+	stopCancel()
+	exitCode, errorMsg, details := exitStatus(ctx, cmd, waitErr, oom)
+	if exitCode == 0 && errorMsg == "" {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", filepath.Base(cmdPath), &ExitError{Code: exitCode, Msg: errorMsg, Details: details})
+}
+
+// openPTY opens a pseudo-terminal for cmd's standard streams and returns
+// both sides; the caller closes tty once cmd has started. Output newlines are not turned into CRLF, which the
+// chunker would take for two line ends.
+func openPTY(cmd *exec.Cmd) (ptm, tty *os.File, err error) {
+	ptm, tty, err = pty.Open()
+	if err != nil {
+		return nil, nil, err
+	}
+	termios, err := unix.IoctlGetTermios(int(tty.Fd()), unix.TCGETS)
+	if err == nil {
+		termios.Oflag &^= unix.ONLCR
+		err = unix.IoctlSetTermios(int(tty.Fd()), unix.TCSETS, termios)
+	}
+	if err != nil {
+		ptm.Close()
+		tty.Close()
+		return nil, nil, err
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = tty, tty, tty
+	// A new session also makes the child a process group leader, which
+	// cancelGracefully relies on; Setpgid cannot be combined with it.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	return ptm, tty, nil
+}
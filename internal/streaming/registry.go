@@ -0,0 +1,262 @@
+package streaming
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// OperationState describes the lifecycle stage of an operation.
+type OperationState string
+
+const (
+	StateRunning   OperationState = "running"
+	StateCompleted OperationState = "completed" // exited with code 0
+	StateFailed    OperationState = "failed"
+	StateCancelled OperationState = "cancelled" // stopped through Registry.Cancel
+)
+
+// maxFinishedOperations bounds how many finished operations stay queryable.
+const maxFinishedOperations = 100
+
+// Operation is a snapshot of a registered operation.
+type Operation struct {
+	ID        string
+	Program   string
+	Args      []string
+	State     OperationState
+	StartTime time.Time
+	EndTime   time.Time
+	ExitCode  int
+	ErrorMsg  string
+	Timeout   time.Duration     // 0 when the operation has no deadline
+	Queued    bool              // waiting at its gate (see WithGate); StartTime is when it was queued
+	QueueWait time.Duration     // how long it waited at its gate before StartTime
+	Labels    map[string]string // policy details attached by the caller via WithLabels
+
+	cancel    context.CancelCauseFunc // nil if the operation cannot be cancelled
+	cancelled bool                    // Cancel was called
+	input     io.Writer               // terminal of a RunCommandPTY child; nil if it takes no input
+}
+
+// Registry tracks running operations and keeps the most recently finished ones.
+type Registry struct {
+	mu       sync.Mutex
+	ops      map[string]*Operation
+	finished []string // IDs of finished operations, oldest first
+	watchers []func(Operation)
+}
+
+// DefaultRegistry records every operation started by RunCommandStreaming.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{ops: make(map[string]*Operation)}
+}
+
+// Watch registers fn to be called with a snapshot of each operation when it
+// is added and again when it finishes. fn must not call back into r.
+func (r *Registry) Watch(fn func(Operation)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.watchers = append(r.watchers, fn)
+}
+
+func (r *Registry) notify(op Operation, watchers []func(Operation)) {
+	for _, fn := range watchers {
+		fn(op)
+	}
+}
+
+func (r *Registry) add(op *Operation) {
+	r.mu.Lock()
+	r.ops[op.ID] = op
+	snap, watchers := op.snapshot(), r.watchers
+	r.mu.Unlock()
+
+	r.notify(snap, watchers)
+}
+
+// finish marks an operation finished and returns its final snapshot.
+func (r *Registry) finish(id string, exitCode int, errorMsg string) (Operation, bool) {
+	r.mu.Lock()
+	op, ok := r.ops[id]
+	if !ok {
+		r.mu.Unlock()
+		return Operation{}, false
+	}
+	op.EndTime = time.Now()
+	op.input = nil
+	op.ExitCode = exitCode
+	op.ErrorMsg = errorMsg
+	op.State = StateCompleted
+	switch {
+	case op.cancelled && exitCode != 0:
+		op.State = StateCancelled
+	case exitCode != 0 || errorMsg != "":
+		op.State = StateFailed
+	}
+
+	r.finished = append(r.finished, id)
+	if len(r.finished) > maxFinishedOperations {
+		delete(r.ops, r.finished[0])
+		r.finished = r.finished[1:]
+	}
+	snap, watchers := op.snapshot(), r.watchers
+	r.mu.Unlock()
+
+	r.notify(snap, watchers)
+	return snap, true
+}
+
+// admitted records that an operation passed its gate after waiting for
+// wait. Its run time is counted from now on.
+func (r *Registry) admitted(id string, wait time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if op, ok := r.ops[id]; ok && op.Queued {
+		op.Queued = false
+		op.QueueWait = wait
+		op.StartTime = time.Now()
+	}
+}
+
+func (r *Registry) setInput(id string, w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if op, ok := r.ops[id]; ok && op.State == StateRunning {
+		op.input = w
+	}
+}
+
+// SendInput writes data to the terminal of a running operation started
+// by RunCommandPTY, as if typed.
+func (r *Registry) SendInput(id, data string) error {
+	r.mu.Lock()
+	op, ok := r.ops[id]
+	var w io.Writer
+	if ok {
+		w = op.input
+	}
+	r.mu.Unlock()
+	switch {
+	case !ok:
+		return fmt.Errorf("unknown operation %q", id)
+	case w == nil:
+		return fmt.Errorf("operation %s does not take input", id)
+	}
+	if _, err := io.WriteString(w, data); err != nil {
+		return fmt.Errorf("write input to %s: %w", id, err)
+	}
+	return nil
+}
+
+// SetLabel adds a label to a running operation, for facts that are only
+// known after it started. It reports whether the operation was running.
+func (r *Registry) SetLabel(id, key, value string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	op, ok := r.ops[id]
+	if !ok || op.State != StateRunning {
+		return false
+	}
+	labels := make(map[string]string, len(op.Labels)+1)
+	for k, v := range op.Labels {
+		labels[k] = v
+	}
+	labels[key] = value
+	op.Labels = labels
+	return true
+}
+
+// Lookup returns a snapshot of the operation with the given ID.
+func (r *Registry) Lookup(id string) (Operation, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	op, ok := r.ops[id]
+	if !ok {
+		return Operation{}, false
+	}
+	return op.snapshot(), true
+}
+
+// Running returns snapshots of the operations that have not finished yet,
+// oldest first.
+func (r *Registry) Running() []Operation {
+	r.mu.Lock()
+	var ops []Operation
+	for _, op := range r.ops {
+		if op.State == StateRunning {
+			ops = append(ops, op.snapshot())
+		}
+	}
+	r.mu.Unlock()
+
+	sort.Slice(ops, func(i, j int) bool { return ops[i].StartTime.Before(ops[j].StartTime) })
+	return ops
+}
+
+// RunningCount returns the number of operations that have not finished yet.
+func (r *Registry) RunningCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	n := 0
+	for _, op := range r.ops {
+		if op.State == StateRunning {
+			n++
+		}
+	}
+	return n
+}
+
+// Duration returns how long the operation ran, or has been running so far.
+// It is measured on the monotonic clock, so RTC corrections and NTP jumps
+// during the operation do not distort it.
+func (op Operation) Duration() time.Duration {
+	if op.EndTime.IsZero() {
+		return time.Since(op.StartTime)
+	}
+	return op.EndTime.Sub(op.StartTime)
+}
+
+// WallDuration returns the difference between the wall-clock end and start
+// times. It differs from Duration when the system clock was changed while
+// the operation ran.
+func (op Operation) WallDuration() time.Duration {
+	end := op.EndTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return end.Round(0).Sub(op.StartTime.Round(0))
+}
+
+func (op *Operation) snapshot() Operation {
+	c := *op
+	c.cancel = nil
+	c.Args = append([]string(nil), op.Args...)
+	c.Labels = make(map[string]string, len(op.Labels))
+	for k, v := range op.Labels {
+		c.Labels[k] = v
+	}
+	return c
+}
+
+type labelsKey struct{}
+
+// WithLabels attaches policy labels (limits, scope, ...) to ctx. Operations
+// started with the returned context record them in the registry.
+func WithLabels(ctx context.Context, labels map[string]string) context.Context {
+	return context.WithValue(ctx, labelsKey{}, labels)
+}
+
+func labelsFrom(ctx context.Context) map[string]string {
+	labels, _ := ctx.Value(labelsKey{}).(map[string]string)
+	return labels
+}
@@ -0,0 +1,119 @@
+package streaming
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRegistryTracksOperation(t *testing.T) {
+	ctx, cancel := context.WithTimeout(WithLabels(context.Background(), map[string]string{"limits": "nofile=64"}), time.Minute)
+	defer cancel()
+
+	sink := &doneSink{OutputSink: MultiSink{}, done: make(chan int, 1)}
+	opID, err := RunCommandStreaming(ctx, sink, nil, "/bin/sh", "-c", "exit 2")
+	if err != nil {
+		t.Fatalf("RunCommandStreaming: %v", err)
+	}
+	<-sink.done
+
+	op, ok := DefaultRegistry.Lookup(opID)
+	if !ok {
+		t.Fatalf("operation %s not registered", opID)
+	}
+	if op.State != StateFailed || op.ExitCode != 2 {
+		t.Errorf("state = %s exit = %d, want failed/2", op.State, op.ExitCode)
+	}
+	if op.Timeout != time.Minute {
+		t.Errorf("timeout = %s, want 1m", op.Timeout)
+	}
+	if op.Labels["limits"] != "nofile=64" {
+		t.Errorf("labels = %v", op.Labels)
+	}
+}
+
+func TestRegistryEvictsFinished(t *testing.T) {
+	r := NewRegistry()
+	for i := 0; i < maxFinishedOperations+1; i++ {
+		id := GenerateOperationID()
+		r.add(&Operation{ID: id, State: StateRunning})
+		r.finish(id, 0, "")
+		if i == 0 {
+			defer func(first string) {
+				if _, ok := r.Lookup(first); ok {
+					t.Errorf("oldest finished operation %s was not evicted", first)
+				}
+			}(id)
+		}
+	}
+}
+
+func TestOperationDuration(t *testing.T) {
+	start := time.Now()
+	op := Operation{StartTime: start, EndTime: start.Add(1500 * time.Millisecond)}
+	if d := op.Duration(); d != 1500*time.Millisecond {
+		t.Errorf("Duration = %s, want 1.5s", d)
+	}
+	if d := op.WallDuration(); d != 1500*time.Millisecond {
+		t.Errorf("WallDuration = %s, want 1.5s", d)
+	}
+
+	details := map[string]interface{}{}
+	addTiming(details, op)
+	if details[DetailDurationMs] != int64(1500) || details[DetailStartTime] != start.Unix() {
+		t.Errorf("details = %v", details)
+	}
+}
+
+func TestRegistryWatch(t *testing.T) {
+	r := NewRegistry()
+	var states []OperationState
+	r.Watch(func(op Operation) { states = append(states, op.State) })
+
+	r.add(&Operation{ID: "op-1-1", State: StateRunning})
+	r.finish("op-1-1", 1, "")
+	r.finish("op-unknown", 0, "")
+
+	if len(states) != 2 || states[0] != StateRunning || states[1] != StateFailed {
+		t.Errorf("watched states = %v, want [running failed]", states)
+	}
+}
+
+func TestRegistrySetLabel(t *testing.T) {
+	r := NewRegistry()
+	labels := map[string]string{"operation": "run"}
+	r.add(&Operation{ID: "op-1", State: StateRunning, Labels: labels})
+
+	if !r.SetLabel("op-1", "version", "1.0") {
+		t.Fatal("SetLabel on a running operation failed")
+	}
+	op, _ := r.Lookup("op-1")
+	if op.Labels["version"] != "1.0" || op.Labels["operation"] != "run" {
+		t.Errorf("labels = %v", op.Labels)
+	}
+	if _, ok := labels["version"]; ok {
+		t.Error("SetLabel modified the caller's label map")
+	}
+
+	r.finish("op-1", 0, "")
+	if r.SetLabel("op-1", "version", "2.0") {
+		t.Error("SetLabel succeeded on a finished operation")
+	}
+	if r.SetLabel("op-2", "version", "1.0") {
+		t.Error("SetLabel succeeded on an unknown operation")
+	}
+}
+
+func TestRegistryRunning(t *testing.T) {
+	r := NewRegistry()
+	now := time.Now()
+	r.add(&Operation{ID: "op-b", State: StateRunning, StartTime: now.Add(time.Second)})
+	r.add(&Operation{ID: "op-a", State: StateRunning, StartTime: now})
+	r.add(&Operation{ID: "op-c", State: StateRunning, StartTime: now})
+	r.finish("op-c", 0, "")
+
+	ops := r.Running()
+	if len(ops) != 2 || ops[0].ID != "op-a" || ops[1].ID != "op-b" {
+		t.Errorf("Running = %+v, want op-a, op-b", ops)
+	}
+}
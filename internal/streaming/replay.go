@@ -0,0 +1,76 @@
+package streaming
+
+const (
+	// replayBytes bounds the output kept per operation for Replay; older
+	// chunks are dropped first.
+	replayBytes = 128 << 10
+	// replayFinished is how many finished operations keep their output.
+	replayFinished = 50
+)
+
+// Chunk is one Output signal kept for replay.
+type Chunk struct {
+	Data     string
+	IsStderr bool
+	Seq      uint64
+}
+
+type replayBuffer struct {
+	chunks   []Chunk
+	size     int
+	complete bool
+}
+
+// recordLocked keeps an Output chunk for Replay.
+func (e *Emitter) recordLocked(operationID string, c Chunk) {
+	b := e.replay[operationID]
+	if b == nil {
+		b = &replayBuffer{}
+		e.replay[operationID] = b
+	}
+	b.chunks = append(b.chunks, c)
+	b.size += len(c.Data)
+	for b.size > replayBytes && len(b.chunks) > 1 {
+		b.size -= len(b.chunks[0].Data)
+		b.chunks[0] = Chunk{}
+		b.chunks = b.chunks[1:]
+	}
+}
+
+// completeLocked marks the output of operationID as final and evicts the
+// buffers of the oldest finished operations.
+func (e *Emitter) completeLocked(operationID string) {
+	b := e.replay[operationID]
+	if b == nil {
+		b = &replayBuffer{}
+		e.replay[operationID] = b
+	}
+	b.complete = true
+	e.replayDone = append(e.replayDone, operationID)
+	if len(e.replayDone) > replayFinished {
+		delete(e.replay, e.replayDone[0])
+		e.replayDone = e.replayDone[1:]
+	}
+}
+
+// Replay returns the buffered Output of an operation with sequence numbers
+// after the given one, and whether the operation has completed. Chunks are
+// recorded when queued, so they may also still arrive as signals; receivers
+// should skip sequence numbers they have seen. A gap between after and the
+// first chunk means older output was dropped from the buffer. ok is false
+// for operations without buffered output.
+func (e *Emitter) Replay(operationID string, after uint64) (chunks []Chunk, complete, ok bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	b := e.replay[operationID]
+	if b == nil {
+		return nil, false, false
+	}
+	for _, c := range b.chunks {
+		if c.Seq > after {
+			chunks = append(chunks, c)
+		}
+	}
+	return chunks, b.complete, true
+}
@@ -0,0 +1,84 @@
+package streaming
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// OutputSink receives the output and completion of streamed operations.
+// *Emitter is the D-Bus implementation; other sinks let the same execution
+// path feed log files or in-process consumers.
+type OutputSink interface {
+	EmitOutput(operationID, data string, isStderr bool) error
+	EmitComplete(operationID string, exitCode int, errorMsg string, details map[string]interface{}) error
+}
+
+// WriterSink writes operation output to plain io.Writers, such as a per-operation
+// log file. Stderr falls back to the stdout writer when nil.
+type WriterSink struct {
+	mu     sync.Mutex
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// NewWriterSink creates a sink writing stdout and stderr chunks to the given writers.
+func NewWriterSink(stdout, stderr io.Writer) *WriterSink {
+	if stderr == nil {
+		stderr = stdout
+	}
+	return &WriterSink{stdout: stdout, stderr: stderr}
+}
+
+// EmitOutput writes the chunk to the matching writer.
+func (s *WriterSink) EmitOutput(operationID, data string, isStderr bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w := s.stdout
+	if isStderr {
+		w = s.stderr
+	}
+	_, err := io.WriteString(w, data)
+	return err
+}
+
+// EmitComplete writes a trailer line recording the exit status.
+func (s *WriterSink) EmitComplete(operationID string, exitCode int, errorMsg string, details map[string]interface{}) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if errorMsg != "" {
+		_, err := fmt.Fprintf(s.stdout, "[%s exited with code %d: %s]\n", operationID, exitCode, errorMsg)
+		return err
+	}
+	_, err := fmt.Fprintf(s.stdout, "[%s exited with code %d]\n", operationID, exitCode)
+	return err
+}
+
+// MultiSink fans every call out to all of its sinks. A failing sink does not
+// prevent delivery to the others; their errors are joined.
+type MultiSink []OutputSink
+
+// EmitOutput forwards the chunk to every sink.
+func (m MultiSink) EmitOutput(operationID, data string, isStderr bool) error {
+	var errs []error
+	for _, s := range m {
+		if err := s.EmitOutput(operationID, data, isStderr); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// EmitComplete forwards completion to every sink.
+func (m MultiSink) EmitComplete(operationID string, exitCode int, errorMsg string, details map[string]interface{}) error {
+	var errs []error
+	for _, s := range m {
+		if err := s.EmitComplete(operationID, exitCode, errorMsg, details); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,207 @@
+package streaming
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// doneSink signals on done once the operation completes.
+type doneSink struct {
+	OutputSink
+	done chan int
+}
+
+func (s *doneSink) EmitComplete(operationID string, exitCode int, errorMsg string, details map[string]interface{}) error {
+	err := s.OutputSink.EmitComplete(operationID, exitCode, errorMsg, details)
+	s.done <- exitCode
+	return err
+}
+
+func TestRunCommandStreamingWriterSink(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	sink := &doneSink{OutputSink: NewWriterSink(&stdout, &stderr), done: make(chan int, 1)}
+
+	opID, err := RunCommandStreaming(context.Background(), sink, nil, "/bin/sh", "-c", "echo out; echo err >&2; exit 3")
+	if err != nil {
+		t.Fatalf("RunCommandStreaming: %v", err)
+	}
+
+	select {
+	case code := <-sink.done:
+		if code != 3 {
+			t.Errorf("exit code = %d, want 3", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("operation did not complete")
+	}
+
+	if !strings.HasPrefix(stdout.String(), "out\n") {
+		t.Errorf("stdout = %q, want it to start with %q", stdout.String(), "out\n")
+	}
+	if !strings.Contains(stdout.String(), opID) {
+		t.Errorf("stdout = %q, want completion trailer for %s", stdout.String(), opID)
+	}
+	if stderr.String() != "err\n" {
+		t.Errorf("stderr = %q, want %q", stderr.String(), "err\n")
+	}
+}
+
+func TestMultiSink(t *testing.T) {
+	var a, b bytes.Buffer
+	m := MultiSink{NewWriterSink(&a, nil), NewWriterSink(&b, nil)}
+
+	if err := m.EmitOutput("op", "hello\n", false); err != nil {
+		t.Fatalf("EmitOutput: %v", err)
+	}
+	if a.String() != "hello\n" || b.String() != "hello\n" {
+		t.Errorf("sinks got %q and %q, want both %q", a.String(), b.String(), "hello\n")
+	}
+}
+
+func TestRunTask(t *testing.T) {
+	var stdout bytes.Buffer
+	sink := &doneSink{OutputSink: NewWriterSink(&stdout, nil), done: make(chan int, 1)}
+
+	opID := RunTask(context.Background(), sink, "test-task", func(ctx context.Context, out func(string, bool)) error {
+		out("step 1\n", false)
+		return errors.New("boom")
+	})
+
+	if code := <-sink.done; code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+	if !strings.HasPrefix(stdout.String(), "step 1\n") {
+		t.Errorf("output = %q", stdout.String())
+	}
+	if op, _ := DefaultRegistry.Lookup(opID); op.ErrorMsg != "boom" || op.Program != "test-task" {
+		t.Errorf("registry entry = %+v", op)
+	}
+}
+
+func TestRunCommandTaskExitError(t *testing.T) {
+	sink := &doneSink{OutputSink: NewWriterSink(&bytes.Buffer{}, nil), done: make(chan int, 1)}
+	opID := RunCommandTask(context.Background(), sink, "/bin/sh", []string{"-c", "exit 3"}, func(ctx context.Context, out func(string, bool)) (map[string]interface{}, error) {
+		err := RunChild(ctx, out, nil, "/bin/sh", "-c", "exit 3")
+		var exitErr *ExitError
+		if errors.As(err, &exitErr) {
+			return nil, exitErr
+		}
+		return nil, err
+	})
+
+	if code := <-sink.done; code != 3 {
+		t.Errorf("exit code = %d, want 3", code)
+	}
+	if op, _ := DefaultRegistry.Lookup(opID); op.ErrorMsg != "" || op.Program != "/bin/sh" || len(op.Args) != 2 {
+		t.Errorf("registry entry = %+v", op)
+	}
+}
+
+func TestRunChild(t *testing.T) {
+	var mu sync.Mutex
+	var lines []string
+	out := func(data string, isStderr bool) {
+		mu.Lock()
+		defer mu.Unlock()
+		if isStderr {
+			data = "E:" + data
+		}
+		lines = append(lines, data)
+	}
+	if err := RunChild(context.Background(), out, nil, "/bin/sh", "-c", "echo a; echo b >&2"); err != nil {
+		t.Fatalf("RunChild: %v", err)
+	}
+	if len(lines) != 2 {
+		t.Errorf("lines = %q", lines)
+	}
+
+	err := RunChild(context.Background(), func(string, bool) {}, nil, "/bin/sh", "-c", "exit 4")
+	if err == nil || !strings.Contains(err.Error(), "code 4") {
+		t.Errorf("RunChild error = %v, want exit code 4", err)
+	}
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 4 || exitErr.Msg != "" {
+		t.Errorf("RunChild error = %#v, want *ExitError with code 4", err)
+	}
+}
+
+func TestRunChildPTY(t *testing.T) {
+	var lines []string
+	out := func(data string, isStderr bool) {
+		if isStderr {
+			data = "E:" + data
+		}
+		lines = append(lines, data)
+	}
+	err := RunChildPTY(context.Background(), out, nil, "/bin/sh", "-c", "test -t 1 && echo tty; echo b >&2")
+	if err != nil {
+		t.Fatalf("RunChildPTY: %v", err)
+	}
+	if want := []string{"tty\n", "b\n"}; strings.Join(lines, "") != strings.Join(want, "") {
+		t.Errorf("lines = %q, want %q", lines, want)
+	}
+
+	err = RunChildPTY(context.Background(), func(string, bool) {}, nil, "/bin/sh", "-c", "exit 3")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Errorf("RunChildPTY error = %#v, want *ExitError with code 3", err)
+	}
+}
+
+func TestRunCommandPTYInput(t *testing.T) {
+	var stdout syncBuffer
+	sink := &doneSink{OutputSink: NewWriterSink(&stdout, nil), done: make(chan int, 1)}
+	opID, err := RunCommandPTY(context.Background(), sink, nil, "/bin/sh", "-c", "read line; echo got $line")
+	if err != nil {
+		t.Fatalf("RunCommandPTY: %v", err)
+	}
+	if err := DefaultRegistry.SendInput(opID, "hello\n"); err != nil {
+		t.Fatalf("SendInput: %v", err)
+	}
+	select {
+	case code := <-sink.done:
+		if code != 0 {
+			t.Errorf("exit code = %d, want 0", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("operation did not complete")
+	}
+	if !strings.Contains(stdout.String(), "got hello\n") {
+		t.Errorf("stdout = %q, want the input echoed back", stdout.String())
+	}
+	if err := DefaultRegistry.SendInput(opID, "x\n"); err == nil {
+		t.Error("SendInput after exit succeeded")
+	}
+
+	opID, err = RunCommandStreaming(context.Background(), sink, nil, "/bin/sh", "-c", "exit 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := DefaultRegistry.SendInput(opID, "x\n"); err == nil {
+		t.Error("SendInput to a command without terminal succeeded")
+	}
+	<-sink.done
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -2,15 +2,15 @@
 package streaming
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
 	"sync"
-	"sync/atomic"
+	"time"
 
 	"github.com/godbus/dbus/v5"
 
@@ -23,152 +23,192 @@ type OutputCallback func(operationID string, data string, isStderr bool)
 
 // CompleteCallback is called when the command completes.
 // exitCode is the process exit code (0 for success), errorMsg is non-empty on error.
-type CompleteCallback func(operationID string, exitCode int, errorMsg string)
+// details describes abnormal terminations (see the Detail* keys).
+type CompleteCallback func(operationID string, exitCode int, errorMsg string, details map[string]interface{})
 
-var operationCounter uint64
-
-// GenerateOperationID generates a unique operation ID for tracking streaming operations.
-func GenerateOperationID() string {
-	id := atomic.AddUint64(&operationCounter, 1)
-	return fmt.Sprintf("op-%d-%d", os.Getpid(), id)
-}
-
-// Emitter wraps a D-Bus connection for emitting streaming signals.
-type Emitter struct {
-	conn *dbus.Conn
-	mu   sync.Mutex
+// RunCommand executes a command and streams its output via D-Bus signals.
+// Returns the operation ID immediately; the command runs asynchronously.
+// The Complete signal will be emitted when the command finishes.
+func RunCommand(ctx context.Context, emitter *Emitter, env []string, cmdPath string, args ...string) (string, error) {
+	return RunCommandStreaming(ctx, emitter, env, cmdPath, args...)
 }
 
-// NewEmitter creates a new signal emitter.
-func NewEmitter(conn *dbus.Conn) *Emitter {
-	return &Emitter{conn: conn}
+// RunCommandStreaming executes a command and streams its output to sink.
+// Returns the operation ID immediately; the command runs asynchronously and
+// sink.EmitComplete is called once it finishes.
+//
+// If ctx carries a gate (see WithGate) the command is started once the gate
+// admits it, and failing to start it is reported through EmitComplete
+// instead of the returned error.
+func RunCommandStreaming(ctx context.Context, sink OutputSink, env []string, cmdPath string, args ...string) (string, error) {
+	return runCommand(ctx, sink, env, cmdPath, args, startChild)
 }
 
-// EmitOutput sends an Output signal with command output data.
-func (e *Emitter) EmitOutput(operationID, data string, isStderr bool) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+func runCommand(ctx context.Context, sink OutputSink, env []string, cmdPath string, args []string,
+	start func(ctx context.Context, env []string, cmdPath string, args []string) (*child, error)) (string, error) {
+	operationID := GenerateOperationID()
 
-	return e.conn.Emit(
-		dbus.ObjectPath(dbusconsts.ObjectPath),
-		dbusconsts.Interface+"."+dbusconsts.SignalOutput,
-		operationID, data, isStderr,
-	)
-}
+	ctx, cancel := context.WithCancelCause(ctx)
+	op := &Operation{
+		ID:        operationID,
+		Program:   cmdPath,
+		Args:      args,
+		State:     StateRunning,
+		StartTime: time.Now(),
+		Labels:    labelsFrom(ctx),
+		Timeout:   timeoutOf(ctx),
+		cancel:    cancel,
+	}
 
-// EmitComplete sends a Complete signal when operation finishes.
-func (e *Emitter) EmitComplete(operationID string, exitCode int, errorMsg string) error {
-	e.mu.Lock()
-	defer e.mu.Unlock()
+	if gateFrom(ctx) == nil {
+		runCtx, done, _ := admit(ctx, operationID)
+		c, err := start(runCtx, env, cmdPath, args)
+		if err != nil {
+			done()
+			cancel(nil)
+			return "", err
+		}
+		log.Printf("[streaming] started command: %s %v (opID=%s)", cmdPath, args, operationID)
+		op.StartTime = time.Now()
+		if c.pty != nil {
+			op.input = c.pty
+		}
+		DefaultRegistry.add(op)
+		go func() {
+			defer cancel(nil)
+			defer done()
+			c.stream(runCtx, sink, operationID)
+		}()
+		return operationID, nil
+	}
 
-	return e.conn.Emit(
-		dbus.ObjectPath(dbusconsts.ObjectPath),
-		dbusconsts.Interface+"."+dbusconsts.SignalComplete,
-		operationID, exitCode, errorMsg,
-	)
+	op.Queued = true
+	DefaultRegistry.add(op)
+	log.Printf("[streaming] queued command: %s %v (opID=%s)", cmdPath, args, operationID)
+	go func() {
+		defer cancel(nil)
+		runCtx, done, err := admit(ctx, operationID)
+		var c *child
+		if err == nil {
+			c, err = start(runCtx, env, cmdPath, args)
+		}
+		if err == nil {
+			log.Printf("[streaming] started command: %s %v (opID=%s)", cmdPath, args, operationID)
+			if c.pty != nil {
+				DefaultRegistry.setInput(operationID, c.pty)
+			}
+			c.stream(runCtx, sink, operationID)
+			done()
+			return
+		}
+		done()
+
+		exitCode, errorMsg, details := -1, err.Error(), map[string]interface{}{}
+		switch {
+		case errors.Is(context.Cause(ctx), ErrCancelled):
+			errorMsg = ErrCancelled.Error()
+			details[DetailCancelled] = true
+		case errors.Is(err, context.DeadlineExceeded):
+			details[DetailTimedOut] = true
+		}
+		log.Printf("[streaming] command not started (opID=%s): %s", operationID, errorMsg)
+		if op, ok := DefaultRegistry.finish(operationID, exitCode, errorMsg); ok {
+			addTiming(details, op)
+		}
+		if emitErr := sink.EmitComplete(operationID, exitCode, errorMsg, details); emitErr != nil {
+			fmt.Fprintf(os.Stderr, "[streaming] failed to emit complete: %v\n", emitErr)
+		}
+	}()
+	return operationID, nil
 }
 
-// RunCommand executes a command and streams its output via D-Bus signals.
-// Returns the operation ID immediately; the command runs asynchronously.
-// The Complete signal will be emitted when the command finishes.
-func RunCommand(ctx context.Context, emitter *Emitter, env []string, cmdPath string, args ...string) (string, error) {
-	operationID := GenerateOperationID()
+// child is a started command whose output has not been read yet.
+type child struct {
+	cmd            *exec.Cmd
+	stdout, stderr io.Reader // stderr is nil for a child on a terminal
+	pty            *os.File  // master side of the child's terminal, if any
+	stopCancel     func()
+	oom            *oomWatch
+}
 
+// startChild starts cmdPath with its output piped back to us. It is killed
+// when ctx is done.
+func startChild(ctx context.Context, env []string, cmdPath string, args []string) (*child, error) {
 	cmd := exec.CommandContext(ctx, cmdPath, args...)
 	cmd.Env = env
+	stopCancel := cancelGracefully(ctx, cmd)
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
-		return "", fmt.Errorf("failed to create stdout pipe: %w", err)
+		stopCancel()
+		return nil, fmt.Errorf("failed to create stdout pipe: %w", err)
 	}
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
-		return "", fmt.Errorf("failed to create stderr pipe: %w", err)
+		stopCancel()
+		return nil, fmt.Errorf("failed to create stderr pipe: %w", err)
 	}
 
+	oom := watchOOM(os.Getpid())
 	if err := cmd.Start(); err != nil {
-		return "", fmt.Errorf("failed to start command: %w", err)
+		stopCancel()
+		return nil, fmt.Errorf("failed to start command: %w", err)
 	}
+	oom.follow(cmd.Process.Pid)
+	return &child{cmd: cmd, stdout: stdout, stderr: stderr, stopCancel: stopCancel, oom: oom}, nil
+}
 
-	log.Printf("[streaming] started command: %s %v (opID=%s)", cmdPath, args, operationID)
+// stream forwards the output of c to sink until it exits, then finishes
+// the operation and emits Complete.
+func (c *child) stream(ctx context.Context, sink OutputSink, operationID string) {
+	var wg sync.WaitGroup
 
-	// Stream output in background
+	// Stream stdout
+	wg.Add(1)
 	go func() {
-		var wg sync.WaitGroup
-		wg.Add(2)
-
-		// Stream stdout
-		go func() {
-			defer wg.Done()
-			streamReader(emitter, operationID, stdout, false)
-		}()
+		defer wg.Done()
+		streamReader(sink, operationID, c.stdout, false)
+	}()
 
-		// Stream stderr
+	// Stream stderr
+	if c.stderr != nil {
+		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			streamReader(emitter, operationID, stderr, true)
+			streamReader(sink, operationID, c.stderr, true)
 		}()
+	}
 
-		wg.Wait()
-
-		// Wait for command to finish
-		err := cmd.Wait()
-		exitCode := 0
-		errorMsg := ""
-		if err != nil {
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				exitCode = exitErr.ExitCode()
-			} else {
-				exitCode = -1
-				errorMsg = err.Error()
-			}
-		}
-
-		log.Printf("[streaming] command finished (opID=%s, exitCode=%d)", operationID, exitCode)
-		if emitErr := emitter.EmitComplete(operationID, exitCode, errorMsg); emitErr != nil {
-			fmt.Fprintf(os.Stderr, "[streaming] failed to emit complete: %v\n", emitErr)
-		}
-	}()
+	wg.Wait()
+	emitErrorLog.Flush()
+	if c.pty != nil {
+		c.pty.Close()
+	}
+	c.oom.follow(c.cmd.Process.Pid)
 
-	return operationID, nil
-}
+	// Wait for command to finish
+	waitErr := c.cmd.Wait()
+	c.stopCancel()
+	exitCode, errorMsg, details := exitStatus(ctx, c.cmd, waitErr, c.oom)
 
-// streamReader reads from a reader line by line and emits output signals.
-func streamReader(emitter *Emitter, operationID string, r io.Reader, isStderr bool) {
-	scanner := bufio.NewScanner(r)
-	// Increase buffer size for long lines
-	buf := make([]byte, 0, 64*1024)
-	scanner.Buffer(buf, 1024*1024)
-	scanner.Split(scanLinesCR)
-
-	for scanner.Scan() {
-		line := scanner.Text() + "\n"
-		if err := emitter.EmitOutput(operationID, line, isStderr); err != nil {
-			// Log error but continue streaming
-			fmt.Fprintf(os.Stderr, "[streaming] failed to emit output: %v\n", err)
-		}
+	log.Printf("[streaming] command finished (opID=%s, exitCode=%d)", operationID, exitCode)
+	if op, ok := DefaultRegistry.finish(operationID, exitCode, errorMsg); ok {
+		addTiming(details, op)
+	}
+	if emitErr := sink.EmitComplete(operationID, exitCode, errorMsg, details); emitErr != nil {
+		fmt.Fprintf(os.Stderr, "[streaming] failed to emit complete: %v\n", emitErr)
 	}
-	// Ignore scanner errors - the process may have terminated
 }
 
-// scanLinesCR is like bufio.ScanLines but also treats carriage returns as line breaks.
-// Some commands (especially those with progress bars) use \r to overwrite the current line.
-// The default ScanLines only recognizes \n, so progress updates may not be captured.
-// This function treats each \r refresh as a separate line for real-time streaming.
-func scanLinesCR(data []byte, atEOF bool) (advance int, token []byte, err error) {
-	// Look for newline or carriage return.
-	for i, b := range data {
-		if b == '\n' || b == '\r' {
-			// We have a full line.
-			return i + 1, data[0:i], nil
+// streamReader reads from a reader line by line and forwards each line to sink.
+// Lines longer than maxChunkSize arrive as several chunks; see readChunks.
+func streamReader(sink OutputSink, operationID string, r io.Reader, isStderr bool) {
+	readChunks(r, maxChunkSize, func(data string) {
+		if err := sink.EmitOutput(operationID, data, isStderr); err != nil {
+			// Log error but continue streaming
+			emitErrorLog.Printf("[streaming] failed to emit output: %v", err)
 		}
-	}
-	// If at EOF, return any remaining data.
-	if atEOF && len(data) > 0 {
-		return len(data), data, nil
-	}
-	// Request more data.
-	return 0, nil, nil
+	})
 }
 
 // Receiver handles receiving streaming signals on the client side.
@@ -206,10 +246,26 @@ func NewReceiver(conn *dbus.Conn) (*Receiver, error) {
 	}, nil
 }
 
+// trailingOutputGrace bounds how long WaitForOperation keeps listening for
+// Output signals that the Complete signal says were sent but have not arrived.
+const trailingOutputGrace = 2 * time.Second
+
 // WaitForOperation waits for all output from a specific operation and returns
 // when the Complete signal is received. It calls outputFn for each output chunk.
 // Returns the exit code and error message from the Complete signal.
+//
+// If Complete reports a final sequence number beyond the Output signals seen
+// so far, the remaining chunks are awaited for a short grace period before
+// returning, so trailing output is not cut off.
 func (r *Receiver) WaitForOperation(operationID string, outputFn func(data string, isStderr bool)) (int, string) {
+	var (
+		received uint64
+		finalSeq uint64
+		done     bool
+		exitCode int
+		errorMsg string
+		grace    <-chan time.Time
+	)
 	for {
 		select {
 		case sig, ok := <-r.signalChan:
@@ -229,19 +285,33 @@ func (r *Receiver) WaitForOperation(operationID string, outputFn func(data strin
 					isStderr, ok3 := sig.Body[2].(bool)
 					if ok1 && ok2 && ok3 && opID == operationID {
 						outputFn(data, isStderr)
+						received++
 					}
 				}
 
 			case dbusconsts.Interface + "." + dbusconsts.SignalComplete:
 				if len(sig.Body) >= 3 {
 					opID, ok1 := sig.Body[0].(string)
-					exitCode, ok2 := sig.Body[1].(int32)
-					errorMsg, ok3 := sig.Body[2].(string)
+					code, ok2 := sig.Body[1].(int32)
+					msg, ok3 := sig.Body[2].(string)
 					if ok1 && ok2 && ok3 && opID == operationID {
-						return int(exitCode), errorMsg
+						done, exitCode, errorMsg = true, int(code), msg
+						finalSeq = received
+						if len(sig.Body) >= 4 {
+							if v, ok := sig.Body[3].(uint64); ok {
+								finalSeq = v
+							}
+						}
+						grace = time.After(trailingOutputGrace)
 					}
 				}
 			}
+			if done && received >= finalSeq {
+				return exitCode, errorMsg
+			}
+
+		case <-grace:
+			return exitCode, errorMsg
 
 		case <-r.stopChan:
 			return -1, "receiver stopped"
@@ -28,6 +28,27 @@ func TestGenerateOperationID(t *testing.T) {
 	}
 }
 
+func TestValidOperationID(t *testing.T) {
+	tests := []struct {
+		id   string
+		want bool
+	}{
+		{GenerateOperationID(), true},
+		{"op-0f8fad5b-d9cb-469f-a165-70867728950e", true},
+		{"op-1234-7", true}, // issued by older daemons
+		{"op-", false},
+		{"op-1234", false},
+		{"0f8fad5b-d9cb-469f-a165-70867728950e", false},
+		{"op-0F8FAD5B-D9CB-469F-A165-70867728950E", false},
+		{"op-1234-7\n", false},
+	}
+	for _, tt := range tests {
+		if got := ValidOperationID(tt.id); got != tt.want {
+			t.Errorf("ValidOperationID(%q) = %v, want %v", tt.id, got, tt.want)
+		}
+	}
+}
+
 func TestStreamReaderMultipleLines(t *testing.T) {
 	pr, pw, err := os.Pipe()
 	if err != nil {
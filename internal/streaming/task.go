@@ -0,0 +1,169 @@
+package streaming
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// TaskFunc is an in-process operation body. It reports progress through out,
+// which forwards to the operation's sink like child process output.
+type TaskFunc func(ctx context.Context, out func(data string, isStderr bool)) error
+
+// DetailedTaskFunc is a TaskFunc that also returns entries for the Complete
+// details dictionary.
+type DetailedTaskFunc func(ctx context.Context, out func(data string, isStderr bool)) (map[string]interface{}, error)
+
+// RunTask runs fn as a streamed operation without spawning a child process.
+// It returns the operation ID immediately; Complete is emitted with exit code
+// 0 when fn returns nil and 1 with the error message otherwise. fn should
+// return once ctx is done; if the operation was cancelled, Complete reports
+// it like a cancelled command.
+func RunTask(ctx context.Context, sink OutputSink, name string, fn TaskFunc) string {
+	return RunDetailedTask(ctx, sink, name, func(ctx context.Context, out func(string, bool)) (map[string]interface{}, error) {
+		return nil, fn(ctx, out)
+	})
+}
+
+// RunDetailedTask is like RunTask but merges the details returned by fn into
+// the Complete signal. If ctx carries a gate (see WithGate), fn is called
+// once the gate admits the operation. If fn returns an *ExitError, Complete
+// reports its exit code, message and details instead of exit code 1.
+func RunDetailedTask(ctx context.Context, sink OutputSink, name string, fn DetailedTaskFunc) string {
+	return runTask(ctx, sink, name, nil, fn)
+}
+
+// RunCommandTask is like RunDetailedTask for a task that mainly runs cmdPath
+// with args, possibly more than once, and records them as the operation's
+// program and arguments.
+func RunCommandTask(ctx context.Context, sink OutputSink, cmdPath string, args []string, fn DetailedTaskFunc) string {
+	return runTask(ctx, sink, cmdPath, args, fn)
+}
+
+func runTask(ctx context.Context, sink OutputSink, program string, args []string, fn DetailedTaskFunc) string {
+	operationID := GenerateOperationID()
+	ctx, cancel := context.WithCancelCause(ctx)
+
+	op := &Operation{
+		ID:        operationID,
+		Program:   program,
+		Args:      args,
+		State:     StateRunning,
+		StartTime: time.Now(),
+		Labels:    labelsFrom(ctx),
+		Timeout:   timeoutOf(ctx),
+		Queued:    gateFrom(ctx) != nil,
+		cancel:    cancel,
+	}
+	DefaultRegistry.add(op)
+	log.Printf("[streaming] started task: %s (opID=%s)", program, operationID)
+
+	go func() {
+		defer cancel(nil)
+		out := func(data string, isStderr bool) {
+			if err := sink.EmitOutput(operationID, data, isStderr); err != nil {
+				emitErrorLog.Printf("[streaming] failed to emit output: %v", err)
+			}
+		}
+
+		exitCode, errorMsg := 0, ""
+		var extra map[string]interface{}
+		runCtx, done, err := admit(ctx, operationID)
+		if err == nil {
+			extra, err = fn(runCtx, out)
+			done()
+		}
+		details := make(map[string]interface{}, len(extra)+4)
+		for k, v := range extra {
+			details[k] = v
+		}
+		exitErr, isExit := err.(*ExitError)
+		switch {
+		case err != nil && errors.Is(context.Cause(ctx), ErrCancelled):
+			exitCode, errorMsg = -1, ErrCancelled.Error()
+			details[DetailCancelled] = true
+		case isExit:
+			exitCode, errorMsg = exitErr.Code, exitErr.Msg
+			for k, v := range exitErr.Details {
+				details[k] = v
+			}
+		case err != nil:
+			exitCode, errorMsg = 1, err.Error()
+		}
+
+		emitErrorLog.Flush()
+		log.Printf("[streaming] task finished (opID=%s, exitCode=%d)", operationID, exitCode)
+		if op, ok := DefaultRegistry.finish(operationID, exitCode, errorMsg); ok {
+			addTiming(details, op)
+		}
+		if emitErr := sink.EmitComplete(operationID, exitCode, errorMsg, details); emitErr != nil {
+			fmt.Fprintf(os.Stderr, "[streaming] failed to emit complete: %v\n", emitErr)
+		}
+	}()
+
+	return operationID
+}
+
+// ExitError is how a child run by RunChild failed, as RunCommandStreaming
+// would report it in Complete.
+type ExitError struct {
+	Code    int
+	Msg     string // empty for a plain nonzero exit
+	Details map[string]interface{}
+}
+
+func (e *ExitError) Error() string {
+	if e.Msg != "" {
+		return e.Msg
+	}
+	return fmt.Sprintf("exited with code %d", e.Code)
+}
+
+// RunChild runs a child process inside a task, forwarding its output to out
+// line by line like RunCommandStreaming does. It returns nil when the child
+// exits with code 0, and otherwise an error wrapping an *ExitError.
+func RunChild(ctx context.Context, out func(data string, isStderr bool), env []string, cmdPath string, args ...string) error {
+	cmd := exec.CommandContext(ctx, cmdPath, args...)
+	cmd.Env = env
+	stopCancel := cancelGracefully(ctx, cmd)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stderr pipe: %w", err)
+	}
+	oom := watchOOM(os.Getpid())
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start command: %w", err)
+	}
+	oom.follow(cmd.Process.Pid)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		readChunks(stdout, maxChunkSize, func(data string) { out(data, false) })
+	}()
+	go func() {
+		defer wg.Done()
+		readChunks(stderr, maxChunkSize, func(data string) { out(data, true) })
+	}()
+	wg.Wait()
+	oom.follow(cmd.Process.Pid)
+
+	waitErr := cmd.Wait()
+	stopCancel()
+	exitCode, errorMsg, details := exitStatus(ctx, cmd, waitErr, oom)
+	if exitCode == 0 && errorMsg == "" {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", filepath.Base(cmdPath), &ExitError{Code: exitCode, Msg: errorMsg, Details: details})
+}
@@ -0,0 +1,173 @@
+// Package telemetry reports install results and user ratings to a store
+// endpoint. Nothing is sent unless the user has given explicit consent,
+// which is stored on disk, and an endpoint is configured.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// EnvURL names the endpoint events are POSTed to as JSON.
+const EnvURL = "LINYAPS_TELEMETRY_URL"
+
+// maxPayloads bounds the history of payloads kept for inspection.
+const maxPayloads = 50
+
+// ErrNoConsent is returned when submitting while the user has not opted in.
+var ErrNoConsent = errors.New("telemetry consent not given")
+
+// Event is the complete set of data sent for one report. It carries no
+// user, machine or network identifiers.
+type Event struct {
+	Kind     string    `json:"kind"` // "install" or "rating"
+	Ref      string    `json:"ref"`
+	Success  bool      `json:"success,omitempty"`
+	ExitCode int       `json:"exit_code,omitempty"`
+	Rating   int       `json:"rating,omitempty"` // 1-5
+	Version  string    `json:"manager_version"`
+	Time     time.Time `json:"time"`
+}
+
+// Payload records a report that was attempted, for transparency.
+type Payload struct {
+	Time   time.Time `json:"time"`
+	URL    string    `json:"url"`
+	Body   string    `json:"body"`
+	Status string    `json:"status"` // HTTP status or error
+}
+
+type config struct {
+	Consent bool      `json:"consent"`
+	Updated time.Time `json:"updated"`
+}
+
+// Reporter sends events when consent is given.
+type Reporter struct {
+	path    string
+	url     string
+	version string
+	client  *http.Client
+
+	mu       sync.Mutex
+	consent  bool
+	payloads []Payload
+}
+
+// DefaultConfigPath returns <user config dir>/linyaps-manager/telemetry.json.
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "linyaps-manager", "telemetry.json")
+}
+
+// NewReporter loads the stored consent from path. An empty url disables
+// sending even with consent.
+func NewReporter(path, url, version string) *Reporter {
+	r := &Reporter{path: path, url: url, version: version, client: &http.Client{Timeout: 15 * time.Second}}
+	if data, err := os.ReadFile(path); err == nil {
+		var c config
+		if err := json.Unmarshal(data, &c); err != nil {
+			log.Printf("[WARN] ignoring invalid telemetry config %s: %v", path, err)
+		} else {
+			r.consent = c.Consent
+		}
+	}
+	return r
+}
+
+// Consent reports whether the user has opted in.
+func (r *Reporter) Consent() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.consent
+}
+
+// SetConsent stores the user's choice.
+func (r *Reporter) SetConsent(consent bool) error {
+	if r.path == "" {
+		return errors.New("no config directory to store consent")
+	}
+	data, err := json.MarshalIndent(config{Consent: consent, Updated: time.Now().UTC()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.consent = consent
+	r.mu.Unlock()
+	return nil
+}
+
+// Payloads returns the most recent reports, oldest first.
+func (r *Reporter) Payloads() []Payload {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Payload(nil), r.payloads...)
+}
+
+// Submit sends ev in the background. It returns ErrNoConsent without
+// sending anything unless the user opted in.
+func (r *Reporter) Submit(ev Event) error {
+	if !r.Consent() {
+		return ErrNoConsent
+	}
+	if r.url == "" {
+		return fmt.Errorf("telemetry endpoint not configured (set %s)", EnvURL)
+	}
+	ev.Version = r.version
+	ev.Time = time.Now().UTC()
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	go r.send(body)
+	return nil
+}
+
+func (r *Reporter) send(body []byte) {
+	p := Payload{Time: time.Now().UTC(), URL: r.url, Body: string(body)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		var resp *http.Response
+		if resp, err = r.client.Do(req); err == nil {
+			resp.Body.Close()
+			p.Status = resp.Status
+		}
+	}
+	if err != nil {
+		p.Status = err.Error()
+	}
+
+	r.mu.Lock()
+	r.payloads = append(r.payloads, p)
+	if len(r.payloads) > maxPayloads {
+		r.payloads = r.payloads[1:]
+	}
+	r.mu.Unlock()
+}
@@ -0,0 +1,65 @@
+package telemetry
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNoConsentSendsNothing(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request sent without consent")
+	}))
+	defer srv.Close()
+
+	r := NewReporter(filepath.Join(t.TempDir(), "telemetry.json"), srv.URL, "1.0")
+	if err := r.Submit(Event{Kind: "install", Ref: "org.example.app"}); !errors.Is(err, ErrNoConsent) {
+		t.Fatalf("Submit err = %v, want ErrNoConsent", err)
+	}
+}
+
+func TestConsentPersistsAndSends(t *testing.T) {
+	got := make(chan Event, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var ev Event
+		json.Unmarshal(body, &ev)
+		got <- ev
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "sub", "telemetry.json")
+	if err := NewReporter(path, srv.URL, "1.0").SetConsent(true); err != nil {
+		t.Fatalf("SetConsent: %v", err)
+	}
+
+	r := NewReporter(path, srv.URL, "1.0")
+	if !r.Consent() {
+		t.Fatal("consent not persisted")
+	}
+	if err := r.Submit(Event{Kind: "rating", Ref: "org.example.app", Rating: 4}); err != nil {
+		t.Fatalf("Submit: %v", err)
+	}
+	select {
+	case ev := <-got:
+		if ev.Ref != "org.example.app" || ev.Rating != 4 || ev.Version != "1.0" {
+			t.Errorf("event = %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("event not sent")
+	}
+
+	// The payload becomes visible once the response is recorded
+	deadline := time.Now().Add(5 * time.Second)
+	for len(r.Payloads()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if p := r.Payloads(); len(p) != 1 || p[0].Status != "200 OK" {
+		t.Errorf("payloads = %+v", p)
+	}
+}
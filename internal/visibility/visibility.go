@@ -0,0 +1,165 @@
+// Package visibility stores which apps each user may see and start on a
+// shared machine. A user without a rule sees every app.
+package visibility
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Rule limits the apps visible to one user. With a non-empty Allow list
+// only matching apps are visible; apps matching Deny are never visible.
+// Patterns are app IDs, or prefixes ending in "*" such as "org.games.*".
+type Rule struct {
+	UID   uint32
+	Allow []string
+	Deny  []string
+}
+
+// IsZero reports whether the rule restricts nothing.
+func (r Rule) IsZero() bool {
+	return len(r.Allow) == 0 && len(r.Deny) == 0
+}
+
+// Visible reports whether appID passes the rule.
+func (r Rule) Visible(appID string) bool {
+	for _, p := range r.Deny {
+		if match(p, appID) {
+			return false
+		}
+	}
+	if len(r.Allow) == 0 {
+		return true
+	}
+	for _, p := range r.Allow {
+		if match(p, appID) {
+			return true
+		}
+	}
+	return false
+}
+
+func match(pattern, appID string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(appID, prefix)
+	}
+	return pattern == appID
+}
+
+var patternRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*\*?$|^\*$`)
+
+// ValidatePattern checks that p is an app ID or an app ID prefix followed by "*".
+func ValidatePattern(p string) error {
+	if !patternRe.MatchString(p) {
+		return fmt.Errorf("invalid app pattern %q", p)
+	}
+	return nil
+}
+
+// Store is the persisted policy.
+type Store struct {
+	path string
+
+	mu    sync.Mutex
+	rules map[uint32]Rule
+}
+
+// Open loads the policy stored at path; a missing file is an empty policy.
+func Open(path string) (*Store, error) {
+	s := &Store{path: path, rules: make(map[uint32]Rule)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var rules []Rule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	for _, r := range rules {
+		s.rules[r.UID] = r
+	}
+	return s, nil
+}
+
+// Empty reports whether no user has a rule, so checks can be skipped.
+func (s *Store) Empty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.rules) == 0
+}
+
+// Visible reports whether appID is visible to uid.
+func (s *Store) Visible(uid uint32, appID string) bool {
+	s.mu.Lock()
+	r, ok := s.rules[uid]
+	s.mu.Unlock()
+	return !ok || r.Visible(appID)
+}
+
+// Rules returns every rule, ordered by UID.
+func (s *Store) Rules() []Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rules := make([]Rule, 0, len(s.rules))
+	for _, r := range s.rules {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].UID < rules[j].UID })
+	return rules
+}
+
+// Set replaces the rule of r.UID; a zero rule removes it.
+func (s *Store) Set(r Rule) error {
+	for _, p := range append(append([]string{}, r.Allow...), r.Deny...) {
+		if err := ValidatePattern(p); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	old, had := s.rules[r.UID]
+	if r.IsZero() {
+		delete(s.rules, r.UID)
+	} else {
+		s.rules[r.UID] = r
+	}
+	if err := s.saveLocked(); err != nil {
+		if had {
+			s.rules[r.UID] = old
+		} else {
+			delete(s.rules, r.UID)
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *Store) saveLocked() error {
+	rules := make([]Rule, 0, len(s.rules))
+	for _, r := range s.rules {
+		rules = append(rules, r)
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].UID < rules[j].UID })
+	data, err := json.MarshalIndent(rules, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,57 @@
+package visibility
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRuleVisible(t *testing.T) {
+	r := Rule{Allow: []string{"org.school.*", "org.deepin.calculator"}, Deny: []string{"org.school.chat"}}
+	tests := map[string]bool{
+		"org.school.maths":      true,
+		"org.deepin.calculator": true,
+		"org.school.chat":       false,
+		"org.games.chess":       false,
+	}
+	for app, want := range tests {
+		if got := r.Visible(app); got != want {
+			t.Errorf("Visible(%q) = %v, want %v", app, got, want)
+		}
+	}
+
+	denyOnly := Rule{Deny: []string{"org.games.*"}}
+	if denyOnly.Visible("org.games.chess") || !denyOnly.Visible("org.school.maths") {
+		t.Error("deny-only rule")
+	}
+}
+
+func TestStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "visibility.json")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Empty() || !s.Visible(1000, "org.games.chess") {
+		t.Fatal("new store restricts")
+	}
+	if err := s.Set(Rule{UID: 1001, Deny: []string{"org.games.*"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Set(Rule{UID: 1001, Deny: []string{"../x"}}); err == nil {
+		t.Error("invalid pattern accepted")
+	}
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Visible(1001, "org.games.chess") || !s.Visible(1000, "org.games.chess") {
+		t.Errorf("rules after reopening: %+v", s.Rules())
+	}
+	if err := s.Set(Rule{UID: 1001}); err != nil {
+		t.Fatal(err)
+	}
+	if !s.Empty() {
+		t.Errorf("zero rule not removed: %+v", s.Rules())
+	}
+}